@@ -0,0 +1,191 @@
+package bench
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+/* ---------- 按置信区间自适应采样 ---------- */
+
+// 停止原因
+const (
+	StopCI          = "ci"           // 均值的 95% 置信区间已达到目标宽度
+	StopMaxDuration = "max-duration" // 达到时长上限
+	StopMaxSamples  = "max-samples"  // 达到样本数上限
+)
+
+// z95 正态分布 95% 双侧分位数
+const z95 = 1.959964
+
+// AdaptiveOptions 自适应采样参数，零值取默认
+type AdaptiveOptions struct {
+	// RelHalfWidth 目标相对半宽：95% 置信区间半宽 / 均值 ≤ 该值时停止。默认 0.02
+	RelHalfWidth float64
+	MinSamples   int           // 判断收敛前至少采集的样本数，默认 30
+	MaxSamples   int           // 样本数上限，0 表示不限
+	MaxDuration  time.Duration // 时长上限，默认 10s
+	Reservoir    int           // 用于分位数估计的蓄水池容量，默认 1024
+	Seed         int64         // 蓄水池抽样的随机种子
+	// BatchThreshold 同 Options.BatchThreshold
+	BatchThreshold time.Duration
+}
+
+func (o AdaptiveOptions) withDefaults() AdaptiveOptions {
+	if o.RelHalfWidth <= 0 {
+		o.RelHalfWidth = 0.02
+	}
+	if o.MinSamples <= 0 {
+		o.MinSamples = 30
+	}
+	if o.MaxDuration <= 0 {
+		o.MaxDuration = 10 * time.Second
+	}
+	if o.Reservoir <= 0 {
+		o.Reservoir = 1024
+	}
+	if o.BatchThreshold <= 0 {
+		o.BatchThreshold = 10 * time.Microsecond
+	}
+	return o
+}
+
+// Sampler 流式统计：Welford 算法维护均值与方差，不保存全部样本；
+// 另以蓄水池抽样（Algorithm R）保留至多 Reservoir 个样本用于分位数估计
+type Sampler struct {
+	opts      AdaptiveOptions
+	n         int
+	mean, m2  float64
+	reservoir []float64
+	rng       *rand.Rand
+}
+
+// NewSampler 创建采样器
+func NewSampler(opts AdaptiveOptions) *Sampler {
+	opts = opts.withDefaults()
+	return &Sampler{opts: opts, reservoir: make([]float64, 0, opts.Reservoir), rng: rand.New(rand.NewSource(opts.Seed))}
+}
+
+// Add 加入一个样本
+func (s *Sampler) Add(x float64) {
+	s.n++
+	d := x - s.mean
+	s.mean += d / float64(s.n)
+	s.m2 += d * (x - s.mean)
+	if len(s.reservoir) < cap(s.reservoir) {
+		s.reservoir = append(s.reservoir, x)
+	} else if j := s.rng.Intn(s.n); j < len(s.reservoir) {
+		s.reservoir[j] = x
+	}
+}
+
+// N 样本数
+func (s *Sampler) N() int { return s.n }
+
+// Mean 样本均值
+func (s *Sampler) Mean() float64 { return s.mean }
+
+// Variance 无偏样本方差
+func (s *Sampler) Variance() float64 {
+	if s.n < 2 {
+		return 0
+	}
+	return s.m2 / float64(s.n-1)
+}
+
+// CIHalfWidth 均值的 95% 置信区间半宽（正态近似）：1.96 * s / √n
+func (s *Sampler) CIHalfWidth() float64 {
+	if s.n < 2 {
+		return math.Inf(1)
+	}
+	return z95 * math.Sqrt(s.Variance()/float64(s.n))
+}
+
+// Converged 样本数不少于 MinSamples 且相对半宽不超过 RelHalfWidth
+func (s *Sampler) Converged() bool {
+	return s.n >= s.opts.MinSamples && s.CIHalfWidth() <= s.opts.RelHalfWidth*math.Abs(s.mean)
+}
+
+// Percentile 由蓄水池按最近秩法估计 p 分位数（0 ≤ p ≤ 1）
+func (s *Sampler) Percentile(p float64) float64 {
+	if len(s.reservoir) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), s.reservoir...)
+	sort.Float64s(sorted)
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// PercentileRankError 分位数估计的秩误差上界：由 DKW 不等式，以 95% 的概率，
+// 蓄水池（k 个样本）的经验分布函数与真实分布函数之差不超过 √(ln(2/0.05) / 2k)。
+// 即报告的 p 分位数实际落在真实分布的 [p-ε, p+ε] 分位之间。样本未超出蓄水池时 k 即全部样本
+func (s *Sampler) PercentileRankError() float64 {
+	if len(s.reservoir) == 0 {
+		return 1
+	}
+	return math.Sqrt(math.Log(2/0.05) / (2 * float64(len(s.reservoir))))
+}
+
+// AdaptiveTiming 自适应采样的结果，耗时均为扣除计时开销后的单次调用耗时
+type AdaptiveTiming struct {
+	Mean          time.Duration
+	CIHalfWidth   time.Duration // 均值的 95% 置信区间为 Mean ± CIHalfWidth
+	RelHalfWidth  float64       // CIHalfWidth / Mean
+	Samples       int
+	Batch         int
+	Calls         int
+	StopReason    string // StopCI / StopMaxDuration / StopMaxSamples
+	P50, P95, P99 time.Duration
+	RankError     float64 // 分位数的秩误差上界，见 Sampler.PercentileRankError
+	TimerOverhead time.Duration
+}
+
+// MeasureAdaptive 循环使用 inputs 采样，直到均值的置信区间达到目标宽度或触达上限。
+// 每个样本为 Batch 次连续调用的平均耗时，批大小的确定方式与 Measure 相同
+func MeasureAdaptive(fn MatchFunc, inputs []map[string]interface{}, opts AdaptiveOptions) AdaptiveTiming {
+	if len(inputs) == 0 {
+		return AdaptiveTiming{}
+	}
+	opts = opts.withDefaults()
+	t := AdaptiveTiming{TimerOverhead: timerOverhead(inputs[0]), Batch: chooseBatch(fn, inputs[0], opts.BatchThreshold)}
+	s := NewSampler(opts)
+	deadline := now().Add(opts.MaxDuration)
+	idx := 0
+	for {
+		start := now()
+		for k := 0; k < t.Batch; k++ {
+			fn(inputs[idx])
+			idx++
+			if idx == len(inputs) {
+				idx = 0
+			}
+		}
+		d := now().Sub(start) - t.TimerOverhead
+		s.Add(float64(max(d, 0)) / float64(t.Batch))
+
+		if s.Converged() {
+			t.StopReason = StopCI
+		} else if opts.MaxSamples > 0 && s.N() >= opts.MaxSamples {
+			t.StopReason = StopMaxSamples
+		} else if now().After(deadline) {
+			t.StopReason = StopMaxDuration
+		}
+		if t.StopReason != "" {
+			break
+		}
+	}
+	t.Samples = s.N()
+	t.Calls = t.Samples * t.Batch
+	t.Mean = time.Duration(s.Mean())
+	t.CIHalfWidth = time.Duration(s.CIHalfWidth())
+	if s.Mean() > 0 {
+		t.RelHalfWidth = s.CIHalfWidth() / s.Mean()
+	}
+	t.P50 = time.Duration(s.Percentile(0.50))
+	t.P95 = time.Duration(s.Percentile(0.95))
+	t.P99 = time.Duration(s.Percentile(0.99))
+	t.RankError = s.PercentileRankError()
+	return t
+}
@@ -0,0 +1,115 @@
+package bench
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// TestSamplerMoments Welford 的均值、方差与置信区间半宽与两遍扫描的直接计算一致
+func TestSamplerMoments(t *testing.T) {
+	s := NewSampler(AdaptiveOptions{})
+	if !math.IsInf(s.CIHalfWidth(), 1) || s.Variance() != 0 {
+		t.Fatal("样本不足两个时方差为 0、半宽为 +Inf")
+	}
+	for x := 1; x <= 10; x++ {
+		s.Add(float64(x))
+	}
+	// 1..10：均值 5.5，无偏方差 82.5 / 9，半宽 1.959964 × √(82.5 / 90)
+	if s.N() != 10 || s.Mean() != 5.5 || math.Abs(s.Variance()-82.5/9) > 1e-12 ||
+		math.Abs(s.CIHalfWidth()-1.959964*math.Sqrt(82.5/90)) > 1e-12 {
+		t.Fatalf("n %d, mean %v, var %v, half %v", s.N(), s.Mean(), s.Variance(), s.CIHalfWidth())
+	}
+
+	r := rand.New(rand.NewSource(1))
+	xs := make([]float64, 5000)
+	s = NewSampler(AdaptiveOptions{})
+	for i := range xs {
+		xs[i] = 1e6 + r.NormFloat64()*300 // 大均值小方差，朴素的 Σx² 算法在此会损失精度
+		s.Add(xs[i])
+	}
+	var sum, ss float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	variance := ss / float64(len(xs)-1)
+	half := z95 * math.Sqrt(variance/float64(len(xs)))
+	if math.Abs(s.Mean()-mean) > 1e-6 || math.Abs(s.Variance()/variance-1) > 1e-9 || math.Abs(s.CIHalfWidth()/half-1) > 1e-9 {
+		t.Fatalf("mean %v / %v, var %v / %v, half %v / %v", s.Mean(), mean, s.Variance(), variance, s.CIHalfWidth(), half)
+	}
+}
+
+// TestSamplerPercentiles 样本未超出蓄水池时分位数精确；超出后蓄水池容量固定，
+// 估计的分位在 DKW 误差界 ε = √(ln 40 / 2k) 之内
+func TestSamplerPercentiles(t *testing.T) {
+	s := NewSampler(AdaptiveOptions{})
+	if s.Percentile(0.5) != 0 || s.PercentileRankError() != 1 {
+		t.Fatal("空采样器")
+	}
+	for x := 10; x >= 1; x-- {
+		s.Add(float64(x))
+	}
+	if s.Percentile(0.5) != 5 || s.Percentile(0.95) != 10 || s.Percentile(0) != 1 {
+		t.Fatalf("P50 %v, P95 %v, P0 %v", s.Percentile(0.5), s.Percentile(0.95), s.Percentile(0))
+	}
+
+	const k, n = 200, 100000
+	s = NewSampler(AdaptiveOptions{Reservoir: k, Seed: 7})
+	for x := 0; x < n; x++ {
+		s.Add(float64(x))
+	}
+	eps := math.Sqrt(math.Log(40) / (2 * k))
+	if len(s.reservoir) != k || math.Abs(s.PercentileRankError()-eps) > 1e-12 {
+		t.Fatalf("蓄水池 %d 个样本，秩误差 %v，应为 %d 与 %v", len(s.reservoir), s.PercentileRankError(), k, eps)
+	}
+	for _, p := range []float64{0.5, 0.95, 0.99} {
+		if got := s.Percentile(p) / n; got < p-eps || got > p+eps {
+			t.Errorf("P%.0f 落在 %.4f 分位，超出 [%.4f, %.4f]", p*100, got, p-eps, p+eps)
+		}
+	}
+}
+
+// normalEngine 每次调用前进 mean ± sd 的正态耗时（固定种子），不低于 0
+func (c *fakeClock) normalEngine(mean, sd time.Duration, seed int64) MatchFunc {
+	r := rand.New(rand.NewSource(seed))
+	return func(map[string]interface{}) []string {
+		c.t = c.t.Add(max(time.Duration(float64(mean)+r.NormFloat64()*float64(sd)), 0))
+		return nil
+	}
+}
+
+// TestMeasureAdaptiveStops 均值 1µs、目标相对半宽 1%：标准差 10ns 时采满 MinSamples 即收敛，
+// 标准差 200ns 时约需 (1.96 × 200 / 10)² ≈ 1537 个样本；另覆盖样本数与时长上限
+func TestMeasureAdaptiveStops(t *testing.T) {
+	c := &fakeClock{t: time.Unix(0, 0), tick: 20 * time.Nanosecond}
+	useClock(t, c)
+	opts := AdaptiveOptions{RelHalfWidth: 0.01, MaxDuration: time.Hour, BatchThreshold: time.Nanosecond}
+	low := MeasureAdaptive(c.normalEngine(time.Microsecond, 10*time.Nanosecond, 1), inputsN(3), opts)
+	high := MeasureAdaptive(c.normalEngine(time.Microsecond, 200*time.Nanosecond, 1), inputsN(3), opts)
+	if low.StopReason != StopCI || high.StopReason != StopCI || low.Batch != 1 {
+		t.Fatalf("low %+v\nhigh %+v", low, high)
+	}
+	if low.Samples != 30 || high.Samples < 1300 || high.Samples > 1800 {
+		t.Fatalf("low 采样 %d 个，high 采样 %d 个", low.Samples, high.Samples)
+	}
+	for _, r := range []AdaptiveTiming{low, high} {
+		if r.RelHalfWidth > 0.01 || r.Mean < 950*time.Nanosecond || r.Mean > 1050*time.Nanosecond || r.P50 > r.P95 || r.P95 > r.P99 {
+			t.Fatalf("%+v", r)
+		}
+	}
+
+	opts.MaxSamples = 100
+	if r := MeasureAdaptive(c.normalEngine(time.Microsecond, 200*time.Nanosecond, 2), inputsN(3), opts); r.StopReason != StopMaxSamples || r.Samples != 100 {
+		t.Fatalf("样本数上限: %+v", r)
+	}
+	opts.MaxSamples, opts.MaxDuration = 0, 100*time.Microsecond
+	// 每个样本约 1µs 调用加 2 次 20ns 的读时钟与 1 次判断截止时间的读数
+	if r := MeasureAdaptive(c.normalEngine(time.Microsecond, 200*time.Nanosecond, 3), inputsN(3), opts); r.StopReason != StopMaxDuration || r.Samples < 80 || r.Samples > 110 {
+		t.Fatalf("时长上限: %+v", r)
+	}
+}
@@ -0,0 +1,48 @@
+package bench
+
+import "runtime"
+
+/* ---------- 内存分配 ---------- */
+
+// Allocs 每次调用的平均堆分配，取自 runtime.MemStats 的累计计数，不受期间 GC 影响
+type Allocs struct {
+	Calls         int
+	BytesPerCall  float64
+	AllocsPerCall float64
+}
+
+// MeasureAllocs 循环使用 inputs 共调用 fn calls 次（calls <= 0 时每条输入一次），统计平均分配。
+// 与计时分开运行，ReadMemStats 本身的停顿不会计入耗时
+func MeasureAllocs(fn MatchFunc, inputs []map[string]interface{}, calls int) Allocs {
+	if len(inputs) == 0 {
+		return Allocs{}
+	}
+	if calls <= 0 {
+		calls = len(inputs)
+	}
+	fn(inputs[0]) // 预热，排除首次调用的惰性初始化
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < calls; i++ {
+		fn(inputs[i%len(inputs)])
+	}
+	runtime.ReadMemStats(&after)
+	return Allocs{
+		Calls:         calls,
+		BytesPerCall:  float64(after.TotalAlloc-before.TotalAlloc) / float64(calls),
+		AllocsPerCall: float64(after.Mallocs-before.Mallocs) / float64(calls),
+	}
+}
+
+// RetainedHeap 返回 build 执行后仍存活的堆内存增量（字节）。前后各强制一次 GC，
+// 只统计 build 构建并由调用方继续持有的对象，如加入引擎的已编译规则；临时分配不计入
+func RetainedHeap(build func()) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
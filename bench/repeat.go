@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"math"
+	"time"
+)
+
+/* ---------- 预热与重复运行 ---------- */
+
+// RepeatOptions 预热与重复参数，零值取默认
+type RepeatOptions struct {
+	Warmup int // 不计时地完整跑 inputs 的遍数，默认 1；小于 0 表示不预热
+	Repeat int // 计时运行次数，默认 1
+	// BatchThreshold 同 Options.BatchThreshold
+	BatchThreshold time.Duration
+}
+
+func (o RepeatOptions) withDefaults() RepeatOptions {
+	if o.Warmup == 0 {
+		o.Warmup = 1
+	} else if o.Warmup < 0 {
+		o.Warmup = 0
+	}
+	if o.Repeat <= 0 {
+		o.Repeat = 1
+	}
+	return o
+}
+
+// Repeated 多次计时运行的结果。每次运行都是一次完整的 Measure，
+// Mean 与 StdDev 针对各次运行的 PerCall 计算，反映运行之间的波动而非单次调用的离散程度
+type Repeated struct {
+	Warmup    int
+	Runs      []Timing
+	Mean      time.Duration // 各次 PerCall 的均值
+	StdDev    time.Duration // 各次 PerCall 的样本标准差，只有一次运行时为 0
+	RelStdDev float64       // 变异系数 StdDev / Mean
+}
+
+// PerRun 各次运行的 PerCall
+func (r Repeated) PerRun() []time.Duration {
+	out := make([]time.Duration, len(r.Runs))
+	for i, t := range r.Runs {
+		out[i] = t.PerCall
+	}
+	return out
+}
+
+// MeasureRepeated 先对 inputs 不计时地调用 fn Warmup 遍，排除冷缓存与 VM 惰性初始化，
+// 再用 Measure 计时 Repeat 遍
+func MeasureRepeated(fn MatchFunc, inputs []map[string]interface{}, opts RepeatOptions) Repeated {
+	opts = opts.withDefaults()
+	r := Repeated{Warmup: opts.Warmup}
+	if len(inputs) == 0 {
+		return r
+	}
+	for w := 0; w < opts.Warmup; w++ {
+		for _, in := range inputs {
+			fn(in)
+		}
+	}
+	r.Runs = make([]Timing, opts.Repeat)
+	var sum float64
+	for i := range r.Runs {
+		r.Runs[i] = Measure(fn, inputs, Options{BatchThreshold: opts.BatchThreshold})
+		sum += float64(r.Runs[i].PerCall)
+	}
+	mean := sum / float64(len(r.Runs))
+	r.Mean = time.Duration(mean)
+	if len(r.Runs) > 1 {
+		var sq float64
+		for _, t := range r.Runs {
+			d := float64(t.PerCall) - mean
+			sq += d * d
+		}
+		r.StdDev = time.Duration(math.Sqrt(sq / float64(len(r.Runs)-1)))
+	}
+	if mean > 0 {
+		r.RelStdDev = float64(r.StdDev) / mean
+	}
+	return r
+}
@@ -0,0 +1,107 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+/* ---------- 机器可读的结果 ---------- */
+
+// ResultSchemaVersion WriteResultsJSON 输出格式的版本；字段只增不改，改动含义或删除字段时递增
+const ResultSchemaVersion = 1
+
+// BenchmarkResult 一个引擎一次基准的结果。耗时均为纳秒整数，便于跨语言解析与画图；
+// 未测量的分配字段为 0
+type BenchmarkResult struct {
+	Timestamp     time.Time `json:"timestamp"` // 基准开始时间（UTC）
+	Seed          int64     `json:"seed"`      // 生成规则与输入所用的随机种子
+	Ruleset       string    `json:"ruleset"`
+	Engine        string    `json:"engine"`
+	Rules         int       `json:"rules"`
+	Inputs        int       `json:"inputs"`
+	MeanNs        int64     `json:"mean_ns"`
+	MinNs         int64     `json:"min_ns"`
+	P50Ns         int64     `json:"p50_ns"`
+	P90Ns         int64     `json:"p90_ns"`
+	P99Ns         int64     `json:"p99_ns"`
+	MaxNs         int64     `json:"max_ns"`
+	StdDevNs      int64     `json:"stddev_ns"`
+	BytesPerCall  float64   `json:"bytes_per_call"`
+	AllocsPerCall float64   `json:"allocs_per_call"`
+	Shape         string    `json:"shape,omitempty"`      // 按规则形态分组计时时的形态（如 flat / nested），整体结果为空
+	Complexity    string    `json:"complexity,omitempty"` // 按规则复杂度分组计时时的叶子数分档（如 4-5），整体结果为空
+	// EvaluatedPerInput 平均每条输入实际执行的规则数，只有能统计的引擎（expr）填写
+	EvaluatedPerInput float64 `json:"evaluated_per_input,omitempty"`
+}
+
+// SetStats 用逐次耗时分布填充分位数字段
+func (r *BenchmarkResult) SetStats(st Stats) {
+	r.MinNs, r.P50Ns, r.P90Ns, r.P99Ns, r.MaxNs = st.Min.Nanoseconds(), st.P50.Nanoseconds(),
+		st.P90.Nanoseconds(), st.P99.Nanoseconds(), st.Max.Nanoseconds()
+	r.StdDevNs = st.StdDev.Nanoseconds()
+}
+
+// SetAllocs 填充分配字段
+func (r *BenchmarkResult) SetAllocs(a Allocs) {
+	r.BytesPerCall, r.AllocsPerCall = a.BytesPerCall, a.AllocsPerCall
+}
+
+// resultsDoc WriteResultsJSON 的顶层结构
+type resultsDoc struct {
+	SchemaVersion int               `json:"schema_version"`
+	Results       []BenchmarkResult `json:"results"`
+}
+
+// WriteResultsJSON 以 {"schema_version": N, "results": [...]} 写出 results
+func WriteResultsJSON(w io.Writer, results []BenchmarkResult) error {
+	if results == nil {
+		results = []BenchmarkResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resultsDoc{SchemaVersion: ResultSchemaVersion, Results: results})
+}
+
+// resultColumns CSV 表头，与 JSON 字段名一致
+var resultColumns = []string{
+	"timestamp", "seed", "ruleset", "engine", "rules", "inputs",
+	"mean_ns", "min_ns", "p50_ns", "p90_ns", "p99_ns", "max_ns", "stddev_ns",
+	"bytes_per_call", "allocs_per_call", "shape", "complexity", "evaluated_per_input",
+}
+
+// WriteResultsCSV 写出表头加每个结果一行，列名与 JSON 字段名相同；时间戳为 RFC 3339
+func WriteResultsCSV(w io.Writer, results []BenchmarkResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(resultColumns); err != nil {
+		return err
+	}
+	i64 := func(n int64) string { return strconv.FormatInt(n, 10) }
+	f64 := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, r := range results {
+		rec := []string{
+			r.Timestamp.Format(time.RFC3339Nano), i64(r.Seed), r.Ruleset, r.Engine, strconv.Itoa(r.Rules), strconv.Itoa(r.Inputs),
+			i64(r.MeanNs), i64(r.MinNs), i64(r.P50Ns), i64(r.P90Ns), i64(r.P99Ns), i64(r.MaxNs), i64(r.StdDevNs),
+			f64(r.BytesPerCall), f64(r.AllocsPerCall), r.Shape, r.Complexity, f64(r.EvaluatedPerInput),
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteResults 按 format（"json" / "csv"）写出 results
+func WriteResults(w io.Writer, format string, results []BenchmarkResult) error {
+	switch format {
+	case "json":
+		return WriteResultsJSON(w, results)
+	case "csv":
+		return WriteResultsCSV(w, results)
+	}
+	return fmt.Errorf("未知输出格式 %q，可选 json / csv", format)
+}
@@ -0,0 +1,126 @@
+package bench
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+/* ---------- 逐次耗时分布 ---------- */
+
+// Stats 单次调用耗时的分布，均已扣除计时开销（下限为 0）
+type Stats struct {
+	Calls                   int
+	Min, P50, P90, P99, Max time.Duration
+	Mean, StdDev            time.Duration
+	TimerOverhead           time.Duration
+}
+
+// MeasureEach 循环使用 inputs 共调用 fn calls 次（calls <= 0 时每条输入一次），每次调用单独计时。
+// 不做分批，因此单次耗时接近时钟分辨率时分位数会被量化；耗时切片预先分配，计时循环内不分配内存
+func MeasureEach(fn MatchFunc, inputs []map[string]interface{}, calls int) Stats {
+	if len(inputs) == 0 {
+		return Stats{}
+	}
+	if calls <= 0 {
+		calls = len(inputs)
+	}
+	overhead := timerOverhead(inputs[0])
+	samples := make([]time.Duration, calls)
+	idx := 0
+	for i := range samples {
+		start := time.Now()
+		fn(inputs[idx])
+		samples[i] = time.Since(start)
+		idx++
+		if idx == len(inputs) {
+			idx = 0
+		}
+	}
+	for i, d := range samples {
+		samples[i] = max(d-overhead, 0)
+	}
+	return summarize(samples, overhead)
+}
+
+// summarize 对 samples 排序（原地）并计算分布
+func summarize(samples []time.Duration, overhead time.Duration) Stats {
+	slices.Sort(samples)
+	st := Stats{Calls: len(samples), TimerOverhead: overhead}
+	st.Min, st.Max = samples[0], samples[len(samples)-1]
+	st.P50, st.P90, st.P99 = nearestRank(samples, 0.50), nearestRank(samples, 0.90), nearestRank(samples, 0.99)
+	var sum float64
+	for _, d := range samples {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(samples))
+	var sq float64
+	for _, d := range samples {
+		sq += (float64(d) - mean) * (float64(d) - mean)
+	}
+	st.Mean = time.Duration(mean)
+	if len(samples) > 1 {
+		st.StdDev = time.Duration(math.Sqrt(sq / float64(len(samples)-1)))
+	}
+	return st
+}
+
+// nearestRank 已排序样本的 p 分位数（最近秩法）
+func nearestRank(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+/* ---------- 并发吞吐 ---------- */
+
+// Concurrent 多个 goroutine 同时调用时的吞吐与逐次耗时
+type Concurrent struct {
+	Goroutines int
+	Elapsed    time.Duration // 从全部 goroutine 开始到最后一个结束的墙钟时间
+	Throughput float64       // 每秒完成的调用数 = Latency.Calls / Elapsed
+	Latency    Stats         // 全部 goroutine 的逐次耗时合并后的分布
+}
+
+// MeasureConcurrent 用 goroutines 个 goroutine 同时调用 fn：第 w 个处理下标 w, w+goroutines, ... 的输入，
+// 共 calls 次（calls <= 0 时每条输入一次）。各 goroutine 的耗时切片预先分配，结束后合并统计
+func MeasureConcurrent(fn MatchFunc, inputs []map[string]interface{}, goroutines, calls int) Concurrent {
+	goroutines = max(goroutines, 1)
+	if len(inputs) == 0 {
+		return Concurrent{Goroutines: goroutines}
+	}
+	if calls <= 0 {
+		calls = len(inputs)
+	}
+	overhead := timerOverhead(inputs[0])
+	samples := make([]time.Duration, calls)
+	var ready, done sync.WaitGroup
+	start := make(chan struct{})
+	for w := 0; w < goroutines; w++ {
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			ready.Done()
+			<-start
+			for i := w; i < calls; i += goroutines {
+				t := time.Now()
+				fn(inputs[i%len(inputs)])
+				samples[i] = time.Since(t)
+			}
+		}()
+	}
+	ready.Wait() // goroutine 启动时间不计入
+	wall := time.Now()
+	close(start)
+	done.Wait()
+	c := Concurrent{Goroutines: goroutines, Elapsed: time.Since(wall)}
+	if c.Elapsed > 0 {
+		c.Throughput = float64(calls) / c.Elapsed.Seconds()
+	}
+	for i, d := range samples {
+		samples[i] = max(d-overhead, 0)
+	}
+	c.Latency = summarize(samples, overhead)
+	return c
+}
@@ -0,0 +1,112 @@
+package bench
+
+import (
+	"sort"
+	"time"
+)
+
+/* ---------- 精确计时 ---------- */
+
+// now 计时使用的单调时钟，测试中替换为可控的假时钟
+var now = time.Now
+
+// MatchFunc 被测的单次匹配调用
+type MatchFunc func(input map[string]interface{}) []string
+
+// Options 计时参数
+type Options struct {
+	// BatchThreshold 单次调用低于该耗时时，每个样本连续计时 K 次调用以摊薄时钟分辨率误差。默认 10µs
+	BatchThreshold time.Duration
+}
+
+// Timing 只统计引擎调用本身的耗时。
+//
+// 计算方式：每个样本 s_i 是连续 Batch 次调用外层的一对单调时钟读数之差，
+// 扣除一次计时开销 TimerOverhead 后（下限为 0）累加，
+// PerCall = Σ max(s_i - TimerOverhead, 0) / (len(Samples) * Batch)。
+// 时钟分辨率为 r 时，单次调用的量化误差不超过 r / Batch。
+type Timing struct {
+	Samples         []time.Duration // 每个样本 Batch 次调用的原始耗时
+	Batch           int             // 每个样本包含的调用次数 K
+	Calls           int             // 总调用次数 = len(Samples) * Batch
+	PerCall         time.Duration   // 扣除计时开销后的单次调用平均耗时
+	TimerOverhead   time.Duration   // 一对时钟读数加一次空调用的开销（每个样本扣除一次）
+	HarnessOverhead time.Duration   // 每个样本在计时区间之外的循环开销：(墙钟总时长 - Σ样本) / 样本数
+}
+
+// Measure 依次对 inputs 调用 fn，只在调用两侧取时间戳
+func Measure(fn MatchFunc, inputs []map[string]interface{}, opts Options) Timing {
+	if len(inputs) == 0 {
+		return Timing{}
+	}
+	if opts.BatchThreshold <= 0 {
+		opts.BatchThreshold = 10 * time.Microsecond
+	}
+	t := Timing{TimerOverhead: timerOverhead(inputs[0]), Batch: chooseBatch(fn, inputs[0], opts.BatchThreshold)}
+
+	n := (len(inputs) + t.Batch - 1) / t.Batch
+	t.Samples = make([]time.Duration, n)
+	t.Calls = n * t.Batch
+	var sum time.Duration
+	idx := 0
+	wall := now()
+	for s := 0; s < n; s++ {
+		start := now()
+		for k := 0; k < t.Batch; k++ {
+			fn(inputs[idx])
+			idx++
+			if idx == len(inputs) {
+				idx = 0
+			}
+		}
+		d := now().Sub(start)
+		t.Samples[s] = d
+		sum += d
+	}
+	elapsed := now().Sub(wall)
+
+	var net time.Duration
+	for _, d := range t.Samples {
+		if d > t.TimerOverhead {
+			net += d - t.TimerOverhead
+		}
+	}
+	t.PerCall = net / time.Duration(t.Calls)
+	t.HarnessOverhead = (elapsed - sum) / time.Duration(n)
+	return t
+}
+
+// chooseBatch 用 input 试跑几次估计单次耗时：低于 threshold 时返回摊薄所需的批大小，否则为 1
+func chooseBatch(fn MatchFunc, input map[string]interface{}, threshold time.Duration) int {
+	probe := make([]time.Duration, 5)
+	for i := range probe {
+		start := now()
+		fn(input)
+		probe[i] = now().Sub(start)
+	}
+	sort.Slice(probe, func(i, j int) bool { return probe[i] < probe[j] })
+	median := probe[len(probe)/2]
+	if median >= threshold {
+		return 1
+	}
+	if median <= 0 {
+		median = 1
+	}
+	return int((threshold + median - 1) / median)
+}
+
+// noop 与被测调用签名相同的空函数，用于估计计时开销
+func noop(map[string]interface{}) []string { return nil }
+
+// timerOverhead 取 1000 次"时钟读数 + 空调用"的中位数
+func timerOverhead(input map[string]interface{}) time.Duration {
+	var fn MatchFunc = noop
+	samples := make([]time.Duration, 1000)
+	for i := range samples {
+		start := now()
+		fn(input)
+		samples[i] = now().Sub(start)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2]
+}
@@ -0,0 +1,93 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 每次读数后前进 tick（模拟读时钟本身的开销），stub 引擎每次调用前进 cost
+type fakeClock struct {
+	t    time.Time
+	tick time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	t := c.t
+	c.t = c.t.Add(c.tick)
+	return t
+}
+
+func (c *fakeClock) engine(cost time.Duration) MatchFunc {
+	return func(map[string]interface{}) []string {
+		c.t = c.t.Add(cost)
+		return nil
+	}
+}
+
+func useClock(t *testing.T, c *fakeClock) {
+	t.Helper()
+	orig := now
+	now = c.now
+	t.Cleanup(func() { now = orig })
+}
+
+func inputsN(n int) []map[string]interface{} {
+	return make([]map[string]interface{}, n)
+}
+
+// TestMeasureBatchingMath 固定耗时 100ns、读时钟 20ns：
+// 批大小 = ⌈10µs / 120ns⌉ = 84，每个样本 84×100ns + 20ns，扣除 20ns 计时开销后单次恰为 100ns
+func TestMeasureBatchingMath(t *testing.T) {
+	c := &fakeClock{t: time.Unix(0, 0), tick: 20 * time.Nanosecond}
+	useClock(t, c)
+	const batch, samples = 84, 10
+	timing := Measure(c.engine(100*time.Nanosecond), inputsN(batch*samples), Options{})
+
+	if timing.TimerOverhead != 20*time.Nanosecond {
+		t.Errorf("TimerOverhead = %v，应为 20ns", timing.TimerOverhead)
+	}
+	if timing.Batch != batch || len(timing.Samples) != samples || timing.Calls != batch*samples {
+		t.Fatalf("Batch = %d，样本 %d 个，Calls = %d", timing.Batch, len(timing.Samples), timing.Calls)
+	}
+	for i, s := range timing.Samples {
+		if s != batch*100*time.Nanosecond+20*time.Nanosecond {
+			t.Fatalf("样本 %d = %v", i, s)
+		}
+	}
+	if timing.PerCall != 100*time.Nanosecond {
+		t.Errorf("PerCall = %v，应为 100ns", timing.PerCall)
+	}
+	// 计时区间之外：开头一次墙钟读数（摊到每个样本 2ns）加每个样本结束读数之后的 20ns
+	if timing.HarnessOverhead != 22*time.Nanosecond {
+		t.Errorf("HarnessOverhead = %v，应为 22ns", timing.HarnessOverhead)
+	}
+}
+
+// TestMeasureNoBatchForSlowCalls 单次调用不低于阈值时不批量，输入不足一批时循环使用
+func TestMeasureNoBatchForSlowCalls(t *testing.T) {
+	c := &fakeClock{t: time.Unix(0, 0), tick: 20 * time.Nanosecond}
+	useClock(t, c)
+	timing := Measure(c.engine(50*time.Microsecond), inputsN(3), Options{})
+	if timing.Batch != 1 || timing.Calls != 3 || timing.PerCall != 50*time.Microsecond {
+		t.Fatalf("%+v", timing)
+	}
+
+	// 自定义阈值：⌈1µs / 120ns⌉ = 9，5 条输入凑成 1 个样本，循环使用输入
+	timing = Measure(c.engine(100*time.Nanosecond), inputsN(5), Options{BatchThreshold: time.Microsecond})
+	if timing.Batch != 9 || timing.Calls != 9 || len(timing.Samples) != 1 || timing.PerCall != 100*time.Nanosecond {
+		t.Fatalf("%+v", timing)
+	}
+}
+
+// TestMeasureClampsBelowOverhead 比计时开销还短的样本按 0 计入，PerCall 不会为负
+func TestMeasureClampsBelowOverhead(t *testing.T) {
+	c := &fakeClock{t: time.Unix(0, 0), tick: 20 * time.Nanosecond}
+	useClock(t, c)
+	timing := Measure(c.engine(0), inputsN(100), Options{})
+	if timing.PerCall != 0 {
+		t.Fatalf("PerCall = %v，应为 0", timing.PerCall)
+	}
+	if got := Measure(c.engine(0), nil, Options{}); got.Calls != 0 || got.Samples != nil {
+		t.Fatalf("空输入: %+v", got)
+	}
+}
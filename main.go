@@ -1,22 +1,893 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"goexprtester/bench"
+	"goexprtester/rule_cel"
+	"goexprtester/rule_engine"
 	"goexprtester/rule_expr"
+	"goexprtester/rule_govaluate"
+	"goexprtester/rule_gval"
+	"goexprtester/rule_naive"
+	"goexprtester/rule_pack"
+	"goexprtester/rule_server"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	soakFlag       = flag.Duration("soak", 0, "以混合负载持续运行指定时长并检测内存/协程增长，0 表示不启用")
+	soakInterval   = flag.Duration("soak-interval", 5*time.Second, "soak 模式采样间隔")
+	soakReportPath = flag.String("soak-report", "soak_report.json", "soak 模式时间序列输出文件")
+	soakHeapSlope  = flag.Float64("soak-max-heap-slope", 256<<10, "soak 模式允许的 HeapAlloc 增长斜率 (bytes/s)")
+	probeFlag      = flag.String("probe", "", "对给定表达式做边界输入探测并打印结果表")
+	dedupFlag      = flag.String("dedup", "off", "重复输入处理方式: off / drop / weighted")
+	checkOrderFlag = flag.Int("check-order", 0, "用 N 个洗牌种子重跑输入，检查命中是否依赖规则评估顺序")
+	fmtFlag        = flag.String("fmt", "", "把规则文本文件就地改写为规范形式")
+	impactFlag     = flag.String("impact", "", "评估删除指定规则的影响，需配合 -rules 与 -replay")
+	impactTagFlag  = flag.String("impact-tag", "", "-impact 只把带该标签的规则视为同一组")
+	rulesFlag      = flag.String("rules", "", "规则文本文件（-fmt 格式）")
+	replayFlag     = flag.String("replay", "", "重放输入文件，每行一个 JSON 对象")
+	optSummaryFlag = flag.Bool("opt-summary", false, "报告编译期优化器对全部规则的效果")
+	replFlag       = flag.Bool("repl", false, "交互式求值：设置因子值、求值表达式、匹配规则文件")
+	engineFlag     = flag.String("engine", "expr", "-repl 与 -serve 默认使用的引擎: expr / govaluate")
+	rewriteFlag    = flag.String("rewrite", "", "按映射文件改名/改类型 -rules 中的因子并写回")
+	dryRunFlag     = flag.Bool("dry-run", false, "-rewrite 只打印差异，不写回")
+	orchestrate    = flag.String("orchestrate", "", "逗号分隔的引擎列表，每个引擎在独立子进程中跑基准后汇总对比")
+	workerFlag     = flag.Bool("worker", false, "内部使用：作为 -orchestrate 的子进程运行")
+	targetCIFlag   = flag.Float64("target-ci", 0, "大于 0 时改为自适应采样，直到均值 95% 置信区间的相对半宽不超过该值")
+	warmupFlag     = flag.Int("warmup", 1, "计时前不计时地跑完整输入的遍数")
+	repeatFlag     = flag.Int("repeat", 1, "计时运行次数，大于 1 时打印每次的均值与运行间标准差")
+	maxBenchFlag   = flag.Duration("max-bench", 10*time.Second, "-target-ci 模式的采样时长上限")
+	rulesetFlag    = flag.String("ruleset", "random", "基准规则集: random（10k 条随机规则）/ realistic（欺诈风控规则包）")
+	parallelFlag   = flag.Bool("parallel", false, "额外以 1/2/4/8 个 worker 运行 MatchParallel，打印加速比")
+	firstFlag      = flag.Bool("first", false, "额外对 MatchFirst 计时，与 Match 对比")
+	fraudRateFlag  = flag.Float64("fraud-rate", 0.05, "-ruleset realistic 时注入欺诈模式的事件比例")
+	concurrentFlag = flag.Bool("concurrent", false, "额外以 1/4/GOMAXPROCS 个 goroutine 并发 Match 各引擎，打印吞吐与耗时分位数")
+	outFlag        = flag.String("out", "", "把各引擎的基准结果写到该文件，供 CI 画图")
+	formatFlag     = flag.String("format", "json", "-out 的格式: json / csv")
+	memFlag        = flag.Bool("mem", false, "额外报告各引擎规则集的常驻堆内存与每次匹配的堆分配")
+	verifyFlag     = flag.Int("verify", 0, "生成 N 条随机规则，在全部引擎上用同一批输入交叉校验命中，不一致时以非零状态退出")
+	factorsFlag    = flag.String("factors", "", "-ruleset random 使用的因子池文件（.json / .yaml），默认内置因子池")
+	seedFlag       = flag.Int64("seed", 0, "生成规则与输入的随机种子，0 表示随机选取；实际使用的种子会打印出来")
+	shapesFlag     = flag.Bool("shapes", false, "-ruleset random 时按规则形态（flat 只访问顶层因子 / nested 访问嵌套因子）分组，额外对比各引擎每条规则的耗时")
+	complexityFlag = flag.Bool("complexity", false, "-ruleset random 时按规则叶子数分档分组，额外对比各引擎每条规则的耗时")
+	minFactorsFlag = flag.Int("min-factors", 0, "-ruleset random 每条规则的最少叶子数，0 表示 1")
+	maxFactorsFlag = flag.Int("max-factors", 0, "-ruleset random 每条规则的最多叶子数，0 表示 5")
+	maxDepthFlag   = flag.Int("max-depth", 0, "-ruleset random 规则 and / or 的最大嵌套层数，0 表示不限")
+	repeatFactors  = flag.Bool("repeat-factors", false, "-ruleset random 允许同一规则多次引用同一因子，叶子数可超过因子池大小")
+	distinctFlag   = flag.Bool("distinct-rules", false, "-ruleset random 跳过与之前规则相同的随机规则，直到得到足够多条互不相同的规则")
+	compileCache   = flag.Bool("compile-cache", false, "expr 引擎让表达式文本相同的规则共享编译结果")
+	compileBench   = flag.Bool("compile-bench", false, "对比 expr / govaluate 在有无编译缓存时 10k 次 AddRule（100 个不同表达式）的耗时后退出")
+	verboseFlag    = flag.Bool("v", false, "-ruleset random 注入随机规则时每条规则输出一行编译结果")
+	typedFlag      = flag.Bool("typed", false, "额外对比 expr-typed：按因子池生成结构体作为 expr.Env 编译规则，输入转换为结构体后匹配")
+	sharedFlag     = flag.Bool("shared", false, "额外对比 expr-shared：同一规则集上每个原子条件每条输入只执行一次的 MatchShared")
+	indexFlag      = flag.Bool("index", false, "expr 引擎启用等值索引，跳过顶层等值约束与输入不符的规则")
+	zipfFlag       = flag.Float64("zipf", 0, "大于 0 时 -ruleset random 按因子池顺序以类 Zipf 分布（第 i 个因子权重 1/i^s）选取因子，取代文件中的权重")
+	serveFlag      = flag.String("serve", "", "以 HTTP 服务方式运行并监听指定地址（如 :8080），请求可用 ?engine= 选择引擎")
+	serveValidate  = flag.Bool("serve-validate", false, "-serve 时 expr 引擎的 POST /match 先按因子池校验输入，不符时返回 422")
+	serveRedact    = flag.Bool("serve-redact", false, "-serve 时错误响应与 /debug/recent 中的输入值经脱敏（字符串取哈希、整数分桶）")
+	serveRecent    = flag.Int("serve-recent", 0, "-serve 时 expr 引擎保留最近 N 次匹配（含输入）供 GET /debug/recent 查看，0 表示不记录")
 )
 
 func main() {
-	engine := rule_expr.NewRuleEngine()
+	flag.Parse()
+
+	if *workerFlag {
+		os.Exit(runWorker())
+	}
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if *orchestrate != "" {
+		fmt.Println("随机种子:", seed)
+		var configs []WorkerConfig
+		for _, name := range strings.Split(*orchestrate, ",") {
+			configs = append(configs, WorkerConfig{Engine: strings.TrimSpace(name), Rules: 10000, Inputs: 100, Seed: seed})
+		}
+		printComparison(Orchestrate(configs))
+		return
+	}
+	if *soakFlag > 0 {
+		os.Exit(runSoak())
+	}
+	if *probeFlag != "" {
+		os.Exit(runProbe(*probeFlag))
+	}
+	if *fmtFlag != "" {
+		os.Exit(runFmt(*fmtFlag))
+	}
+	if *replFlag {
+		st, err := os.Stdin.Stat()
+		interactive := err == nil && st.Mode()&os.ModeCharDevice != 0
+		os.Exit(runRepl(os.Stdin, os.Stdout, *engineFlag, interactive))
+	}
+	if *verifyFlag > 0 {
+		os.Exit(runVerify(*verifyFlag, seed))
+	}
+	if *compileBench {
+		os.Exit(runCompileBench(seed))
+	}
+	if *serveFlag != "" {
+		os.Exit(runServe(*serveFlag, *engineFlag, rule_server.Options{Validate: *serveValidate, Redact: *serveRedact, Recent: *serveRecent}))
+	}
+	if *rewriteFlag != "" {
+		os.Exit(runRewrite(*rewriteFlag, *rulesFlag, *dryRunFlag, os.Stdout))
+	}
+	if *impactFlag != "" {
+		os.Exit(runImpact(*impactFlag))
+	}
+
+	if *outFlag != "" && *formatFlag != "json" && *formatFlag != "csv" {
+		fmt.Println("未知输出格式:", *formatFlag)
+		os.Exit(2)
+	}
+
+	fmt.Printf("随机种子: %d（-seed %d 可复现同一批规则与输入）\n", seed, seed)
+	started := time.Now().UTC()
+	var engine *rule_expr.RuleEngine
+	var compared []benchBackend
+	var inputs []map[string]interface{}
+	var events []rule_pack.Event
+	footprint := make(map[string]int64) // 引擎名 -> 规则集常驻堆内存，仅 -mem 时记录
+	load := func(b benchBackend, fn func() error) {
+		var err error
+		if *memFlag {
+			footprint[b.name] = bench.RetainedHeap(func() { err = fn() })
+		} else {
+			err = fn()
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	var pool *rule_engine.FactorPool // -ruleset random 使用的因子池
+	switch *rulesetFlag {
+	case "random":
+		pool = rule_engine.DefaultFactorPool()
+		if *factorsFlag != "" {
+			var err error
+			if pool, err = rule_engine.LoadFactorPool(*factorsFlag); err != nil {
+				fmt.Println("读取因子池失败:", err)
+				os.Exit(2)
+			}
+		}
+		if *zipfFlag > 0 {
+			pool = pool.WithZipfWeights(*zipfFlag)
+		}
+		if err := genOptions().Validate(); err != nil {
+			fmt.Println("随机规则参数无效:", err)
+			os.Exit(2)
+		}
+		engine = newExprEngine(pool)
+		compared = withTyped(withShared(benchBackends(engine, pool.Factors), pool), pool)
+
+		// 1. 每个引擎各注入 10k 条随机规则；默认只输出每个引擎一行汇总，-v 时逐条输出
+		for _, b := range compared {
+			opts := genOptions()
+			opts.Logger = log.New(os.Stdout, "["+b.name+"] ", 0)
+			opts.Verbose = *verboseFlag
+			load(b, func() error {
+				return rule_engine.InjectRandomRulesFrom(b.engine, b.syntax, pool, randomRuleCount, seed, opts)
+			})
+		}
+		printComplexity(rule_engine.RandomTreesFrom(pool, randomRuleCount, seed, genOptions()))
+
+		// 2. 生成随机输入，各引擎共用
+		inputs = rule_engine.GenRandomInputsFrom(pool, 100, inputSeed(seed))
+	case "realistic":
+		engine = newExprEngine(rule_pack.FactorPool())
+		compared = withTyped(withShared(benchBackends(engine, rule_pack.FactorPool().Factors), rule_pack.FactorPool()), rule_pack.FactorPool())
+		// 规则包只使用各引擎的公共语法子集
+		for _, b := range compared {
+			load(b, func() error { return rule_pack.Load(b.engine.AddRule) })
+		}
+		// 规则包只有约 200 条规则，用更多事件让每种欺诈模式都有足够样本
+		events = rule_pack.GenEvents(seed, 2000, *fraudRateFlag)
+		inputs = rule_pack.Inputs(events)
+	default:
+		fmt.Println("未知规则集:", *rulesetFlag)
+		os.Exit(2)
+	}
+
+	for _, b := range compared {
+		if s, ok := b.engine.(rule_expr.Shared); ok {
+			fmt.Printf("[%s] %d 条规则共用 %d 个原子条件\n", b.name, s.RuleCount(), s.SharedPredicates())
+		}
+	}
+
+	// 3. Benchmark：同一批输入依次跑每个引擎
+	averages := make([]string, 0, len(compared))
+	results := make([]bench.BenchmarkResult, 0, len(compared))
+	for _, b := range compared {
+		in := rule_engine.AdaptInputs(b.syntax, inputs) // 不支持 time.Time 的后端改用 Unix 秒
+		var avg time.Duration
+		if *targetCIFlag > 0 {
+			t := rule_engine.BenchmarkMatchAdaptive(b.engine, in, bench.AdaptiveOptions{
+				RelHalfWidth: *targetCIFlag,
+				MaxDuration:  *maxBenchFlag,
+			})
+			avg = t.Mean
+			fmt.Printf("[%s] 平均每条数据匹配耗时: %s ± %s (95%% CI, 相对 %.2f%%), %d 个样本, 停止原因 %s\n",
+				b.name, t.Mean, t.CIHalfWidth, t.RelHalfWidth*100, t.Samples, t.StopReason)
+			fmt.Printf("[%s] P50 %s, P95 %s, P99 %s (分位秩误差 ±%.3f)\n", b.name, t.P50, t.P95, t.P99, t.RankError)
+		} else {
+			rep := rule_engine.BenchmarkMatchRepeated(b.engine, in, repeatOptions())
+			timing := rep.Runs[len(rep.Runs)-1]
+			avg = rep.Mean
+			fmt.Printf("[%s] 平均每条数据匹配耗时: %s (%d ns)\n", b.name, avg, avg.Nanoseconds())
+			if len(rep.Runs) > 1 {
+				fmt.Printf("[%s] 预热 %d 遍, %d 次运行: %s, 运行间标准差 %s (%.2f%%)\n",
+					b.name, rep.Warmup, len(rep.Runs), formatDurations(rep.PerRun()), rep.StdDev, rep.RelStdDev*100)
+			}
+			fmt.Printf("[%s] 计时开销 %s/样本, 循环开销 %s/样本, 每样本 %d 次调用\n",
+				b.name, timing.TimerOverhead, timing.HarnessOverhead, timing.Batch)
+		}
+		averages = append(averages, fmt.Sprintf("%s %s", b.name, avg))
+
+		st := rule_engine.BenchmarkMatchStats(b.engine, in, max(len(inputs), 1000))
+		fmt.Printf("[%s] 逐次耗时 (%d 次): min %s, p50 %s, p90 %s, p99 %s, max %s, 均值 %s, 标准差 %s\n",
+			b.name, st.Calls, st.Min, st.P50, st.P90, st.P99, st.Max, st.Mean, st.StdDev)
+		res := bench.BenchmarkResult{
+			Timestamp: started, Seed: seed, Ruleset: *rulesetFlag, Engine: b.name,
+			Rules: b.engine.RuleCount(), Inputs: len(inputs), MeanNs: avg.Nanoseconds(),
+		}
+		res.SetStats(st)
+		if x, ok := b.engine.(*rule_expr.RuleEngine); ok {
+			es := x.EvalStats()
+			res.EvaluatedPerInput = es.PerMatch()
+			fmt.Printf("[%s] 每条输入平均执行 %.1f / %d 条规则（等值索引登记 %d 条，逐条执行 %d 条）\n",
+				b.name, es.PerMatch(), b.engine.RuleCount(), es.Indexed, es.Fallback)
+		}
+		if x, ok := b.engine.(*rule_expr.TypedEngine); ok {
+			if d, err := rule_expr.BenchmarkMatchTyped(x, in, repeatOptions()); err != nil {
+				fmt.Printf("[%s] 转换输入失败: %v\n", b.name, err)
+			} else {
+				fmt.Printf("[%s] 预先转换输入后平均每条 %s（上面的耗时含 map 到结构体的转换）\n", b.name, d)
+			}
+		}
+		if *memFlag || *outFlag != "" {
+			res.SetAllocs(rule_engine.BenchmarkMatchAllocs(b.engine, in, len(inputs)))
+		}
+		results = append(results, res)
+	}
+	fmt.Println("平均耗时对比:", strings.Join(averages, " | "))
+	if *memFlag {
+		mem := make([]string, 0, len(compared))
+		for i, b := range compared {
+			a := results[i]
+			mem = append(mem, fmt.Sprintf("%s 规则集 %s, 每次匹配 %s / %.1f 次分配",
+				b.name, formatBytes(footprint[b.name]), formatBytes(int64(a.BytesPerCall)), a.AllocsPerCall))
+		}
+		fmt.Println("内存对比:", strings.Join(mem, " | "))
+	}
+	if *shapesFlag || *complexityFlag {
+		if pool == nil {
+			fmt.Println("-shapes / -complexity 只支持 -ruleset random")
+		} else {
+			trees := rule_engine.RandomTreesFrom(pool, randomRuleCount, seed, genOptions())
+			if *shapesFlag {
+				results = append(results, benchGroups(pool, trees, seed, inputs, started, "形态",
+					[]string{rule_engine.ShapeFlat, rule_engine.ShapeNested},
+					func(t *rule_engine.Tree) string { return t.Shape() },
+					func(r *bench.BenchmarkResult, g string) { r.Shape = g })...)
+			}
+			if *complexityFlag {
+				results = append(results, benchGroups(pool, trees, seed, inputs, started, "复杂度",
+					rule_engine.ComplexityBuckets(),
+					func(t *rule_engine.Tree) string { return t.Complexity().Bucket() },
+					func(r *bench.BenchmarkResult, g string) { r.Complexity = g })...)
+			}
+		}
+	}
+	if *outFlag != "" {
+		if err := writeResults(*outFlag, *formatFlag, results); err != nil {
+			fmt.Println("写出基准结果失败:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("基准结果已写入 %s (%s)\n", *outFlag, *formatFlag)
+	}
+
+	if *concurrentFlag {
+		counts := slices.Compact(slices.Sorted(slices.Values([]int{1, 4, runtime.GOMAXPROCS(0)})))
+		for _, b := range compared {
+			in := rule_engine.AdaptInputs(b.syntax, inputs)
+			for _, n := range counts {
+				c := rule_engine.BenchmarkMatchConcurrent(b.engine, in, n)
+				fmt.Printf("[%s] 并发 %d: 吞吐 %.0f 次/秒, p50 %s, p90 %s, p99 %s, max %s\n",
+					b.name, n, c.Throughput, c.Latency.P50, c.Latency.P90, c.Latency.P99, c.Latency.Max)
+			}
+		}
+	}
+
+	if *firstFlag {
+		first := rule_expr.BenchmarkMatchFirst(engine, inputs).PerCall
+		full := rule_expr.BenchmarkMatchPrecise(engine, inputs).PerCall
+		fmt.Printf("MatchFirst 每条 %s, Match 每条 %s", first, full)
+		if first > 0 {
+			fmt.Printf(", 快 %.1fx", float64(full)/float64(first))
+		}
+		fmt.Println()
+	}
+
+	if *parallelFlag {
+		for _, p := range rule_expr.BenchmarkMatchParallel(engine, inputs, []int{1, 2, 4, 8}) {
+			fmt.Printf("MatchParallel workers=%d: 每条 %s, 加速比 %.2fx\n", p.Workers, p.Timing.PerCall, p.Speedup)
+		}
+	}
+
+	if events != nil {
+		printDetection(rule_pack.Detect(events, engine.Match))
+	}
 
-	// 1. 注入 10k 条随机规则
-	if err := rule_expr.InjectRandomRules(engine, 10000); err != nil {
+	if *optSummaryFlag {
+		sum := engine.OptimizationSummary()
+		fmt.Printf("优化器: %d 条规则中 %d 条字节码缩减, 指令 %d -> %d, AST 节点 %d -> %d, 平均求值 %s -> %s\n",
+			sum.Rules, sum.Reduced, sum.OpsPlain, sum.OpsOptimized, sum.NodesPlain, sum.NodesOptimized,
+			sum.EvalPlain, sum.EvalOptimized)
+	}
+
+	if *checkOrderFlag > 0 {
+		seeds := make([]int64, *checkOrderFlag)
+		for i := range seeds {
+			seeds[i] = int64(i + 1)
+		}
+		if v := rule_expr.CheckOrderIndependence(engine, inputs, seeds); len(v) > 0 {
+			for _, o := range v {
+				fmt.Printf("顺序依赖: 输入 #%d 种子 %d, 默认 %v, 洗牌后 %v\n", o.InputIndex, o.Seed, o.Baseline, o.Shuffled)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("评估顺序检查通过 (%d 个种子)\n", len(seeds))
+	}
+
+	if mode, ok := dedupModes[*dedupFlag]; ok && mode != rule_expr.DedupOff {
+		rep := rule_expr.BenchmarkMatchDedup(engine, inputs, mode)
+		fmt.Printf("去重模式 %s: %d/%d 条唯一 (重复率 %.1f%%), 唯一输入平均 %s, 每条输入平均 %s\n",
+			rep.Mode, rep.Unique, rep.Total, rep.DedupRatio*100, rep.MeanUnique, rep.MeanPerInput)
+	}
+}
+
+// benchBackend 参与基准对比的一个引擎及其随机规则写法
+type benchBackend struct {
+	name   string
+	engine rule_engine.Engine
+	syntax rule_engine.Syntax
+}
+
+// benchBackends 返回参与对比的全部引擎，expr 使用传入的 exprEngine（其余命令行选项只作用于它），
+// 其余引擎新建；需要声明变量的引擎（cel）按 factors 声明。新增后端时在这里登记
+func benchBackends(exprEngine *rule_expr.RuleEngine, factors []rule_engine.Factor) []benchBackend {
+	celEngine, err := rule_cel.NewRuleEngine(factors)
+	if err != nil {
+		panic(err)
+	}
+	return []benchBackend{
+		{"expr", exprEngine, rule_expr.Syntax},
+		{"govaluate", &rule_govaluate.RuleEngine{}, rule_govaluate.Syntax},
+		{"cel", celEngine, rule_cel.Syntax},
+		{"gval", &rule_gval.RuleEngine{}, rule_gval.Syntax},
+		{"naive", &rule_naive.RuleEngine{}, rule_naive.Syntax},
+	}
+}
+
+// withShared -shared 时追加 expr-shared：另建一个启用共享原子条件的 expr 引擎，加载同样的规则，以 MatchShared 匹配
+func withShared(compared []benchBackend, pool *rule_engine.FactorPool) []benchBackend {
+	if !*sharedFlag {
+		return compared
+	}
+	shared := rule_expr.Shared{RuleEngine: newExprEngine(pool, rule_expr.WithSharedPredicates())}
+	return append(compared, benchBackend{"expr-shared", shared, rule_expr.Syntax})
+}
+
+// withTyped -typed 时追加 expr-typed：以 pool 生成结构体环境的 TypedEngine，Match 时先把 map 输入转换为结构体
+func withTyped(compared []benchBackend, pool *rule_engine.FactorPool) []benchBackend {
+	if !*typedFlag {
+		return compared
+	}
+	typed, err := rule_expr.NewTypedEngine(pool.Factors)
+	if err != nil {
 		panic(err)
 	}
+	return append(compared, benchBackend{"expr-typed", typed, rule_expr.Syntax})
+}
+
+// randomRuleCount -ruleset random 每个引擎注入的规则数
+const randomRuleCount = 10000
+
+// repeatOptions 由 -warmup / -repeat 构造计时参数
+func repeatOptions() bench.RepeatOptions {
+	warmup := *warmupFlag
+	if warmup == 0 {
+		warmup = -1 // RepeatOptions 的 0 表示默认值
+	}
+	return bench.RepeatOptions{Warmup: warmup, Repeat: *repeatFlag}
+}
+
+// genOptions 由 -min-factors / -max-factors / -max-depth / -repeat-factors / -distinct-rules 构造随机规则参数
+func genOptions() rule_engine.GenOptions {
+	return rule_engine.GenOptions{
+		MinFactors:    *minFactorsFlag,
+		MaxFactors:    *maxFactorsFlag,
+		MaxDepth:      *maxDepthFlag,
+		RepeatFactors: *repeatFactors,
+		Distinct:      *distinctFlag,
+	}
+}
+
+// newExprEngine 新建静态检查使用 pool 的 expr 引擎，-compile-cache / -index 时启用编译缓存 / 等值索引，
+// extra 为额外选项
+func newExprEngine(pool *rule_engine.FactorPool, extra ...rule_expr.EngineOption) *rule_expr.RuleEngine {
+	opts := append([]rule_expr.EngineOption{rule_expr.WithFactorPool(pool)}, extra...)
+	if *compileCache {
+		opts = append(opts, rule_expr.WithCompileCache())
+	}
+	if *indexFlag {
+		opts = append(opts, rule_expr.WithEqualityIndex())
+	}
+	return rule_expr.NewRuleEngine(opts...)
+}
+
+// printComplexity 打印随机规则的复杂度分布：各叶子数分档的规则数，以及平均叶子数、节点数与最大深度
+func printComplexity(trees []*rule_engine.Tree) {
+	counts := make(map[string]int)
+	var factors, nodes, depth int
+	for _, t := range trees {
+		c := t.Complexity()
+		counts[c.Bucket()]++
+		factors += c.Factors
+		nodes += c.Nodes
+		depth = max(depth, c.Depth)
+	}
+	var parts []string
+	for _, b := range rule_engine.ComplexityBuckets() {
+		if counts[b] > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", b, counts[b]))
+		}
+	}
+	n := float64(max(len(trees), 1))
+	fmt.Printf("规则复杂度 (叶子数分档): %s | 平均 %.1f 个叶子, %.1f 个节点, 最大深度 %d\n",
+		strings.Join(parts, ", "), float64(factors)/n, float64(nodes)/n, depth)
+}
+
+// benchGroups 把与基准相同的随机规则 trees 按 key 分组（组按 order 排列，label 为分组名称），
+// 每组加载到各引擎的新实例中单独计时，set 把组名写入结果。各组规则数不同，以每条规则的平均耗时对比
+func benchGroups(pool *rule_engine.FactorPool, trees []*rule_engine.Tree, seed int64, inputs []map[string]interface{}, started time.Time,
+	label string, order []string, key func(*rule_engine.Tree) string, set func(*bench.BenchmarkResult, string)) []bench.BenchmarkResult {
+	groups := make(map[string][]int) // 组名 -> 规则下标
+	for i, t := range trees {
+		groups[key(t)] = append(groups[key(t)], i)
+	}
+	var results []bench.BenchmarkResult
+	perRule := make(map[string]map[string]float64) // 引擎 -> 组名 -> 每条规则纳秒
+	var names, present []string
+	for _, group := range order {
+		ids := groups[group]
+		if len(ids) == 0 {
+			fmt.Printf("%s %s: 没有规则\n", label, group)
+			continue
+		}
+		present = append(present, group)
+		for _, b := range benchBackends(newExprEngine(pool), pool.Factors) {
+			for _, i := range ids {
+				if err := b.engine.AddRule(fmt.Sprintf("auto-%d", i+1), trees[i].Render(b.syntax)); err != nil {
+					panic(err)
+				}
+			}
+			avg := rule_engine.BenchmarkMatchRepeated(b.engine, rule_engine.AdaptInputs(b.syntax, inputs), repeatOptions()).Mean
+			per := float64(avg.Nanoseconds()) / float64(len(ids))
+			fmt.Printf("[%s] %s %s: %d 条规则, 每条数据 %s, 每条规则 %.1f ns\n", b.name, label, group, len(ids), avg, per)
+			if perRule[b.name] == nil {
+				perRule[b.name] = make(map[string]float64)
+				names = append(names, b.name)
+			}
+			perRule[b.name][group] = per
+			res := bench.BenchmarkResult{
+				Timestamp: started, Seed: seed, Ruleset: *rulesetFlag, Engine: b.name,
+				Rules: len(ids), Inputs: len(inputs), MeanNs: avg.Nanoseconds(),
+			}
+			set(&res, group)
+			results = append(results, res)
+		}
+	}
+	// 每个引擎列出各组每条规则的耗时，并给出最后一组相对第一组的倍数
+	summary := make([]string, 0, len(names))
+	for _, name := range names {
+		parts := make([]string, len(present))
+		for i, group := range present {
+			parts[i] = fmt.Sprintf("%s %.1f ns", group, perRule[name][group])
+		}
+		s := name + " " + strings.Join(parts, " / ")
+		if first, last := perRule[name][present[0]], perRule[name][present[len(present)-1]]; len(present) > 1 && first > 0 {
+			s += fmt.Sprintf(" (%.2fx)", last/first)
+		}
+		summary = append(summary, s)
+	}
+	fmt.Printf("%s对比 (每条规则): %s\n", label, strings.Join(summary, " | "))
+	return results
+}
 
-	// 2. 生成 20k 条随机输入
-	inputs := rule_expr.GenRandomInputs(100)
+// inputSeed 由规则种子派生输入种子，避免规则与输入取自同一随机序列
+func inputSeed(seed int64) int64 {
+	return seed + 1
+}
 
-	// 3. Benchmark
-	avg := rule_expr.BenchmarkMatch(engine, inputs)
-	fmt.Printf("平均每条数据匹配耗时: %s (%d ns)\n", avg, avg.Nanoseconds())
+// runVerify 在全部引擎上交叉校验 n 条随机规则，打印不一致的输入（最多 maxShown 个）
+func runVerify(n int, seed int64) int {
+	const maxShown = 10
+	var targets []rule_engine.Target
+	for _, b := range benchBackends(rule_expr.NewRuleEngine(), rule_engine.Factors) {
+		targets = append(targets, rule_engine.Target{Name: b.name, Engine: b.engine, Syntax: b.syntax})
+	}
+	rep, err := rule_engine.Verify(targets, seed, n, rule_engine.GenRandomInputsSeeded(1000, inputSeed(seed)))
+	if err != nil {
+		fmt.Println("校验失败:", err)
+		return 1
+	}
+	fmt.Printf("种子 %d: %d 条规则 x %d 条输入，引擎 %s\n", rep.Seed, rep.Rules, rep.Inputs, strings.Join(rep.Targets, ", "))
+	if len(rep.Mismatches) == 0 {
+		fmt.Println("全部引擎命中一致")
+		return 0
+	}
+	fmt.Printf("%d 条输入的命中不一致\n", len(rep.Mismatches))
+	for _, m := range rep.Mismatches[:min(len(rep.Mismatches), maxShown)] {
+		raw, _ := json.Marshal(m.Input)
+		fmt.Printf("输入 #%d %s\n", m.InputIndex, raw)
+		for _, d := range m.Rules {
+			fmt.Printf("  规则 %s 仅被 %s 命中\n", d.ID, strings.Join(d.HitBy, ", "))
+			for i, name := range rep.Targets {
+				fmt.Printf("    %-10s %s\n", name, d.Exprs[i])
+			}
+		}
+	}
+	return 1
+}
+
+// runCompileBench 以 100 个互不相同的随机表达式轮流加入 10k 条规则（模板化规则集的形态），
+// 分别计时 expr 与 govaluate 不启用和启用编译缓存时的 AddRule，打印耗时与缓存统计
+func runCompileBench(seed int64) int {
+	const distinct, count = 100, 10000
+	fmt.Println("随机种子:", seed)
+	trees := rule_engine.RandomTreesFrom(rule_engine.DefaultFactorPool(), distinct, seed, rule_engine.GenOptions{Distinct: true})
+	render := func(syntax rule_engine.Syntax) []string {
+		exprs := make([]string, len(trees))
+		for i, t := range trees {
+			exprs[i] = t.Render(syntax)
+		}
+		return exprs
+	}
+	type variant struct {
+		name   string
+		engine rule_engine.Engine
+		stats  func() rule_engine.CacheStats
+	}
+	plainExpr, cachedExpr := rule_expr.NewRuleEngine(), rule_expr.NewRuleEngine(rule_expr.WithCompileCache())
+	cachedGovaluate := &rule_govaluate.RuleEngine{CompileCache: rule_engine.NewCompileCache(0)}
+	backends := []struct {
+		name     string
+		syntax   rule_engine.Syntax
+		variants []variant
+	}{
+		{"expr", rule_expr.Syntax, []variant{
+			{"无缓存", plainExpr, nil},
+			{"编译缓存", cachedExpr, cachedExpr.CompileCacheStats},
+		}},
+		{"govaluate", rule_govaluate.Syntax, []variant{
+			{"无缓存", &rule_govaluate.RuleEngine{}, nil},
+			{"编译缓存", cachedGovaluate, cachedGovaluate.CompileCacheStats},
+		}},
+	}
+	for _, b := range backends {
+		exprs := render(b.syntax)
+		var base time.Duration
+		for _, v := range b.variants {
+			d, err := rule_engine.BenchmarkAddRule(v.engine, exprs, count)
+			if err != nil {
+				fmt.Printf("[%s] %s: %v\n", b.name, v.name, err)
+				return 1
+			}
+			line := fmt.Sprintf("[%s] %s: %d 次 AddRule (%d 个不同表达式) 用时 %s, 每次 %s",
+				b.name, v.name, count, len(exprs), d, d/count)
+			if base == 0 {
+				base = d
+			} else if d > 0 {
+				line += fmt.Sprintf(" (%.1fx)", float64(base)/float64(d))
+			}
+			if v.stats != nil {
+				s := v.stats()
+				line += fmt.Sprintf(" | 命中 %d, 未命中 %d, 命中率 %.1f%%, 缓存 %d/%d 条",
+					s.Hits, s.Misses, s.HitRate()*100, s.Entries, s.Capacity)
+			}
+			fmt.Println(line)
+		}
+	}
+	return 0
+}
+
+// formatBytes 以 B / KiB / MiB 显示字节数
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 || n <= -1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10 || n <= -1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// writeResults 把基准结果按 format 写到 path
+func writeResults(path, format string, results []bench.BenchmarkResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := bench.WriteResults(f, format, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// formatDurations 以 " / " 连接各次耗时
+func formatDurations(ds []time.Duration) string {
+	parts := make([]string, len(ds))
+	for i, d := range ds {
+		parts[i] = d.String()
+	}
+	return strings.Join(parts, " / ")
+}
+
+// printDetection 打印规则包在注入事件上的检出情况
+func printDetection(rep rule_pack.DetectionReport) {
+	fmt.Printf("事件 %d 条，其中正常 %d 条；%d 条正常事件命中通用策略规则\n", rep.Events, rep.Benign, rep.PolicyHit)
+	for _, p := range rep.Patterns {
+		fmt.Printf("%-18s 注入 %4d 检出 %4d (%.1f%%) 误报 %d\n",
+			p.Pattern, p.Injected, p.Caught, p.Recall()*100, p.FalseAlarms)
+	}
+	fmt.Printf("%d 条规则未命中任何事件\n", len(rep.NeverHit))
+}
+
+var dedupModes = map[string]rule_expr.DedupMode{
+	"off":      rule_expr.DedupOff,
+	"drop":     rule_expr.DedupDrop,
+	"weighted": rule_expr.DedupWeighted,
+}
+
+func runSoak() int {
+	report, err := RunSoak(SoakConfig{
+		Duration:      *soakFlag,
+		Interval:      *soakInterval,
+		Rules:         1000,
+		Matchers:      4,
+		Mutators:      1,
+		WarmupRatio:   0.2,
+		MaxHeapSlope:  *soakHeapSlope,
+		MaxGorouSlope: 0.01,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := report.WriteJSON(*soakReportPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("soak 完成: %d 个样本, HeapAlloc 斜率 %.0f bytes/s, goroutine 斜率 %.3f 个/s\n",
+		len(report.Samples), report.HeapSlope, report.GorouSlope)
+	if report.Leaking {
+		fmt.Println("检测到增长:", report.Reason)
+		return 1
+	}
+	if report.ReplaceErr != "" {
+		fmt.Println("整体替换规则集失败:", report.ReplaceErr)
+		return 1
+	}
+	return 0
+}
+
+func runProbe(exprStr string) int {
+	report := rule_expr.ProbeRule(exprStr, rule_expr.DefaultFactorPool())
+	if report.CompileErr != "" {
+		fmt.Println("编译失败:", report.CompileErr)
+		return 1
+	}
+	for _, w := range report.Warnings {
+		fmt.Println("警告:", w)
+	}
+	for _, p := range report.Probes {
+		if p.Err != "" {
+			fmt.Printf("%-32s ERROR  %s\n", p.Label, p.Err)
+		} else {
+			fmt.Printf("%-32s %v\n", p.Label, p.Result)
+		}
+	}
+	fmt.Printf("共 %d 个探测，%d 个执行出错\n", len(report.Probes), report.Errors)
+	if report.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runFmt 就地改写规则文本文件；内容已是规范形式时不写盘
+func runFmt(path string) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("读取失败:", err)
+		return 1
+	}
+	var out bytes.Buffer
+	if err := rule_expr.FormatText(bytes.NewReader(src), &out); err != nil {
+		fmt.Printf("%s: %v\n", path, err)
+		return 1
+	}
+	if bytes.Equal(src, out.Bytes()) {
+		return 0
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		fmt.Println("写入失败:", err)
+		return 1
+	}
+	return 0
+}
+
+func runServe(addr, engine string, opts rule_server.Options) int {
+	s, err := rule_server.New(engine, opts)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	fmt.Printf("在 %s 提供规则服务，默认引擎 %s\n", addr, engine)
+	if err := http.ListenAndServe(addr, s.Handler()); err != nil {
+		fmt.Fprintln(os.Stderr, "HTTP 服务退出:", err)
+		return 1
+	}
+	return 0
+}
+
+func runImpact(ruleID string) int {
+	engine := rule_expr.NewRuleEngine()
+	rf, err := os.Open(*rulesFlag)
+	if err != nil {
+		fmt.Println("读取规则失败:", err)
+		return 1
+	}
+	err = engine.ImportText(rf)
+	rf.Close()
+	if err != nil {
+		fmt.Printf("%s: %v\n", *rulesFlag, err)
+		return 1
+	}
+	inputs, err := readReplay(*replayFlag)
+	if err != nil {
+		fmt.Println("读取重放输入失败:", err)
+		return 1
+	}
+	rep, err := engine.ImpactAnalysis(ruleID, inputs, rule_expr.ImpactOptions{Tag: *impactTagFlag})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("规则 %s: %d 条输入中命中 %d 条, 独占 %d 条 (%.2f%%), 首个命中 %d 条\n",
+		rep.RuleID, rep.Inputs, rep.Hits, rep.UniqueHits, rep.UniqueRatio()*100, rep.FirstMatch)
+	for _, id := range slices.Sorted(maps.Keys(rep.Overlap)) {
+		fmt.Printf("  与 %s 重叠 %d 条\n", id, rep.Overlap[id])
+	}
+	for _, id := range slices.Sorted(maps.Keys(rep.FirstChange)) {
+		name := id
+		if name == "" {
+			name = "(无命中)"
+		}
+		fmt.Printf("  删除后首个命中改为 %s: %d 条\n", name, rep.FirstChange[id])
+	}
+	return 0
+}
+
+// readReplay 读取每行一个 JSON 对象的输入文件
+func readReplay(path string) ([]map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var inputs []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var in map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, sc.Err()
+}
+
+// rewriteMapping 映射文件中一个因子的迁移方式；文件为 YAML（JSON 也是合法的 YAML），例如
+//
+//	ip_risk: {rename: high_risk_ip}
+//	user_id: {to: string}
+type rewriteMapping struct {
+	Rename string `yaml:"rename"`
+	To     string `yaml:"to"` // 目前只支持 "string"：整数常量改写为同值字符串
+}
+
+// readRewriteMapping 读取映射文件并转换为 RewriteRules 的参数
+func readRewriteMapping(path string) (map[string]rule_expr.FactorRewrite, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取映射失败: %w", err)
+	}
+	var file map[string]rewriteMapping
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	mapping := make(map[string]rule_expr.FactorRewrite, len(file))
+	for name, m := range file {
+		if m.To != "" && m.To != "string" {
+			return nil, fmt.Errorf("%s: 因子 %s 的目标类型 %q 不受支持", path, name, m.To)
+		}
+		mapping[name] = rule_expr.FactorRewrite{RenameTo: m.Rename, ToString: m.To == "string"}
+	}
+	return mapping, nil
+}
+
+func runRewrite(mappingPath, rulesPath string, dryRun bool, out io.Writer) int {
+	mapping, err := readRewriteMapping(mappingPath)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	engine := rule_expr.NewRuleEngine()
+	src, err := os.ReadFile(rulesPath)
+	if err != nil {
+		fmt.Fprintln(out, "读取规则失败:", err)
+		return 1
+	}
+	if err := engine.ImportText(bytes.NewReader(src)); err != nil {
+		fmt.Fprintf(out, "%s: %v\n", rulesPath, err)
+		return 1
+	}
+	var rep rule_expr.RewriteReport
+	if dryRun {
+		rep = engine.PlanRewrite(mapping)
+	} else if rep, err = engine.RewriteRules(mapping); err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	fmt.Fprint(out, rep.Diff())
+	for _, f := range rep.Failed {
+		fmt.Fprintf(out, "! %s: %s\n", f.ID, f.Err)
+	}
+	fmt.Fprintf(out, "改写 %d 条, 无法改写 %d 条, 未涉及 %d 条\n", len(rep.Changed), len(rep.Failed), rep.Unchanged)
+	if dryRun || len(rep.Changed) == 0 {
+		return 0
+	}
+	var buf bytes.Buffer
+	if err := engine.ExportText(&buf); err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	if err := os.WriteFile(rulesPath, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(out, "写入失败:", err)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+/* ---------- 多引擎隔离基准 ---------- */
+
+// WorkerConfig 父进程通过 stdin 发给 worker 的基准配置
+type WorkerConfig struct {
+	Engine string `json:"engine"` // benchBackends 中的引擎名，如 "expr" / "govaluate" / "cel" / "gval" / "naive"
+	Rules  int    `json:"rules"`
+	Inputs int    `json:"inputs"`
+	Seed   int64  `json:"seed"` // 各 worker 使用同一种子，保证对比的是同一批规则与输入
+}
+
+// WorkerResult worker 通过 stdout 返回的结果；父进程补充进程级字段
+type WorkerResult struct {
+	Engine     string `json:"engine"`
+	PerCallNs  int64  `json:"per_call_ns"`
+	TimerNs    int64  `json:"timer_overhead_ns"`
+	HarnessNs  int64  `json:"harness_overhead_ns"`
+	PeakRSS    int64  `json:"peak_rss_bytes,omitempty"` // 由父进程从 rusage 取得
+	WallTime   string `json:"wall_time,omitempty"`
+	Err        string `json:"error,omitempty"`
+	StderrTail string `json:"stderr_tail,omitempty"`
+	ExitCode   int    `json:"exit_code"`
+}
+
+// runWorker 在子进程中执行：从 stdin 读配置，跑一个引擎的基准，把结果写到 stdout。
+// 规则生成会向 stdout 打印日志，因此先把 os.Stdout 指向 stderr，结果写到原 stdout
+func runWorker() int {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	var cfg WorkerConfig
+	if err := json.NewDecoder(os.Stdin).Decode(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "读取 worker 配置失败:", err)
+		return 2
+	}
+	res := WorkerResult{Engine: cfg.Engine}
+	var b benchBackend
+	for _, c := range benchBackends(rule_expr.NewRuleEngine(), rule_engine.Factors) {
+		if c.name == cfg.Engine {
+			b = c
+		}
+	}
+	if b.engine == nil {
+		fmt.Fprintf(os.Stderr, "未知引擎 %q\n", cfg.Engine)
+		return 2
+	}
+	if err := rule_engine.InjectRandomRulesSeeded(b.engine, b.syntax, cfg.Rules, cfg.Seed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	t := rule_engine.BenchmarkMatchPrecise(b.engine, rule_engine.AdaptInputs(b.syntax, rule_engine.GenRandomInputsSeeded(cfg.Inputs, inputSeed(cfg.Seed))))
+	res.PerCallNs, res.TimerNs, res.HarnessNs = t.PerCall.Nanoseconds(), t.TimerOverhead.Nanoseconds(), t.HarnessOverhead.Nanoseconds()
+	if err := json.NewEncoder(out).Encode(res); err != nil {
+		fmt.Fprintln(os.Stderr, "写出结果失败:", err)
+		return 1
+	}
+	return 0
+}
+
+// Orchestrate 依次为每个配置启动一个新的子进程（当前可执行文件 + -worker），
+// 使各引擎的基准互不受对方堆状态影响。单个 worker 失败只记录在其结果中，不影响其他 worker
+func Orchestrate(configs []WorkerConfig) []WorkerResult {
+	self, err := os.Executable()
+	results := make([]WorkerResult, 0, len(configs))
+	for _, cfg := range configs {
+		if err != nil {
+			results = append(results, WorkerResult{Engine: cfg.Engine, Err: "无法定位当前可执行文件: " + err.Error(), ExitCode: -1})
+			continue
+		}
+		results = append(results, runWorkerProcess(self, cfg))
+	}
+	return results
+}
+
+func runWorkerProcess(self string, cfg WorkerConfig) WorkerResult {
+	res := WorkerResult{Engine: cfg.Engine}
+	in, err := json.Marshal(cfg)
+	if err != nil {
+		res.Err, res.ExitCode = err.Error(), -1
+		return res
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(self, "-worker")
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	runErr := cmd.Run()
+	res.WallTime = time.Since(start).Round(time.Millisecond).String()
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+		res.PeakRSS = peakRSS(cmd.ProcessState)
+	}
+	if runErr != nil {
+		res.Err = runErr.Error()
+		res.StderrTail = tail(stderr.String(), 512)
+		return res
+	}
+	var got WorkerResult
+	if err := json.NewDecoder(&stdout).Decode(&got); err != nil {
+		res.Err = "解析 worker 结果失败: " + err.Error()
+		res.StderrTail = tail(stderr.String(), 512)
+		return res
+	}
+	got.PeakRSS, got.WallTime, got.ExitCode = res.PeakRSS, res.WallTime, res.ExitCode
+	return got
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// printComparison 打印合并后的对比表
+func printComparison(results []WorkerResult) {
+	fmt.Printf("%-10s %14s %12s %10s %s\n", "引擎", "每次匹配", "峰值 RSS", "耗时", "状态")
+	for _, r := range results {
+		status := "ok"
+		if r.Err != "" {
+			status = fmt.Sprintf("失败 (exit %d): %s", r.ExitCode, r.Err)
+		}
+		rss := "-"
+		if r.PeakRSS > 0 {
+			rss = fmt.Sprintf("%.1f MiB", float64(r.PeakRSS)/(1<<20))
+		}
+		fmt.Printf("%-10s %14s %12s %10s %s\n", r.Engine, time.Duration(r.PerCallNs), rss, r.WallTime, status)
+		if r.StderrTail != "" {
+			fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimSpace(r.StderrTail), "\n", "\n    "))
+		}
+	}
+}
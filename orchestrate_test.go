@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeWorkerEnv 设置后 -worker 子进程不跑基准，而是按 runFakeWorker 回显配置或模拟中途退出
+const fakeWorkerEnv = "GOEXPRTESTER_FAKE_WORKER"
+
+// TestMain 使测试二进制本身可作为 Orchestrate 的 worker：os.Executable 在测试中指向测试二进制，
+// 子进程以 -worker 启动时不运行测试，而是执行 worker 逻辑
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *workerFlag {
+		if os.Getenv(fakeWorkerEnv) != "" {
+			os.Exit(runFakeWorker())
+		}
+		os.Exit(runWorker())
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeWorker 把收到的配置编码进结果字段回传；引擎名为 "crash" 时写出半行结果后以 3 退出
+func runFakeWorker() int {
+	var cfg WorkerConfig
+	if err := json.NewDecoder(os.Stdin).Decode(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "读取 worker 配置失败:", err)
+		return 2
+	}
+	if cfg.Engine == "crash" {
+		fmt.Fprint(os.Stdout, `{"engine":"cra`)
+		fmt.Fprintln(os.Stderr, "模拟崩溃")
+		return 3
+	}
+	json.NewEncoder(os.Stdout).Encode(WorkerResult{Engine: cfg.Engine, PerCallNs: int64(cfg.Rules), TimerNs: int64(cfg.Inputs), HarnessNs: cfg.Seed})
+	return 0
+}
+
+// TestOrchestrateProtocol 配置经 stdin、结果经 stdout 往返；中途退出的 worker 记为失败并带上 stderr，
+// 其前后的 worker 照常完成
+func TestOrchestrateProtocol(t *testing.T) {
+	t.Setenv(fakeWorkerEnv, "1")
+	results := Orchestrate([]WorkerConfig{
+		{Engine: "a", Rules: 11, Inputs: 22, Seed: 33},
+		{Engine: "crash", Rules: 1, Inputs: 1, Seed: 1},
+		{Engine: "b", Rules: 44, Inputs: 55, Seed: -66},
+	})
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	want := map[int]WorkerResult{
+		0: {Engine: "a", PerCallNs: 11, TimerNs: 22, HarnessNs: 33},
+		2: {Engine: "b", PerCallNs: 44, TimerNs: 55, HarnessNs: -66},
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Err != "" || got.ExitCode != 0 || got.Engine != w.Engine || got.PerCallNs != w.PerCallNs ||
+			got.TimerNs != w.TimerNs || got.HarnessNs != w.HarnessNs || got.WallTime == "" {
+			t.Errorf("第 %d 个 worker: %+v，应为 %+v", i, got, w)
+		}
+	}
+	crash := results[1]
+	if crash.Engine != "crash" || crash.ExitCode != 3 || crash.Err == "" || !strings.Contains(crash.StderrTail, "模拟崩溃") {
+		t.Fatalf("中途退出的 worker: %+v", crash)
+	}
+}
+
+// TestOrchestrateRealWorker 以极小的负载跑真实的 worker；未知引擎的 worker 失败，不影响其他引擎
+func TestOrchestrateRealWorker(t *testing.T) {
+	results := Orchestrate([]WorkerConfig{
+		{Engine: "expr", Rules: 5, Inputs: 5, Seed: 1},
+		{Engine: "nope", Rules: 5, Inputs: 5, Seed: 1},
+		{Engine: "govaluate", Rules: 5, Inputs: 5, Seed: 1},
+	})
+	for _, i := range []int{0, 2} {
+		if r := results[i]; r.Err != "" || r.PerCallNs <= 0 {
+			t.Errorf("%s: %+v", r.Engine, r)
+		}
+	}
+	if r := results[1]; r.ExitCode != 2 || !strings.Contains(r.StderrTail, "未知引擎") {
+		t.Fatalf("未知引擎: %+v", r)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+	"goexprtester/rule_govaluate"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Knetic/govaluate"
+	"github.com/expr-lang/expr"
+)
+
+/* ---------- 交互式求值 ---------- */
+
+const replHelp = `命令:
+  set name=value   设置因子值（按因子池声明的类型解析）
+  unset name       删除因子
+  :input           显示当前输入
+  :rules FILE      加载规则文本文件（-fmt 格式）
+  :match           用当前输入匹配已加载的规则
+  :history         显示历史命令
+  :help            显示本帮助
+  :quit            退出
+其余输入按表达式求值`
+
+// repl 一个求值会话；命令从 in 逐行读取，非终端输入时不打印提示符，便于脚本驱动
+type repl struct {
+	engine  string // "expr" 或 "govaluate"
+	out     io.Writer
+	pool    *rule_expr.FactorPool
+	input   map[string]interface{}
+	history []string
+	match   func(map[string]interface{}) []string // 已加载规则的匹配函数，未加载时为 nil
+}
+
+func runRepl(in io.Reader, out io.Writer, engine string, prompt bool) int {
+	if engine != "expr" && engine != "govaluate" {
+		fmt.Fprintf(out, "未知引擎 %q，可选 expr / govaluate\n", engine)
+		return 2
+	}
+	r := &repl{engine: engine, out: out, pool: rule_expr.DefaultFactorPool(), input: make(map[string]interface{})}
+	sc := bufio.NewScanner(in)
+	for {
+		if prompt {
+			fmt.Fprint(out, engine+"> ")
+		}
+		if !sc.Scan() {
+			break
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		r.history = append(r.history, line)
+		if line == ":quit" {
+			break
+		}
+		r.exec(line)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(out, "读取输入失败:", err)
+		return 1
+	}
+	return 0
+}
+
+func (r *repl) exec(line string) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "set":
+		name, raw, ok := strings.Cut(arg, "=")
+		if !ok {
+			fmt.Fprintln(r.out, "用法: set name=value")
+			return
+		}
+		name = strings.TrimSpace(name)
+		v, err := r.parseValue(name, strings.TrimSpace(raw))
+		if err != nil {
+			fmt.Fprintln(r.out, "错误:", err)
+			return
+		}
+		rule_engine.SetPath(r.input, name, v) // user.country 写入嵌套的 user 对象
+		fmt.Fprintf(r.out, "%s = %#v\n", name, v)
+	case "unset":
+		rule_engine.DeletePath(r.input, arg)
+	case ":input":
+		for _, k := range sortedKeys(r.input) {
+			fmt.Fprintf(r.out, "%s = %#v\n", k, r.input[k])
+		}
+	case ":rules":
+		r.loadRules(arg)
+	case ":match":
+		if r.match == nil {
+			fmt.Fprintln(r.out, "尚未加载规则，先执行 :rules FILE")
+			return
+		}
+		hits := slices.Clone(r.match(r.engineInput()))
+		slices.Sort(hits)
+		fmt.Fprintf(r.out, "命中 %d 条: %v\n", len(hits), hits)
+	case ":history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%3d  %s\n", i+1, h)
+		}
+	case ":help":
+		fmt.Fprintln(r.out, replHelp)
+	default:
+		if strings.HasPrefix(cmd, ":") {
+			fmt.Fprintf(r.out, "未知命令 %s，输入 :help 查看帮助\n", cmd)
+			return
+		}
+		r.eval(line)
+	}
+}
+
+// parseValue 已声明的因子按 Kind 解析；未声明的依次尝试 bool、整数、浮点，否则作为字符串
+func (r *repl) parseValue(name, raw string) (interface{}, error) {
+	if unq, err := strconv.Unquote(raw); err == nil {
+		raw = unq
+	}
+	f, ok := r.pool.Lookup(name)
+	if !ok {
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b, nil
+		}
+		if n, err := strconv.Atoi(raw); err == nil {
+			return n, nil
+		}
+		if x, err := strconv.ParseFloat(raw, 64); err == nil {
+			return x, nil
+		}
+		return raw, nil
+	}
+	switch f.Kind {
+	case rule_expr.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s 应为 bool: %q", name, raw)
+		}
+		return b, nil
+	case rule_expr.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s 应为整数: %q", name, raw)
+		}
+		return n, nil
+	case rule_expr.Float:
+		x, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s 应为数值: %q", name, raw)
+		}
+		return x, nil
+	case rule_expr.Time:
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s 应为 RFC 3339 时间: %q", name, raw)
+		}
+		return t, nil
+	case rule_expr.List:
+		// 元素以逗号分隔，空值为空列表
+		if raw == "" {
+			return []string{}, nil
+		}
+		return strings.Split(raw, ","), nil
+	default:
+		return raw, nil
+	}
+}
+
+func (r *repl) eval(src string) {
+	start := time.Now()
+	var (
+		out interface{}
+		err error
+	)
+	if r.engine == "govaluate" {
+		var e *govaluate.EvaluableExpression
+		if e, err = rule_govaluate.Parse(src); err == nil {
+			out, err = e.Evaluate(r.engineInput())
+		}
+	} else {
+		out, err = expr.Eval(src, r.input)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(r.out, "错误: %v\n", err)
+		return
+	}
+	fmt.Fprintf(r.out, "%#v  (%s)\n", out, elapsed)
+}
+
+// engineInput 当前输入按所选引擎转换后的形式：govaluate 的嵌套因子取展平后的点路径键
+func (r *repl) engineInput() map[string]interface{} {
+	if r.engine == "govaluate" {
+		return rule_engine.AdaptInputs(rule_govaluate.Syntax, []map[string]interface{}{r.input})[0]
+	}
+	return r.input
+}
+
+func (r *repl) loadRules(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(r.out, "错误:", err)
+		return
+	}
+	defer f.Close()
+	rules, err := rule_expr.ReadText(f)
+	if err != nil {
+		fmt.Fprintf(r.out, "错误: %s: %v\n", path, err)
+		return
+	}
+	var add func(id, exprStr string) error
+	if r.engine == "govaluate" {
+		engine := &rule_govaluate.RuleEngine{}
+		add, r.match = engine.AddRule, engine.Match
+	} else {
+		engine := rule_expr.NewRuleEngine()
+		add, r.match = engine.AddRule, engine.Match
+	}
+	for _, tr := range rules {
+		if err := add(tr.ID, tr.Expr); err != nil {
+			fmt.Fprintf(r.out, "错误: 规则 %s: %v\n", tr.ID, err)
+			r.match = nil
+			return
+		}
+	}
+	fmt.Fprintf(r.out, "已加载 %d 条规则\n", len(rules))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// elapsedRe 求值结果后的耗时每次不同，比对前替换为固定文本
+var elapsedRe = regexp.MustCompile(`  \([0-9.]+[nµm]?s\)$`)
+
+// runScript 以脚本方式驱动一次会话，返回去掉耗时后的记录
+func runScript(t *testing.T, engine, script string) (string, int) {
+	t.Helper()
+	var out bytes.Buffer
+	code := runRepl(strings.NewReader(script), &out, engine, false)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = elapsedRe.ReplaceAllString(line, "  (耗时)")
+	}
+	return strings.Join(lines, "\n") + "\n", code
+}
+
+func writeRulesFile(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplScriptedSession(t *testing.T) {
+	path := writeRulesFile(t, `big | 0 |  | amount > 100
+prod_cn | 0 |  | env == "prod" and user.country == "CN"
+small | 0 |  | amount < 10
+`)
+	script := strings.Join([]string{
+		"set env=prod",
+		"set user_id=12345",
+		"set amount=250",
+		"set user.country=CN",
+		"set user_id=abc",
+		`env == "prod" and amount > 100`,
+		"user_id + 1",
+		"amount >",
+		":match",
+		":rules " + path,
+		":match",
+		":input",
+		":nope",
+		":quit",
+		"set env=staging", // :quit 之后的命令不再执行
+	}, "\n")
+	got, code := runScript(t, "expr", script)
+	want := `env = "prod"
+user_id = 12345
+amount = 250
+user.country = "CN"
+错误: user_id 应为整数: "abc"
+true  (耗时)
+12346  (耗时)
+错误: unexpected token EOF (1:8)
+ | amount >
+ | .......^
+尚未加载规则，先执行 :rules FILE
+已加载 3 条规则
+命中 2 条: [big prod_cn]
+amount = 250
+env = "prod"
+user = map[string]interface {}{"country":"CN"}
+user_id = 12345
+未知命令 :nope，输入 :help 查看帮助
+`
+	if code != 0 || got != want {
+		t.Fatalf("code = %d，记录不符:\n--- 实际\n%s--- 期望\n%s", code, got, want)
+	}
+}
+
+// TestReplGovaluate govaluate 引擎下嵌套因子按展平后的点路径键求值与匹配
+func TestReplGovaluate(t *testing.T) {
+	path := writeRulesFile(t, "big | 0 |  | amount > 100\ncn | 0 |  | [user.country] == 'CN' && is_vip\n")
+	script := "set amount=250\nset user.country=CN\nset is_vip=true\n[user.country] == 'CN'\n:rules " + path + "\n:match\nunset is_vip\n:match\n"
+	got, code := runScript(t, "govaluate", script)
+	want := `amount = 250
+user.country = "CN"
+is_vip = true
+true  (耗时)
+已加载 2 条规则
+命中 2 条: [big cn]
+命中 1 条: [big]
+`
+	if code != 0 || got != want {
+		t.Fatalf("code = %d，记录不符:\n--- 实际\n%s--- 期望\n%s", code, got, want)
+	}
+}
+
+func TestReplBadRulesFile(t *testing.T) {
+	path := writeRulesFile(t, "ok | 0 |  | is_vip\nbad | 0 |  | is_vip and\n")
+	got, _ := runScript(t, "expr", ":rules "+path+"\n:match\n:history\n")
+	if !strings.Contains(got, "错误: 规则 bad:") || !strings.Contains(got, "尚未加载规则") {
+		t.Fatalf("加载失败后不应保留部分规则:\n%s", got)
+	}
+	if !strings.Contains(got, "  3  :history\n") {
+		t.Fatalf("历史应记录全部命令:\n%s", got)
+	}
+	if _, code := runScript(t, "lua", ""); code != 2 {
+		t.Fatalf("未知引擎应返回 2，实际 %d", code)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunRewrite YAML 与 JSON 写法的映射文件都可读取；-dry-run 不写回，否则写回规范形式
+func TestRunRewrite(t *testing.T) {
+	dir := t.TempDir()
+	const src = "a | 0 |  | ip_risk and user_id == 12345\nb | 0 |  | is_vip\n"
+	rules := filepath.Join(dir, "rules.txt")
+	for name, mapping := range map[string]string{
+		"mapping.yaml": "ip_risk: {rename: high_risk_ip}\nuser_id:\n  to: string\n",
+		"mapping.json": `{"ip_risk": {"rename": "high_risk_ip"}, "user_id": {"to": "string"}}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(mapping), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(rules, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if code := runRewrite(path, rules, true, &out); code != 0 {
+			t.Fatalf("%s: dry run 返回 %d: %s", name, code, out.String())
+		}
+		wantOut := "- a: ip_risk and user_id == 12345\n+ a: high_risk_ip and user_id == \"12345\"\n改写 1 条, 无法改写 0 条, 未涉及 1 条\n"
+		if out.String() != wantOut {
+			t.Fatalf("%s: 输出:\n%s应为:\n%s", name, out.String(), wantOut)
+		}
+		if got, _ := os.ReadFile(rules); string(got) != src {
+			t.Fatalf("%s: dry run 不应写回:\n%s", name, got)
+		}
+		if code := runRewrite(path, rules, false, io.Discard); code != 0 {
+			t.Fatalf("%s: 返回 %d", name, code)
+		}
+		want := "a | 0 |  | high_risk_ip and user_id == \"12345\"\nb | 0 |  | is_vip\n"
+		if got, _ := os.ReadFile(rules); string(got) != want {
+			t.Fatalf("%s: 写回结果:\n%s应为:\n%s", name, got, want)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(bad, []byte("user_id: {to: float}\n"), 0o644)
+	if _, err := readRewriteMapping(bad); err == nil {
+		t.Fatal("不支持的目标类型应报错")
+	}
+}
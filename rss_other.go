@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// peakRSS 非 Unix 平台无 rusage，返回 0 表示未知
+func peakRSS(*os.ProcessState) int64 { return 0 }
@@ -0,0 +1,167 @@
+package rule_cel
+
+import (
+	"fmt"
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+/* ---------- RuleEngine 与 Rule (CEL) ---------- */
+
+type Rule struct {
+	ID         string
+	ExprString string
+	Program    cel.Program
+}
+
+type RuleEngine struct {
+	env   *cel.Env
+	rules sync.Map   // id -> *Rule
+	mu    sync.Mutex // 串行化规则变更与 ListRules 快照
+	count int        // 规则数，由 mu 保护
+}
+
+var _ rule_engine.Engine = (*RuleEngine)(nil)
+
+// NewRuleEngine 以 factors 声明 CEL 环境（每个因子一个变量，Bool/String/Int/Float/Time/List 分别对应 bool/string/int/double/timestamp/list(string)），
+// factors 为 nil 时使用 rule_engine.Factors；嵌套因子按点路径声明为限定名变量。规则只能引用已声明的因子
+func NewRuleEngine(factors []rule_engine.Factor) (*RuleEngine, error) {
+	if factors == nil {
+		factors = rule_engine.Factors
+	}
+	opts := make([]cel.EnvOption, 0, len(factors))
+	for _, f := range factors {
+		var t *cel.Type
+		switch f.Kind {
+		case rule_engine.Bool:
+			t = cel.BoolType
+		case rule_engine.String:
+			t = cel.StringType
+		case rule_engine.Int:
+			t = cel.IntType
+		case rule_engine.Float:
+			t = cel.DoubleType
+		case rule_engine.Time:
+			t = cel.TimestampType
+		case rule_engine.List:
+			t = cel.ListType(cel.StringType)
+		default:
+			return nil, fmt.Errorf("因子 %s 的类型 %d 不受支持", f.Name, f.Kind)
+		}
+		opts = append(opts, cel.Variable(f.Name, t))
+	}
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 CEL 环境失败: %w", err)
+	}
+	return &RuleEngine{env: env}, nil
+}
+
+// AddRule 编译并加入/替换一条规则；表达式须通过类型检查且结果为 bool
+func (re *RuleEngine) AddRule(id, exprStr string) error {
+	ast, iss := re.env.Compile(exprStr)
+	if iss.Err() != nil {
+		return iss.Err()
+	}
+	if !ast.OutputType().IsExactType(types.BoolType) {
+		return fmt.Errorf("表达式结果为 %s，不是 bool", ast.OutputType())
+	}
+	prg, err := re.env.Program(ast)
+	if err != nil {
+		return err
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if _, loaded := re.rules.Swap(id, &Rule{
+		ID:         id,
+		ExprString: exprStr,
+		Program:    prg,
+	}); !loaded {
+		re.count++
+	}
+	return nil
+}
+
+// RemoveRule 删除规则，返回该 ID 是否存在；可与 Match 并发
+func (re *RuleEngine) RemoveRule(id string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	_, ok := re.rules.LoadAndDelete(id)
+	if ok {
+		re.count--
+	}
+	return ok
+}
+
+// RuleInfo 规则的只读快照，不含编译结果
+type RuleInfo struct {
+	ID   string
+	Expr string
+}
+
+// ListRules 返回按 ID 排序的规则快照；与规则变更互斥，因此是某一时刻的完整规则集
+func (re *RuleEngine) ListRules() []RuleInfo {
+	re.mu.Lock()
+	out := make([]RuleInfo, 0, re.count)
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		out = append(out, RuleInfo{ID: r.ID, Expr: r.ExprString})
+		return true
+	})
+	re.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RuleCount 返回当前规则数
+func (re *RuleEngine) RuleCount() int {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.count
+}
+
+// Match 遍历执行全部规则并返回命中 ID；执行出错（如输入缺少规则引用的因子）的规则按未命中处理
+func (re *RuleEngine) Match(input map[string]interface{}) []string {
+	var hits []string
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		out, _, err := r.Program.Eval(input)
+		if err == nil && out == types.True {
+			hits = append(hits, r.ID)
+		}
+		return true
+	})
+	return hits
+}
+
+/* ---------- 随机规则注入 & Benchmark ---------- */
+
+// Syntax 随机规则使用的 CEL 写法；嵌套因子声明为限定名变量（如 user.country），
+// 类型检查照常进行，输入须展平为同名的键
+var Syntax = rule_engine.Syntax{Not: "!", And: "&&", Or: "||", Bool: "%s", Time: `timestamp("%s")`, In: "%[1]s in %[2]s", Set: "[%s]", Len: "size(%s)",
+	FlatPaths: true}
+
+// InjectRandomRules 生成 count 条随机规则，见 rule_engine.InjectRandomRules
+func InjectRandomRules(re *RuleEngine, count int, opts ...rule_engine.GenOptions) error {
+	return rule_engine.InjectRandomRules(re, Syntax, count, opts...)
+}
+
+// GenRandomInputs 生成 n 条随机测试数据，见 rule_engine.GenRandomInputs；嵌套因子展平为点路径键
+func GenRandomInputs(n int) []map[string]interface{} {
+	return rule_engine.AdaptInputs(Syntax, rule_engine.GenRandomInputs(n))
+}
+
+func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	return rule_engine.BenchmarkMatch(re, inputs, opts...)
+}
+
+// BenchmarkMatchPrecise 只对引擎调用本身计时，并给出计时与循环开销
+func BenchmarkMatchPrecise(re *RuleEngine, inputs []map[string]interface{}) bench.Timing {
+	return rule_engine.BenchmarkMatchPrecise(re, inputs)
+}
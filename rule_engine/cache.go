@@ -0,0 +1,107 @@
+package rule_engine
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/* ---------- 编译缓存 ---------- */
+
+// DefaultCompileCacheSize 编译缓存默认最多保留的条目数
+const DefaultCompileCacheSize = 10000
+
+// CompileCache 各后端共用的编译结果缓存：键为表达式文本（及影响编译结果的选项），值为后端的编译产物。
+// 超过容量时淘汰最久未使用的条目；可并发使用
+type CompileCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List                    // 最近使用的在前
+	items    map[interface{}]*list.Element // 键 -> ll 中的元素，元素值为 *cacheEntry
+	stats    CacheStats
+}
+
+type cacheEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// CacheStats 编译缓存的累计统计
+type CacheStats struct {
+	Hits      uint64 // 命中次数
+	Misses    uint64 // 未命中（需要编译）的次数
+	Evictions uint64 // 因超过容量被淘汰的条目数
+	Entries   int    // 当前条目数
+	Capacity  int    // 容量
+}
+
+// HitRate 命中率，没有查询时为 0
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewCompileCache 新建最多保留 capacity 条的缓存，capacity <= 0 取 DefaultCompileCacheSize
+func NewCompileCache(capacity int) *CompileCache {
+	if capacity <= 0 {
+		capacity = DefaultCompileCacheSize
+	}
+	return &CompileCache{capacity: capacity, ll: list.New(), items: make(map[interface{}]*list.Element)}
+}
+
+// Get 取缓存的编译结果并计入命中 / 未命中；key 须可比较
+func (c *CompileCache) Get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		c.stats.Hits++
+		return el.Value.(*cacheEntry).value, true
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// Add 写入编译结果并返回缓存中该键的值：两个调用同时编译同一键时保留先写入的一份，
+// 后来者拿到已有的值，使同文本的规则共享同一份编译结果
+func (c *CompileCache) Add(key, value interface{}) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*cacheEntry).value
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key, value})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+	return value
+}
+
+// Stats 返回累计统计
+func (c *CompileCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries, s.Capacity = c.ll.Len(), c.capacity
+	return s
+}
+
+// BenchmarkAddRule 在 e 上调用 count 次 AddRule，第 i 次以 ID tpl-i 加入 exprs[i % len(exprs)]，返回总耗时。
+// 用于观察同一表达式以大量 ID 重复加入（模板化规则）时编译缓存的效果；e 应为新建的空引擎
+func BenchmarkAddRule(e Engine, exprs []string, count int) (time.Duration, error) {
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("tpl-%d", i)
+		if err := e.AddRule(id, exprs[i%len(exprs)]); err != nil {
+			return 0, fmt.Errorf("加入规则 %s 失败: %w", id, err)
+		}
+	}
+	return time.Since(start), nil
+}
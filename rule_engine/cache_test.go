@@ -0,0 +1,69 @@
+package rule_engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestCompileCacheEvictsLeastRecentlyUsed 超过容量时淘汰最久未使用的条目，Get 与重复 Add 都算使用
+func TestCompileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCompileCache(3)
+	for _, k := range []string{"a", "b", "c"} {
+		c.Add(k, k+"!")
+	}
+	c.Get("a")      // 顺序（新到旧）: a c b
+	c.Add("b", "x") // 已有键：保留旧值并移到最前，顺序 b a c
+	c.Add("d", "d!")
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("c 最久未使用，应被淘汰")
+	}
+	for _, k := range []string{"a", "b", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("%s 不应被淘汰", k)
+		}
+	}
+	if v, _ := c.Get("b"); v != "b!" {
+		t.Fatalf("重复 Add 应保留先写入的值，得到 %v", v)
+	}
+	s := c.Stats()
+	if s.Entries != 3 || s.Capacity != 3 || s.Evictions != 1 || s.Hits != 5 || s.Misses != 1 {
+		t.Fatalf("%+v", s)
+	}
+	if NewCompileCache(0).Stats().Capacity != DefaultCompileCacheSize {
+		t.Fatal("capacity <= 0 应取默认容量")
+	}
+}
+
+// TestCompileCacheConcurrent 并发 Get / Add 同一批键：同一键最终只有一个值，所有调用方拿到的都是它
+func TestCompileCacheConcurrent(t *testing.T) {
+	const keys, workers = 50, 8
+	c := NewCompileCache(keys)
+	got := make([][]interface{}, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[w] = make([]interface{}, keys)
+			for i := 0; i < keys; i++ {
+				v, ok := c.Get(i)
+				if !ok {
+					v = c.Add(i, fmt.Sprintf("%d@%d", i, w))
+				}
+				got[w][i] = v
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < keys; i++ {
+		for w := 1; w < workers; w++ {
+			if got[w][i] != got[0][i] {
+				t.Fatalf("键 %d: worker 0 得到 %v，worker %d 得到 %v", i, got[0][i], w, got[w][i])
+			}
+		}
+	}
+	if s := c.Stats(); s.Entries != keys || s.Hits+s.Misses != keys*workers || s.Evictions != 0 {
+		t.Fatalf("%+v", s)
+	}
+}
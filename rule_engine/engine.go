@@ -0,0 +1,607 @@
+package rule_engine
+
+import (
+	"errors"
+	"fmt"
+	"goexprtester/bench"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- 引擎接口 ---------- */
+
+// Engine 各后端 RuleEngine 的公共操作；基准、随机规则注入等只依赖这个接口，
+// 新增后端只需实现它并提供对应的 Syntax
+type Engine interface {
+	AddRule(id, exprStr string) error // 编译并加入（或替换）一条规则
+	RemoveRule(id string) bool        // 删除规则，返回该 ID 是否存在
+	Match(input map[string]interface{}) []string
+	RuleCount() int
+}
+
+// Syntax 后端表达式语言中随机规则用到的写法
+type Syntax struct {
+	Not, And, Or string // 逻辑运算符，如 "not" / "and" / "or" 或 "!" / "&&" / "||"
+	Bool         string // 单个 Bool 因子的写法，%s 为因子名，如 "%s" 或 "%s == true"
+	// Time 时间常量的写法，%s 为 RFC 3339 时间，如 `date("%s")`；输入中的 Time 因子为 time.Time。
+	// 为空表示后端无法比较 time.Time：常量写成 Unix 秒整数，输入须先经 AdaptInputs 转为 int64 Unix 秒
+	Time string
+	In   string // 成员判断的写法，%[1]s 为元素，%[2]s 为集合，如 "%[1]s in %[2]s"；用于 List 因子与 String 的集合成员
+	Set  string // 集合常量的写法，%s 为逗号分隔的元素，如 "[%s]" 或 "(%s)"
+	Len  string // List 长度的写法，%s 为因子名，如 "len(%s)" 或 "size(%s)"
+	// AnyLists 为 true 表示后端只识别 []interface{} 形式的列表，输入须先经 AdaptInputs 转换 []string
+	AnyLists bool
+	Path     string // 嵌套因子的引用写法，%s 为点路径，如 govaluate 的 "[%s]"；为空即点路径本身
+	// FlatPaths 为 true 表示后端按完整点路径取值，输入中的嵌套 map 须先经 AdaptInputs 展平为 "a.b" 形式的键
+	FlatPaths bool
+	// Ternary 布尔三元式的写法，依次为条件、成立与不成立时的分支，如 "((%s) ? %s : %s)"；
+	// 为空表示后端不支持，不能使用 GenOptions.TernaryRate
+	Ternary string
+	// Coalesce 空值合并的写法，%s 为因子引用，如 "(%s ?? 0)"；为空表示后端不支持，不能使用 GenOptions.CoalesceRate
+	Coalesce string
+}
+
+// supports 检查 syntax 能否输出 opts 要求生成的写法
+func (s Syntax) supports(opts GenOptions) error {
+	switch {
+	case opts.TernaryRate > 0 && s.Ternary == "":
+		return errors.New("该写法不支持三元式，TernaryRate 须为 0")
+	case opts.CoalesceRate > 0 && s.Coalesce == "":
+		return errors.New("该写法不支持空值合并，CoalesceRate 须为 0")
+	}
+	return nil
+}
+
+// Ref 因子在表达式中的引用写法
+func (s Syntax) Ref(name string) string {
+	if s.Path != "" && IsNested(name) {
+		return fmt.Sprintf(s.Path, name)
+	}
+	return name
+}
+
+/* ---------- 随机规则注入 ---------- */
+
+// InjectRandomRules 按 syntax 用内置因子池生成 count 条随机规则（ID 为 auto-1 起）并加入 e，种子取当前时间。
+// opts 可选，控制叶子的运算符分布，省略时取默认（见 GenOptions）
+func InjectRandomRules(e Engine, syntax Syntax, count int, opts ...GenOptions) error {
+	return InjectRandomRulesSeeded(e, syntax, count, time.Now().UnixNano(), opts...)
+}
+
+// InjectRandomRulesSeeded 同 InjectRandomRules，但使用给定种子：种子相同时生成的规则文本逐字节相同。
+// 规则树与写法无关，因此同一种子下各引擎拿到的是语义相同的规则
+func InjectRandomRulesSeeded(e Engine, syntax Syntax, count int, seed int64, opts ...GenOptions) error {
+	return InjectRandomRulesFrom(e, syntax, DefaultFactorPool(), count, seed, opts...)
+}
+
+// InjectRandomRulesFrom 同 InjectRandomRulesSeeded，但规则引用 pool 中的因子；pool 须已通过 Validate
+func InjectRandomRulesFrom(e Engine, syntax Syntax, pool *FactorPool, count int, seed int64, opts ...GenOptions) error {
+	if err := genOptions(opts).Validate(); err != nil {
+		return fmt.Errorf("运算符分布不合法: %w", err)
+	}
+	if err := syntax.supports(genOptions(opts)); err != nil {
+		return err
+	}
+	trees, rep := RandomTreesReport(pool, count, seed, opts...)
+	if len(trees) < count {
+		return fmt.Errorf("连续 %d 条随机规则都与已有规则重复，因子池只生成了 %d 条不同的规则，不足 %d 条",
+			maxDuplicateStreak, len(trees), count)
+	}
+	o := genOptions(opts)
+	logger := o.logger()
+	start := time.Now()
+	for i, tree := range trees {
+		ruleID := fmt.Sprintf("auto-%d", i+1)
+		exprStr := tree.Render(syntax)
+		if err := e.AddRule(ruleID, exprStr); err != nil {
+			return fmt.Errorf("编译规则 %s 失败: %w", ruleID, err)
+		}
+		if o.Verbose {
+			logger.Printf("编译规则 %s 成功: %s", ruleID, exprStr)
+		}
+	}
+	elapsed := time.Since(start)
+	if o.Distinct {
+		logger.Printf("编译 %d 条随机规则用时 %s（生成 %d 条，跳过 %d 条重复规则）", len(trees), formatMillis(elapsed), rep.Generated, rep.Duplicates)
+	} else {
+		logger.Printf("编译 %d 条随机规则用时 %s（其中 %d 条与之前的规则重复）", len(trees), formatMillis(elapsed), rep.Duplicates)
+	}
+	return nil
+}
+
+// formatMillis 以毫秒输出耗时，保留一位小数
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
+
+// RandomTreesFrom 用 seed 从 pool 生成 count 棵随机规则树（各 ≤5 因子），即 InjectRandomRulesFrom 注入的规则：
+// 第 i 棵对应 ID auto-(i+1)
+func RandomTreesFrom(pool *FactorPool, count int, seed int64, opts ...GenOptions) []*Tree {
+	trees, _ := RandomTreesReport(pool, count, seed, opts...)
+	return trees
+}
+
+// GenReport 一批随机规则的生成统计
+type GenReport struct {
+	Generated  int // 生成的规则数，Distinct 时包括被跳过的重复规则
+	Duplicates int // 与之前某条规则相同（见 Tree.Key）的规则数；Distinct 时这些规则被跳过
+}
+
+// maxDuplicateStreak Distinct 时连续生成这么多条重复规则即认为不同的规则已经取尽
+const maxDuplicateStreak = 10000
+
+// RandomTreesReport 同 RandomTreesFrom，同时统计重复规则。Distinct 时跳过重复规则，
+// 若连续 maxDuplicateStreak 条都重复（因子池太小，不同的规则不足 count 条），返回的规则少于 count 条
+func RandomTreesReport(pool *FactorPool, count int, seed int64, opts ...GenOptions) ([]*Tree, GenReport) {
+	r := rand.New(rand.NewSource(seed))
+	distinct := genOptions(opts).Distinct
+	trees := make([]*Tree, 0, count)
+	seen := make(map[string]bool, count)
+	var rep GenReport
+	for streak := 0; len(trees) < count && streak < maxDuplicateStreak; {
+		t := RandomTreeFrom(r, pool, 5, opts...)
+		rep.Generated++
+		if key := t.Key(); seen[key] {
+			rep.Duplicates++
+			if distinct {
+				streak++
+				continue
+			}
+		} else {
+			seen[key] = true
+		}
+		streak = 0
+		trees = append(trees, t)
+	}
+	return trees, rep
+}
+
+// genOptions 取可选参数中的 GenOptions，省略时为零值（即默认分布）
+func genOptions(opts []GenOptions) GenOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return GenOptions{}
+}
+
+// RandomExpr 从内置因子池随机选取 1~maxFactors 个不同因子，拼装为 syntax 写法的布尔表达式
+func RandomExpr(r *rand.Rand, syntax Syntax, maxFactors int) string {
+	return RandomTree(r, maxFactors).Render(syntax)
+}
+
+// Tree 与后端写法无关的随机规则；同一棵树按不同 Syntax 输出的表达式语义相同
+type Tree struct {
+	Op          string      // "and" / "or" / "not" / "cond"，叶子为空
+	Left, Right *Tree       // not 只用 Left；cond 为条件成立与不成立时的分支
+	Cond        *Tree       // cond 的条件，整棵树即三元式 Cond ? Left : Right
+	Factor      Factor      // 叶子引用的因子
+	Value       interface{} // 叶子与因子比较的常量，Bool 因子为 nil
+	// Cmp 叶子的比较运算符：Int 为 == != > >= < <=，String 为 == != 或 in（Value 为 []string 集合），
+	// Float 为 > >= < <= 之一，Time 为 < 或 >，List 为 "in"（Value 为元素）或长度比较
+	Cmp string
+	Len bool // List 叶子比较的是列表长度（Value 为 int），否则为成员判断（Value 为元素）
+	// Coalesce Int 叶子以 (x ?? 0) 取值：因子缺失或为 nil 时按 0 比较
+	Coalesce bool
+	// Arith Int 叶子比较前的算术修饰，"% 2"（Cmp 为 ==，Value 为 0 或 1）或 "+ 1"（Cmp 为 >）；空表示直接比较
+	Arith string
+}
+
+// floatCmps Float 因子随机使用的范围比较
+var floatCmps = []string{">", ">=", "<", "<="}
+
+// timeCmps Time 因子随机使用的比较："<" 早于常量（如账户注册早于某日，即账龄较老），">" 晚于常量（较新）
+var timeCmps = []string{"<", ">"}
+
+// lenCmps List 因子长度随机使用的比较
+var lenCmps = []string{">", ">=", "<", "=="}
+
+// RandomTree 从内置因子池随机选取 1~maxFactors 个不同因子，拼装为布尔表达式树，运算符按默认分布
+func RandomTree(r *rand.Rand, maxFactors int) *Tree {
+	return RandomTreeFrom(r, DefaultFactorPool(), maxFactors)
+}
+
+// RandomTreeFrom 同 RandomTree，但从 pool 中按因子权重选取因子（见 FactorPool.PickFactors）；maxFactors 超过因子数时以因子数为上限。
+// opts 可选，须已通过 Validate；其中 MaxFactors 非零时取代 maxFactors
+func RandomTreeFrom(r *rand.Rand, pool *FactorPool, maxFactors int, opts ...GenOptions) *Tree {
+	o := genOptions(opts)
+	if o.MaxFactors == 0 {
+		o.MaxFactors = maxFactors
+	}
+	o = o.withDefaults()
+	hi := o.MaxFactors
+	if !o.RepeatFactors {
+		hi = min(hi, len(pool.Factors))
+	}
+	if o.MaxDepth > 0 {
+		hi = min(hi, 1<<min(o.MaxDepth, 30))
+	}
+	lo := min(max(o.MinFactors, 1), hi)
+	factors := pool.PickFactors(r, lo+r.Intn(hi-lo+1), o.RepeatFactors)
+	return buildTree(r, factors, o, o.MaxDepth)
+}
+
+// buildTree 递归生成子表达式；depth 为剩余可用的 and / or 层数，0 表示不限（调用方保证叶子数不超过 2^depth）
+func buildTree(r *rand.Rand, factors []Factor, opts GenOptions, depth int) *Tree {
+	if len(factors) == 1 {
+		leaf := &Tree{Factor: factors[0]}
+		switch f := factors[0]; f.Kind {
+		case Int:
+			leaf.Cmp, leaf.Value = opts.IntCmp(r, f)
+			intModifiers(r, leaf, opts)
+		case String:
+			leaf.Cmp, leaf.Value = opts.StringCmp(r, f)
+		case Float:
+			leaf.Value = f.SampleValues[r.Intn(len(f.SampleValues))]
+			leaf.Cmp = floatCmps[r.Intn(len(floatCmps))]
+		case Time:
+			leaf.Value = f.SampleValues[r.Intn(len(f.SampleValues))]
+			leaf.Cmp = timeCmps[r.Intn(len(timeCmps))]
+		case List:
+			listLeaf(r, leaf)
+		}
+		// 按 NotRate（默认 30%）取反
+		if r.Float64() < opts.NotRate {
+			return &Tree{Op: "not", Left: leaf}
+		}
+		return leaf
+	}
+	// 三元式以首个叶子为条件，其余叶子分给两支；不限深度时才生成
+	if depth == 0 && len(factors) >= 3 && opts.TernaryRate > 0 && r.Float64() < opts.TernaryRate {
+		split := 2 + r.Intn(len(factors)-2)
+		return &Tree{Op: "cond", Cond: buildTree(r, factors[:1], opts, 0),
+			Left: buildTree(r, factors[1:split], opts, 0), Right: buildTree(r, factors[split:], opts, 0)}
+	}
+	// 限制深度时两侧都不能超过下一层的容量 2^(depth-1)
+	lo, hi := 1, len(factors)-1
+	if depth > 0 {
+		half := 1 << (depth - 1)
+		lo, hi = max(lo, len(factors)-half), min(hi, half)
+		depth--
+	}
+	split := lo + r.Intn(hi-lo+1)
+	t := &Tree{Op: "and", Left: buildTree(r, factors[:split], opts, depth), Right: buildTree(r, factors[split:], opts, depth)}
+	if r.Float64() < opts.OrRate {
+		t.Op = "or"
+	}
+	return t
+}
+
+// intModifiers 按 CoalesceRate / ArithRate 给 Int 叶子加上 ?? 与算术修饰；
+// 概率为 0 时不消耗随机数，同一种子生成的规则与不设这两个选项时相同
+func intModifiers(r *rand.Rand, leaf *Tree, opts GenOptions) {
+	if opts.CoalesceRate > 0 && r.Float64() < opts.CoalesceRate {
+		leaf.Coalesce = true
+	}
+	if opts.ArithRate > 0 && r.Float64() < opts.ArithRate {
+		if r.Intn(2) == 0 {
+			leaf.Arith, leaf.Cmp, leaf.Value = "% 2", "==", leaf.Value.(int)%2
+		} else {
+			leaf.Arith, leaf.Cmp = "+ 1", ">"
+		}
+	}
+}
+
+// listLeaf 一半为成员判断（元素取自全部样例列表），一半为长度比较（常量不超过最长样例列表的长度）
+func listLeaf(r *rand.Rand, leaf *Tree) {
+	var members []string
+	longest := 0
+	for _, v := range leaf.Factor.SampleValues {
+		l := v.([]string)
+		members = append(members, l...)
+		longest = max(longest, len(l))
+	}
+	if len(members) > 0 && r.Float64() < 0.5 {
+		leaf.Cmp, leaf.Value = "in", members[r.Intn(len(members))]
+		return
+	}
+	leaf.Len, leaf.Cmp, leaf.Value = true, lenCmps[r.Intn(len(lenCmps))], r.Intn(longest+1)
+}
+
+// 规则形态，用于按访问方式分组比较耗时
+const (
+	ShapeFlat   = "flat"   // 只引用顶层因子
+	ShapeNested = "nested" // 至少引用一个嵌套因子
+)
+
+// Shape 返回规则形态：引用了嵌套因子为 ShapeNested，否则为 ShapeFlat
+func (t *Tree) Shape() string {
+	switch {
+	case t.Op == "":
+		if IsNested(t.Factor.Name) {
+			return ShapeNested
+		}
+		return ShapeFlat
+	case t.Left.Shape() == ShapeNested || t.Right != nil && t.Right.Shape() == ShapeNested ||
+		t.Cond != nil && t.Cond.Shape() == ShapeNested:
+		return ShapeNested
+	}
+	return ShapeFlat
+}
+
+// keySyntax Tree.Key 使用的写法，只用于判重
+var keySyntax = Syntax{Not: "not", And: "and", Or: "or", Bool: "%s", In: "%[1]s in %[2]s", Set: "[%s]", Len: "len(%s)",
+	Ternary: "((%s) ? %s : %s)", Coalesce: "(%s ?? 0)"}
+
+// Key 与写法无关的规则标识：两棵树的 Key 相同当且仅当它们在任何 Syntax 下输出的表达式都相同
+func (t *Tree) Key() string {
+	return t.Render(keySyntax)
+}
+
+// Render 按 syntax 输出表达式
+func (t *Tree) Render(syntax Syntax) string {
+	switch t.Op {
+	case "not":
+		return syntax.Not + " (" + t.Left.Render(syntax) + ")"
+	case "and":
+		return fmt.Sprintf("(%s %s %s)", t.Left.Render(syntax), syntax.And, t.Right.Render(syntax))
+	case "or":
+		return fmt.Sprintf("(%s %s %s)", t.Left.Render(syntax), syntax.Or, t.Right.Render(syntax))
+	case "cond":
+		return fmt.Sprintf(syntax.Ternary, t.Cond.Render(syntax), t.Left.Render(syntax), t.Right.Render(syntax))
+	}
+	name := syntax.Ref(t.Factor.Name)
+	if t.Coalesce {
+		name = fmt.Sprintf(syntax.Coalesce, name)
+	}
+	switch {
+	case t.Arith != "":
+		return fmt.Sprintf("%s %s %s %v", name, t.Arith, t.Cmp, t.Value)
+	case t.Len:
+		return fmt.Sprintf("%s %s %v", fmt.Sprintf(syntax.Len, name), t.Cmp, t.Value)
+	case t.Cmp == "in" && t.Factor.Kind == List:
+		return fmt.Sprintf(syntax.In, strconv.Quote(t.Value.(string)), name)
+	case t.Cmp == "in":
+		set := t.Value.([]string)
+		quoted := make([]string, len(set))
+		for i, s := range set {
+			quoted[i] = strconv.Quote(s)
+		}
+		return fmt.Sprintf(syntax.In, name, fmt.Sprintf(syntax.Set, strings.Join(quoted, ", ")))
+	}
+	switch v := t.Value.(type) {
+	case nil:
+		return fmt.Sprintf(syntax.Bool, name)
+	case string:
+		return fmt.Sprintf("%s %s %q", name, t.Cmp, v)
+	case float64:
+		return fmt.Sprintf("%s %s %s", name, t.Cmp, FormatFloat(v))
+	case time.Time:
+		if syntax.Time == "" {
+			return fmt.Sprintf("%s %s %d", name, t.Cmp, v.Unix())
+		}
+		return fmt.Sprintf("%s %s %s", name, t.Cmp, fmt.Sprintf(syntax.Time, v.UTC().Format(time.RFC3339)))
+	default:
+		return fmt.Sprintf("%s %s %v", name, t.Cmp, v)
+	}
+}
+
+// FormatFloat 输出带小数点的浮点常量（250 写作 250.0），各后端都将其解析为浮点数而非整数
+func FormatFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+/* ---------- 随机数据生成 & Benchmark ---------- */
+
+// GenRandomInputs 生成 n 条随机测试数据，每条包含内置因子池中的全部因子，种子取当前时间
+func GenRandomInputs(n int) []map[string]interface{} {
+	return GenRandomInputsSeeded(n, time.Now().UnixNano())
+}
+
+// GenRandomInputsSeeded 同 GenRandomInputs，但使用给定种子：种子相同时生成的数据逐行相同
+func GenRandomInputsSeeded(n int, seed int64) []map[string]interface{} {
+	return GenRandomInputsFrom(DefaultFactorPool(), n, seed)
+}
+
+// GenRandomInputsFrom 同 GenRandomInputsSeeded，但每条包含 pool 中的全部因子；pool 须已通过 Validate
+func GenRandomInputsFrom(pool *FactorPool, n int, seed int64) []map[string]interface{} {
+	r := rand.New(rand.NewSource(seed))
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = RandomRowFrom(r, pool)
+	}
+	return rows
+}
+
+// GenRandomInputsMissing 同 GenRandomInputsFrom，但 Int 因子以 missingRate 的概率取 nil，用于覆盖 ?? 的空值分支。
+// 以 nil 值而非删除键表示缺失：govaluate 对不存在的键直接报错，即使写了 ??
+func GenRandomInputsMissing(pool *FactorPool, n int, seed int64, missingRate float64) []map[string]interface{} {
+	r := rand.New(rand.NewSource(seed))
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		row := make(map[string]interface{}, len(pool.Factors))
+		for _, f := range pool.Factors {
+			var v interface{}
+			if f.Kind != Int || missingRate <= 0 || r.Float64() >= missingRate {
+				v = RandomValue(r, f)
+			}
+			SetPath(row, f.Name, v)
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// RandomRow 用 r 按内置因子池生成一行随机输入；调用方可以为每行提供独立的随机源以便并行生成
+func RandomRow(r *rand.Rand) map[string]interface{} {
+	return RandomRowFrom(r, DefaultFactorPool())
+}
+
+// RandomRowFrom 同 RandomRow，但按 pool 生成；嵌套因子写入对应的嵌套 map
+func RandomRowFrom(r *rand.Rand, pool *FactorPool) map[string]interface{} {
+	row := make(map[string]interface{}, len(pool.Factors))
+	for _, f := range pool.Factors {
+		if IsNested(f.Name) {
+			SetPath(row, f.Name, RandomValue(r, f))
+		} else {
+			row[f.Name] = RandomValue(r, f)
+		}
+	}
+	return row
+}
+
+// RandomValue 用 r 为因子 f 生成一个随机取值：Bool 各半；String 取样例值；
+// Int 80% 取样例值、20% 取随机 5 位数；Float 一半恰好取样例值（范围比较的边界），
+// 一半在样例值范围两侧各外扩 10% 的区间内均匀取值并保留两位小数。Float 一律为 float64。
+// Time 与 Float 相同地取样例值或范围内的随机时刻，结果为精确到秒的 UTC time.Time；
+// List 取样例列表之一的副本（可能为空列表）
+func RandomValue(r *rand.Rand, f Factor) interface{} {
+	switch f.Kind {
+	case Bool:
+		return r.Intn(2) == 0
+	case String:
+		return f.SampleValues[r.Intn(len(f.SampleValues))]
+	case Int:
+		if r.Float64() < 0.8 {
+			return f.SampleValues[r.Intn(len(f.SampleValues))]
+		}
+		return r.Intn(90000) + 10000
+	case Float:
+		if r.Float64() < 0.5 {
+			return f.SampleValues[r.Intn(len(f.SampleValues))]
+		}
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range f.SampleValues {
+			lo, hi = min(lo, v.(float64)), max(hi, v.(float64))
+		}
+		pad := (hi - lo) * 0.1
+		return math.Round((lo-pad+r.Float64()*(hi-lo+2*pad))*100) / 100
+	case Time:
+		if r.Float64() < 0.5 {
+			return f.SampleValues[r.Intn(len(f.SampleValues))]
+		}
+		lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+		for _, v := range f.SampleValues {
+			u := v.(time.Time).Unix()
+			lo, hi = min(lo, u), max(hi, u)
+		}
+		pad := (hi - lo) / 10
+		return time.Unix(lo-pad+r.Int63n(hi-lo+2*pad+1), 0).UTC()
+	case List:
+		return slices.Clone(f.SampleValues[r.Intn(len(f.SampleValues))].([]string))
+	}
+	return nil
+}
+
+// AdaptInputs 按 syntax 转换输入中后端无法直接使用的值：syntax.Time 为空时 time.Time 转为 int64 Unix 秒
+// （随机生成的时间都精确到秒，转换不丢失信息）；syntax.AnyLists 为 true 时 []string 转为 []interface{}；
+// syntax.FlatPaths 为 true 时嵌套 map 展平为 "a.b" 形式的键。
+// 无需转换时原样返回 inputs；否则返回浅拷贝，只替换需要转换的行，不修改原输入
+func AdaptInputs(syntax Syntax, inputs []map[string]interface{}) []map[string]interface{} {
+	if syntax.Time != "" && !syntax.AnyLists && !syntax.FlatPaths {
+		return inputs
+	}
+	var out []map[string]interface{}
+	for i, in := range inputs {
+		if needsAdapt(syntax, in) {
+			if out == nil {
+				out = slices.Clone(inputs)
+			}
+			row := make(map[string]interface{}, len(in))
+			adaptInto(syntax, row, "", in)
+			out[i] = row
+		}
+	}
+	if out == nil {
+		return inputs
+	}
+	return out
+}
+
+// adaptValue 返回 v 在该后端下的表示；第二个返回值表示是否需要转换
+func adaptValue(syntax Syntax, v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		if syntax.Time == "" {
+			return x.Unix(), true
+		}
+	case []string:
+		if syntax.AnyLists {
+			items := make([]interface{}, len(x))
+			for i, s := range x {
+				items[i] = s
+			}
+			return items, true
+		}
+	}
+	return v, false
+}
+
+func needsAdapt(syntax Syntax, in map[string]interface{}) bool {
+	for _, v := range in {
+		if m, ok := v.(map[string]interface{}); ok {
+			if syntax.FlatPaths || needsAdapt(syntax, m) {
+				return true
+			}
+		} else if _, ok := adaptValue(syntax, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptInto 把 in 转换后写入 dst，键加上 prefix；嵌套 map 按 syntax.FlatPaths 展平或逐层复制
+func adaptInto(syntax Syntax, dst map[string]interface{}, prefix string, in map[string]interface{}) {
+	for k, v := range in {
+		if m, ok := v.(map[string]interface{}); ok {
+			if syntax.FlatPaths {
+				adaptInto(syntax, dst, prefix+k+".", m)
+				continue
+			}
+			nested := make(map[string]interface{}, len(m))
+			adaptInto(syntax, nested, "", m)
+			v = nested
+		} else {
+			v, _ = adaptValue(syntax, v)
+		}
+		dst[prefix+k] = v
+	}
+}
+
+// BenchmarkMatch 返回 e 对 inputs 单次 Match 的平均耗时。opts 可省略，
+// 默认先预热一遍再计时一遍；给出时只取第一个，见 BenchmarkMatchRepeated
+func BenchmarkMatch(e Engine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	var o bench.RepeatOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return BenchmarkMatchRepeated(e, inputs, o).Mean
+}
+
+// BenchmarkMatchRepeated 预热 opts.Warmup 遍后计时 opts.Repeat 遍，返回各次均值与运行间的波动
+func BenchmarkMatchRepeated(e Engine, inputs []map[string]interface{}, opts bench.RepeatOptions) bench.Repeated {
+	return bench.MeasureRepeated(e.Match, inputs, opts)
+}
+
+// BenchmarkMatchPrecise 只对引擎调用本身计时，并给出计时与循环开销
+func BenchmarkMatchPrecise(e Engine, inputs []map[string]interface{}) bench.Timing {
+	return bench.Measure(e.Match, inputs, bench.Options{})
+}
+
+// BenchmarkMatchAdaptive 持续采样直到均值的置信区间达到 opts 的目标宽度或触达上限
+func BenchmarkMatchAdaptive(e Engine, inputs []map[string]interface{}, opts bench.AdaptiveOptions) bench.AdaptiveTiming {
+	return bench.MeasureAdaptive(e.Match, inputs, opts)
+}
+
+// BenchmarkMatchStats 对每次 Match 单独计时，返回 calls 次调用（calls <= 0 时每条输入一次）的耗时分布
+func BenchmarkMatchStats(e Engine, inputs []map[string]interface{}, calls int) bench.Stats {
+	return bench.MeasureEach(e.Match, inputs, calls)
+}
+
+// BenchmarkMatchAllocs 统计 calls 次 Match（calls <= 0 时每条输入一次）的平均堆分配
+func BenchmarkMatchAllocs(e Engine, inputs []map[string]interface{}, calls int) bench.Allocs {
+	return bench.MeasureAllocs(e.Match, inputs, calls)
+}
+
+// BenchmarkMatchConcurrent 用 goroutines 个 goroutine 同时在 e 上 Match，inputs 每条一次，
+// 返回总吞吐与逐次耗时分布。用于观察共享引擎在读多写少负载下的竞争
+func BenchmarkMatchConcurrent(e Engine, inputs []map[string]interface{}, goroutines int) bench.Concurrent {
+	return bench.MeasureConcurrent(e.Match, inputs, goroutines, len(inputs))
+}
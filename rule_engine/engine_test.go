@@ -0,0 +1,53 @@
+package rule_engine
+
+import (
+	"slices"
+	"testing"
+)
+
+// recordingEngine 按加入顺序记录 "ID: 表达式"，不做编译与匹配
+type recordingEngine struct{ rules []string }
+
+func (e *recordingEngine) AddRule(id, exprStr string) error {
+	e.rules = append(e.rules, id+": "+exprStr)
+	return nil
+}
+func (e *recordingEngine) RemoveRule(string) bool                { return false }
+func (e *recordingEngine) Match(map[string]interface{}) []string { return nil }
+func (e *recordingEngine) RuleCount() int                        { return len(e.rules) }
+
+func injectRecorded(t *testing.T, syntax Syntax, seed int64, opts ...GenOptions) []string {
+	t.Helper()
+	e := &recordingEngine{}
+	if err := InjectRandomRulesSeeded(e, syntax, 300, seed, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return e.rules
+}
+
+// TestInjectRandomRulesSeededDeterministic 同一种子、写法与选项下规则文本逐字节相同，种子不同则不同
+func TestInjectRandomRulesSeededDeterministic(t *testing.T) {
+	plain := Syntax{Not: "!", And: "&&", Or: "||", Bool: "%s", In: "%[1]s in %[2]s", Set: "(%s)", Len: "len(%s)", FlatPaths: true}
+	options := map[string][]GenOptions{
+		"默认":    nil,
+		"判重与嵌套": {{Distinct: true, RepeatFactors: true, MaxDepth: 2, NotRate: 0.5}},
+		"额外语法":  {{TernaryRate: 0.3, CoalesceRate: 0.3, ArithRate: 0.3}},
+	}
+	for name, opts := range options {
+		for sname, syntax := range map[string]Syntax{"key": keySyntax, "plain": plain} {
+			if name == "额外语法" && sname == "plain" {
+				continue // plain 不支持三元式与 ??
+			}
+			first := injectRecorded(t, syntax, 42, opts...)
+			if len(first) != 300 {
+				t.Fatalf("%s/%s: 生成 %d 条", name, sname, len(first))
+			}
+			if again := injectRecorded(t, syntax, 42, opts...); !slices.Equal(again, first) {
+				t.Fatalf("%s/%s: 同一种子两次生成的规则不同", name, sname)
+			}
+			if other := injectRecorded(t, syntax, 43, opts...); slices.Equal(other, first) {
+				t.Fatalf("%s/%s: 不同种子生成了相同的规则", name, sname)
+			}
+		}
+	}
+}
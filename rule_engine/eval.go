@@ -0,0 +1,151 @@
+package rule_engine
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+/* ---------- 参考求值 ---------- */
+
+// Eval 不经任何后端、直接按规则树的语义对 input 求值，作为跨引擎对比的参考结果。
+// input 为 GenRandomInputsFrom 生成的原始形式（嵌套 map、time.Time、[]string），不经 AdaptInputs 转换。
+// and / or / 三元式从左到右短路求值，出错的子树使整条规则出错（各后端均按未命中处理）。
+// 因子缺失或为 nil 时：带 Coalesce 的 Int 叶子按 0 比较；不带算术修饰的 == / != 按不相等处理；
+// 其余比较、算术与 Bool 叶子出错。Int 因子的处理与 expr、govaluate 对 nil 值一致；
+// 已知的后端差异：govaluate 对输入中不存在的键一律报错，且 Bool 叶子写作 == true，nil 按 false 处理
+func (t *Tree) Eval(input map[string]interface{}) (bool, error) {
+	switch t.Op {
+	case "not":
+		ok, err := t.Left.Eval(input)
+		return !ok, err
+	case "and", "or":
+		ok, err := t.Left.Eval(input)
+		if err != nil || ok == (t.Op == "or") {
+			return ok, err
+		}
+		return t.Right.Eval(input)
+	case "cond":
+		ok, err := t.Cond.Eval(input)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return t.Left.Eval(input)
+		}
+		return t.Right.Eval(input)
+	}
+	return t.evalLeaf(input)
+}
+
+func (t *Tree) evalLeaf(input map[string]interface{}) (bool, error) {
+	name := t.Factor.Name
+	v, _ := lookupPath(input, name)
+	if v == nil {
+		switch {
+		case t.Coalesce:
+			v = 0
+		case t.Arith == "" && !t.Len && (t.Cmp == "==" || t.Cmp == "!="):
+			return t.Cmp == "!=", nil
+		default:
+			return false, fmt.Errorf("因子 %s 缺失", name)
+		}
+	}
+	switch t.Factor.Kind {
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return false, typeError(name, "bool", v)
+		}
+		return b, nil
+	case Int:
+		x, ok := v.(int)
+		if !ok {
+			return false, typeError(name, "int", v)
+		}
+		switch t.Arith {
+		case "% 2":
+			x %= 2
+		case "+ 1":
+			x++
+		}
+		return compare(t.Cmp, cmp.Compare(x, t.Value.(int)))
+	case Float:
+		x, ok := v.(float64)
+		if !ok {
+			return false, typeError(name, "float64", v)
+		}
+		// cmp.Compare 把 NaN 排在最小，这里按 IEEE 754：NaN 与任何值比较只有 != 成立
+		if math.IsNaN(x) {
+			return t.Cmp == "!=", nil
+		}
+		return compare(t.Cmp, cmp.Compare(x, t.Value.(float64)))
+	case Time:
+		x, ok := v.(time.Time)
+		if !ok {
+			return false, typeError(name, "time.Time", v)
+		}
+		return compare(t.Cmp, x.Compare(t.Value.(time.Time)))
+	case String:
+		x, ok := v.(string)
+		if !ok {
+			return false, typeError(name, "string", v)
+		}
+		if t.Cmp == "in" {
+			return slices.Contains(t.Value.([]string), x), nil
+		}
+		return compare(t.Cmp, strings.Compare(x, t.Value.(string)))
+	case List:
+		x, ok := v.([]string)
+		if !ok {
+			return false, typeError(name, "[]string", v)
+		}
+		if t.Len {
+			return compare(t.Cmp, cmp.Compare(len(x), t.Value.(int)))
+		}
+		return slices.Contains(x, t.Value.(string)), nil
+	}
+	return false, fmt.Errorf("因子 %s 的类型 %v 不受支持", name, t.Factor.Kind)
+}
+
+// compare 把三路比较结果 c 按运算符 op 转为布尔值
+func compare(op string, c int) (bool, error) {
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	}
+	return false, fmt.Errorf("不支持的比较运算符 %q", op)
+}
+
+func typeError(name, want string, v interface{}) error {
+	return fmt.Errorf("因子 %s 应为 %s，实际为 %T", name, want, v)
+}
+
+// lookupPath 按点路径从 row 取值，中间层不存在或不是 map 时返回 false
+func lookupPath(row map[string]interface{}, name string) (interface{}, bool) {
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			v, ok := row[name]
+			return v, ok
+		}
+		next, ok := row[head].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		row, name = next, rest
+	}
+}
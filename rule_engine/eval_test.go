@@ -0,0 +1,63 @@
+package rule_engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTreeEval(t *testing.T) {
+	n := Factor{Name: "user.n", Kind: Int}
+	b := Factor{Name: "b", Kind: Bool}
+	leaf := func(cmp string, v int) *Tree { return &Tree{Factor: n, Cmp: cmp, Value: v} }
+	cases := []struct {
+		name    string
+		tree    *Tree
+		input   map[string]interface{}
+		want    bool
+		wantErr bool
+	}{
+		{"比较", leaf(">", 3), map[string]interface{}{"user": map[string]interface{}{"n": 4}}, true, false},
+		{"nil ==", leaf("==", 3), map[string]interface{}{}, false, false},
+		{"nil !=", leaf("!=", 3), map[string]interface{}{}, true, false},
+		{"nil >", leaf(">", 3), map[string]interface{}{}, false, true},
+		{"??", &Tree{Factor: n, Cmp: "<", Value: 1, Coalesce: true}, map[string]interface{}{}, true, false},
+		{"% 2", &Tree{Factor: n, Cmp: "==", Value: 1, Arith: "% 2"}, map[string]interface{}{"user": map[string]interface{}{"n": 7}}, true, false},
+		{"+ 1", &Tree{Factor: n, Cmp: ">", Value: 7, Arith: "+ 1"}, map[string]interface{}{"user": map[string]interface{}{"n": 7}}, true, false},
+		{"nil + 1", &Tree{Factor: n, Cmp: ">", Value: 7, Arith: "+ 1"}, map[string]interface{}{}, false, true},
+		{"and 短路", &Tree{Op: "and", Left: &Tree{Factor: b}, Right: leaf(">", 3)}, map[string]interface{}{"b": false}, false, false},
+		{"or 短路", &Tree{Op: "or", Left: &Tree{Factor: b}, Right: leaf(">", 3)}, map[string]interface{}{"b": true}, true, false},
+		{"or 出错", &Tree{Op: "or", Left: &Tree{Factor: b}, Right: leaf(">", 3)}, map[string]interface{}{"b": false}, false, true},
+		{"三元式只求值选中的分支", &Tree{Op: "cond", Cond: &Tree{Factor: b}, Left: leaf("==", 3), Right: leaf(">", 3)},
+			map[string]interface{}{"b": true}, false, false},
+		{"not 传递错误", &Tree{Op: "not", Left: leaf(">", 3)}, map[string]interface{}{}, true, true},
+		{"NaN <", &Tree{Factor: Factor{Name: "f", Kind: Float}, Cmp: "<", Value: 1.0}, map[string]interface{}{"f": math.NaN()}, false, false},
+		{"NaN !=", &Tree{Factor: Factor{Name: "f", Kind: Float}, Cmp: "!=", Value: 1.0}, map[string]interface{}{"f": math.NaN()}, true, false},
+		{"类型不符", leaf(">", 3), map[string]interface{}{"user": map[string]interface{}{"n": "x"}}, false, true},
+	}
+	for _, c := range cases {
+		got, err := c.tree.Eval(c.input)
+		if (err != nil) != c.wantErr || (err == nil && got != c.want) {
+			t.Errorf("%s: %v, %v", c.name, got, err)
+		}
+	}
+}
+
+// TestRenderConstructs 三元式、?? 与算术修饰按 Syntax 输出；Syntax 不支持时注入报错
+func TestRenderConstructs(t *testing.T) {
+	n := Factor{Name: "n", Kind: Int}
+	tree := &Tree{Op: "cond", Cond: &Tree{Factor: Factor{Name: "b", Kind: Bool}},
+		Left:  &Tree{Factor: n, Cmp: "==", Value: 1, Arith: "% 2", Coalesce: true},
+		Right: &Tree{Factor: n, Cmp: ">", Value: 3, Arith: "+ 1"}}
+	if got, want := tree.Render(keySyntax), "((b) ? (n ?? 0) % 2 == 1 : n + 1 > 3)"; got != want {
+		t.Fatalf("Render = %s，应为 %s", got, want)
+	}
+	if c := tree.Complexity(); c.Factors != 3 || c.Nodes != 4 || c.Depth != 1 {
+		t.Fatalf("Complexity = %+v", c)
+	}
+	plain := Syntax{Not: "!", And: "&&", Or: "||", Bool: "%s", In: "%[1]s in %[2]s", Set: "[%s]", Len: "len(%s)"}
+	err := InjectRandomRulesSeeded(nil, plain, 1, 1, GenOptions{TernaryRate: 0.5})
+	if err == nil || !strings.Contains(err.Error(), "三元式") {
+		t.Fatalf("不支持三元式的写法应报错: %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package rule_engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/* ---------- 因子 ---------- */
+
+type Kind int
+
+const (
+	Bool Kind = iota
+	String
+	Int
+	Float // 取值为 float64，随机规则对其生成范围比较而非 ==
+	Time  // 取值为 time.Time，随机规则对其生成早于 / 晚于比较；表示方式见 Syntax.Time
+	List  // 取值为 []string，随机规则对其生成成员判断与长度比较；表示方式见 Syntax.AnyLists
+)
+
+var kindNames = [...]string{Bool: "bool", String: "string", Int: "int", Float: "float", Time: "time", List: "list"}
+
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// MarshalText 因子池文件中的类型写作 "bool" / "string" / "int" / "float" / "time" / "list"
+func (k Kind) MarshalText() ([]byte, error) {
+	if k < 0 || int(k) >= len(kindNames) {
+		return nil, fmt.Errorf("未知因子类型 %d", int(k))
+	}
+	return []byte(kindNames[k]), nil
+}
+
+func (k *Kind) UnmarshalText(text []byte) error {
+	for i, name := range kindNames {
+		if string(text) == name {
+			*k = Kind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("未知因子类型 %q，可选 %s", text, strings.Join(kindNames[:], " / "))
+}
+
+// Factor 描述一类可用于规则的因子
+type Factor struct {
+	Name         string        `json:"name" yaml:"name"`                                       // 变量名；含 "." 时为嵌套字段的点路径，见 IsNested
+	Kind         Kind          `json:"kind" yaml:"kind"`                                       // Bool / String / Int / Float / Time / List
+	SampleValues []interface{} `json:"sample_values,omitempty" yaml:"sample_values,omitempty"` // 用于生成比较常量与随机输入；Float / Time 为阈值，List 为若干 []string
+	Description  string        `json:"description,omitempty" yaml:"description,omitempty"`     // 因子含义，用于错误提示与工具展示
+	Example      interface{}   `json:"example,omitempty" yaml:"example,omitempty"`             // 示例值
+	// Enumerated 为 true 时 SampleValues 即完整取值域，静态检查据此判断规则能否命中
+	Enumerated bool `json:"enumerated,omitempty" yaml:"enumerated,omitempty"`
+	// Weight 生成随机规则时被选中的相对权重，0 取默认值 1；全部因子等权时均匀选取。不影响随机输入
+	Weight float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// Factors 内置因子池：随机规则、随机输入与各后端的静态检查共用这一份定义
+var Factors = []Factor{
+	// Bool
+	{Name: "is_vip", Kind: Bool, Description: "用户是否为 VIP", Example: true},
+	{Name: "blacklisted", Kind: Bool, Description: "用户是否在黑名单中", Example: false},
+	{Name: "email_verified", Kind: Bool, Description: "邮箱是否已验证", Example: true},
+	{Name: "high_risk_ip", Kind: Bool, Description: "请求 IP 是否被标记为高风险", Example: false},
+	// String
+	{Name: "env", Kind: String, SampleValues: []interface{}{"prod", "staging", "test_env"},
+		Description: "运行环境", Example: "prod", Enumerated: true},
+	{Name: "payment_method", Kind: String, SampleValues: []interface{}{"ABCD", "XYZ", "PAYPAL", "STRIPE"},
+		Description: "支付渠道", Example: "PAYPAL", Enumerated: true},
+	// Int
+	{Name: "user_id", Kind: Int, SampleValues: []interface{}{12345, 67890, 13579, 24680},
+		Description: "用户 ID", Example: 12345},
+	// Float
+	{Name: "amount", Kind: Float, SampleValues: []interface{}{9.99, 99.5, 250.0, 1000.0},
+		Description: "交易金额（元）", Example: 99.5},
+	{Name: "risk_score", Kind: Float, SampleValues: []interface{}{0.2, 0.5, 0.8, 0.95},
+		Description: "风控模型评分（0-1）", Example: 0.35},
+	// Time
+	{Name: "account_created_at", Kind: Time, SampleValues: []interface{}{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, Description: "账户注册时间", Example: time.Date(2024, 3, 15, 8, 30, 0, 0, time.UTC)},
+	// List
+	{Name: "tags", Kind: List, SampleValues: []interface{}{
+		[]string{}, []string{"beta"}, []string{"beta", "vip"}, []string{"new", "mobile", "promo"},
+		[]string{"beta", "mobile", "promo", "vip"},
+	}, Description: "用户标签", Example: []string{"beta", "vip"}},
+	// 嵌套字段
+	{Name: "user.country", Kind: String, SampleValues: []interface{}{"CN", "US", "DE", "JP"},
+		Description: "用户所在国家", Example: "CN", Enumerated: true},
+	{Name: "user.profile.level", Kind: Int, SampleValues: []interface{}{1, 2, 3, 5},
+		Description: "会员等级", Example: 2},
+}
+
+/* ---------- 因子池 ---------- */
+
+// FactorPool 一组因子，描述规则可以引用的输入。用于生成随机规则与输入时须先通过 Validate；
+// 只用于静态检查的因子池（如规则包的扩展因子）可以不给 SampleValues
+type FactorPool struct {
+	Factors []Factor `json:"factors" yaml:"factors"`
+}
+
+// DefaultFactorPool 返回内置的因子池
+func DefaultFactorPool() *FactorPool {
+	return &FactorPool{Factors: Factors}
+}
+
+// NewFactorPool 用 factors 构造因子池并校验，见 Validate
+func NewFactorPool(factors []Factor) (*FactorPool, error) {
+	p := &FactorPool{Factors: factors}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate 检查因子池能否用于生成随机规则与输入：至少一个因子，名称非空且不重复，类型已知，
+// Weight 为非负有限数，String / Int / Float / Time / List 因子的 SampleValues 非空且类型与因子一致；
+// 点路径的各段非空，且一个因子不能同时是另一个嵌套因子的上层对象（如 user 与 user.country）
+func (p *FactorPool) Validate() error {
+	if len(p.Factors) == 0 {
+		return errors.New("因子池为空")
+	}
+	seen := make(map[string]bool, len(p.Factors))
+	for i, f := range p.Factors {
+		if f.Name == "" {
+			return fmt.Errorf("第 %d 个因子缺少名称", i+1)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("因子 %s 重复", f.Name)
+		}
+		if slices.Contains(strings.Split(f.Name, "."), "") {
+			return fmt.Errorf("因子 %s 的路径含空段", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Weight < 0 || math.IsNaN(f.Weight) || math.IsInf(f.Weight, 0) {
+			return fmt.Errorf("因子 %s 的权重 %v 不是非负有限数", f.Name, f.Weight)
+		}
+		switch f.Kind {
+		case Bool:
+		case String, Int, Float, Time, List:
+			if len(f.SampleValues) == 0 {
+				return fmt.Errorf("因子 %s (%s) 缺少 SampleValues", f.Name, f.Kind)
+			}
+			for _, v := range f.SampleValues {
+				if !sampleMatchesKind(f.Kind, v) {
+					return fmt.Errorf("因子 %s (%s) 的 SampleValues 含类型不符的值 %v (%T)", f.Name, f.Kind, v, v)
+				}
+			}
+		default:
+			return fmt.Errorf("因子 %s 的类型 %s 不受支持", f.Name, f.Kind)
+		}
+	}
+	for _, f := range p.Factors {
+		for parent := f.Name; strings.Contains(parent, "."); {
+			parent = parent[:strings.LastIndexByte(parent, '.')]
+			if seen[parent] {
+				return fmt.Errorf("因子 %s 是嵌套因子 %s 的上层对象，不能同时作为取值", parent, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func sampleMatchesKind(k Kind, v interface{}) bool {
+	switch k {
+	case String:
+		_, ok := v.(string)
+		return ok
+	case Int:
+		_, ok := v.(int)
+		return ok
+	case Float:
+		_, ok := v.(float64)
+		return ok
+	case Time:
+		t, ok := v.(time.Time)
+		return ok && t.Nanosecond() == 0 // 须精确到秒，转换为 Unix 秒时不丢失信息
+	case List:
+		_, ok := v.([]string)
+		return ok
+	}
+	return false
+}
+
+// Lookup 按名称查找因子
+func (p *FactorPool) Lookup(name string) (Factor, bool) {
+	for _, f := range p.Factors {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Factor{}, false
+}
+
+/* ---------- 因子权重 ---------- */
+
+// weight 因子的实际权重，0 取默认值 1
+func (f Factor) weight() float64 {
+	if f.Weight == 0 {
+		return 1
+	}
+	return f.Weight
+}
+
+// uniform 全部因子的实际权重是否相同
+func (p *FactorPool) uniform() bool {
+	for _, f := range p.Factors {
+		if f.weight() != p.Factors[0].weight() {
+			return false
+		}
+	}
+	return true
+}
+
+// PickFactors 按 Weight 随机选取 n 个因子：repeat 为 false 时互不相同（n 不能超过因子数），
+// 否则有放回地选取。全部因子等权时与按 r.Perm / r.Intn 均匀选取的结果相同，即加入权重之前的行为
+func (p *FactorPool) PickFactors(r *rand.Rand, n int, repeat bool) []Factor {
+	factors := make([]Factor, n)
+	switch {
+	case p.uniform() && repeat:
+		for i := range factors {
+			factors[i] = p.Factors[r.Intn(len(p.Factors))]
+		}
+	case p.uniform():
+		for i, idx := range r.Perm(len(p.Factors))[:n] {
+			factors[i] = p.Factors[idx]
+		}
+	default:
+		// 逐个按剩余因子的权重抽取；不重复时抽中的因子从候选中移除
+		cands := slices.Clone(p.Factors)
+		for i := range factors {
+			total := 0.0
+			for _, f := range cands {
+				total += f.weight()
+			}
+			x, k := r.Float64()*total, len(cands)-1
+			for j, f := range cands {
+				if x < f.weight() {
+					k = j
+					break
+				}
+				x -= f.weight()
+			}
+			factors[i] = cands[k]
+			if !repeat {
+				cands = slices.Delete(cands, k, k+1)
+			}
+		}
+	}
+	return factors
+}
+
+// WithZipfWeights 返回因子池的副本，按因子顺序设置类 Zipf 分布的权重：第 i 个（从 1 起）因子的权重为 1/i^s。
+// s 越大，靠前的少数因子出现在越多规则中，更接近真实规则集的长尾分布；s 为 0 时等权
+func (p *FactorPool) WithZipfWeights(s float64) *FactorPool {
+	factors := slices.Clone(p.Factors)
+	for i := range factors {
+		factors[i].Weight = 1 / math.Pow(float64(i+1), s)
+	}
+	return &FactorPool{Factors: factors}
+}
+
+/* ---------- 因子池文件 ---------- */
+
+// LoadFactorPool 读取因子池文件并校验；按扩展名区分格式：.json 或 .yaml / .yml。
+// 文件为 {"factors": [{"name": ..., "kind": "bool|string|int|float|time|list", "sample_values": [...]}, ...]}，
+// 其余字段（description / example / enumerated / weight）可选
+func LoadFactorPool(path string) (*FactorPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = "json"
+	case ".yaml", ".yml":
+		format = "yaml"
+	default:
+		return nil, fmt.Errorf("无法从扩展名判断因子池格式: %s（应为 .json / .yaml / .yml）", path)
+	}
+	p, err := ParseFactorPool(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// ParseFactorPool 按 format（"json" / "yaml"）解析因子池并校验。
+// JSON 数字统一解码为 float64，YAML 的整数解码为 int；Int 因子的整数取值转换为 int，
+// Float 因子的取值转换为 float64，Time 因子的 RFC 3339 字符串转换为 time.Time，
+// List 因子的字符串数组转换为 []string，与内置因子池一致
+func ParseFactorPool(data []byte, format string) (*FactorPool, error) {
+	var p FactorPool
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("解析因子池失败: %w", err)
+		}
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("解析因子池失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("未知因子池格式 %q，可选 json / yaml", format)
+	}
+	for i := range p.Factors {
+		f := &p.Factors[i]
+		switch f.Kind {
+		case Int:
+			for j, v := range f.SampleValues {
+				if x, ok := v.(float64); ok && x == math.Trunc(x) && math.Abs(x) <= 1<<53 {
+					f.SampleValues[j] = int(x)
+				}
+			}
+			if x, ok := f.Example.(float64); ok && x == math.Trunc(x) {
+				f.Example = int(x)
+			}
+		case Float:
+			for j, v := range f.SampleValues {
+				if n, ok := v.(int); ok {
+					f.SampleValues[j] = float64(n)
+				}
+			}
+			if n, ok := f.Example.(int); ok {
+				f.Example = float64(n)
+			}
+		case Time:
+			for j, v := range f.SampleValues {
+				if s, ok := v.(string); ok {
+					t, err := time.Parse(time.RFC3339, s)
+					if err != nil {
+						return nil, fmt.Errorf("因子 %s 的取值 %q 不是 RFC 3339 时间", f.Name, s)
+					}
+					f.SampleValues[j] = t
+				}
+			}
+			if s, ok := f.Example.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					f.Example = t
+				}
+			}
+		case List:
+			for j, v := range f.SampleValues {
+				if l, ok := stringList(v); ok {
+					f.SampleValues[j] = l
+				}
+			}
+			if l, ok := stringList(f.Example); ok {
+				f.Example = l
+			}
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// stringList 把解码得到的 []interface{}（元素均为字符串）转换为 []string
+func stringList(v interface{}) ([]string, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		if out[i], ok = item.(string); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+/* ---------- 未知因子提示 ---------- */
+
+// Suggest 按编辑距离返回与 name 最接近的因子（距离不超过 maxDist），距离相同按名称排序
+func (p *FactorPool) Suggest(name string, maxDist int) []Factor {
+	type candidate struct {
+		f    Factor
+		dist int
+	}
+	var cands []candidate
+	for _, f := range p.Factors {
+		if d := editDistance(name, f.Name); d <= maxDist {
+			cands = append(cands, candidate{f, d})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].dist != cands[j].dist {
+			return cands[i].dist < cands[j].dist
+		}
+		return cands[i].f.Name < cands[j].f.Name
+	})
+	out := make([]Factor, len(cands))
+	for i, c := range cands {
+		out[i] = c.f
+	}
+	return out
+}
+
+// UnknownFactorMessage 生成未知因子的提示，附带最接近的候选及其说明
+func (p *FactorPool) UnknownFactorMessage(name string) string {
+	cands := p.Suggest(name, 2)
+	if len(cands) == 0 {
+		return fmt.Sprintf("未知因子 %s", name)
+	}
+	hints := make([]string, len(cands))
+	for i, f := range cands {
+		hints[i] = fmt.Sprintf("%s（%s）", f.Name, f.Description)
+	}
+	return fmt.Sprintf("未知因子 %s，是否想用 %s", name, strings.Join(hints, "、"))
+}
+
+// editDistance Levenshtein 距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,76 @@
+package rule_engine
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestPickFactorsWeighted 有放回抽取 10000 次，各因子的频率与权重占比之差在 5 个标准差以内；
+// 不重复抽取时每个因子至多出现一次
+func TestPickFactorsWeighted(t *testing.T) {
+	const draws = 10000
+	p := (&FactorPool{Factors: Factors[:5]}).WithZipfWeights(1)
+	total := 0.0
+	for _, f := range p.Factors {
+		total += f.Weight
+	}
+	counts := make(map[string]int)
+	for _, f := range p.PickFactors(rand.New(rand.NewSource(1)), draws, true) {
+		counts[f.Name]++
+	}
+	for _, f := range p.Factors {
+		want := f.Weight / total
+		sigma := math.Sqrt(want * (1 - want) / draws)
+		if got := float64(counts[f.Name]) / draws; math.Abs(got-want) > 5*sigma {
+			t.Errorf("%s: 频率 %.4f，权重占比 %.4f（容差 %.4f）", f.Name, got, want, 5*sigma)
+		}
+	}
+
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		seen := make(map[string]bool)
+		for _, f := range p.PickFactors(r, len(p.Factors), false) {
+			if seen[f.Name] {
+				t.Fatalf("不重复抽取时 %s 出现了两次", f.Name)
+			}
+			seen[f.Name] = true
+		}
+	}
+}
+
+// TestPickFactorsUniformSequence 等权时与加入权重之前按 r.Perm / r.Intn 选取的序列一致；
+// 全部权重相同但不为 1 时同样走均匀路径
+func TestPickFactorsUniformSequence(t *testing.T) {
+	weighted := slices.Clone(Factors)
+	for i := range weighted {
+		weighted[i].Weight = 3
+	}
+	pools := map[string]*FactorPool{
+		"未设权重":   DefaultFactorPool(),
+		"权重均为 3": {Factors: weighted},
+	}
+	for name, p := range pools {
+		for _, repeat := range []bool{false, true} {
+			got, want := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+			for round := 0; round < 50; round++ {
+				n := 1 + round%len(p.Factors)
+				picked := p.PickFactors(got, n, repeat)
+				var idx []int
+				if repeat {
+					for i := 0; i < n; i++ {
+						idx = append(idx, want.Intn(len(p.Factors)))
+					}
+				} else {
+					idx = want.Perm(len(p.Factors))[:n]
+				}
+				for i, k := range idx {
+					if picked[i].Name != p.Factors[k].Name {
+						t.Fatalf("%s repeat=%v 第 %d 轮第 %d 个: %s，应为 %s", name, repeat, round, i, picked[i].Name, p.Factors[k].Name)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package rule_engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+/* ---------- 随机规则的生成参数 ---------- */
+
+// WeightedOp 带相对权重的比较运算符
+type WeightedOp struct {
+	Op     string
+	Weight int
+}
+
+// GenOptions 随机规则的结构与叶子运算符分布，零值取默认
+type GenOptions struct {
+	// MinFactors / MaxFactors 每条规则的叶子数在其间均匀选取，默认 1 与 5（RandomTreeFrom 的 maxFactors 参数）。
+	// 不允许重复因子时以因子池大小为上限
+	MinFactors, MaxFactors int
+	// RepeatFactors 允许同一规则多次引用同一因子（有放回地选取），叶子数因此可以超过因子池大小
+	RepeatFactors bool
+	// MaxDepth and / or 的最大嵌套层数（not 不计），0 表示不限；叶子数以 2^MaxDepth 为上限
+	MaxDepth int
+	NotRate  float64 // 叶子取反的概率，默认 0.3；小于 0 表示从不取反
+	OrRate   float64 // 内部节点为 or（否则为 and）的概率，默认 0.5；小于 0 表示全为 and
+	// Distinct 跳过与之前某条规则相同的规则，继续生成直到得到 count 条互不相同的规则，见 RandomTreesReport
+	Distinct bool
+
+	// TernaryRate 三个及以上叶子时以首个叶子为条件生成布尔三元式的概率，MaxDepth 非零时不生成。
+	// TernaryRate / CoalesceRate / ArithRate 默认 0 即不生成，此时不消耗随机数，同一种子生成的规则与不设时相同；
+	// 非零时须后端的 Syntax 支持对应写法
+	TernaryRate  float64
+	CoalesceRate float64 // Int 叶子写成 (x ?? 0) 的概率
+	ArithRate    float64 // Int 叶子比较前经 % 2 或 + 1 修饰的概率
+
+	// IntOps Int 因子可用的比较（== != > >= < <=）及权重，默认六种等权
+	IntOps []WeightedOp
+	// StringOps String 因子可用的比较（== != in）及权重，默认 == 占一半，!= 与集合成员 in 各占四分之一
+	StringOps []WeightedOp
+	// RandomRate Int 常量取随机 5 位数而非样例值的概率，默认 0.3；小于 0 表示总取样例值
+	RandomRate float64
+	SetSize    int // in 集合最多包含的样例值个数，默认 3
+
+	// Logger 注入随机规则时的输出，nil 时不输出；*log.Logger 可直接使用
+	Logger Logger
+	// Verbose 为 true 时每编译成功一条规则输出一行；编译失败总是通过返回的错误报告
+	Verbose bool
+}
+
+// Logger 注入随机规则时的输出，每次调用一行，format 不含结尾换行
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// logger 返回 Logger，nil 时为不输出的默认值
+func (o GenOptions) logger() Logger {
+	if o.Logger == nil {
+		return nopLogger{}
+	}
+	return o.Logger
+}
+
+// EqualityOnly 只生成 == 且常量总取样例值，即加入运算符分布之前的规则形态，便于对比
+var EqualityOnly = GenOptions{
+	IntOps:     []WeightedOp{{"==", 1}},
+	StringOps:  []WeightedOp{{"==", 1}},
+	RandomRate: -1,
+}
+
+var (
+	intOps    = []string{"==", "!=", ">", ">=", "<", "<="}
+	stringOps = []string{"==", "!=", "in"}
+)
+
+func (o GenOptions) withDefaults() GenOptions {
+	if o.MaxFactors <= 0 {
+		o.MaxFactors = 5
+	}
+	o.NotRate = rateDefault(o.NotRate, 0.3)
+	o.OrRate = rateDefault(o.OrRate, 0.5)
+	if len(o.IntOps) == 0 {
+		for _, op := range intOps {
+			o.IntOps = append(o.IntOps, WeightedOp{op, 1})
+		}
+	}
+	if len(o.StringOps) == 0 {
+		o.StringOps = []WeightedOp{{"==", 2}, {"!=", 1}, {"in", 1}}
+	}
+	if o.RandomRate == 0 {
+		o.RandomRate = 0.3
+	}
+	if o.SetSize <= 0 {
+		o.SetSize = 3
+	}
+	return o
+}
+
+// rateDefault 概率参数：0 取默认值 def，小于 0 表示 0
+func rateDefault(v, def float64) float64 {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return 0
+	}
+	return v
+}
+
+// Validate 检查叶子数与深度参数非负且 MinFactors 不大于 MaxFactors、概率不大于 1，
+// 运算符受支持、权重非负且每组至少一个正权重
+func (o GenOptions) Validate() error {
+	switch {
+	case o.MinFactors < 0 || o.MaxFactors < 0 || o.MaxDepth < 0:
+		return errors.New("MinFactors / MaxFactors / MaxDepth 不能为负")
+	case o.MaxFactors > 0 && o.MinFactors > o.MaxFactors:
+		return fmt.Errorf("MinFactors %d 大于 MaxFactors %d", o.MinFactors, o.MaxFactors)
+	case o.NotRate > 1 || o.OrRate > 1:
+		return errors.New("NotRate / OrRate 不能大于 1")
+	case o.TernaryRate > 1 || o.CoalesceRate > 1 || o.ArithRate > 1:
+		return errors.New("TernaryRate / CoalesceRate / ArithRate 不能大于 1")
+	}
+	o = o.withDefaults()
+	if err := validateOps("IntOps", o.IntOps, intOps); err != nil {
+		return err
+	}
+	return validateOps("StringOps", o.StringOps, stringOps)
+}
+
+func validateOps(field string, ops []WeightedOp, allowed []string) error {
+	total := 0
+	for _, w := range ops {
+		if !slices.Contains(allowed, w.Op) {
+			return fmt.Errorf("%s 含不支持的运算符 %q，可选 %v", field, w.Op, allowed)
+		}
+		if w.Weight < 0 {
+			return fmt.Errorf("%s 中 %s 的权重为负", field, w.Op)
+		}
+		total += w.Weight
+	}
+	if total == 0 {
+		return errors.New(field + " 的权重之和为 0")
+	}
+	return nil
+}
+
+func pickOp(r *rand.Rand, ops []WeightedOp) string {
+	total := 0
+	for _, w := range ops {
+		total += w.Weight
+	}
+	n := r.Intn(total)
+	for _, w := range ops {
+		if n < w.Weight {
+			return w.Op
+		}
+		n -= w.Weight
+	}
+	return ops[len(ops)-1].Op
+}
+
+// IntCmp 为 Int 因子 f 随机选取比较运算符与常量；o 须已通过 Validate
+func (o GenOptions) IntCmp(r *rand.Rand, f Factor) (op string, v int) {
+	o = o.withDefaults()
+	op = pickOp(r, o.IntOps)
+	if o.RandomRate > 0 && r.Float64() < o.RandomRate {
+		return op, r.Intn(90000) + 10000 // 与 RandomValue 的随机取值同一范围
+	}
+	return op, f.SampleValues[r.Intn(len(f.SampleValues))].(int)
+}
+
+// StringCmp 为 String 因子 f 随机选取比较运算符与常量：== / != 的常量为样例值，
+// in 的常量为 2 ~ SetSize 个不同样例值组成的 []string（样例值不足时取全部）；o 须已通过 Validate
+func (o GenOptions) StringCmp(r *rand.Rand, f Factor) (op string, v interface{}) {
+	o = o.withDefaults()
+	op = pickOp(r, o.StringOps)
+	if op != "in" {
+		return op, f.SampleValues[r.Intn(len(f.SampleValues))].(string)
+	}
+	hi := min(o.SetSize, len(f.SampleValues))
+	lo := min(2, hi)
+	set := make([]string, 0, hi)
+	for _, i := range r.Perm(len(f.SampleValues))[:lo+r.Intn(hi-lo+1)] {
+		set = append(set, f.SampleValues[i].(string))
+	}
+	return op, set
+}
+
+/* ---------- 规则复杂度 ---------- */
+
+// Complexity 一条规则实际生成的复杂度，用于按复杂度分组比较基准结果
+type Complexity struct {
+	Factors int // 叶子数；允许重复因子时同一因子按出现次数计
+	Nodes   int // 节点总数：叶子与 and / or / not / cond
+	Depth   int // and / or / cond 的最大嵌套层数，单个叶子为 0
+}
+
+// Complexity 统计规则树的复杂度
+func (t *Tree) Complexity() Complexity {
+	switch t.Op {
+	case "":
+		return Complexity{Factors: 1, Nodes: 1}
+	case "not":
+		c := t.Left.Complexity()
+		c.Nodes++
+		return c
+	case "cond":
+		c, l, r := t.Cond.Complexity(), t.Left.Complexity(), t.Right.Complexity()
+		return Complexity{Factors: c.Factors + l.Factors + r.Factors, Nodes: c.Nodes + l.Nodes + r.Nodes + 1,
+			Depth: max(c.Depth, l.Depth, r.Depth) + 1}
+	}
+	l, r := t.Left.Complexity(), t.Right.Complexity()
+	return Complexity{Factors: l.Factors + r.Factors, Nodes: l.Nodes + r.Nodes + 1, Depth: max(l.Depth, r.Depth) + 1}
+}
+
+// complexityBuckets 按叶子数分档的上界（含）与名称，最后一档不设上界
+var complexityBuckets = []struct {
+	upTo int
+	name string
+}{{1, "1"}, {3, "2-3"}, {5, "4-5"}, {10, "6-10"}, {20, "11-20"}, {math.MaxInt, "21+"}}
+
+// ComplexityBuckets 全部分档名称，按叶子数从少到多
+func ComplexityBuckets() []string {
+	names := make([]string, len(complexityBuckets))
+	for i, b := range complexityBuckets {
+		names[i] = b.name
+	}
+	return names
+}
+
+// Bucket 按叶子数所在的分档，见 ComplexityBuckets
+func (c Complexity) Bucket() string {
+	for _, b := range complexityBuckets {
+		if c.Factors <= b.upTo {
+			return b.name
+		}
+	}
+	return complexityBuckets[len(complexityBuckets)-1].name
+}
@@ -0,0 +1,72 @@
+package rule_engine
+
+import "strings"
+
+/* ---------- 嵌套因子 ---------- */
+
+// 因子名含 "." 时表示嵌套字段：因子 "user.country" 在输入中为 {"user": {"country": ...}}，
+// 表达式中写作 user.country（各后端的写法见 Syntax.Path 与 Syntax.FlatPaths）
+
+// IsNested 因子名是否为点路径
+func IsNested(name string) bool {
+	return strings.Contains(name, ".")
+}
+
+// SetPath 按点路径把 v 写入 row；中间层不存在或不是 map 时新建一层
+func SetPath(row map[string]interface{}, name string, v interface{}) {
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			row[name] = v
+			return
+		}
+		next, ok := row[head].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			row[head] = next
+		}
+		row, name = next, rest
+	}
+}
+
+// LookupPath 按点路径取值；任一层缺失或不是 map 时返回 false
+func LookupPath(row map[string]interface{}, name string) (interface{}, bool) {
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			v, ok := row[name]
+			return v, ok
+		}
+		if row, ok = row[head].(map[string]interface{}); !ok {
+			return nil, false
+		}
+		name = rest
+	}
+}
+
+// DeletePath 按点路径删除字段，保留中间层；路径不存在时什么也不做
+func DeletePath(row map[string]interface{}, name string) {
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			delete(row, name)
+			return
+		}
+		if row, ok = row[head].(map[string]interface{}); !ok {
+			return
+		}
+		name = rest
+	}
+}
+
+// CloneRow 复制一行输入，嵌套的 map 逐层复制，其余值（包括列表）共享
+func CloneRow(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = CloneRow(m)
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package rule_engine
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+/* ---------- 跨引擎一致性校验 ---------- */
+
+// Target 参与校验的一个引擎及其写法
+type Target struct {
+	Name   string
+	Engine Engine
+	Syntax Syntax
+}
+
+// RuleDiff 某个输入上各引擎结论不一致的一条规则
+type RuleDiff struct {
+	ID    string
+	Exprs []string // 各引擎加载的表达式，与 VerifyReport.Targets 顺序一致
+	HitBy []string // 命中该规则的引擎名
+}
+
+// Mismatch 各引擎命中集合不同的一个输入
+type Mismatch struct {
+	InputIndex int
+	Input      map[string]interface{}
+	Rules      []RuleDiff // 按规则 ID 排序
+}
+
+// VerifyReport 一次校验的结果；Mismatches 为空表示全部引擎在全部输入上命中相同
+type VerifyReport struct {
+	Targets    []string
+	Seed       int64
+	Rules      int
+	Inputs     int
+	Mismatches []Mismatch
+}
+
+// Verify 用 seed 生成 ruleCount 条与写法无关的随机规则（见 RandomTree），按各引擎的 Syntax 翻译后
+// 以 verify-1 起的 ID 加入每个引擎，再用同一批 inputs 匹配，报告命中集合不同的输入及相关规则。
+// targets 应为空引擎；任一引擎拒绝翻译后的规则时返回错误，这本身说明翻译有问题。
+// 输入按各引擎的 Syntax 经 AdaptInputs 转换时间与列表表示后再匹配，报告中的 Input 为转换前的原始输入
+func Verify(targets []Target, seed int64, ruleCount int, inputs []map[string]interface{}) (VerifyReport, error) {
+	rep := VerifyReport{Seed: seed, Rules: ruleCount, Inputs: len(inputs)}
+	for _, t := range targets {
+		rep.Targets = append(rep.Targets, t.Name)
+	}
+	exprs := make(map[string][]string, ruleCount) // 规则 ID -> 各引擎的表达式
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < ruleCount; i++ {
+		id := fmt.Sprintf("verify-%d", i+1)
+		tree := RandomTree(r, 5)
+		for _, t := range targets {
+			s := tree.Render(t.Syntax)
+			if err := t.Engine.AddRule(id, s); err != nil {
+				return rep, fmt.Errorf("%s 编译规则 %s 失败: %s: %w", t.Name, id, s, err)
+			}
+			exprs[id] = append(exprs[id], s)
+		}
+	}
+
+	adapted := make([][]map[string]interface{}, len(targets))
+	for k, t := range targets {
+		adapted[k] = AdaptInputs(t.Syntax, inputs)
+	}
+	for i, in := range inputs {
+		hitBy := make(map[string][]string) // 规则 ID -> 命中的引擎
+		for k, t := range targets {
+			for _, id := range t.Engine.Match(adapted[k][i]) {
+				hitBy[id] = append(hitBy[id], t.Name)
+			}
+		}
+		var diffs []RuleDiff
+		for id, names := range hitBy {
+			if len(names) != len(targets) {
+				diffs = append(diffs, RuleDiff{ID: id, Exprs: exprs[id], HitBy: names})
+			}
+		}
+		if diffs != nil {
+			slices.SortFunc(diffs, func(a, b RuleDiff) int { return compareIDs(a.ID, b.ID) })
+			rep.Mismatches = append(rep.Mismatches, Mismatch{InputIndex: i, Input: in, Rules: diffs})
+		}
+	}
+	return rep, nil
+}
+
+// compareIDs 同前缀的 ID 按数值顺序排列：verify-9 在 verify-10 之前
+func compareIDs(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+}
@@ -0,0 +1,225 @@
+package rule_expr
+
+import (
+	"sort"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+/* ---------- AST 分析 ---------- */
+
+// comparison 规则中 "因子 op 常量" 形式的叶子比较
+type comparison struct {
+	Factor   string
+	Operator string
+	Value    interface{}
+}
+
+// ruleShape 一条表达式中可静态提取的信息
+type ruleShape struct {
+	Identifiers []string     // 引用到的因子，去重并排序；成员访问 user.country 记为点路径 "user.country"
+	Operators   []string     // 用到的运算符，去重并排序
+	Comparisons []comparison // 因子与常量的比较
+	// Required 顶层 and 连接的等值约束，任一不成立规则即不命中：因子 == 非 nil 常量，裸因子记为 == true，
+	// 因子 in 常量数组记为 in（Value 为 []interface{}）；not / or 之下的比较不是必要条件，不在其中
+	Required []comparison
+	// Canonical 规范形式的表达式文本，见 canonicalExpr
+	Canonical string
+}
+
+// analyzeExpr 解析表达式并提取因子与常量比较
+func analyzeExpr(exprStr string) (*ruleShape, error) {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return nil, err
+	}
+	inner := make(innerMembers)
+	ast.Walk(&tree.Node, inner)
+	v := &shapeVisitor{seen: make(map[string]bool), seenOp: make(map[string]bool), inner: inner}
+	ast.Walk(&tree.Node, v)
+	sort.Strings(v.shape.Identifiers)
+	sort.Strings(v.shape.Operators)
+	v.shape.Required = requiredEqualities(tree.Node, nil)
+	ast.Walk(&tree.Node, canonicalizer{}) // 改写语法树，须在以上分析之后
+	v.shape.Canonical = tree.Node.String()
+	return &v.shape, nil
+}
+
+type shapeVisitor struct {
+	shape  ruleShape
+	seen   map[string]bool
+	seenOp map[string]bool
+	inner  innerMembers
+}
+
+// innerMembers 点路径内部的节点：user.profile.level 中的 user 与 user.profile。
+// ast.Walk 先访问子节点，因此须先单独遍历一遍标记出来，避免把路径前缀也记为因子
+type innerMembers map[ast.Node]bool
+
+func (m innerMembers) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.MemberNode); ok {
+		if _, ok := factorPath(n); ok {
+			m[n.Node] = true
+		}
+	}
+}
+
+// factorPath 因子引用的点路径：标识符本身，或由标识符与常量字符串成员组成的访问链
+func factorPath(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		return n.Value, true
+	case *ast.MemberNode:
+		prop, ok := n.Property.(*ast.StringNode)
+		if !ok {
+			return "", false
+		}
+		base, ok := factorPath(n.Node)
+		if !ok {
+			return "", false
+		}
+		return base + "." + prop.Value, true
+	}
+	return "", false
+}
+
+func (v *shapeVisitor) addIdent(name string) {
+	if !v.seen[name] {
+		v.seen[name] = true
+		v.shape.Identifiers = append(v.shape.Identifiers, name)
+	}
+}
+
+// opAliases 把符号写法统一成关键字写法
+var opAliases = map[string]string{"&&": "and", "||": "or", "!": "not"}
+
+func (v *shapeVisitor) addOp(op string) {
+	if alias, ok := opAliases[op]; ok {
+		op = alias
+	}
+	if !v.seenOp[op] {
+		v.seenOp[op] = true
+		v.shape.Operators = append(v.shape.Operators, op)
+	}
+}
+
+func (v *shapeVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		if !v.inner[n] {
+			v.addIdent(n.Value)
+		}
+	case *ast.MemberNode:
+		if path, ok := factorPath(n); ok && !v.inner[n] {
+			v.addIdent(path)
+		}
+	case *ast.UnaryNode:
+		v.addOp(n.Operator)
+	case *ast.BinaryNode:
+		v.addOp(n.Operator)
+		if c, ok := factorComparison(n); ok {
+			v.shape.Comparisons = append(v.shape.Comparisons, c)
+		}
+	}
+}
+
+// factorComparison 识别 "因子 op 常量" 或 "常量 op 因子"
+func factorComparison(n *ast.BinaryNode) (comparison, bool) {
+	if id, ok := factorPath(n.Left); ok {
+		if lit, ok := literalValue(n.Right); ok {
+			return comparison{id, n.Operator, lit}, true
+		}
+	} else if id, ok := factorPath(n.Right); ok {
+		if lit, ok := literalValue(n.Left); ok {
+			return comparison{id, n.Operator, lit}, true
+		}
+	}
+	return comparison{}, false
+}
+
+// setComparison 识别 "因子 in [常量, ...]"；数组含 nil 或非常量元素时返回 false
+func setComparison(n *ast.BinaryNode) (comparison, bool) {
+	id, ok := factorPath(n.Left)
+	arr, isArr := n.Right.(*ast.ArrayNode)
+	if !ok || !isArr {
+		return comparison{}, false
+	}
+	values := make([]interface{}, len(arr.Nodes))
+	for i, node := range arr.Nodes {
+		lit, ok := literalValue(node)
+		if !ok || lit == nil {
+			return comparison{}, false
+		}
+		values[i] = lit
+	}
+	return comparison{id, "in", values}, true
+}
+
+// requiredEqualities 沿顶层 and 链收集等值约束，追加到 out
+func requiredEqualities(node ast.Node, out []comparison) []comparison {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "and", "&&":
+			return requiredEqualities(n.Right, requiredEqualities(n.Left, out))
+		case "==":
+			// x == nil 在 x 缺失时成立，不能作为索引条件
+			if c, ok := factorComparison(n); ok && c.Value != nil {
+				out = append(out, c)
+			}
+		case "in":
+			if c, ok := setComparison(n); ok {
+				out = append(out, c)
+			}
+		}
+	case *ast.IdentifierNode, *ast.MemberNode:
+		// 裸因子作为条件时只有取值为 true 才命中
+		if path, ok := factorPath(n); ok {
+			out = append(out, comparison{path, "==", true})
+		}
+	}
+	return out
+}
+
+// literalValue 取常量节点的 Go 值
+func literalValue(node ast.Node) (interface{}, bool) {
+	switch n := node.(type) {
+	case *ast.StringNode:
+		return n.Value, true
+	case *ast.IntegerNode:
+		return n.Value, true
+	case *ast.FloatNode:
+		return n.Value, true
+	case *ast.BoolNode:
+		return n.Value, true
+	case *ast.NilNode:
+		return nil, true
+	}
+	return nil, false
+}
+
+// canonicalExpr 把表达式规范化为统一写法：去掉多余括号与空白，&&/||/! 统一为 and/or/not
+func canonicalExpr(exprStr string) (string, error) {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return "", err
+	}
+	ast.Walk(&tree.Node, canonicalizer{})
+	return tree.Node.String(), nil
+}
+
+type canonicalizer struct{}
+
+func (canonicalizer) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.UnaryNode:
+		if alias, ok := opAliases[n.Operator]; ok {
+			n.Operator = alias
+		}
+	case *ast.BinaryNode:
+		if alias, ok := opAliases[n.Operator]; ok {
+			n.Operator = alias
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package rule_expr
+
+import (
+	"errors"
+	"sync"
+)
+
+/* ---------- 异步匹配 ---------- */
+
+var (
+	ErrQueueFull   = errors.New("匹配队列已满")
+	ErrAsyncClosed = errors.New("异步引擎已关闭")
+	ErrCancelled   = errors.New("匹配请求已取消")
+)
+
+// MatchFuture 一次异步匹配的结果句柄
+type MatchFuture struct {
+	owner *AsyncEngine
+	input map[string]interface{}
+	done  chan struct{}
+	hits  []string
+	err   error
+}
+
+// Done 在结果就绪（完成或取消）时关闭
+func (f *MatchFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result 阻塞等待并返回命中 ID
+func (f *MatchFuture) Result() ([]string, error) {
+	<-f.done
+	return f.hits, f.err
+}
+
+// Cancel 若请求尚未开始执行，将其移出队列并返回 true；已开始或已完成则返回 false
+func (f *MatchFuture) Cancel() bool {
+	a := f.owner
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, q := range a.queue {
+		if q == f {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			f.err = ErrCancelled
+			close(f.done)
+			return true
+		}
+	}
+	return false
+}
+
+// AsyncEngine 在 RuleEngine 之上提供有界队列 + 固定 worker 的异步匹配
+type AsyncEngine struct {
+	engine   *RuleEngine
+	capacity int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*MatchFuture
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// DefaultAsyncCapacity NewAsyncEngine 的 capacity <= 0 时使用的队列容量
+const DefaultAsyncCapacity = 1024
+
+// NewAsyncEngine 创建并启动 workers 个 worker（<= 0 取 1），队列最多容纳 capacity 个未开始的请求
+// （<= 0 取 DefaultAsyncCapacity）。engine.Close 会先排空该队列再关闭持久化后端
+func NewAsyncEngine(engine *RuleEngine, workers, capacity int) *AsyncEngine {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = DefaultAsyncCapacity
+	}
+	a := &AsyncEngine{engine: engine, capacity: capacity}
+	a.cond = sync.NewCond(&a.mu)
+	for i := 0; i < workers; i++ {
+		a.wg.Add(1)
+		go a.worker()
+	}
+	engine.attachAsync(a)
+	return a
+}
+
+// SubmitMatch 提交一次匹配；队列满时返回 ErrQueueFull，关闭后返回 ErrAsyncClosed
+func (a *AsyncEngine) SubmitMatch(input map[string]interface{}) (*MatchFuture, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil, ErrAsyncClosed
+	}
+	if len(a.queue) >= a.capacity {
+		return nil, ErrQueueFull
+	}
+	f := &MatchFuture{owner: a, input: input, done: make(chan struct{})}
+	a.queue = append(a.queue, f)
+	a.cond.Signal()
+	return f, nil
+}
+
+// Close 停止接收新请求，等待队列中已提交的请求执行完毕后返回
+func (a *AsyncEngine) Close() {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+func (a *AsyncEngine) worker() {
+	defer a.wg.Done()
+	for {
+		a.mu.Lock()
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 {
+			a.mu.Unlock()
+			return
+		}
+		f := a.queue[0]
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		a.mu.Unlock()
+
+		f.hits = a.engine.Match(f.input)
+		close(f.done)
+	}
+}
@@ -0,0 +1,137 @@
+package rule_expr
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// blockingEngine 返回一个执行规则时会阻塞的引擎：规则对 {"x": "a"} 执行出错，
+// 出错回调在匹配协程中同步调用，先通知 started 再等待 gate 关闭
+func blockingEngine(t *testing.T) (re *RuleEngine, started chan struct{}, gate chan struct{}) {
+	t.Helper()
+	re = NewRuleEngine()
+	if err := re.AddRule("cmp", "x > 1"); err != nil {
+		t.Fatal(err)
+	}
+	started, gate = make(chan struct{}, 16), make(chan struct{})
+	re.SetEvalErrorHandler(func(string, error) {
+		started <- struct{}{}
+		<-gate
+	})
+	return re, started, gate
+}
+
+var blockingInput = map[string]interface{}{"x": "a"}
+
+func TestAsyncQueueSaturationAndCancel(t *testing.T) {
+	re, started, gate := blockingEngine(t)
+	a := NewAsyncEngine(re, 1, 2)
+	defer a.Close()
+
+	running, err := a.SubmitMatch(blockingInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started // 唯一的 worker 已取走第一个请求并阻塞
+	queued1, err1 := a.SubmitMatch(blockingInput)
+	queued2, err2 := a.SubmitMatch(map[string]interface{}{"x": 5})
+	if err1 != nil || err2 != nil {
+		t.Fatalf("队列未满时提交失败: %v, %v", err1, err2)
+	}
+	if _, err := a.SubmitMatch(blockingInput); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("队列已满时 err = %v，应为 ErrQueueFull", err)
+	}
+
+	if !queued1.Cancel() {
+		t.Fatal("尚未开始的请求应能取消")
+	}
+	select {
+	case <-queued1.Done():
+	default:
+		t.Fatal("取消后 Done 应已关闭")
+	}
+	if hits, err := queued1.Result(); !errors.Is(err, ErrCancelled) || hits != nil {
+		t.Fatalf("取消的请求 Result = %v, %v", hits, err)
+	}
+	if running.Cancel() {
+		t.Fatal("已开始的请求不能取消")
+	}
+	// 取消腾出了一个位置
+	if _, err := a.SubmitMatch(map[string]interface{}{"x": 0}); err != nil {
+		t.Fatalf("取消后应能再提交: %v", err)
+	}
+
+	close(gate)
+	if hits, err := queued2.Result(); err != nil || !slices.Equal(hits, []string{"cmp"}) {
+		t.Fatalf("queued2 Result = %v, %v", hits, err)
+	}
+	if _, err := running.Result(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncDefaultCapacity(t *testing.T) {
+	re := NewRuleEngine()
+	a := NewAsyncEngine(re, 2, 0)
+	defer a.Close()
+	if a.capacity != DefaultAsyncCapacity {
+		t.Fatalf("capacity = %d，应为 %d", a.capacity, DefaultAsyncCapacity)
+	}
+	f, err := a.SubmitMatch(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("capacity 为 0 时提交失败: %v", err)
+	}
+	if _, err := f.Result(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncMatchesSyncResults(t *testing.T) {
+	re := NewRuleEngine()
+	if err := InjectRandomRulesSeeded(re, 300, 7); err != nil {
+		t.Fatal(err)
+	}
+	inputs := GenRandomInputsSeeded(200, 8)
+	a := NewAsyncEngine(re, 4, len(inputs))
+	futures := make([]*MatchFuture, len(inputs))
+	for i, in := range inputs {
+		f, err := a.SubmitMatch(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures[i] = f
+	}
+	a.Close() // 排空队列后返回
+	for i, f := range futures {
+		got, err := f.Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := re.Match(inputs[i]); !slices.Equal(got, want) {
+			t.Fatalf("输入 #%d: 异步命中 %v，同步命中 %v", i, got, want)
+		}
+	}
+	if _, err := a.SubmitMatch(inputs[0]); !errors.Is(err, ErrAsyncClosed) {
+		t.Fatalf("关闭后 err = %v，应为 ErrAsyncClosed", err)
+	}
+}
+
+func TestEngineCloseDrainsAsync(t *testing.T) {
+	re, started, gate := blockingEngine(t)
+	a := NewAsyncEngine(re, 1, 4)
+	first, _ := a.SubmitMatch(blockingInput)
+	<-started
+	queued, _ := a.SubmitMatch(map[string]interface{}{"x": 3})
+	close(gate)
+	if err := re.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*MatchFuture{first, queued} {
+		select {
+		case <-f.Done():
+		default:
+			t.Fatal("引擎关闭后已提交的请求应已执行完毕")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package rule_expr
+
+/* ---------- 批量匹配 ---------- */
+
+// MatchBatchByRule 对 inputs 逐条 Match，并按规则倒排：规则 ID -> 命中的输入下标（升序）。
+// 没有任何命中的规则不出现在结果中
+func (re *RuleEngine) MatchBatchByRule(inputs []map[string]interface{}) map[string][]int {
+	perInput := make([][]string, len(inputs))
+	for i, in := range inputs {
+		perInput[i] = re.Match(in)
+	}
+	return InvertHits(perInput)
+}
+
+// InvertHits 把逐条输入的命中列表倒排为规则 ID -> 输入下标（升序），见 MatchBatchByRule。
+// 先统计各规则的命中数，所有下标切片按实际长度从同一块内存切出，追加时不会互相覆盖
+func InvertHits(perInput [][]string) map[string][]int {
+	counts := make(map[string]int)
+	total := 0
+	for _, hits := range perInput {
+		for _, id := range hits {
+			counts[id]++
+		}
+		total += len(hits)
+	}
+	backing := make([]int, total)
+	byRule := make(map[string][]int, len(counts))
+	off := 0
+	for id, n := range counts {
+		byRule[id] = backing[off : off : off+n]
+		off += n
+	}
+	for i, hits := range perInput {
+		for _, id := range hits {
+			byRule[id] = append(byRule[id], i)
+		}
+	}
+	return byRule
+}
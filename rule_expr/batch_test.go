@@ -0,0 +1,55 @@
+package rule_expr
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestMatchBatchByRuleIsTranspose 倒排结果恰为逐条 Match 结果的转置：下标升序，无命中的规则不出现
+func TestMatchBatchByRuleIsTranspose(t *testing.T) {
+	re := NewRuleEngine()
+	if err := InjectRandomRulesSeeded(re, 60, 7); err != nil {
+		t.Fatal(err)
+	}
+	re.AddRule("never", `env == "nowhere"`)
+	inputs := GenRandomInputsSeeded(500, 7)
+	byRule := re.MatchBatchByRule(inputs)
+
+	want := make(map[string][]int)
+	for i, in := range inputs {
+		for _, id := range re.Match(in) {
+			want[id] = append(want[id], i)
+		}
+	}
+	if len(byRule) != len(want) {
+		t.Fatalf("倒排含 %d 条规则，逐条匹配命中 %d 条", len(byRule), len(want))
+	}
+	for id, idx := range want {
+		if !slices.Equal(byRule[id], idx) {
+			t.Fatalf("%s: %v，应为 %v", id, byRule[id], idx)
+		}
+	}
+	if _, ok := byRule["never"]; ok {
+		t.Fatal("无命中的规则不应出现")
+	}
+	if len(re.MatchBatchByRule(nil)) != 0 {
+		t.Fatal("空批次应返回空结果")
+	}
+}
+
+// TestInvertHitsCapacity 各规则的下标切片容量等于长度，向其中一条追加不会覆盖另一条
+func TestInvertHitsCapacity(t *testing.T) {
+	byRule := InvertHits([][]string{{"a", "b"}, {"b"}, nil, {"a", "b"}})
+	if !slices.Equal(byRule["a"], []int{0, 3}) || !slices.Equal(byRule["b"], []int{0, 1, 3}) || len(byRule) != 2 {
+		t.Fatalf("%v", byRule)
+	}
+	for id, idx := range byRule {
+		if cap(idx) != len(idx) {
+			t.Fatalf("%s: len %d cap %d", id, len(idx), cap(idx))
+		}
+	}
+	a := append(byRule["a"], 99)
+	if !slices.Equal(byRule["b"], []int{0, 1, 3}) || !slices.Equal(a, []int{0, 3, 99}) {
+		t.Fatalf("追加后 a %v b %v", a, byRule["b"])
+	}
+}
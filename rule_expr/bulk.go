@@ -0,0 +1,68 @@
+package rule_expr
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+)
+
+/* ---------- 批量加入规则 ---------- */
+
+// AddRules 并行编译 rules（id -> 表达式），把编译成功的规则一次性加入引擎，
+// 返回加入的条数与失败的规则（ID -> 原因，没有失败时为 nil）。
+// 一条规则失败不影响其他规则；规则集只发布一次新快照，generation 只加一。
+// 配置了 store 时逐条落盘，落盘失败的规则同样计入 failures 且不加入内存
+func (re *RuleEngine) AddRules(rules map[string]string) (added int, failures map[string]error) {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	compiled := make([]*Rule, len(ids))
+	errs := make([]error, len(ids))
+	parallelFill(len(ids), runtime.GOMAXPROCS(0), func(i int) {
+		compiled[i], errs[i] = re.compileRule(ids[i], rules[ids[i]], RuleMeta{})
+	})
+
+	fail := func(id string, err error) {
+		if failures == nil {
+			failures = make(map[string]error)
+		}
+		failures[id] = err
+	}
+	for i, err := range errs {
+		if err != nil {
+			fail(ids[i], fmt.Errorf("编译规则 %s 失败: %w", ids[i], err))
+		}
+	}
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.closed {
+		for i, r := range compiled {
+			if r != nil {
+				fail(ids[i], ErrEngineClosed)
+			}
+		}
+		return 0, failures
+	}
+	good := make([]*Rule, 0, len(compiled))
+	for _, r := range compiled {
+		if r == nil {
+			continue
+		}
+		if re.store != nil {
+			if err := re.store.SaveRule(r.ID, r.ExprStr, r.meta()); err != nil {
+				fail(r.ID, fmt.Errorf("持久化规则 %s 失败: %w", r.ID, err))
+				continue
+			}
+		}
+		good = append(good, r)
+	}
+	if len(good) > 0 {
+		re.putAllLocked(good)
+		re.generation.Add(1)
+	}
+	return len(good), failures
+}
@@ -0,0 +1,127 @@
+package rule_expr
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mixedBatch 有效与无效表达式混合的一批规则，返回批次与应失败的 ID
+func mixedBatch() (map[string]string, []string) {
+	rules := map[string]string{
+		"ok_prod":   `env == "prod"`,
+		"ok_vip":    "is_vip",
+		"ok_amount": "amount > 100",
+		"bad_parse": "amount >",
+		"bad_paren": `(env == "prod"`,
+		"bad_type":  "amount + 1",
+		"bad_lit":   `"prod"`,
+	}
+	return rules, []string{"bad_lit", "bad_paren", "bad_parse", "bad_type"}
+}
+
+func failedIDs(failures map[string]error) []string {
+	ids := make([]string, 0, len(failures))
+	for id := range failures {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestAddRulesMixedBatch(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("ok_vip", "blacklisted") // 被批次覆盖
+	gen := re.Generation()
+
+	rules, wantFailed := mixedBatch()
+	added, failures := re.AddRules(rules)
+	if added != 3 || !slices.Equal(failedIDs(failures), wantFailed) {
+		t.Fatalf("added = %d, failures = %v", added, failures)
+	}
+	for id, err := range failures {
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("%s 的错误应带上规则 ID: %v", id, err)
+		}
+	}
+	if !errors.Is(failures["bad_type"], ErrNotBool) {
+		t.Errorf("bad_type 应报 ErrNotBool: %v", failures["bad_type"])
+	}
+	if re.RuleCount() != 3 || re.Generation() != gen+1 {
+		t.Fatalf("RuleCount = %d，generation %d → %d，应加入 3 条且只发布一次", re.RuleCount(), gen, re.Generation())
+	}
+	hits := re.Match(map[string]interface{}{"env": "prod", "is_vip": true, "amount": 500.0, "blacklisted": false})
+	slices.Sort(hits)
+	if !slices.Equal(hits, []string{"ok_amount", "ok_prod", "ok_vip"}) {
+		t.Fatalf("hits = %v", hits)
+	}
+
+	// 全部失败时不发布新快照
+	gen = re.Generation()
+	if added, failures := re.AddRules(map[string]string{"x": "amount >"}); added != 0 || len(failures) != 1 || re.Generation() != gen {
+		t.Fatalf("added = %d, failures = %v, generation %d → %d", added, failures, gen, re.Generation())
+	}
+	if added, failures := re.AddRules(nil); added != 0 || failures != nil {
+		t.Fatalf("空批次: added = %d, failures = %v", added, failures)
+	}
+}
+
+// TestAddRulesStoreFailure 落盘失败的规则计入 failures 且不加入内存
+func TestAddRulesStoreFailure(t *testing.T) {
+	store := newMemStore()
+	re := NewRuleEngine(WithPersistence(store))
+	store.failSave = errors.New("磁盘已满")
+	added, failures := re.AddRules(map[string]string{"a": "is_vip", "b": "amount >"})
+	if added != 0 || len(failures) != 2 || re.RuleCount() != 0 {
+		t.Fatalf("added = %d, failures = %v, RuleCount = %d", added, failures, re.RuleCount())
+	}
+	if !strings.Contains(failures["a"].Error(), "磁盘已满") {
+		t.Fatalf("a: %v", failures["a"])
+	}
+
+	store.failSave = nil
+	if added, _ := re.AddRules(map[string]string{"a": "is_vip"}); added != 1 || store.rules["a"].Expr != "is_vip" {
+		t.Fatalf("added = %d, store = %v", added, store.rules)
+	}
+}
+
+func TestAddRulesClosed(t *testing.T) {
+	re := NewRuleEngine()
+	re.Close()
+	added, failures := re.AddRules(map[string]string{"a": "is_vip", "b": "amount >"})
+	if added != 0 || !errors.Is(failures["a"], ErrEngineClosed) || failures["b"] == nil {
+		t.Fatalf("added = %d, failures = %v", added, failures)
+	}
+}
+
+// 对比逐条 AddRule 与 AddRules：后者并行编译且只发布一次快照
+func BenchmarkAddRuleLoop(b *testing.B) {
+	rules := bulkRules(2000)
+	for i := 0; i < b.N; i++ {
+		re := NewRuleEngine()
+		for id, expr := range rules {
+			if err := re.AddRule(id, expr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAddRules(b *testing.B) {
+	rules := bulkRules(2000)
+	for i := 0; i < b.N; i++ {
+		if _, failures := NewRuleEngine().AddRules(rules); failures != nil {
+			b.Fatal(failures)
+		}
+	}
+}
+
+func bulkRules(n int) map[string]string {
+	rules := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		rules[fmt.Sprintf("r%d", i)] = fmt.Sprintf(`amount > %d and env == "prod" or user_id == %d`, i, i)
+	}
+	return rules
+}
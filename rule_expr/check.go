@@ -0,0 +1,44 @@
+package rule_expr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+/* ---------- 结果类型检查 ---------- */
+
+// ErrNotBool 表达式的结果一定不是 bool
+var ErrNotBool = errors.New("表达式结果不是 bool")
+
+// checkBool 补充 expr.AsBool：未声明类型的变量参与算术运算时 AsBool 无法判断结果类型，
+// 这里根据最外层节点拒绝一定不是 bool 的表达式。变量、函数、成员访问与三元表达式视为可能是 bool
+func checkBool(exprStr string) error {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return err
+	}
+	if !mayBeBool(tree.Node) {
+		return fmt.Errorf("%w: %s", ErrNotBool, exprStr)
+	}
+	return nil
+}
+
+func mayBeBool(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "+", "-", "*", "/", "%", "**", "^", "..", "??":
+			return n.Operator == "??" && mayBeBool(n.Left) && mayBeBool(n.Right)
+		}
+		return true
+	case *ast.UnaryNode:
+		return n.Operator == "!" || n.Operator == "not"
+	case *ast.IntegerNode, *ast.FloatNode, *ast.StringNode, *ast.ArrayNode, *ast.MapNode, *ast.NilNode:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,27 @@
+package rule_expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddRuleRejectsNonBool(t *testing.T) {
+	re := NewRuleEngine()
+	// AsBool 能判断的常量表达式由编译器拒绝，其余由 checkBool 拒绝
+	for _, exprStr := range []string{`"prod"`, "1 + 1", "[is_vip]"} {
+		if err := re.AddRule("r", exprStr); err == nil {
+			t.Errorf("%s: 结果一定不是 bool，应拒绝", exprStr)
+		}
+	}
+	for _, exprStr := range []string{"amount + 1", "-amount", "amount ?? 0", "amount * rate"} {
+		if err := re.AddRule("r", exprStr); !errors.Is(err, ErrNotBool) {
+			t.Errorf("%s: err = %v，应为 ErrNotBool", exprStr, err)
+		}
+	}
+	// 结果类型在编译时未知的表达式照常接受
+	for _, exprStr := range []string{"is_vip", "user.vip", "not is_vip", "(amount > 1)", "is_vip ? true : false", "flag ?? false", `env == "prod"`} {
+		if err := re.AddRule("r", exprStr); err != nil {
+			t.Errorf("%s: %v", exprStr, err)
+		}
+	}
+}
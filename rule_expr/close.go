@@ -0,0 +1,123 @@
+package rule_expr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/* ---------- 有序关闭 ---------- */
+
+// ErrEngineClosed 引擎关闭后再变更规则
+var ErrEngineClosed = errors.New("规则引擎已关闭")
+
+// DefaultCloseTimeout Close 使用的单步超时
+const DefaultCloseTimeout = 10 * time.Second
+
+// DrainStep 关闭过程中一个子系统的结果
+type DrainStep struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+	TimedOut bool
+}
+
+// DrainReport 一次关闭的逐步结果，顺序即执行顺序
+type DrainReport struct {
+	Steps []DrainStep
+}
+
+// Failed 返回失败或超时的步骤名
+func (r *DrainReport) Failed() []string {
+	var names []string
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+type closeStep struct {
+	name string
+	run  func() error
+}
+
+// Close 以 DefaultCloseTimeout 为单步超时执行 CloseWithTimeout
+func (re *RuleEngine) Close() error {
+	return re.CloseWithTimeout(DefaultCloseTimeout)
+}
+
+// CloseWithTimeout 按固定顺序关闭：停止接受规则变更 → 排空挂接的异步匹配队列 → 最后关闭持久化后端。
+// 每一步最多等待 d；某步失败或超时不影响后续步骤，所有失败合并到返回的 error 中。
+// 只有第一次调用生效，之后的调用直接返回 nil；结果可通过 DrainReport 查看
+func (re *RuleEngine) CloseWithTimeout(d time.Duration) error {
+	var err error
+	re.closeOnce.Do(func() {
+		report := &DrainReport{}
+		var errs []error
+		for _, step := range re.closeSteps() {
+			s := runCloseStep(step, d)
+			report.Steps = append(report.Steps, s)
+			if s.Err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name, s.Err))
+			}
+		}
+		re.drain.Store(report)
+		err = errors.Join(errs...)
+	})
+	return err
+}
+
+// DrainReport 返回关闭结果，尚未关闭时为 nil
+func (re *RuleEngine) DrainReport() *DrainReport {
+	return re.drain.Load()
+}
+
+func (re *RuleEngine) closeSteps() []closeStep {
+	steps := []closeStep{
+		{"mutations", func() error {
+			re.mu.Lock() // 等待进行中的变更完成
+			re.closed = true
+			re.mu.Unlock()
+			return nil
+		}},
+		{"async", func() error {
+			re.asyncMu.Lock()
+			asyncs := re.asyncs
+			re.asyncs = nil
+			re.asyncMu.Unlock()
+			for _, a := range asyncs {
+				a.Close()
+			}
+			return nil
+		}},
+	}
+	if re.store != nil {
+		steps = append(steps, closeStep{"persistence", re.store.Close})
+	}
+	return steps
+}
+
+// runCloseStep 在独立协程中执行一步；超时后不再等待，该协程自行结束
+func runCloseStep(step closeStep, d time.Duration) DrainStep {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- step.run() }()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return DrainStep{Name: step.name, Duration: time.Since(start), Err: err}
+	case <-timer.C:
+		return DrainStep{Name: step.name, Duration: time.Since(start),
+			Err: fmt.Errorf("超过 %s 未完成", d), TimedOut: true}
+	}
+}
+
+// attachAsync 让 Close 负责排空 a 的队列
+func (re *RuleEngine) attachAsync(a *AsyncEngine) {
+	re.asyncMu.Lock()
+	re.asyncs = append(re.asyncs, a)
+	re.asyncMu.Unlock()
+}
@@ -0,0 +1,84 @@
+package rule_expr
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCloseReportsSlowSubsystem 异步队列排不空时只有该步超时，其余步骤照常完成，再次 Close 不做任何事
+func TestCloseReportsSlowSubsystem(t *testing.T) {
+	re, started, gate := blockingEngine(t)
+	defer close(gate)
+	store := newMemStore()
+	re.store = store
+	a := NewAsyncEngine(re, 1, 4)
+	if _, err := a.SubmitMatch(blockingInput); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	err := re.CloseWithTimeout(50 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "async") {
+		t.Fatalf("err = %v，应报告 async 超时", err)
+	}
+	report := re.DrainReport()
+	if report == nil {
+		t.Fatal("关闭后应有 DrainReport")
+	}
+	var names []string
+	for _, s := range report.Steps {
+		names = append(names, s.Name)
+		if (s.Name == "async") != s.TimedOut {
+			t.Errorf("步骤 %s: TimedOut = %v", s.Name, s.TimedOut)
+		}
+	}
+	if !slices.Equal(names, []string{"mutations", "async", "persistence"}) {
+		t.Fatalf("关闭顺序 = %v", names)
+	}
+	if failed := report.Failed(); !slices.Equal(failed, []string{"async"}) {
+		t.Fatalf("Failed() = %v，应只有 async", failed)
+	}
+	store.mu.Lock()
+	closed := store.closed
+	store.mu.Unlock()
+	if !closed {
+		t.Error("前一步超时后持久化仍应关闭")
+	}
+	if err := re.AddRule("late", "is_vip"); !errors.Is(err, ErrEngineClosed) {
+		t.Errorf("关闭后 AddRule err = %v，应为 ErrEngineClosed", err)
+	}
+
+	if err := re.Close(); err != nil {
+		t.Fatalf("第二次 Close err = %v，应为 nil", err)
+	}
+	if re.DrainReport() != report {
+		t.Error("第二次 Close 不应替换 DrainReport")
+	}
+}
+
+func TestCloseSlowPersistence(t *testing.T) {
+	store := newMemStore()
+	store.closeGate = make(chan struct{})
+	defer close(store.closeGate)
+	re := NewRuleEngine(WithPersistence(store))
+	err := re.CloseWithTimeout(20 * time.Millisecond)
+	if err == nil {
+		t.Fatal("持久化关闭超时应返回错误")
+	}
+	if failed := re.DrainReport().Failed(); !slices.Equal(failed, []string{"persistence"}) {
+		t.Fatalf("Failed() = %v，应只有 persistence", failed)
+	}
+}
+
+func TestCloseWithoutStore(t *testing.T) {
+	re := NewRuleEngine()
+	if err := re.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if failed := re.DrainReport().Failed(); len(failed) != 0 {
+		t.Fatalf("Failed() = %v", failed)
+	}
+}
@@ -0,0 +1,142 @@
+package rule_expr
+
+import (
+	"goexprtester/rule_engine"
+	"math/rand"
+	"time"
+)
+
+/* ---------- 列式输入 ---------- */
+
+// column 单个因子的一整列数据；String 列只存样例值下标，避免逐行装箱
+type column struct {
+	factor FactorTemplate
+	bools  []bool
+	ints   []int
+	floats []float64
+	times  []time.Time
+	lists  [][]string
+	nested bool    // 嵌套因子，见 rule_engine.IsNested
+	idx    []uint8 // String: SampleValues 下标
+	boxed  []interface{}
+}
+
+// InputColumns 按因子分列存放的大批量输入，生成时不创建逐行 map
+type InputColumns struct {
+	n    int
+	cols []column
+}
+
+// GenRandomInputColumns 以列式结构生成 n 条随机输入，取值分布与 GenRandomInputs 相同
+func GenRandomInputColumns(n int) *InputColumns {
+	return genRandomInputColumns(time.Now().UnixNano(), n)
+}
+
+// genRandomInputColumns 第 i 行与 genRandomInputs(seed, ...) 的第 i 行相同
+func genRandomInputColumns(seed int64, n int) *InputColumns {
+	c := &InputColumns{n: n, cols: make([]column, len(rule_engine.Factors))}
+	for j, f := range rule_engine.Factors {
+		col := column{factor: f, nested: rule_engine.IsNested(f.Name)}
+		switch f.Kind {
+		case Bool:
+			col.bools = make([]bool, n)
+		case String:
+			col.idx = make([]uint8, n)
+			col.boxed = f.SampleValues
+		case Int:
+			col.ints = make([]int, n)
+		case Float:
+			col.floats = make([]float64, n)
+		case Time:
+			col.times = make([]time.Time, n)
+		case List:
+			col.lists = make([][]string, n)
+		}
+		c.cols[j] = col
+	}
+	// 与 GenRandomInputsParallel 相同的逐行随机源与取数顺序（见 rule_engine.RandomRow），复用同一个 Rand 避免逐行分配
+	src := &splitMix64{}
+	r := rand.New(src)
+	for i := 0; i < n; i++ {
+		src.state = itemSeed(seed, i)
+		for j := range c.cols {
+			col := &c.cols[j]
+			f := col.factor
+			switch f.Kind {
+			case Bool:
+				col.bools[i] = r.Intn(2) == 0
+			case String:
+				col.idx[i] = uint8(r.Intn(len(f.SampleValues)))
+			case Int:
+				if r.Float64() < 0.8 {
+					col.ints[i] = f.SampleValues[r.Intn(len(f.SampleValues))].(int)
+				} else {
+					col.ints[i] = r.Intn(90000) + 10000
+				}
+			case Float:
+				col.floats[i] = rule_engine.RandomValue(r, f).(float64)
+			case Time:
+				col.times[i] = rule_engine.RandomValue(r, f).(time.Time)
+			case List:
+				col.lists[i] = rule_engine.RandomValue(r, f).([]string)
+			}
+		}
+	}
+	return c
+}
+
+// Len 行数
+func (c *InputColumns) Len() int {
+	return c.n
+}
+
+// Row 把第 i 行写入 dst 并返回；dst 为 nil 时新建。
+// 匹配循环里复用同一个 dst 即可只在真正需要 map 的后端处付出构造成本
+func (c *InputColumns) Row(i int, dst map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(c.cols))
+	}
+	for j := range c.cols {
+		col := &c.cols[j]
+		var v interface{}
+		switch col.factor.Kind {
+		case Bool:
+			v = col.bools[i]
+		case String:
+			v = col.boxed[col.idx[i]]
+		case Int:
+			v = col.ints[i]
+		case Float:
+			v = col.floats[i]
+		case Time:
+			v = col.times[i]
+		case List:
+			v = col.lists[i]
+		}
+		if col.nested {
+			rule_engine.SetPath(dst, col.factor.Name, v) // 复用 dst 时也复用其中的嵌套 map
+		} else {
+			dst[col.factor.Name] = v
+		}
+	}
+	return dst
+}
+
+// Materialize 转换成逐行 map，供需要 []map 的调用方使用
+func (c *InputColumns) Materialize() []map[string]interface{} {
+	rows := make([]map[string]interface{}, c.n)
+	for i := range rows {
+		rows[i] = c.Row(i, nil)
+	}
+	return rows
+}
+
+// BenchmarkMatchColumns 与 BenchmarkMatch 相同，但逐行复用同一个 map
+func BenchmarkMatchColumns(re *RuleEngine, cols *InputColumns) time.Duration {
+	row := make(map[string]interface{}, len(cols.cols))
+	start := time.Now()
+	for i := 0; i < cols.n; i++ {
+		_ = re.Match(cols.Row(i, row))
+	}
+	return time.Since(start) / time.Duration(cols.n)
+}
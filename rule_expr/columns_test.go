@@ -0,0 +1,44 @@
+package rule_expr
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// TestInputColumnsRows 每一行视图（新建或复用 dst）与同种子逐行生成的 map 完全相同，
+// 物化结果与两种形式上的匹配结果也一致
+func TestInputColumnsRows(t *testing.T) {
+	const seed, n = 42, 2000
+	cols := genRandomInputColumns(seed, n)
+	want := genRandomInputs(seed, n, 1)
+	if cols.Len() != n {
+		t.Fatalf("Len = %d", cols.Len())
+	}
+	re := NewRuleEngine()
+	if err := InjectRandomRulesSeeded(re, 200, seed); err != nil {
+		t.Fatal(err)
+	}
+	dst := make(map[string]interface{})
+	hits := 0
+	for i := 0; i < n; i++ {
+		if got := cols.Row(i, nil); !reflect.DeepEqual(got, want[i]) {
+			t.Fatalf("第 %d 行:\n%v\n应为\n%v", i, got, want[i])
+		}
+		// 复用的 dst 须完全覆盖上一行，包括嵌套 map 中的字段
+		if got := cols.Row(i, dst); !reflect.DeepEqual(got, want[i]) {
+			t.Fatalf("复用 dst 的第 %d 行:\n%v\n应为\n%v", i, got, want[i])
+		}
+		got, exp := re.Match(dst), re.Match(want[i])
+		if !slices.Equal(got, exp) {
+			t.Fatalf("第 %d 行的匹配结果 %v，应为 %v", i, got, exp)
+		}
+		hits += len(exp)
+	}
+	if hits == 0 {
+		t.Fatal("规则在样本上没有任何命中，比较没有意义")
+	}
+	if !reflect.DeepEqual(cols.Materialize(), want) {
+		t.Fatal("Materialize 与逐行生成的结果不同")
+	}
+}
@@ -0,0 +1,90 @@
+package rule_expr
+
+import (
+	"slices"
+
+	"goexprtester/rule_engine"
+
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 编译缓存 ---------- */
+
+// compiledExpr 只由表达式文本（与引擎的因子池）决定的编译结果，同文本的规则可以共享，构造后只读
+type compiledExpr struct {
+	program  *vm.Program
+	shape    *ruleShape
+	warnings []string
+	verdict  string
+	dag      *boolNode // 原子条件上的布尔树，仅启用 WithSharedPredicates 时有
+}
+
+// compileKey 编译结果由表达式文本与是否容忍缺失因子共同决定
+type compileKey struct {
+	expr    string
+	lenient bool
+}
+
+// WithCompileCache 让表达式文本相同的规则共享同一份编译结果（*vm.Program 与静态检查结论），
+// AddRule 及批量加载、规则文件加载都经过缓存。适合含大量重复表达式的规则集（如模板化规则）：只编译一次，
+// 也只占一份内存。最多保留 rule_engine.DefaultCompileCacheSize 条，超出时淘汰最久未用的；
+// 被淘汰或规则被删除不影响已加入的规则，它们各自持有编译结果
+func WithCompileCache() EngineOption {
+	return WithCompileCacheSize(0)
+}
+
+// WithCompileCacheSize 同 WithCompileCache，但最多保留 size 条，size <= 0 取默认值
+func WithCompileCacheSize(size int) EngineOption {
+	return func(re *RuleEngine) {
+		re.cache = rule_engine.NewCompileCache(size)
+	}
+}
+
+// CompileCacheStats 返回编译缓存的命中 / 未命中 / 淘汰统计；未启用缓存时为零值
+func (re *RuleEngine) CompileCacheStats() rule_engine.CacheStats {
+	if re.cache == nil {
+		return rule_engine.CacheStats{}
+	}
+	return re.cache.Stats()
+}
+
+// compile 编译并静态检查表达式，lenient 见 WithAllowUndefined；启用编译缓存时同文本同模式只编译一次。
+// 可并发调用，两个调用同时编译同一文本时各自编译，缓存保留先写入的一份
+func (re *RuleEngine) compile(exprStr string, lenient bool) (*compiledExpr, error) {
+	key := compileKey{exprStr, lenient}
+	if re.cache != nil {
+		if c, ok := re.cache.Get(key); ok {
+			return c.(*compiledExpr), nil
+		}
+	}
+	p, err := compileProgram(exprStr, lenient)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBool(exprStr); err != nil {
+		return nil, err
+	}
+	shape, err := analyzeExpr(exprStr)
+	if err != nil {
+		return nil, err
+	}
+	warnings, verdict, err := lintExpr(exprStr, re.pool)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiledExpr{program: p, shape: shape, warnings: warnings, verdict: verdict}
+	if re.preds != nil {
+		if c.dag, err = re.preds.decompose(exprStr, lenient); err != nil {
+			return nil, err
+		}
+	}
+	if re.cache != nil {
+		c = re.cache.Add(key, c).(*compiledExpr)
+	}
+	return c, nil
+}
+
+// ruleWarnings 每条规则持有自己的提示切片，调用方修改 Rule.Warnings 不会影响共享同一编译结果的规则
+func (c *compiledExpr) ruleWarnings() []string {
+	return slices.Clone(c.warnings)
+}
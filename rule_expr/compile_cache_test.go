@@ -0,0 +1,82 @@
+package rule_expr
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"goexprtester/rule_engine"
+)
+
+// templateExprs 100 个互不相同的随机表达式，模拟模板化规则集
+func templateExprs(tb testing.TB) []string {
+	tb.Helper()
+	trees := rule_engine.RandomTreesFrom(rule_engine.DefaultFactorPool(), 100, 1, rule_engine.GenOptions{Distinct: true})
+	exprs := make([]string, len(trees))
+	for i, tree := range trees {
+		exprs[i] = tree.Render(Syntax)
+	}
+	return exprs
+}
+
+// TestCompileCacheConcurrentAddRule 并发加入同文本的规则：缓存只保留每个文本一份，
+// 所有同文本规则共用同一个 Program，匹配结果与不启用缓存时相同
+func TestCompileCacheConcurrentAddRule(t *testing.T) {
+	exprs := templateExprs(t)[:10]
+	const workers, perWorker = 8, 50
+	cached, plain := NewRuleEngine(WithCompileCache()), NewRuleEngine()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				if err := cached.AddRule(id, exprs[i%len(exprs)]); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		for i := 0; i < perWorker; i++ {
+			plain.AddRule(fmt.Sprintf("w%d-%d", w, i), exprs[i%len(exprs)])
+		}
+	}
+	wg.Wait()
+
+	s := cached.CompileCacheStats()
+	if s.Entries != len(exprs) || s.Hits+s.Misses != workers*perWorker || s.Misses < uint64(len(exprs)) {
+		t.Fatalf("%+v", s)
+	}
+	programs := make(map[string]interface{})
+	for _, r := range cached.SearchRules(RuleQuery{}) {
+		if p, ok := programs[r.ExprStr]; !ok {
+			programs[r.ExprStr] = r.Program
+		} else if p != r.Program {
+			t.Fatalf("规则 %s 没有与同文本的规则共用 Program", r.ID)
+		}
+	}
+	for i, in := range GenRandomInputsSeeded(100, 2) {
+		if got, want := cached.Match(in), plain.Match(in); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("输入 #%d: 缓存 %v，无缓存 %v", i, got, want)
+		}
+	}
+}
+
+// 对比有无编译缓存时 10k 次 AddRule（100 个不同表达式轮流加入），与 -compile-bench 的场景相同
+func BenchmarkAddRuleCached(b *testing.B) {
+	benchmarkAddRule(b, WithCompileCache())
+}
+
+func BenchmarkAddRuleUncached(b *testing.B) {
+	benchmarkAddRule(b)
+}
+
+func benchmarkAddRule(b *testing.B, opts ...EngineOption) {
+	exprs := templateExprs(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rule_engine.BenchmarkAddRule(NewRuleEngine(opts...), exprs, 10000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package rule_expr
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// referenceMatch 不经引擎内部结构的参考实现：从 ListRules 取启用且开关打开的规则，
+// 按优先级降序、ID 升序逐条单独编译求值；programs 缓存同文本的编译结果
+func referenceMatch(re *RuleEngine, flags map[string]bool, programs map[string]*vm.Program, input map[string]interface{}) []string {
+	rules := re.ListRules()
+	slices.SortFunc(rules, func(a, b RuleInfo) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	var hits []string
+	for _, r := range rules {
+		if !r.Enabled || (r.Flag != "" && !flags[r.Flag]) {
+			continue
+		}
+		p, ok := programs[r.Expr]
+		if !ok {
+			p, _ = expr.Compile(r.Expr) // 引擎已接受的表达式一定能编译
+			programs[r.Expr] = p
+		}
+		if out, err := expr.Run(p, input); err == nil && out == true {
+			hits = append(hits, r.ID)
+		}
+	}
+	return hits
+}
+
+// TestMatchPathsConformance 每种规则变更之后，Match、已弃用的 MatchNoneSync 与参考实现
+// 在同一组种子输入上给出完全相同（含顺序）的命中；分别覆盖默认配置与打开全部匹配优化的配置
+func TestMatchPathsConformance(t *testing.T) {
+	configs := map[string][]EngineOption{
+		"默认":   nil,
+		"全部优化": {WithCompileCache(), WithEqualityIndex(), WithSharedPredicates()},
+	}
+	for name, opts := range configs {
+		t.Run(name, func(t *testing.T) {
+			re := NewRuleEngine(opts...)
+			flags := map[string]bool{"beta": true}
+			re.SetFlagProvider(func(flag string) bool { return flags[flag] })
+			programs := make(map[string]*vm.Program)
+			corpus := GenRandomInputsSeeded(200, 7)
+			corpus = append(corpus, map[string]interface{}{"env": "prod", "is_vip": true, "amount": 500.0, "user_id": 12345})
+
+			check := func(step string) {
+				t.Helper()
+				for i, in := range corpus {
+					want := referenceMatch(re, flags, programs, in)
+					got, legacy := re.Match(in), re.MatchNoneSync(in)
+					if !slices.Equal(got, want) || !slices.Equal(legacy, want) {
+						t.Fatalf("%s 后第 %d 条输入:\nMatch         %v\nMatchNoneSync %v\n参考实现      %v", step, i, got, legacy, want)
+					}
+				}
+			}
+
+			if err := InjectRandomRulesSeeded(re, 60, 7); err != nil {
+				t.Fatal(err)
+			}
+			check("InjectRandomRulesSeeded")
+			ids := re.ListRules()
+
+			mutations := []struct {
+				name string
+				fn   func() error
+			}{
+				{"AddRule 覆盖", func() error { return re.AddRule(ids[0].ID, `env == "prod"`) }},
+				{"AddRuleWithPriority", func() error { return re.AddRuleWithPriority("p_vip", "is_vip", 5) }},
+				{"AddRuleWithTags", func() error { return re.AddRuleWithTags("t_big", "amount > 100", "risk") }},
+				{"AddRuleWithFlag", func() error { return re.AddRuleWithFlag("f_prod", `env == "prod"`, "beta") }},
+				// 不再查询开关后按默认值（关闭）处理，此后结果缓存才会生效
+				{"SetFlagProvider(nil)", func() error { flags["beta"] = false; re.SetFlagProvider(nil); return nil }},
+				{"SetPriority", func() error { return re.SetPriority(ids[1].ID, 9) }},
+				{"SetTags", func() error { return re.SetTags("t_big", "ops") }},
+				{"DisableRule", func() error { re.DisableRule("p_vip"); re.DisableRule(ids[2].ID); return nil }},
+				{"EnableRule", func() error { re.EnableRule(ids[2].ID); return nil }},
+				{"RemoveRule", func() error { re.RemoveRule(ids[3].ID); return nil }},
+				{"AddRules", func() error {
+					re.AddRules(map[string]string{"b_ok": "user_id == 12345", "b_bad": "user_id ==", ids[4].ID: "not is_vip"})
+					return nil
+				}},
+				{"AddRuleAuto", func() error { _, err := re.AddRuleAuto("amount < 50", RuleMeta{Priority: 2}); return err }},
+				{"RewriteRules", func() error {
+					_, err := re.RewriteRules(map[string]FactorRewrite{"is_vip": {RenameTo: "vip"}})
+					return err
+				}},
+				{"ImportText", func() error {
+					return re.ImportText(strings.NewReader("i_one | 3 | risk | vip or env == \"staging\"\n"))
+				}},
+				{"ReloadRulesFile", func() error {
+					path := filepath.Join(t.TempDir(), "rules.txt")
+					if err := os.WriteFile(path, []byte("r_one | 0 |  | amount > 10\nr_two | 1 |  | is_vip\n"), 0o644); err != nil {
+						return err
+					}
+					return re.ReloadRulesFile(path).Err
+				}},
+				{"SetResultCache", func() error { re.SetResultCache(len(corpus), ResultCacheOptions{Paranoid: true}); return nil }},
+				{"缓存开启后 AddRule", func() error { return re.AddRule("r_three", `env == "prod"`) }},
+				{"缓存开启后加入带开关的规则", func() error { return re.AddRuleWithFlag("f_two", "is_vip", "beta") }},
+				{"缓存开启后 SetFlagDefault", func() error { flags["beta"] = true; re.SetFlagDefault(true); return nil }},
+			}
+			for _, m := range mutations {
+				if err := m.fn(); err != nil {
+					t.Fatalf("%s: %v", m.name, err)
+				}
+				check(m.name)
+			}
+			if re.RuleCount() != 4 {
+				t.Fatalf("重新加载后应只剩文件中的规则与之后加入的两条，实际 %d 条", re.RuleCount())
+			}
+			if s := re.ResultCacheStats(); s.Hits == 0 {
+				t.Fatalf("结果缓存未被使用: %+v", s)
+			}
+		})
+	}
+}
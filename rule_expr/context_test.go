@@ -0,0 +1,51 @@
+package rule_expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestMatchContextCancelStopsEarly 在第 100 条规则求值前取消：最多再评估 ctxCheckEvery 条即停止，
+// 返回 ctx.Err() 与已收集的部分命中，未评估的规则不计入 EvalStats
+func TestMatchContextCancelStopsEarly(t *testing.T) {
+	const rules, cancelAt = 10000, 100
+	re := NewRuleEngine()
+	for i := 0; i < rules; i++ {
+		// 每条规则一个开关，开关在规则求值前查询，借此得知遍历进度
+		if err := re.AddRuleWithFlag(fmt.Sprintf("r%05d", i), "x == 1", fmt.Sprintf("f%05d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.SetFlagProvider(func(flag string) bool {
+		if flag == fmt.Sprintf("f%05d", cancelAt) {
+			cancel()
+		}
+		return true
+	})
+
+	before := re.EvalStats().Evaluated
+	hits, err := re.MatchContext(ctx, map[string]interface{}{"x": 1})
+	evaluated := re.EvalStats().Evaluated - before
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v，应为 context.Canceled", err)
+	}
+	if evaluated <= cancelAt || evaluated > cancelAt+ctxCheckEvery || evaluated >= uint64(re.RuleCount()) {
+		t.Fatalf("取消后评估了 %d 条规则，应在 (%d, %d] 之间", evaluated, cancelAt, cancelAt+ctxCheckEvery)
+	}
+	if uint64(len(hits)) != evaluated || hits[0] != "r00000" {
+		t.Fatalf("应返回已评估规则的命中，得到 %d 条", len(hits))
+	}
+
+	// 已经结束的 ctx 不评估任何规则
+	before = re.EvalStats().Evaluated
+	if hits, err := re.MatchContext(ctx, map[string]interface{}{"x": 1}); err == nil || len(hits) != 0 || re.EvalStats().Evaluated != before {
+		t.Fatalf("已取消的 ctx: hits %d, err %v", len(hits), err)
+	}
+	if hits, err := re.MatchContext(context.Background(), map[string]interface{}{"x": 1}); err != nil || len(hits) != rules {
+		t.Fatalf("未取消时应评估全部规则: hits %d, err %v", len(hits), err)
+	}
+}
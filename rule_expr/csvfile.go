@@ -0,0 +1,187 @@
+package rule_expr
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"goexprtester/rule_engine"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- CSV 规则与输入 ---------- */
+
+// CSVRowError CSV 中无法使用的一行；Line 为该行在文件中的行号（从 1 开始，含表头）
+type CSVRowError struct {
+	Line int
+	Err  error
+}
+
+func (e CSVRowError) Error() string {
+	return fmt.Sprintf("第 %d 行: %v", e.Line, e.Err)
+}
+
+// LoadRulesFromCSV 读取表头为 id,expression,enabled 的 CSV 并逐条加入引擎（列顺序不限，enabled 可省略）。
+// enabled 取 "true"/"false"，空值视为 true；为 false 的规则以停用状态加入（见 DisableRule）。
+// 坏行（列数不对、字段为空或取值非法、编译失败）记入 bad 并跳过，不影响其他行；
+// 只有无法读取、表头不合法或 CSV 语法错误（如引号不匹配）时返回 err
+func LoadRulesFromCSV(re *RuleEngine, r io.Reader) (loaded int, bad []CSVRowError, err error) {
+	cr := newCSVReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return 0, nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	for _, name := range []string{"id", "expression"} {
+		if _, ok := col[name]; !ok {
+			return 0, nil, fmt.Errorf("表头缺少 %s 列", name)
+		}
+	}
+	enabledCol, hasEnabled := col["enabled"]
+
+	err = eachCSVRow(cr, len(header), func(line int, rec []string) error {
+		id, exprStr := rec[col["id"]], rec[col["expression"]]
+		if id == "" || exprStr == "" {
+			return errors.New("id 与 expression 不能为空")
+		}
+		enabled := true
+		if hasEnabled && rec[enabledCol] != "" {
+			b, err := parseCSVBool(rec[enabledCol])
+			if err != nil {
+				return fmt.Errorf("enabled: %w", err)
+			}
+			enabled = b
+		}
+		if err := re.addRule(id, exprStr, RuleMeta{Disabled: !enabled}, true); err != nil {
+			return fmt.Errorf("编译规则 %s 失败: %w", id, err)
+		}
+		loaded++
+		return nil
+	}, &bad)
+	return loaded, bad, err
+}
+
+// ReadInputsCSV 读取输入 CSV：表头每列是 pool 中的一个因子，每行转换为一条可直接传给 Match 的输入。
+// 按因子类型显式转换：Bool 只接受 "true"/"false"，Int 只接受十进制整数，String 原样保留
+// （CSV 的引号在解析时已去掉），List 的元素以 '|' 分隔、emptyListCell（"[]"）表示空列表。
+// 任何类型的空单元格都表示该因子缺失，不写入输入。
+// 坏行记入 bad 并跳过；只有无法读取、表头含未知因子或 CSV 语法错误时返回 err
+func ReadInputsCSV(r io.Reader, pool *FactorPool) (inputs []map[string]interface{}, bad []CSVRowError, err error) {
+	cr := newCSVReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+	factors := make([]FactorTemplate, len(header))
+	for i, name := range header {
+		f, ok := pool.Lookup(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("表头第 %d 列: %s", i+1, pool.UnknownFactorMessage(name))
+		}
+		if slices.Contains(header[:i], name) {
+			return nil, nil, fmt.Errorf("表头第 %d 列: 因子 %s 重复", i+1, name)
+		}
+		factors[i] = f
+	}
+
+	err = eachCSVRow(cr, len(header), func(line int, rec []string) error {
+		row := make(map[string]interface{}, len(rec))
+		for i, cell := range rec {
+			if cell == "" {
+				continue
+			}
+			v, err := coerceCSV(factors[i], cell)
+			if err != nil {
+				return fmt.Errorf("%s: %w", factors[i].Name, err)
+			}
+			rule_engine.SetPath(row, factors[i].Name, v)
+		}
+		inputs = append(inputs, row)
+		return nil
+	}, &bad)
+	return inputs, bad, err
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // 列数由 eachCSVRow 检查，以便按行报告而不是中止
+	cr.Comment = '#'
+	return cr
+}
+
+// eachCSVRow 依次处理表头之后的记录；fn 返回的错误与列数不符都记为坏行
+func eachCSVRow(cr *csv.Reader, width int, fn func(line int, rec []string) error, bad *[]CSVRowError) error {
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			// 引号不匹配等语法错误无法定位到下一条记录的起点，只能终止
+			return perr
+		} else if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) != width {
+			*bad = append(*bad, CSVRowError{line, fmt.Errorf("有 %d 列，表头为 %d 列", len(rec), width)})
+			continue
+		}
+		if err := fn(line, rec); err != nil {
+			*bad = append(*bad, CSVRowError{line, err})
+		}
+	}
+}
+
+// emptyListCell 输入 CSV 中表示空列表的单元格
+const emptyListCell = "[]"
+
+func coerceCSV(f FactorTemplate, cell string) (interface{}, error) {
+	switch f.Kind {
+	case Bool:
+		return parseCSVBool(cell)
+	case Int:
+		n, err := strconv.Atoi(cell)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是整数", cell)
+		}
+		return n, nil
+	case Float:
+		x, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是数值", cell)
+		}
+		return x, nil
+	case Time:
+		t, err := time.Parse(time.RFC3339, cell)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是 RFC 3339 时间", cell)
+		}
+		return t, nil
+	case List:
+		// 空单元格在此之前已按缺失处理，空列表须显式写作 emptyListCell
+		if cell == emptyListCell {
+			return []string{}, nil
+		}
+		return strings.Split(cell, "|"), nil
+	default:
+		return cell, nil
+	}
+}
+
+func parseCSVBool(cell string) (bool, error) {
+	switch cell {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q 不是 true/false", cell)
+}
@@ -0,0 +1,93 @@
+package rule_expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadInputsCSV(t *testing.T) {
+	const data = `is_vip,user_id,amount,account_created_at,tags,user.country
+true,12345,99.5,2024-01-02T03:04:05Z,a|b,CN
+false,,,,[],
+# 注释行
+maybe,1,1,,,
+true,1,1,,
+`
+	inputs, bad, err := ReadInputsCSV(strings.NewReader(data), DefaultFactorPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{
+			"is_vip": true, "user_id": 12345, "amount": 99.5,
+			"account_created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"tags":               []string{"a", "b"},
+			"user":               map[string]interface{}{"country": "CN"},
+		},
+		// 空单元格为缺失，"[]" 为空列表
+		{"is_vip": false, "tags": []string{}},
+	}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Fatalf("inputs = %#v", inputs)
+	}
+	if len(bad) != 2 || bad[0].Line != 5 || bad[1].Line != 6 {
+		t.Fatalf("坏行 = %v，应为第 5 行（取值非法）与第 6 行（列数不对）", bad)
+	}
+	if !strings.Contains(bad[0].Error(), "is_vip") {
+		t.Errorf("坏行应指出因子: %v", bad[0])
+	}
+}
+
+func TestReadInputsCSVEmptyListMatchesLen(t *testing.T) {
+	inputs, _, err := ReadInputsCSV(strings.NewReader("tags\n[]\n\n"), DefaultFactorPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := NewRuleEngine()
+	if err := re.AddRule("empty", "len(tags) == 0"); err != nil {
+		t.Fatal(err)
+	}
+	if hits := re.Match(inputs[0]); len(hits) != 1 {
+		t.Fatalf("空列表输入应命中 len(tags) == 0，实际 %v", hits)
+	}
+}
+
+func TestReadInputsCSVBadHeader(t *testing.T) {
+	for _, header := range []string{"no_such_factor\n", "is_vip,is_vip\n"} {
+		if _, _, err := ReadInputsCSV(strings.NewReader(header), DefaultFactorPool()); err == nil {
+			t.Errorf("表头 %q 应报错", header)
+		}
+	}
+}
+
+func TestLoadRulesFromCSV(t *testing.T) {
+	const data = `enabled,id,expression
+,a,is_vip
+false,b,blacklisted
+true,c,is_vip and (
+yes,d,is_vip
+,,is_vip
+`
+	re := NewRuleEngine()
+	loaded, bad, err := LoadRulesFromCSV(re, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 || len(bad) != 3 {
+		t.Fatalf("loaded = %d，坏行 %v", loaded, bad)
+	}
+	for i, line := range []int{4, 5, 6} {
+		if bad[i].Line != line {
+			t.Errorf("第 %d 个坏行的行号 = %d，应为 %d", i, bad[i].Line, line)
+		}
+	}
+	infos := re.ListRules()
+	if !infos[0].Enabled || infos[1].Enabled {
+		t.Fatalf("enabled 列未生效: %+v", infos)
+	}
+	if _, _, err := LoadRulesFromCSV(NewRuleEngine(), strings.NewReader("id,enabled\n")); err == nil {
+		t.Fatal("缺少 expression 列时应报错")
+	}
+}
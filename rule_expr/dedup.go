@@ -0,0 +1,99 @@
+package rule_expr
+
+import (
+	"time"
+)
+
+/* ---------- 输入去重 ---------- */
+
+// DedupMode 基准测试对重复输入的处理方式
+type DedupMode int
+
+const (
+	DedupOff      DedupMode = iota // 原样匹配全部输入
+	DedupDrop                      // 只匹配去重后的输入
+	DedupWeighted                  // 去重后匹配，按重复次数加权外推总耗时
+)
+
+func (m DedupMode) String() string {
+	switch m {
+	case DedupDrop:
+		return "drop"
+	case DedupWeighted:
+		return "weighted"
+	default:
+		return "off"
+	}
+}
+
+// DedupInputs 去掉完全相同的输入，返回唯一输入及每条在原始序列中出现的次数（保持首次出现顺序）
+func DedupInputs(inputs []map[string]interface{}) (unique []map[string]interface{}, counts []int) {
+	pos := make(map[string]int, len(inputs))
+	for _, in := range inputs {
+		key := inputKey(in)
+		if i, ok := pos[key]; ok {
+			counts[i]++
+			continue
+		}
+		pos[key] = len(unique)
+		unique = append(unique, in)
+		counts = append(counts, 1)
+	}
+	return unique, counts
+}
+
+// DedupReport 去重基准的结果
+type DedupReport struct {
+	Mode          DedupMode
+	Total         int           // 原始输入条数
+	Unique        int           // 去重后条数
+	DedupRatio    float64       // 重复输入占比 1 - Unique/Total
+	MeanUnique    time.Duration // 唯一输入的平均匹配耗时（反映真实工作量）
+	TotalDuration time.Duration // off 为实测总耗时，drop 为唯一输入总耗时，weighted 为外推的原始输入流总耗时
+	MeanPerInput  time.Duration // 每条被计入的输入的平均耗时：weighted 按 Total，drop 按 Unique
+}
+
+// BenchmarkMatchDedup 按 mode 处理重复输入后执行匹配
+func BenchmarkMatchDedup(re *RuleEngine, inputs []map[string]interface{}, mode DedupMode) DedupReport {
+	return benchmarkDedup(func(in map[string]interface{}) { _ = re.Match(in) }, inputs, mode, time.Now)
+}
+
+// benchmarkDedup 是 BenchmarkMatchDedup 的实现，时钟可替换以便测试外推结果
+func benchmarkDedup(match func(map[string]interface{}), inputs []map[string]interface{}, mode DedupMode, now func() time.Time) DedupReport {
+	report := DedupReport{Mode: mode, Total: len(inputs)}
+	if len(inputs) == 0 {
+		return report
+	}
+	if mode == DedupOff {
+		start := now()
+		for _, in := range inputs {
+			match(in)
+		}
+		report.TotalDuration = now().Sub(start)
+		report.Unique = len(inputs)
+		report.MeanUnique = report.TotalDuration / time.Duration(len(inputs))
+		report.MeanPerInput = report.MeanUnique
+		return report
+	}
+
+	unique, counts := DedupInputs(inputs)
+	report.Unique = len(unique)
+	report.DedupRatio = 1 - float64(len(unique))/float64(len(inputs))
+	var sum, weighted time.Duration
+	for i, in := range unique {
+		start := now()
+		match(in)
+		d := now().Sub(start)
+		sum += d
+		weighted += d * time.Duration(counts[i])
+	}
+	report.MeanUnique = sum / time.Duration(len(unique))
+	if mode == DedupWeighted {
+		report.TotalDuration = weighted
+		report.MeanPerInput = weighted / time.Duration(len(inputs))
+	} else {
+		report.TotalDuration = sum
+		report.MeanPerInput = report.MeanUnique
+	}
+	return report
+}
@@ -0,0 +1,84 @@
+package rule_expr
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// craftedInputs 7 条输入，3 条唯一：a 出现 4 次（键顺序不同也算相同），b 出现 2 次，c 出现 1 次
+func craftedInputs() []map[string]interface{} {
+	a := func() map[string]interface{} {
+		return map[string]interface{}{"env": "prod", "amount": 10.0, "user": map[string]interface{}{"country": "CN"}}
+	}
+	b := func() map[string]interface{} { return map[string]interface{}{"env": "prod", "amount": 20.0} }
+	return []map[string]interface{}{
+		a(), b(), a(),
+		{"user": map[string]interface{}{"country": "CN"}, "amount": 10.0, "env": "prod"},
+		{"env": "prod", "amount": 10}, // int 与 float64 不是同一输入
+		b(), a(),
+	}
+}
+
+func TestDedupInputs(t *testing.T) {
+	unique, counts := DedupInputs(craftedInputs())
+	if len(unique) != 3 || !slices.Equal(counts, []int{4, 2, 1}) {
+		t.Fatalf("unique = %d 条，counts = %v，应为 3 条与 [4 2 1]", len(unique), counts)
+	}
+	if unique[2]["amount"] != 10 {
+		t.Fatalf("应保持首次出现的顺序: %v", unique)
+	}
+}
+
+// fakeClock 每次匹配按输入的 amount 推进固定耗时，使实测与外推可以精确比较
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) match(in map[string]interface{}) {
+	switch v := in["amount"].(type) {
+	case float64:
+		c.now = c.now.Add(time.Duration(v) * time.Microsecond)
+	case int:
+		c.now = c.now.Add(time.Duration(v) * 3 * time.Microsecond)
+	}
+}
+
+func TestBenchmarkDedupModes(t *testing.T) {
+	inputs := craftedInputs()
+	run := func(mode DedupMode) DedupReport {
+		c := &fakeClock{now: time.Unix(0, 0)}
+		return benchmarkDedup(c.match, inputs, mode, c.Now)
+	}
+	off, drop, weighted := run(DedupOff), run(DedupDrop), run(DedupWeighted)
+
+	// 4×10 + 2×20 + 1×30 = 110µs
+	if off.TotalDuration != 110*time.Microsecond || off.DedupRatio != 0 || off.Unique != 7 {
+		t.Fatalf("off: %+v", off)
+	}
+	if want := 1 - 3.0/7; weighted.DedupRatio != want || drop.DedupRatio != want {
+		t.Fatalf("DedupRatio = %v / %v，应为 %v", weighted.DedupRatio, drop.DedupRatio, want)
+	}
+	if weighted.TotalDuration != off.TotalDuration || weighted.MeanPerInput != off.MeanPerInput {
+		t.Fatalf("外推总耗时 %v（平均 %v）应与完整运行的 %v（平均 %v）相同",
+			weighted.TotalDuration, weighted.MeanPerInput, off.TotalDuration, off.MeanPerInput)
+	}
+	// drop 只计唯一输入：10 + 20 + 30
+	if drop.TotalDuration != 60*time.Microsecond || drop.MeanPerInput != 20*time.Microsecond || drop.MeanUnique != weighted.MeanUnique {
+		t.Fatalf("drop: %+v", drop)
+	}
+	for _, r := range []DedupReport{off, drop, weighted} {
+		if r.Total != 7 {
+			t.Fatalf("%s: Total = %d", r.Mode, r.Total)
+		}
+	}
+	if weighted.Mode.String() != "weighted" || drop.Mode.String() != "drop" || off.Mode.String() != "off" {
+		t.Fatal("报告应注明使用的模式")
+	}
+}
+
+func TestBenchmarkMatchDedupEmpty(t *testing.T) {
+	if r := BenchmarkMatchDedup(NewRuleEngine(), nil, DedupWeighted); r.Total != 0 || r.TotalDuration != 0 {
+		t.Fatalf("空输入: %+v", r)
+	}
+}
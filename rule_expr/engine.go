@@ -1,45 +1,41 @@
 package rule_expr
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+	"slices"
+	"sort"
 	"time"
 
 	"sync"
+	"sync/atomic"
 
-	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 )
 
 /* ---------- 因子模板 ---------- */
 
-type Kind int
+// 因子定义与内置因子池由 rule_engine 提供，各后端共用同一份
+type (
+	Kind           = rule_engine.Kind
+	FactorTemplate = rule_engine.Factor
+	FactorPool     = rule_engine.FactorPool
+)
 
 const (
-	Bool Kind = iota
-	String
-	Int
+	Bool   = rule_engine.Bool
+	String = rule_engine.String
+	Int    = rule_engine.Int
+	Float  = rule_engine.Float
+	Time   = rule_engine.Time
+	List   = rule_engine.List
 )
 
-// FactorTemplate 描述一类可用于规则的因子
-type FactorTemplate struct {
-	Name         string        // 变量名
-	Kind         Kind          // Bool / String / Int
-	SampleValues []interface{} // 枚举值，用于生成 "==" 常量
-}
-
-// 现实场景因子池
-var factorPool = []FactorTemplate{
-	// Bool
-	{"is_vip", Bool, nil},
-	{"blacklisted", Bool, nil},
-	{"email_verified", Bool, nil},
-	{"high_risk_ip", Bool, nil},
-	// String
-	{"env", String, []interface{}{"prod", "staging", "test_env"}},
-	{"payment_method", String, []interface{}{"ABCD", "XYZ", "PAYPAL", "STRIPE"}},
-	// Int
-	{"user_id", Int, []interface{}{12345, 67890, 13579, 24680}},
+// DefaultFactorPool 返回内置的因子池
+func DefaultFactorPool() *FactorPool {
+	return rule_engine.DefaultFactorPool()
 }
 
 /* ---------- RuleEngine 与 Rule ---------- */
@@ -48,163 +44,685 @@ type Rule struct {
 	ID      string
 	ExprStr string
 	Program *vm.Program
+
+	Warnings []string // 编译时静态检查给出的提示
+	Factors  []string // 读取的输入变量，编译时从 AST 提取，去重并排序；成员访问 user.country 记为点路径
+	Flag     string   // 非空时仅当特性开关打开才参与匹配，见 SetFlagProvider
+	Priority int      // 越大越先评估，见 AddRuleWithPriority
+	Tags     []string // 已排序去重，见 AddRuleWithTags
+	// Description 规则说明，只用于展示与导出，见 LoadRulesFromJSON
+	Description string
+	// AllowUndefined 规则以容忍缺失因子的模式编译，见 WithAllowUndefined
+	AllowUndefined bool
+
+	disabled          atomic.Bool // 见 DisableRule；零值为启用
+	undefinedOverride *bool       // 加入时的 RuleMeta.AllowUndefined，nil 表示随引擎默认值
+
+	shape   *ruleShape // 编译时提取的因子与运算符
+	dag     *boolNode  // 原子条件上的布尔树，见 WithSharedPredicates
+	verdict string     // 声明域下的结论，见 LintFinding.Verdict
 }
 
 type RuleEngine struct {
-	rules         sync.Map // id -> *Rule
-	rulesNoneSync map[string]*Rule
+	// rules 当前规则快照（按加入顺序），匹配路径无锁读取；快照本身不可修改，
+	// 变更在 mu 下写时复制后整体替换。新增规则可能复用底层数组的空余容量，
+	// 但只写入旧快照长度之外的槽位，旧快照的读者看不到
+	rules    atomic.Pointer[[]*Rule]
+	index    map[string]int           // id -> 在当前快照中的下标，只在 mu 下使用
+	redactor atomic.Pointer[Redactor] // nil 表示原样输出
+
+	order   atomic.Pointer[orderedRules] // 按评估顺序排好的快照，见 ordered
+	orderMu sync.Mutex                   // 避免多个匹配同时重建 order
+
+	mu       sync.Mutex                // 串行化规则变更，保证内存与 store 顺序一致
+	store    RuleStore                 // 可选持久化后端
+	pool     *FactorPool               // 静态检查使用的因子池
+	cache    *rule_engine.CompileCache // 非 nil 时同文本的规则共享编译结果，见 WithCompileCache
+	useIndex bool                      // 匹配前用等值索引筛掉不可能命中的规则，见 WithEqualityIndex
+	preds    *predicateTable           // 非 nil 时规则拆出共享的原子条件，见 WithSharedPredicates
+	lenient  bool                      // 校验输入时不要求因子齐全，见 WithLenientInputs
+	// allowUndefined 规则默认容忍缺失因子，见 WithAllowUndefined
+	allowUndefined bool
+	closed         bool // Close 之后拒绝规则变更，由 mu 保护
+
+	idGen IDGenerator // AddRuleAuto 使用的 ID 生成器，由 mu 保护
+
+	generation atomic.Uint64                // 每次规则变更加一
+	recent     atomic.Pointer[recentRing]   // 最近匹配记录，nil 表示关闭
+	results    atomic.Pointer[resultCache]  // 匹配结果缓存，nil 表示关闭
+	shuffle    atomic.Pointer[shuffleState] // 非 nil 时每次匹配随机化评估顺序
+	flags      atomic.Pointer[flagProvider] // 特性开关，nil 时带开关的规则按关闭处理
+	evalErrors atomic.Uint64                // 规则执行出错（含结果不是 bool）的累计次数
+	matchCalls atomic.Uint64                // 见 EvalStats
+	evaluated  atomic.Uint64                // 见 EvalStats
+	onEvalErr  atomic.Pointer[func(ruleID string, err error)]
+	schema     atomic.Pointer[schemaCache] // InputJSONSchema 的缓存
+
+	asyncMu   sync.Mutex
+	asyncs    []*AsyncEngine // Close 时需要排空的异步匹配
+	closeOnce sync.Once
+	drain     atomic.Pointer[DrainReport]
 }
 
-func NewRuleEngine() *RuleEngine {
-	return &RuleEngine{
-		rules:         sync.Map{},
-		rulesNoneSync: make(map[string]*Rule),
+func NewRuleEngine(opts ...EngineOption) *RuleEngine {
+	re := &RuleEngine{
+		index: make(map[string]int),
+		pool:  DefaultFactorPool(),
 	}
+	for _, opt := range opts {
+		opt(re)
+	}
+	return re
 }
 
 // AddRule 编译并加入（或覆盖）一条规则
 func (re *RuleEngine) AddRule(id, exprStr string) error {
-	p, err := expr.Compile(exprStr, expr.AsBool())
+	return re.addRule(id, exprStr, RuleMeta{}, true)
+}
+
+// AddRuleWithFlag 加入受特性开关 flag 控制的规则
+func (re *RuleEngine) AddRuleWithFlag(id, exprStr, flag string) error {
+	return re.addRule(id, exprStr, RuleMeta{Flag: flag}, true)
+}
+
+// RuleMeta 规则表达式以外的属性，零值即 AddRule 加入的规则
+type RuleMeta struct {
+	Flag        string   // 见 AddRuleWithFlag
+	Priority    int      // 见 AddRuleWithPriority
+	Tags        []string // 见 AddRuleWithTags；经 normalizeTags 校验、排序去重
+	Description string
+	Disabled    bool // 加入后即处于停用状态，见 DisableRule
+	// AllowUndefined 非 nil 时覆盖引擎的 WithAllowUndefined
+	AllowUndefined *bool
+}
+
+// addRule 编译规则；persist 为 true 且配置了 store 时先落盘再更新内存
+func (re *RuleEngine) addRule(id, exprStr string, meta RuleMeta, persist bool) error {
+	r, err := re.compileRule(id, exprStr, meta)
 	if err != nil {
 		return err
 	}
-	re.rules.Store(id, &Rule{
-		ID:      id,
-		ExprStr: exprStr,
-		Program: p,
-	})
-	re.rulesNoneSync[id] = &Rule{
-		ID:      id,
-		ExprStr: exprStr,
-		Program: p,
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.insertLocked(r, persist)
+}
+
+// insertLocked 新增或替换编译好的规则；persist 为 true 且配置了 store 时先落盘。调用方持有 mu
+func (re *RuleEngine) insertLocked(r *Rule, persist bool) error {
+	if re.closed {
+		return ErrEngineClosed
 	}
+	if persist && re.store != nil {
+		if err := re.store.SaveRule(r.ID, r.ExprStr, r.meta()); err != nil {
+			return fmt.Errorf("持久化规则 %s 失败: %w", r.ID, err)
+		}
+	}
+	re.putLocked(r)
+	re.generation.Add(1)
 	return nil
 }
 
-// Match 遍历执行全部规则，返回命中 ID
-func (re *RuleEngine) Match(input map[string]interface{}) []string {
-	var hits []string
-	re.rules.Range(func(_, value any) bool {
-		r := value.(*Rule)
-		out, _ := expr.Run(r.Program, input)
-		if out.(bool) {
-			hits = append(hits, r.ID)
+// compileRule 编译并静态检查表达式，不修改引擎，可并发调用
+func (re *RuleEngine) compileRule(id, exprStr string, meta RuleMeta) (*Rule, error) {
+	lenient := re.ruleAllowsUndefined(meta)
+	c, err := re.compile(exprStr, lenient)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rule{
+		ID:       id,
+		ExprStr:  exprStr,
+		Program:  c.program,
+		shape:    c.shape,
+		Warnings: c.ruleWarnings(),
+		Factors:  slices.Clone(c.shape.Identifiers),
+		Flag:     meta.Flag,
+		Priority: meta.Priority,
+		Tags:     meta.Tags,
+		verdict:  c.verdict,
+		dag:      c.dag,
+
+		Description:    meta.Description,
+		AllowUndefined: lenient,
+
+		undefinedOverride: meta.AllowUndefined,
+	}
+	r.disabled.Store(meta.Disabled)
+	return r, nil
+}
+
+// snapshot 返回当前规则快照，调用方不得修改
+func (re *RuleEngine) snapshot() []*Rule {
+	if p := re.rules.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// lookup 按 ID 取规则
+func (re *RuleEngine) lookup(id string) (*Rule, bool) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	i, ok := re.index[id]
+	if !ok {
+		return nil, false
+	}
+	return re.snapshot()[i], true
+}
+
+// putLocked 新增或替换规则并发布新快照；调用方持有 mu
+func (re *RuleEngine) putLocked(r *Rule) {
+	old := re.snapshot()
+	var next []*Rule
+	if i, ok := re.index[r.ID]; ok {
+		next = slices.Clone(old)
+		next[i] = r
+	} else {
+		re.index[r.ID] = len(old)
+		next = append(old, r) // 只写入 len(old) 处，旧快照不可见
+	}
+	re.rules.Store(&next)
+}
+
+// putAllLocked 批量新增或替换规则，只复制并发布一次快照；调用方持有 mu
+func (re *RuleEngine) putAllLocked(rules []*Rule) {
+	old := re.snapshot()
+	next := make([]*Rule, len(old), len(old)+len(rules))
+	copy(next, old)
+	for _, r := range rules {
+		if i, ok := re.index[r.ID]; ok {
+			next[i] = r
+		} else {
+			re.index[r.ID] = len(next)
+			next = append(next, r)
 		}
-		return true
-	})
-	return hits
+	}
+	re.rules.Store(&next)
 }
 
-func (re *RuleEngine) MatchNoneSync(input map[string]interface{}) []string {
-	var hits []string
-	for _, r := range re.rulesNoneSync {
-		out, _ := expr.Run(r.Program, input)
-		if out.(bool) {
-			hits = append(hits, r.ID)
+// replaceAllLocked 用 rules 整体替换规则集并发布新快照，返回相对旧规则集新增、删除与修改的 ID（均已排序）。
+// 旧规则集中被停用的 ID 在新规则集中保持停用；调用方持有 mu
+func (re *RuleEngine) replaceAllLocked(rules []*Rule) (added, removed, updated []string) {
+	old := re.snapshot()
+	next := slices.Clone(rules)
+	index := make(map[string]int, len(next))
+	for i, r := range next {
+		index[r.ID] = i
+		j, ok := re.index[r.ID]
+		if !ok {
+			added = append(added, r.ID)
+			continue
+		}
+		prev := old[j]
+		if prev.ExprStr != r.ExprStr || prev.Flag != r.Flag || prev.Priority != r.Priority ||
+			!slices.Equal(prev.Tags, r.Tags) || prev.Description != r.Description {
+			updated = append(updated, r.ID)
+		}
+		if !prev.Enabled() {
+			r.disabled.Store(true)
 		}
 	}
-	return hits
+	for _, r := range old {
+		if _, ok := index[r.ID]; !ok {
+			removed = append(removed, r.ID)
+		}
+	}
+	re.index = index
+	re.rules.Store(&next)
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(updated)
+	return added, removed, updated
 }
 
-/* ---------- 随机规则注入 ---------- */
+// removeLocked 删除规则并发布新快照；调用方持有 mu
+func (re *RuleEngine) removeLocked(id string) bool {
+	i, ok := re.index[id]
+	if !ok {
+		return false
+	}
+	old := re.snapshot()
+	next := make([]*Rule, 0, len(old)-1)
+	next = append(append(next, old[:i]...), old[i+1:]...)
+	delete(re.index, id)
+	for j := i; j < len(next); j++ {
+		re.index[next[j].ID] = j
+	}
+	re.rules.Store(&next)
+	return true
+}
 
-// InjectRandomRules 生成 count 条随机规则
-func InjectRandomRules(re *RuleEngine, count int) error {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < count; i++ {
-		ruleID := fmt.Sprintf("auto-%d", i+1)
-		exprStr := randomExpr(r, 5) // ≤5 因子
-		if err := re.AddRule(ruleID, exprStr); err != nil {
-			return fmt.Errorf("编译规则 %s 失败: %w", ruleID, err)
-		} else {
-			fmt.Printf("编译规则 %s 成功: %s\n", ruleID, exprStr)
+// Enabled 规则是否参与匹配
+func (r *Rule) Enabled() bool {
+	return !r.disabled.Load()
+}
+
+// DisableRule 暂停规则而不删除已编译的程序，返回该 ID 是否存在。
+// 可与匹配并发调用：进行中的匹配在评估到该规则时读取最新状态。
+// 配置了 store 时先落盘，落盘失败则不改变状态并返回 false；用 AddRule 等覆盖规则后恢复为启用
+func (re *RuleEngine) DisableRule(id string) bool {
+	return re.setEnabled(id, false)
+}
+
+// EnableRule 恢复被 DisableRule 暂停的规则，返回该 ID 是否存在
+func (re *RuleEngine) EnableRule(id string) bool {
+	return re.setEnabled(id, true)
+}
+
+func (re *RuleEngine) setEnabled(id string, on bool) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	i, ok := re.index[id]
+	if !ok {
+		return false
+	}
+	r := re.snapshot()[i]
+	if r.Enabled() == on {
+		return true // 状态未变，不算规则变更
+	}
+	if re.store != nil {
+		meta := r.meta()
+		meta.Disabled = !on
+		if err := re.store.SaveRule(r.ID, r.ExprStr, meta); err != nil {
+			return false // 保持内存与 store 一致
 		}
 	}
+	r.disabled.Store(!on)
+	re.generation.Add(1)
+	return true
+}
+
+// updateMeta 修改规则的优先级或标签而不重新编译；配置了 store 时先落盘，失败则不修改内存
+func (re *RuleEngine) updateMeta(id string, edit func(*RuleMeta)) error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.closed {
+		return ErrEngineClosed
+	}
+	i, ok := re.index[id]
+	if !ok {
+		return fmt.Errorf("规则 %s 不存在", id)
+	}
+	old := re.snapshot()[i]
+	meta := old.meta()
+	edit(&meta)
+	if re.store != nil {
+		if err := re.store.SaveRule(id, old.ExprStr, meta); err != nil {
+			return fmt.Errorf("持久化规则 %s 失败: %w", id, err)
+		}
+	}
+	re.putLocked(old.withMeta(meta))
+	re.generation.Add(1)
 	return nil
 }
 
-// randomExpr 随机拼装布尔表达式
-func randomExpr(r *rand.Rand, maxFactors int) string {
-	// 1. 随机选取 1~maxFactors 个不同因子
-	n := r.Intn(maxFactors) + 1
-	perm := r.Perm(len(factorPool))[:n]
-	var factors []FactorTemplate
-	for _, idx := range perm {
-		factors = append(factors, factorPool[idx])
+// meta 规则当前的元数据，用于持久化与导出
+func (r *Rule) meta() RuleMeta {
+	return RuleMeta{
+		Flag:           r.Flag,
+		Priority:       r.Priority,
+		Tags:           slices.Clone(r.Tags),
+		Description:    r.Description,
+		Disabled:       !r.Enabled(),
+		AllowUndefined: r.undefinedOverride,
 	}
-	// 2. 递归拼装
-	return buildSubExpr(r, factors)
 }
 
-// buildSubExpr 递归生成子表达式
-func buildSubExpr(r *rand.Rand, factors []FactorTemplate) string {
-	if len(factors) == 1 {
-		frag := snippet(r, factors[0])
-		// 30% 概率前置 not
-		if r.Float64() < 0.3 {
-			return "not (" + frag + ")"
+// withMeta 返回换上 meta 中优先级、标签与停用状态的副本，编译产物与旧规则共用。
+// 特性开关、说明与容忍模式不随之改变（后者需要重新编译）
+func (r *Rule) withMeta(meta RuleMeta) *Rule {
+	next := &Rule{
+		ID:       r.ID,
+		ExprStr:  r.ExprStr,
+		Program:  r.Program,
+		Warnings: r.Warnings,
+		Factors:  r.Factors,
+		Flag:     r.Flag,
+		Priority: meta.Priority,
+		Tags:     meta.Tags,
+		shape:    r.shape,
+		dag:      r.dag,
+		verdict:  r.verdict,
+
+		Description:       r.Description,
+		AllowUndefined:    r.AllowUndefined,
+		undefinedOverride: r.undefinedOverride,
+	}
+	next.disabled.Store(meta.Disabled)
+	return next
+}
+
+// RemoveRule 删除规则，返回该 ID 是否存在；配置了 store 时先从 store 删除。
+// 与匹配并发安全：进行中的匹配继续使用删除前的快照
+func (re *RuleEngine) RemoveRule(id string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.closed {
+		return false
+	}
+	if _, ok := re.index[id]; !ok {
+		return false
+	}
+	if re.store != nil {
+		if err := re.store.DeleteRule(id); err != nil {
+			return false // 保持内存与 store 一致：store 删除失败则不删内存
 		}
-		return frag
 	}
-	split := r.Intn(len(factors)-1) + 1
-	left := buildSubExpr(r, factors[:split])
-	right := buildSubExpr(r, factors[split:])
-	op := "and"
-	if r.Float64() < 0.5 {
-		op = "or"
+	re.removeLocked(id)
+	re.generation.Add(1)
+	return true
+}
+
+// RuleInfo 规则的只读快照，不含编译产物
+type RuleInfo struct {
+	ID          string
+	Expr        string
+	Flag        string
+	Priority    int
+	Tags        []string
+	Enabled     bool
+	Description string
+	// AllowUndefined 规则是否容忍缺失因子，见 WithAllowUndefined
+	AllowUndefined bool
+}
+
+// ListRules 返回按 ID 排序的规则快照，是某一时刻的完整规则集
+func (re *RuleEngine) ListRules() []RuleInfo {
+	rules := re.snapshot()
+	out := make([]RuleInfo, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, RuleInfo{
+			ID:          r.ID,
+			Expr:        r.ExprStr,
+			Flag:        r.Flag,
+			Priority:    r.Priority,
+			Tags:        slices.Clone(r.Tags),
+			Enabled:     r.Enabled(),
+			Description: r.Description,
+
+			AllowUndefined: r.AllowUndefined,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RuleCount 返回当前规则数
+func (re *RuleEngine) RuleCount() int {
+	return len(re.snapshot())
+}
+
+// GetRuleFactors 返回规则读取的输入变量（见 Rule.Factors）；规则不存在时返回 false
+func (re *RuleEngine) GetRuleFactors(id string) ([]string, bool) {
+	r, ok := re.lookup(id)
+	if !ok {
+		return nil, false
 	}
-	return fmt.Sprintf("(%s %s %s)", left, op, right)
+	return slices.Clone(r.Factors), true
 }
 
-// snippet 产生单个因子的表达式片段
-func snippet(r *rand.Rand, f FactorTemplate) string {
-	switch f.Kind {
-	case Bool:
-		return f.Name
-	case String:
-		v := f.SampleValues[r.Intn(len(f.SampleValues))].(string)
-		return fmt.Sprintf("%s == %q", f.Name, v)
-	case Int:
-		v := f.SampleValues[r.Intn(len(f.SampleValues))].(int)
-		return fmt.Sprintf("%s == %d", f.Name, v)
-	default:
-		return f.Name
+// FactorUsage 返回每个输入变量被当前快照中多少条规则读取（含停用的规则）；同一规则多次读取只计一次
+func (re *RuleEngine) FactorUsage() map[string]int {
+	usage := make(map[string]int)
+	for _, r := range re.snapshot() {
+		for _, f := range r.Factors {
+			usage[f]++
+		}
 	}
+	return usage
 }
 
-/* ---------- 随机数据生成 & Benchmark ---------- */
+// Generation 返回当前规则集版本号，每次规则变更递增
+func (re *RuleEngine) Generation() uint64 {
+	return re.generation.Load()
+}
 
-// GenRandomInputs 生成 n 条随机测试数据
-func GenRandomInputs(n int) []map[string]interface{} {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	rows := make([]map[string]interface{}, n)
-	for i := 0; i < n; i++ {
-		row := make(map[string]interface{}, len(factorPool))
-		for _, f := range factorPool {
-			switch f.Kind {
-			case Bool:
-				row[f.Name] = r.Intn(2) == 0
-			case String:
-				row[f.Name] = f.SampleValues[r.Intn(len(f.SampleValues))]
-			case Int:
-				// 80% 概率用样例值，20% 用随机 5 位数
-				if r.Float64() < 0.8 {
-					row[f.Name] = f.SampleValues[r.Intn(len(f.SampleValues))]
-				} else {
-					row[f.Name] = r.Intn(90000) + 10000
-				}
+// Match 在调用开始时的规则快照上执行全部规则，按评估顺序（优先级从高到低，同优先级按 ID 升序）
+// 返回命中 ID；与规则变更并发安全，本次调用不会看到执行期间的变更
+func (re *RuleEngine) Match(input map[string]interface{}) []string {
+	hits, _ := re.MatchContext(context.Background(), input)
+	return hits
+}
+
+// ctxCheckEvery MatchContext 每评估多少条规则检查一次 ctx
+const ctxCheckEvery = 64
+
+// MatchContext 同 Match，但每评估 ctxCheckEvery 条规则检查一次 ctx：ctx 结束时停止遍历，
+// 返回已收集的部分命中与 ctx.Err()。被中断的匹配不写入最近匹配记录
+func (re *RuleEngine) MatchContext(ctx context.Context, input map[string]interface{}) ([]string, error) {
+	start := time.Now()
+	generation := re.generation.Load() // 先于读取规则，规则集随后变更时写入的结果即已过期
+	flags := re.flagSet()
+	cache := re.resultCacheFor(flags.p)
+	var key [16]byte
+	var canonical []byte
+	if cache != nil {
+		canonical = appendCanonicalMap(nil, input)
+		key = cache.hash(canonical)
+		if hits, ok := cache.get(key, canonical, generation, flags.p); ok {
+			re.matchCalls.Add(1)
+			re.recordMatch(input, hits, start)
+			return hits, nil
+		}
+	}
+	rules := re.orderedView().candidates(input)
+	if sh := re.shuffle.Load(); sh != nil {
+		rules = sh.shuffled(rules)
+	}
+	// Background 等不可取消的 ctx 的 Done() 为 nil，跳过检查
+	hits, failed, interrupted := re.evalRules(rules, &flags, input, ctx.Done(), nil)
+	if interrupted {
+		return hits, ctx.Err()
+	}
+	if cache != nil && !failed { // 有规则执行出错时不缓存结果
+		cache.put(key, canonical, generation, flags.p, hits)
+	}
+	re.recordMatch(input, hits, start)
+	return hits, nil
+}
+
+// evalRules 按顺序评估 rules 中开关允许的规则，返回命中 ID 与是否有规则出错，并计入 EvalStats（一次调用及实际评估的条数）。
+// 出错的规则经 noteEvalError 计数后再交给 onErr（可为 nil）。done 非 nil 时每 ctxCheckEvery 条检查一次，
+// 已结束则停止并返回已收集的命中与 interrupted = true
+func (re *RuleEngine) evalRules(rules []*Rule, flags *flagSet, input map[string]interface{}, done <-chan struct{},
+	onErr func(*Rule, error)) (hits []string, failed, interrupted bool) {
+	var machine vm.VM // 同一次匹配内复用 VM 及其栈，避免每条规则新建
+	evaluated := 0
+	defer func() {
+		re.matchCalls.Add(1)
+		re.evaluated.Add(uint64(evaluated))
+	}()
+	for i, r := range rules {
+		if done != nil && i%ctxCheckEvery == 0 {
+			select {
+			case <-done:
+				return hits, failed, true
+			default:
 			}
 		}
-		rows[i] = row
+		if !flags.allows(r) {
+			continue
+		}
+		evaluated++
+		if ok, err := evalRule(&machine, r, input); err != nil {
+			re.noteEvalError(r, err)
+			if onErr != nil {
+				onErr(r, err)
+			}
+			failed = true
+		} else if ok {
+			hits = append(hits, r.ID)
+		}
 	}
-	return rows
+	return hits, failed, false
 }
 
-// BenchmarkMatch 顺序匹配全部规则
-func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}) time.Duration {
+// MatchFirst 按评估顺序返回第一条命中的规则 ID，命中后立即停止评估。
+// 评估顺序为优先级从高到低、同优先级按 ID 升序；开启 ShuffleEvaluationOrder 时结果会随洗牌合法地变化，
+// 需要始终按优先级取结果时用 MatchFirstByPriority
+func (re *RuleEngine) MatchFirst(input map[string]interface{}) (string, bool) {
+	rules := re.orderedView().candidates(input)
+	if sh := re.shuffle.Load(); sh != nil {
+		rules = sh.shuffled(rules)
+	}
+	return re.matchFirst(rules, input)
+}
+
+// matchFirst 在 rules 上按顺序评估，返回第一条命中
+func (re *RuleEngine) matchFirst(rules []*Rule, input map[string]interface{}) (string, bool) {
 	start := time.Now()
-	for _, in := range inputs {
-		_ = re.MatchNoneSync(in)
+	var machine vm.VM
+	flags := re.flagSet()
+	evaluated := 0
+	defer func() {
+		re.matchCalls.Add(1)
+		re.evaluated.Add(uint64(evaluated))
+	}()
+	for _, r := range rules {
+		if !flags.allows(r) {
+			continue
+		}
+		evaluated++
+		if ok, err := evalRule(&machine, r, input); err != nil {
+			re.noteEvalError(r, err)
+		} else if ok {
+			re.recordMatch(input, []string{r.ID}, start)
+			return r.ID, true
+		}
 	}
-	return time.Since(start) / time.Duration(len(inputs))
+	re.recordMatch(input, nil, start)
+	return "", false
+}
+
+// MatchWithErrors 同 Match（不受 ShuffleEvaluationOrder 与等值索引影响，始终执行全部规则），另返回执行出错的规则：
+// 规则 ID -> 错误。出错的规则视为未命中；没有错误时 map 为 nil
+func (re *RuleEngine) MatchWithErrors(input map[string]interface{}) ([]string, map[string]error) {
+	start := time.Now()
+	var errs map[string]error
+	flags := re.flagSet()
+	hits, _, _ := re.evalRules(re.ordered(), &flags, input, nil, func(r *Rule, err error) {
+		if errs == nil {
+			errs = make(map[string]error)
+		}
+		errs[r.ID] = err
+	})
+	re.recordMatch(input, hits, start)
+	return hits, errs
+}
+
+// EvalErrors 返回规则执行出错（运行时错误或结果不是 bool）的累计次数；出错的规则按未命中处理
+func (re *RuleEngine) EvalErrors() uint64 {
+	return re.evalErrors.Load()
+}
+
+// SetEvalErrorHandler 设置规则执行出错时的回调，在匹配协程中同步调用；nil 表示取消
+func (re *RuleEngine) SetEvalErrorHandler(fn func(ruleID string, err error)) {
+	if fn == nil {
+		re.onEvalErr.Store(nil)
+		return
+	}
+	re.onEvalErr.Store(&fn)
+}
+
+func (re *RuleEngine) noteEvalError(r *Rule, err error) {
+	re.evalErrors.Add(1)
+	if fn := re.onEvalErr.Load(); fn != nil {
+		(*fn)(r.ID, err)
+	}
+}
+
+// evalRule 执行一条规则；运行时错误（如类型不匹配）或结果不是 bool（如引用的因子不在输入中）时返回错误
+func evalRule(machine *vm.VM, r *Rule, input map[string]interface{}) (bool, error) {
+	out, err := machine.Run(r.Program, input)
+	if err != nil {
+		return false, err
+	}
+	b, ok := out.(bool)
+	if !ok {
+		if out == nil {
+			return false, fmt.Errorf("规则结果为 nil，引用的因子可能不在输入中")
+		}
+		return false, fmt.Errorf("规则结果不是 bool: %T(%v)", out, out)
+	}
+	return b, nil
+}
+
+// MatchNoneSync 与 Match 相同。早期版本在一份不加锁的 map 副本上遍历以对比 sync.Map 的开销，
+// 规则改为单一快照后两者已无区别，仅为兼容既有调用保留。
+//
+// Deprecated: 使用 Match
+func (re *RuleEngine) MatchNoneSync(input map[string]interface{}) []string {
+	return re.Match(input)
+}
+
+/* ---------- 随机规则注入 ---------- */
+
+// Syntax 随机规则使用的 expr 写法
+var Syntax = rule_engine.Syntax{Not: "not", And: "and", Or: "or", Bool: "%s", Time: `date("%s")`, In: "%[1]s in %[2]s", Set: "[%s]", Len: "len(%s)",
+	Ternary: "((%s) ? %s : %s)", Coalesce: "(%s ?? 0)"}
+
+var _ rule_engine.Engine = (*RuleEngine)(nil)
+
+// InjectRandomRules 生成 count 条随机规则，见 rule_engine.InjectRandomRules
+func InjectRandomRules(re *RuleEngine, count int, opts ...rule_engine.GenOptions) error {
+	return rule_engine.InjectRandomRules(re, Syntax, count, opts...)
+}
+
+// InjectRandomRulesSeeded 用给定种子生成 count 条随机规则，见 rule_engine.InjectRandomRulesSeeded
+func InjectRandomRulesSeeded(re *RuleEngine, count int, seed int64, opts ...rule_engine.GenOptions) error {
+	return rule_engine.InjectRandomRulesSeeded(re, Syntax, count, seed, opts...)
+}
+
+/* ---------- 随机数据生成 & Benchmark ---------- */
+
+// GenRandomInputs 生成 n 条随机测试数据，见 rule_engine.GenRandomInputs
+func GenRandomInputs(n int) []map[string]interface{} {
+	return rule_engine.GenRandomInputs(n)
+}
+
+// GenRandomInputsSeeded 用给定种子生成 n 条随机测试数据，见 rule_engine.GenRandomInputsSeeded
+func GenRandomInputsSeeded(n int, seed int64) []map[string]interface{} {
+	return rule_engine.GenRandomInputsSeeded(n, seed)
+}
+
+// GenRandomInputsParallel 用 workers 个 goroutine 生成 n 条随机测试数据，取值分布与 GenRandomInputs 相同；
+// 每行使用独立的随机源，结果与相同种子下的串行生成完全一致
+func GenRandomInputsParallel(n, workers int) []map[string]interface{} {
+	return genRandomInputs(time.Now().UnixNano(), n, workers)
+}
+
+func genRandomInputs(seed int64, n, workers int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	parallelFill(n, workers, func(i int) {
+		rows[i] = rule_engine.RandomRow(itemRand(seed, i))
+	})
+	return rows
+}
+
+// BenchmarkMatch 顺序匹配全部规则；opts 为预热与重复次数，见 rule_engine.BenchmarkMatch
+func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	return rule_engine.BenchmarkMatch(re, inputs, opts...)
+}
+
+// BenchmarkMatchPrecise 只对引擎调用本身计时，并给出计时与循环开销
+func BenchmarkMatchPrecise(re *RuleEngine, inputs []map[string]interface{}) bench.Timing {
+	return rule_engine.BenchmarkMatchPrecise(re, inputs)
+}
+
+// BenchmarkMatchFirst 对 MatchFirst 计时，与 BenchmarkMatchPrecise 的方式相同
+func BenchmarkMatchFirst(re *RuleEngine, inputs []map[string]interface{}) bench.Timing {
+	return bench.Measure(func(in map[string]interface{}) []string {
+		re.MatchFirst(in)
+		return nil
+	}, inputs, bench.Options{})
+}
+
+// BenchmarkMatchAdaptive 持续采样直到均值的置信区间达到 opts 的目标宽度或触达上限
+func BenchmarkMatchAdaptive(re *RuleEngine, inputs []map[string]interface{}, opts bench.AdaptiveOptions) bench.AdaptiveTiming {
+	return rule_engine.BenchmarkMatchAdaptive(re, inputs, opts)
+}
+
+// BenchmarkMatchConcurrent 多个 goroutine 共享同一引擎并发 Match，报告吞吐与逐次耗时分位数
+func BenchmarkMatchConcurrent(re *RuleEngine, inputs []map[string]interface{}, goroutines int) bench.Concurrent {
+	return rule_engine.BenchmarkMatchConcurrent(re, inputs, goroutines)
 }
@@ -0,0 +1,122 @@
+package rule_expr
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// TestMatchMistypedInput 回归：整数 user_id 遇到与字符串比较的规则时，各匹配路径都不 panic；
+// == 在 expr 中直接为 false，> 与缺失的 Bool 因子求值出错，按未命中处理并计数、回调
+func TestMatchMistypedInput(t *testing.T) {
+	re := NewRuleEngine()
+	for id, expr := range map[string]string{
+		"eq":  `user_id == "abc"`,
+		"gt":  `user_id > "abc"`,
+		"vip": "is_vip",
+		"ok":  "user_id == 12345",
+	} {
+		if err := re.AddRule(id, expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var failed []string
+	re.SetEvalErrorHandler(func(ruleID string, err error) {
+		if err == nil {
+			t.Errorf("%s: 回调收到 nil 错误", ruleID)
+		}
+		failed = append(failed, ruleID)
+	})
+	input := map[string]interface{}{"user_id": 12345}
+
+	if hits := re.Match(input); !slices.Equal(hits, []string{"ok"}) {
+		t.Fatalf("Match = %v", hits)
+	}
+	if hits := re.MatchNoneSync(input); !slices.Equal(hits, []string{"ok"}) {
+		t.Fatalf("MatchNoneSync = %v", hits)
+	}
+	hits, errs := re.MatchWithErrors(input)
+	if !slices.Equal(hits, []string{"ok"}) || len(errs) != 2 || errs["gt"] == nil || errs["vip"] == nil {
+		t.Fatalf("MatchWithErrors = %v, %v", hits, errs)
+	}
+	slices.Sort(failed)
+	if re.EvalErrors() != 6 || !slices.Equal(failed, []string{"gt", "gt", "gt", "vip", "vip", "vip"}) {
+		t.Fatalf("EvalErrors = %d，回调 %v", re.EvalErrors(), failed)
+	}
+}
+
+// TestRuleFactors 取反、嵌套、成员访问、函数调用与闭包中抽取的输入变量；函数名与 # 不计入，重复读取只计一次
+func TestRuleFactors(t *testing.T) {
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"not is_vip", []string{"is_vip"}},
+		{`!(user.country in ["CN", "US"]) and not (amount > 100)`, []string{"amount", "user.country"}},
+		{`is_vip or (amount > 100 and (user.profile.level >= 2 or user.country == "CN"))`,
+			[]string{"amount", "is_vip", "user.country", "user.profile.level"}},
+		{`user["country"] == "CN"`, []string{"user.country"}},
+		{`len(tags) > 0 and "vip" in tags`, []string{"tags"}},
+		{`upper(env) == "PROD" and abs(amount - 10) < 5`, []string{"amount", "env"}},
+		{`any(tags, # == "vip") and amount > 1`, []string{"amount", "tags"}},
+		{"amount > 100 and amount < 500 or amount == 7", []string{"amount"}},
+		{`(user_id ?? 0) % 2 == 1 ? is_vip : env == "prod"`, []string{"env", "is_vip", "user_id"}},
+	}
+	re := NewRuleEngine()
+	want := make(map[string]int)
+	for i, c := range cases {
+		id := fmt.Sprintf("r%d", i)
+		if err := re.AddRule(id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := re.GetRuleFactors(id); !ok || !slices.Equal(got, c.want) {
+			t.Errorf("%s: %v，应为 %v", c.expr, got, c.want)
+		}
+		for _, f := range c.want {
+			want[f]++
+		}
+	}
+	if got := re.FactorUsage(); !maps.Equal(got, want) {
+		t.Fatalf("FactorUsage = %v，应为 %v", got, want)
+	}
+	if _, ok := re.GetRuleFactors("missing"); ok {
+		t.Fatal("不存在的规则应返回 false")
+	}
+}
+
+// TestEvalStatsCountsEveryPath 各匹配路径每次调用计一次，并计入实际评估的规则数（开关关闭的规则不计）
+func TestEvalStatsCountsEveryPath(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRuleWithTags("t1", "is_vip", "risk")
+	re.AddRuleWithTags("t2", "amount > 100", "risk")
+	re.AddRuleWithTags("t3", "user_id > 0", "risk") // 输入中缺少 user_id，执行出错
+	re.AddRule("u1", `env == "prod"`)
+	re.AddRuleWithFlag("off", "is_vip", "beta")
+	input := map[string]interface{}{"is_vip": false, "amount": 500.0, "env": "prod"}
+
+	paths := []struct {
+		name      string
+		fn        func()
+		evaluated uint64
+	}{
+		{"Match", func() { re.Match(input) }, 4},
+		{"MatchByTag", func() { re.MatchByTag("risk", input) }, 3},
+		{"MatchByTag 无此标签", func() { re.MatchByTag("none", input) }, 0},
+		{"MatchWithErrors", func() { re.MatchWithErrors(input) }, 4},
+		{"MatchFirst", func() { re.MatchFirst(input) }, 2}, // 按 ID 顺序 t1 未命中、t2 命中
+		{"MatchParallel", func() { re.MatchParallel(input, 2) }, 4},
+	}
+	for _, p := range paths {
+		before := re.EvalStats()
+		p.fn()
+		after := re.EvalStats()
+		if after.Matches-before.Matches != 1 || after.Evaluated-before.Evaluated != p.evaluated {
+			t.Errorf("%s: Matches +%d，Evaluated +%d，应为 +1 与 +%d", p.name,
+				after.Matches-before.Matches, after.Evaluated-before.Evaluated, p.evaluated)
+		}
+	}
+	if hits, errs := re.MatchWithErrors(input); !slices.Equal(hits, []string{"t2", "u1"}) || len(errs) != 1 || errs["t3"] == nil {
+		t.Fatalf("MatchWithErrors = %v, %v", hits, errs)
+	}
+}
@@ -0,0 +1,221 @@
+package rule_expr
+
+import (
+	"slices"
+	"strings"
+)
+
+/* ---------- 等值索引 ---------- */
+
+// WithEqualityIndex 启用等值索引：规则顶层 and 连接的 "因子 == 常量"（及 "因子 in [常量, ...]"）是命中的必要条件，
+// 按其中一个约束把规则登记到 (因子, 常量) 的倒排表中（in 按数组中的每个常量登记）；匹配时只评估输入取值对得上的规则，
+// 以及没有可索引约束、必须逐条执行的规则（约束在 not / or 之下、只有范围比较等）。
+// 作用于 Match / MatchContext / MatchFirst / MatchFirstByPriority，命中结果与顺序和不启用时相同；
+// 被跳过的规则不执行，因此也不会报告执行错误。需要完整错误报告时用 MatchWithErrors（始终逐条执行）。
+// 索引随评估顺序在规则变更后的第一次匹配时重建
+func WithEqualityIndex() EngineOption {
+	return func(re *RuleEngine) {
+		re.useIndex = true
+	}
+}
+
+// eqIndex 某个评估顺序快照上的等值索引；规则以其在评估顺序中的下标表示
+type eqIndex struct {
+	byFactor map[string]*factorPostings
+	factors  []string // byFactor 的键，已排序
+	fallback []int32  // 没有可索引约束的规则，升序
+	indexed  int      // 登记在倒排表中的规则数
+}
+
+// factorPostings 一个因子上的倒排表
+type factorPostings struct {
+	byValue map[interface{}][]int32 // 归一化的常量 -> 规则下标，升序
+	all     []int32                 // 按该因子登记的全部规则，输入取值无法归一化时使用
+}
+
+// buildEqIndex 为按评估顺序排列的 rules 建立等值索引
+func buildEqIndex(rules []*Rule) *eqIndex {
+	idx := &eqIndex{byFactor: make(map[string]*factorPostings)}
+	for i, r := range rules {
+		c, keys, ok := indexConstraint(r.shape.Required)
+		if !ok {
+			idx.fallback = append(idx.fallback, int32(i))
+			continue
+		}
+		p := idx.byFactor[c.Factor]
+		if p == nil {
+			p = &factorPostings{byValue: make(map[interface{}][]int32)}
+			idx.byFactor[c.Factor] = p
+			idx.factors = append(idx.factors, c.Factor)
+		}
+		for _, key := range keys {
+			p.byValue[key] = append(p.byValue[key], int32(i))
+		}
+		p.all = append(p.all, int32(i))
+		idx.indexed++
+	}
+	slices.Sort(idx.factors)
+	return idx
+}
+
+// indexConstraint 选取用于登记的约束及其归一化后的常量（去重）。常量都能归一化的约束中，
+// 按筛选力度优先取非 bool 的 ==，其次 bool 的 ==，最后 in
+func indexConstraint(required []comparison) (comparison, []interface{}, bool) {
+	best, bestRank := -1, 0
+	var bestKeys []interface{}
+	for i, c := range required {
+		values := []interface{}{c.Value}
+		if c.Operator == "in" {
+			values = c.Value.([]interface{})
+		}
+		keys, ok := normalizeAll(values)
+		if !ok {
+			continue
+		}
+		rank := 3
+		if c.Operator == "in" {
+			rank = 1
+		} else if _, isBool := c.Value.(bool); isBool {
+			rank = 2
+		}
+		if rank > bestRank {
+			best, bestRank, bestKeys = i, rank, keys
+		}
+	}
+	if best < 0 {
+		return comparison{}, nil, false
+	}
+	return required[best], bestKeys, true
+}
+
+// normalizeAll 归一化并去重一组常量，任一无法归一化时返回 false
+func normalizeAll(values []interface{}) ([]interface{}, bool) {
+	keys := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		key, ok := normalizeEq(v)
+		if !ok {
+			return nil, false
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, true
+}
+
+// normalizeEq 把常量或输入取值归一化为索引键：expr 的 == 跨数值类型按数值比较，数值统一为 float64
+// （大整数转换后可能相撞，只会多评估规则，不会漏掉）；字符串与 bool 原样；其余类型返回 false
+func normalizeEq(v interface{}) (interface{}, bool) {
+	switch x := v.(type) {
+	case string, bool:
+		return x, true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return nil, false
+}
+
+// lookupEq 按点路径取输入值：found 为 false 表示缺失（与任何非 nil 常量都不相等）；
+// 中间层不是 map（如结构体，expr 仍能访问其字段）时无法判断，known 为 false
+func lookupEq(input map[string]interface{}, name string) (v interface{}, found, known bool) {
+	m := input
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			v, found = m[name]
+			return v, found, true
+		}
+		switch next := m[head].(type) {
+		case map[string]interface{}:
+			m, name = next, rest
+		case nil:
+			return nil, false, true
+		default:
+			return nil, false, false
+		}
+	}
+}
+
+// candidates 返回 input 下可能命中的规则，保持评估顺序；未启用等值索引时为全部规则
+func (o *orderedRules) candidates(input map[string]interface{}) []*Rule {
+	idx := o.eq
+	if idx == nil {
+		return o.rules
+	}
+	pos := make([]int32, len(idx.fallback), len(idx.fallback)+len(idx.factors)*8)
+	copy(pos, idx.fallback)
+	for _, name := range idx.factors {
+		p := idx.byFactor[name]
+		v, found, known := lookupEq(input, name)
+		if !known {
+			pos = append(pos, p.all...)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if key, ok := normalizeEq(v); ok {
+			pos = append(pos, p.byValue[key]...)
+		} else {
+			pos = append(pos, p.all...)
+		}
+	}
+	slices.Sort(pos)
+	out := make([]*Rule, len(pos))
+	for i, k := range pos {
+		out[i] = o.rules[k]
+	}
+	return out
+}
+
+/* ---------- 评估统计 ---------- */
+
+// EvalStats 规则评估统计，用于观察等值索引跳过了多少规则
+type EvalStats struct {
+	Matches   uint64 // Match / MatchContext / MatchFirst / MatchFirstByPriority / MatchByTag / MatchWithErrors / MatchParallel 的累计调用次数
+	Evaluated uint64 // 这些调用实际执行的规则数
+	Indexed   int    // 当前规则中登记在等值索引里、可被跳过的规则数；未启用索引时为 0
+	Fallback  int    // 当前规则中每次匹配都要执行的规则数；未启用索引时为全部规则
+}
+
+// PerMatch 平均每次匹配执行的规则数
+func (s EvalStats) PerMatch() float64 {
+	if s.Matches == 0 {
+		return 0
+	}
+	return float64(s.Evaluated) / float64(s.Matches)
+}
+
+// EvalStats 返回评估统计；Indexed / Fallback 取自当前规则集
+func (re *RuleEngine) EvalStats() EvalStats {
+	s := EvalStats{Matches: re.matchCalls.Load(), Evaluated: re.evaluated.Load()}
+	o := re.orderedView()
+	if o.eq != nil {
+		s.Indexed, s.Fallback = o.eq.indexed, len(o.eq.fallback)
+	} else {
+		s.Fallback = len(o.rules)
+	}
+	return s
+}
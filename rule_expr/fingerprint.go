@@ -0,0 +1,125 @@
+package rule_expr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+)
+
+/* ---------- 输入指纹 ---------- */
+
+// 规范编码中的类型标记。同值不同类型（int 1、int64 1、float64 1、"1"）编码必然不同
+const (
+	tagNil byte = iota + 1
+	tagBool
+	tagInt
+	tagInt8
+	tagInt16
+	tagInt32
+	tagInt64
+	tagUint
+	tagUint8
+	tagUint16
+	tagUint32
+	tagUint64
+	tagFloat32
+	tagFloat64
+	tagString
+	tagMap   // map[string]interface{}，键排序后递归编码
+	tagSlice // []interface{}，按顺序递归编码
+	tagOther // 其余类型：%T 与 %v 的文本，尽力而为
+)
+
+// FingerprintInput 返回输入的 128 位指纹：对规范编码做 FNV-1a 128。
+//
+// 规范编码：按键的字节序排序，每个键值对依次写入
+// uvarint(键长) 键 类型标记 值。值的编码：bool 一个字节；有符号整数 8 字节大端补码；
+// 无符号整数 8 字节大端；浮点数为 IEEE 754 位模式（float32 4 字节、float64 8 字节）；
+// 字符串 uvarint(长度) 加内容；map[string]interface{} 与 []interface{} 先写 uvarint(元素数) 再递归。
+// 结果与 map 的插入顺序无关，外部调用方可以预先计算
+func FingerprintInput(input map[string]interface{}) [16]byte {
+	return fingerprintBytes(appendCanonicalMap(nil, input))
+}
+
+// fingerprintBytes 对已算好的规范编码求指纹
+func fingerprintBytes(canonical []byte) [16]byte {
+	h := fnv.New128a()
+	h.Write(canonical)
+	var out [16]byte
+	h.Sum(out[:0])
+	return out
+}
+
+// inputKey 输入的规范编码，用作去重等场景的 map 键
+func inputKey(in map[string]interface{}) string {
+	return string(appendCanonicalMap(nil, in))
+}
+
+func appendCanonicalMap(b []byte, m map[string]interface{}) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	b = binary.AppendUvarint(b, uint64(len(keys)))
+	for _, k := range keys {
+		b = appendCanonicalString(b, k)
+		b = appendCanonicalValue(b, m[k])
+	}
+	return b
+}
+
+func appendCanonicalString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendCanonicalValue(b []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return append(b, tagNil)
+	case bool:
+		if x {
+			return append(b, tagBool, 1)
+		}
+		return append(b, tagBool, 0)
+	case int:
+		return binary.BigEndian.AppendUint64(append(b, tagInt), uint64(x))
+	case int8:
+		return binary.BigEndian.AppendUint64(append(b, tagInt8), uint64(x))
+	case int16:
+		return binary.BigEndian.AppendUint64(append(b, tagInt16), uint64(x))
+	case int32:
+		return binary.BigEndian.AppendUint64(append(b, tagInt32), uint64(x))
+	case int64:
+		return binary.BigEndian.AppendUint64(append(b, tagInt64), uint64(x))
+	case uint:
+		return binary.BigEndian.AppendUint64(append(b, tagUint), uint64(x))
+	case uint8:
+		return binary.BigEndian.AppendUint64(append(b, tagUint8), uint64(x))
+	case uint16:
+		return binary.BigEndian.AppendUint64(append(b, tagUint16), uint64(x))
+	case uint32:
+		return binary.BigEndian.AppendUint64(append(b, tagUint32), uint64(x))
+	case uint64:
+		return binary.BigEndian.AppendUint64(append(b, tagUint64), x)
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, tagFloat32), math.Float32bits(x))
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, tagFloat64), math.Float64bits(x))
+	case string:
+		return appendCanonicalString(append(b, tagString), x)
+	case map[string]interface{}:
+		return appendCanonicalMap(append(b, tagMap), x)
+	case []interface{}:
+		b = binary.AppendUvarint(append(b, tagSlice), uint64(len(x)))
+		for _, e := range x {
+			b = appendCanonicalValue(b, e)
+		}
+		return b
+	default:
+		return appendCanonicalString(append(b, tagOther), fmt.Sprintf("%T:%v", x, x))
+	}
+}
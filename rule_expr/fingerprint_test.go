@@ -0,0 +1,174 @@
+package rule_expr
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestFingerprintInsertionOrder 相同内容的 map 以不同顺序构造，指纹相同
+func TestFingerprintInsertionOrder(t *testing.T) {
+	keys := []string{"env", "amount", "user", "tags", "is_vip", "z", "a"}
+	value := func(k string) interface{} {
+		switch k {
+		case "user":
+			return map[string]interface{}{"country": "CN", "level": 3}
+		case "tags":
+			return []interface{}{"x", 1, nil}
+		}
+		return k + "-v"
+	}
+	var want [16]byte
+	for i := 0; i < 20; i++ {
+		m := make(map[string]interface{})
+		order := slices.Clone(keys)
+		for j := range order {
+			k := (j*7 + i) % len(order)
+			order[j], order[k] = order[k], order[j]
+		}
+		for _, k := range order {
+			m[k] = value(k)
+		}
+		if got := FingerprintInput(m); i == 0 {
+			want = got
+		} else if got != want {
+			t.Fatalf("插入顺序 %v 的指纹不同", order)
+		}
+	}
+}
+
+// TestFingerprintDistinguishesTypes 同值不同类型、嵌套结构与键值边界不同的输入指纹都不同
+func TestFingerprintDistinguishesTypes(t *testing.T) {
+	inputs := map[string]map[string]interface{}{
+		"int 1":            {"x": 1},
+		"int64 1":          {"x": int64(1)},
+		"float64 1":        {"x": 1.0},
+		"float32 1":        {"x": float32(1)},
+		`"1"`:              {"x": "1"},
+		"true":             {"x": true},
+		"nil":              {"x": nil},
+		"缺失":               {},
+		"嵌套 map":           {"x": map[string]interface{}{"1": 1}},
+		"列表":               {"x": []interface{}{1}},
+		"空列表":              {"x": []interface{}{}},
+		"键值边界 ab/c":        {"ab": "c"},
+		"键值边界 a/bc":        {"a": "bc"},
+		"两个键":              {"a": "b", "c": "d"},
+		"一个键含分隔":           {"a": "b\x00c"},
+		"user.country 点路径": {"user.country": "CN"},
+		"user.country 嵌套":  {"user": map[string]interface{}{"country": "CN"}},
+	}
+	seen := make(map[[16]byte]string)
+	for name, in := range inputs {
+		fp := FingerprintInput(in)
+		if other, ok := seen[fp]; ok {
+			t.Errorf("%s 与 %s 指纹相同", name, other)
+		}
+		seen[fp] = name
+	}
+}
+
+func TestResultCacheHitsAndInvalidation(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("big", "amount > 100")
+	re.AddRule("vip", "is_vip")
+	re.SetResultCache(8)
+	in := map[string]interface{}{"amount": 250.0, "is_vip": false}
+
+	first := re.Match(in)
+	second := re.Match(map[string]interface{}{"is_vip": false, "amount": 250.0})
+	if !slices.Equal(first, []string{"big"}) || !slices.Equal(second, first) {
+		t.Fatalf("first = %v, second = %v", first, second)
+	}
+	if s := re.ResultCacheStats(); s.Hits != 1 || s.Misses != 1 || s.Entries != 1 {
+		t.Fatalf("stats = %+v", s)
+	}
+	second[0] = "changed" // 返回的是副本
+	if got := re.Match(in); !slices.Equal(got, []string{"big"}) {
+		t.Fatalf("修改返回值影响了缓存: %v", got)
+	}
+
+	// 同值不同类型是不同的输入：int 250 让 amount > 100 照常求值
+	if got := re.Match(map[string]interface{}{"amount": 250, "is_vip": true}); len(got) != 2 {
+		t.Fatalf("got %v", got)
+	}
+
+	// 规则变更后旧结果失效
+	re.DisableRule("big")
+	if got := re.Match(in); len(got) != 0 {
+		t.Fatalf("停用后仍返回缓存的命中: %v", got)
+	}
+	re.AddRule("all", "true")
+	if got := re.Match(in); !slices.Equal(got, []string{"all"}) {
+		t.Fatalf("加入规则后仍返回缓存的结果: %v", got)
+	}
+
+	// 执行出错的匹配不写入缓存
+	re.EnableRule("big")
+	before := re.ResultCacheStats().Entries
+	re.Match(map[string]interface{}{"amount": "x", "is_vip": false})
+	if s := re.ResultCacheStats(); s.Entries != before {
+		t.Fatalf("执行出错的结果不应缓存: %+v", s)
+	}
+}
+
+// TestResultCacheParanoidCatchesCollision 用所有输入都相同的弱哈希制造碰撞：
+// 普通模式返回了另一个输入的结果，Paranoid 模式检测到碰撞并返回正确结果
+func TestResultCacheParanoidCatchesCollision(t *testing.T) {
+	constHash := func([]byte) [16]byte { return [16]byte{1} }
+	a := map[string]interface{}{"is_vip": true}
+	b := map[string]interface{}{"is_vip": false}
+
+	for _, paranoid := range []bool{false, true} {
+		re := NewRuleEngine()
+		re.AddRule("vip", "is_vip")
+		re.SetResultCache(8, ResultCacheOptions{Paranoid: paranoid})
+		re.results.Load().hash = constHash
+
+		re.Match(a)
+		got := re.Match(b)
+		s := re.ResultCacheStats()
+		if !paranoid {
+			if !slices.Equal(got, []string{"vip"}) || s.Collisions != 0 {
+				t.Fatalf("普通模式应无法察觉碰撞（返回 a 的结果）: got %v, %+v", got, s)
+			}
+			continue
+		}
+		if len(got) != 0 || s.Collisions != 1 || s.Hits != 0 {
+			t.Fatalf("Paranoid 模式应检测到碰撞: got %v, %+v", got, s)
+		}
+		if got := re.Match(a); !slices.Equal(got, []string{"vip"}) || re.ResultCacheStats().Collisions != 2 {
+			t.Fatalf("碰撞的条目被覆盖后 a 应再次检测到碰撞: got %v, %+v", got, re.ResultCacheStats())
+		}
+	}
+}
+
+func TestResultCacheBypassAndEviction(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRuleWithFlag("beta", "is_vip", "beta")
+	re.SetResultCache(2)
+	on := true
+	re.SetFlagProvider(func(string) bool { return on })
+	in := map[string]interface{}{"is_vip": true}
+	re.Match(in)
+	on = false
+	if got := re.Match(in); len(got) != 0 {
+		t.Fatalf("设置了特性开关时不应使用缓存: %v", got)
+	}
+	if s := re.ResultCacheStats(); s.Hits+s.Misses != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+
+	re = NewRuleEngine()
+	re.AddRule("vip", "is_vip")
+	re.SetResultCache(2)
+	for i := 0; i < 3; i++ {
+		re.Match(map[string]interface{}{"is_vip": true, "n": i})
+	}
+	if s := re.ResultCacheStats(); s.Entries != 2 || s.Evictions != 1 || s.Capacity != 2 {
+		t.Fatalf("stats = %+v", s)
+	}
+	re.SetResultCache(0)
+	if s := re.ResultCacheStats(); s != (ResultCacheStats{}) {
+		t.Fatalf("关闭后 stats = %+v", s)
+	}
+}
@@ -0,0 +1,85 @@
+package rule_expr
+
+/* ---------- 特性开关 ---------- */
+
+// flagProvider 外部特性开关；fn 为 nil 或 panic 时使用 def
+type flagProvider struct {
+	fn  func(flag string) bool
+	def bool
+}
+
+// SetFlagProvider 设置特性开关查询函数。带 Flag 的规则仅在 fn(Flag) 为 true 时参与匹配，
+// 同一次 Match 中每个开关最多查询一次。传 nil 表示不再查询，带开关的规则按默认值处理
+func (re *RuleEngine) SetFlagProvider(fn func(flag string) bool) {
+	p := &flagProvider{fn: fn}
+	if old := re.flags.Load(); old != nil {
+		p.def = old.def
+	}
+	re.flags.Store(p)
+}
+
+// SetFlagDefault 设置查询函数为 nil 或查询 panic 时开关的取值，默认 false（关闭）
+func (re *RuleEngine) SetFlagDefault(on bool) {
+	p := &flagProvider{def: on}
+	if old := re.flags.Load(); old != nil {
+		p.fn = old.fn
+	}
+	re.flags.Store(p)
+}
+
+// flagSet 一次匹配内的开关视图：固定使用匹配开始时的 provider，并缓存查询结果
+type flagSet struct {
+	p     *flagProvider
+	cache map[string]bool
+}
+
+func (re *RuleEngine) flagSet() flagSet {
+	return flagSet{p: re.flags.Load()}
+}
+
+// resolve 预先查询 rules 引用的全部开关；之后对这些规则调用 allows 不再写缓存，可在多个 goroutine 间只读共享
+func (s *flagSet) resolve(rules []*Rule) {
+	for _, r := range rules {
+		if _, ok := s.cache[r.Flag]; r.Flag == "" || ok {
+			continue
+		}
+		if s.cache == nil {
+			s.cache = make(map[string]bool)
+		}
+		s.cache[r.Flag] = s.query(r.Flag)
+	}
+}
+
+// allows 规则已启用，且不带开关或开关打开时返回 true
+func (s *flagSet) allows(r *Rule) bool {
+	if r.disabled.Load() {
+		return false
+	}
+	if r.Flag == "" {
+		return true
+	}
+	if on, ok := s.cache[r.Flag]; ok {
+		return on
+	}
+	on := s.query(r.Flag)
+	if s.cache == nil {
+		s.cache = make(map[string]bool)
+	}
+	s.cache[r.Flag] = on
+	return on
+}
+
+func (s *flagSet) query(flag string) (on bool) {
+	if s.p == nil {
+		return false
+	}
+	if s.p.fn == nil {
+		return s.p.def
+	}
+	defer func() {
+		if recover() != nil {
+			on = s.p.def
+		}
+	}()
+	return s.p.fn(flag)
+}
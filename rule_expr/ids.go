@@ -0,0 +1,126 @@
+package rule_expr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------- 规则 ID 生成 ---------- */
+
+// IDGenerator 为程序化创建的规则分配 ID，参数为规范化后的表达式
+type IDGenerator interface {
+	Next(exprCanonical string) string
+}
+
+// SequentialIDs 顺序 ID：prefix-1、prefix-2 ...
+type SequentialIDs struct {
+	Prefix string
+	n      atomic.Int64
+}
+
+func (g *SequentialIDs) Next(string) string {
+	return fmt.Sprintf("%s-%d", g.Prefix, g.n.Add(1))
+}
+
+// ULIDIDs 按时间有序的随机 ID：48 位毫秒时间戳 + 80 位随机数，Crockford base32 编码为 26 个字符
+type ULIDIDs struct{}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func (ULIDIDs) Next(string) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+	// 128 位按 5 位一组编码，最高位补 2 个 0
+	out := make([]byte, 26)
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// ContentAddresser 可由 IDGenerator 额外实现：ContentAddressed 返回 true 表示 Next 只取决于表达式，
+// 同一表达式总得到同一 ID。AddRuleAuto 据此把 ID 已被占用的情况当作重复（表达式相同）或冲突（表达式不同），
+// 而不是重新生成
+type ContentAddresser interface {
+	ContentAddressed() bool
+}
+
+// ContentHashIDs 内容寻址 ID：规范化表达式的 sha256 前 8 字节。
+// 相同规则得到相同 ID，AddRuleAuto 会直接返回已有规则而不重复添加；值与指针均可传给 SetIDGenerator
+type ContentHashIDs struct {
+	Prefix string
+}
+
+func (g ContentHashIDs) Next(exprCanonical string) string {
+	sum := sha256.Sum256([]byte(exprCanonical))
+	return g.Prefix + hex.EncodeToString(sum[:8])
+}
+
+func (ContentHashIDs) ContentAddressed() bool {
+	return true
+}
+
+// ErrIDCollision 内容寻址 ID 已被表达式不同的规则占用
+var ErrIDCollision = errors.New("自动 ID 冲突")
+
+// maxIDAttempts 自动 ID 冲突时的最大重试次数
+const maxIDAttempts = 16
+
+// SetIDGenerator 设置 AddRuleAuto 使用的 ID 生成器，默认 SequentialIDs{Prefix: "auto"}
+func (re *RuleEngine) SetIDGenerator(g IDGenerator) {
+	re.mu.Lock()
+	re.idGen = g
+	re.mu.Unlock()
+}
+
+// AddRuleAuto 用 ID 生成器分配 ID，按 meta 添加规则并返回 ID。查重与写入在同一次加锁内完成，
+// 不会覆盖并发 AddRule 加入的同 ID 规则；生成的 ID 已被占用时重新生成，最多 maxIDAttempts 次。
+// 生成器实现 ContentAddresser（如 ContentHashIDs）时不重新生成：同一表达式已存在则直接返回其 ID
+// （不更新其属性），ID 被表达式不同的规则占用则返回 ErrIDCollision
+func (re *RuleEngine) AddRuleAuto(exprStr string, meta RuleMeta) (string, error) {
+	canonical, err := canonicalExpr(exprStr)
+	if err != nil {
+		return "", err
+	}
+	if meta.Tags, err = normalizeTags(meta.Tags); err != nil {
+		return "", err
+	}
+	r, err := re.compileRule("", exprStr, meta)
+	if err != nil {
+		return "", err
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.idGen == nil {
+		re.idGen = &SequentialIDs{Prefix: "auto"}
+	}
+	ca, ok := re.idGen.(ContentAddresser)
+	contentAddressed := ok && ca.ContentAddressed()
+	for i := 0; i < maxIDAttempts; i++ {
+		id := re.idGen.Next(canonical)
+		j, exists := re.index[id]
+		if !exists {
+			r.ID = id
+			return id, re.insertLocked(r, true)
+		}
+		if contentAddressed {
+			existing := re.snapshot()[j].ExprStr
+			if c, err := canonicalExpr(existing); err == nil && c == canonical {
+				return id, nil
+			}
+			return "", fmt.Errorf("%w: %s 已被规则 %q 占用", ErrIDCollision, id, existing)
+		}
+	}
+	return "", fmt.Errorf("连续 %d 次生成的 ID 均已被占用", maxIDAttempts)
+}
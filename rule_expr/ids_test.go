@@ -0,0 +1,204 @@
+package rule_expr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAddRuleAutoSequentialSkipsTakenIDs(t *testing.T) {
+	re := NewRuleEngine()
+	if err := re.AddRule("auto-1", "is_vip"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := re.AddRuleAuto("blacklisted", RuleMeta{Priority: 3, Tags: []string{"risk", "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "auto-2" {
+		t.Fatalf("id = %s，应跳过已占用的 auto-1", id)
+	}
+	rules := re.ListRules()
+	if len(rules) != 2 || rules[0].Expr != "is_vip" {
+		t.Fatalf("已有规则被覆盖: %+v", rules)
+	}
+	if got := rules[1]; got.Priority != 3 || strings.Join(got.Tags, ",") != "a,risk" {
+		t.Fatalf("meta 未生效: %+v", got)
+	}
+}
+
+func TestAddRuleAutoULIDUnique(t *testing.T) {
+	re := NewRuleEngine()
+	re.SetIDGenerator(ULIDIDs{})
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		id, err := re.AddRuleAuto("is_vip", RuleMeta{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 || seen[id] {
+			t.Fatalf("ULID %q 长度不对或重复", id)
+		}
+		seen[id] = true
+	}
+	if re.RuleCount() != 200 {
+		t.Fatalf("RuleCount = %d，ULID 模式不应去重", re.RuleCount())
+	}
+}
+
+// scriptedIDs 依次返回 ids，用完后返回 fallback-n
+type scriptedIDs struct {
+	ids   []string
+	calls int
+}
+
+func (g *scriptedIDs) Next(string) string {
+	g.calls++
+	if len(g.ids) > 0 {
+		id := g.ids[0]
+		g.ids = g.ids[1:]
+		return id
+	}
+	return fmt.Sprintf("fallback-%d", g.calls)
+}
+
+func TestAddRuleAutoRetriesAndGivesUp(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("taken", "is_vip")
+	re.SetIDGenerator(&scriptedIDs{ids: []string{"taken", "taken"}})
+	id, err := re.AddRuleAuto("blacklisted", RuleMeta{})
+	if err != nil || id != "fallback-3" {
+		t.Fatalf("AddRuleAuto = %q, %v，应在两次冲突后取 fallback-3", id, err)
+	}
+
+	always := make([]string, maxIDAttempts)
+	for i := range always {
+		always[i] = "taken"
+	}
+	re.SetIDGenerator(&scriptedIDs{ids: always})
+	if _, err := re.AddRuleAuto("blacklisted", RuleMeta{}); err == nil {
+		t.Fatal("ID 一直被占用时应报错")
+	}
+}
+
+func TestAddRuleAutoContentHashDedupes(t *testing.T) {
+	for _, gen := range []IDGenerator{ContentHashIDs{Prefix: "h-"}, &ContentHashIDs{Prefix: "h-"}} {
+		t.Run(fmt.Sprintf("%T", gen), func(t *testing.T) {
+			re := NewRuleEngine()
+			re.SetIDGenerator(gen)
+			first, err := re.AddRuleAuto(`env == "prod" && is_vip`, RuleMeta{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 写法不同、规范形式相同的表达式
+			second, err := re.AddRuleAuto(`env=="prod" and is_vip`, RuleMeta{Priority: 9})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first != second || !strings.HasPrefix(first, "h-") {
+				t.Fatalf("相同规则得到不同 ID: %s / %s", first, second)
+			}
+			if re.RuleCount() != 1 {
+				t.Fatalf("RuleCount = %d，重复规则不应再添加", re.RuleCount())
+			}
+		})
+	}
+}
+
+// countingHash 内容寻址的生成器，记录 Next 的调用次数
+type countingHash struct {
+	ContentHashIDs
+	calls int
+}
+
+func (g *countingHash) Next(exprCanonical string) string {
+	g.calls++
+	return g.ContentHashIDs.Next(exprCanonical)
+}
+
+func TestAddRuleAutoContentHashCollision(t *testing.T) {
+	re := NewRuleEngine()
+	gen := &countingHash{}
+	canonical, _ := canonicalExpr("is_vip")
+	if err := re.AddRule(gen.ContentHashIDs.Next(canonical), "blacklisted"); err != nil {
+		t.Fatal(err)
+	}
+	re.SetIDGenerator(gen)
+	_, err := re.AddRuleAuto("is_vip", RuleMeta{})
+	if !errors.Is(err, ErrIDCollision) {
+		t.Fatalf("err = %v，应为 ErrIDCollision", err)
+	}
+	if gen.calls != 1 {
+		t.Fatalf("内容寻址的生成器被调用 %d 次，冲突时应立即失败", gen.calls)
+	}
+}
+
+func TestContentHashIDsStableAcrossRestart(t *testing.T) {
+	const rule = `payment_method in ["PAYPAL", "STRIPE"] and amount > 100`
+	before := NewRuleEngine()
+	before.SetIDGenerator(ContentHashIDs{})
+	id, err := before.AddRuleAuto(rule, RuleMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := before.ExportText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	after := NewRuleEngine()
+	if err := after.ImportText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	after.SetIDGenerator(ContentHashIDs{})
+	again, err := after.AddRuleAuto(rule, RuleMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id || after.RuleCount() != 1 {
+		t.Fatalf("重启后 ID = %s（原 %s），规则数 %d", again, id, after.RuleCount())
+	}
+}
+
+// TestAddRuleAutoConcurrent 与 AddRule 并发时自动 ID 互不相同，且不会覆盖已存在的规则
+func TestAddRuleAutoConcurrent(t *testing.T) {
+	re := NewRuleEngine()
+	var wg sync.WaitGroup
+	ids := make([][]string, 4)
+	for g := range ids {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				id, err := re.AddRuleAuto("is_vip", RuleMeta{})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids[g] = append(ids[g], id)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				re.AddRule(fmt.Sprintf("manual-%d-%d", g, i), "blacklisted")
+			}
+		}()
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for _, list := range ids {
+		for _, id := range list {
+			if seen[id] {
+				t.Fatalf("自动 ID %s 重复", id)
+			}
+			seen[id] = true
+		}
+	}
+	if re.RuleCount() != 400 {
+		t.Fatalf("RuleCount = %d，应为 400", re.RuleCount())
+	}
+}
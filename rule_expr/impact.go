@@ -0,0 +1,106 @@
+package rule_expr
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 规则下线影响评估 ---------- */
+
+// ImpactOptions ImpactAnalysis 的选项，零值以整个规则集为一组
+type ImpactOptions struct {
+	// Tag 非空时只把带该标签的规则视为同一组（与 MatchByTag 的范围一致），规则本身须带该标签
+	Tag string
+}
+
+// ImpactReport 在一批历史输入上评估删除某条规则的影响。
+// 只统计已启用且开关打开的规则；"首个命中"与 MatchFirst 一致，按优先级降序、ID 升序取第一条
+type ImpactReport struct {
+	RuleID      string
+	Tag         string // 评估范围，空表示整个规则集
+	Inputs      int
+	Hits        int            // 该规则命中的输入数
+	UniqueHits  int            // 只有该规则命中的输入数，删除后这些输入不再命中任何规则
+	Overlap     map[string]int // 其他规则 ID -> 与该规则同时命中的输入数
+	FirstMatch  int            // 该规则是首个命中的输入数
+	FirstChange map[string]int // 删除后首个命中改变的去向（"" 表示不再命中）-> 输入数
+}
+
+// UniqueRatio 独占命中占全部输入的比例
+func (r ImpactReport) UniqueRatio() float64 {
+	if r.Inputs == 0 {
+		return 0
+	}
+	return float64(r.UniqueHits) / float64(r.Inputs)
+}
+
+// ImpactAnalysis 用 inputs 重放当前规则集，统计 ruleID 的命中、独占命中与重叠情况。
+// 开关按每条输入分别查询，与逐条调用 Match 相同。不会写入最近匹配记录，也不计入 EvalStats
+func (re *RuleEngine) ImpactAnalysis(ruleID string, inputs []map[string]interface{}, opts ...ImpactOptions) (ImpactReport, error) {
+	var opt ImpactOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	target, ok := re.lookup(ruleID)
+	if !ok {
+		return ImpactReport{}, fmt.Errorf("规则 %s 不存在", ruleID)
+	}
+	view := re.orderedView()
+	rules := view.rules
+	if opt.Tag != "" {
+		if !slices.Contains(target.Tags, opt.Tag) {
+			return ImpactReport{}, fmt.Errorf("规则 %s 不带标签 %q", ruleID, opt.Tag)
+		}
+		rules = view.byTag[opt.Tag]
+	}
+
+	rep := ImpactReport{
+		RuleID:      ruleID,
+		Tag:         opt.Tag,
+		Inputs:      len(inputs),
+		Overlap:     make(map[string]int),
+		FirstChange: make(map[string]int),
+	}
+	var machine vm.VM
+	var hits []string
+	for _, in := range inputs {
+		hits = hits[:0]
+		self := false
+		flags := re.flagSet()
+		for _, r := range rules {
+			if !flags.allows(r) {
+				continue
+			}
+			if ok, _ := evalRule(&machine, r, in); !ok {
+				continue
+			}
+			if r.ID == ruleID {
+				self = true
+			}
+			hits = append(hits, r.ID)
+		}
+		if !self {
+			continue
+		}
+		rep.Hits++
+		if len(hits) == 1 {
+			rep.UniqueHits++
+		}
+		for _, id := range hits {
+			if id != ruleID {
+				rep.Overlap[id]++
+			}
+		}
+		if hits[0] == ruleID {
+			rep.FirstMatch++
+			next := ""
+			if len(hits) > 1 {
+				next = hits[1]
+			}
+			rep.FirstChange[next]++
+		}
+	}
+	return rep, nil
+}
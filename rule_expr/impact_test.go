@@ -0,0 +1,109 @@
+package rule_expr
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// impactEngine 一组重叠已知的规则：
+//
+//	target  amount > 100            优先级 0，标签 risk
+//	big     amount > 500            优先级 5，标签 risk（在 target 之前评估）
+//	vip     is_vip                  优先级 0，标签 risk
+//	prod    env == "prod"           优先级 0，无标签
+//	off     amount > 0              已停用
+//	beta    amount > 0              开关 beta 关闭
+func impactEngine(t *testing.T) *RuleEngine {
+	t.Helper()
+	re := NewRuleEngine()
+	for _, err := range []error{
+		re.AddRuleWithTags("target", "amount > 100", "risk"),
+		re.AddRuleWithTags("big", "amount > 500", "risk"),
+		re.SetPriority("big", 5),
+		re.AddRuleWithTags("vip", "is_vip", "risk"),
+		re.AddRule("prod", `env == "prod"`),
+		re.AddRule("off", "amount > 0"),
+		re.AddRuleWithFlag("beta", "amount > 0", "beta"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	re.DisableRule("off")
+	return re
+}
+
+func impactInputs() []map[string]interface{} {
+	in := func(amount float64, vip bool, env string) map[string]interface{} {
+		return map[string]interface{}{"amount": amount, "is_vip": vip, "env": env}
+	}
+	return []map[string]interface{}{
+		in(150, false, "dev"),  // 只有 target
+		in(150, false, "dev"),  // 只有 target
+		in(150, true, "dev"),   // target + vip
+		in(150, false, "prod"), // target + prod
+		in(600, false, "dev"),  // big 先于 target
+		in(600, true, "prod"),  // big + target + prod + vip
+		in(50, true, "prod"),   // 未命中 target
+	}
+}
+
+func TestImpactAnalysis(t *testing.T) {
+	re := impactEngine(t)
+	rep, err := re.ImpactAnalysis("target", impactInputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Inputs != 7 || rep.Hits != 6 || rep.UniqueHits != 2 || rep.FirstMatch != 3 {
+		t.Fatalf("%+v", rep)
+	}
+	// 停用的 off 与开关关闭的 beta 不计入重叠
+	if want := map[string]int{"big": 2, "vip": 2, "prod": 2}; !maps.Equal(rep.Overlap, want) {
+		t.Fatalf("Overlap = %v, 应为 %v", rep.Overlap, want)
+	}
+	// 同优先级按 ID 升序：prod < target < vip，故 target + prod 时首个命中是 prod
+	if want := map[string]int{"": 2, "vip": 1}; !maps.Equal(rep.FirstChange, want) {
+		t.Fatalf("FirstChange = %v, 应为 %v", rep.FirstChange, want)
+	}
+	for _, in := range impactInputs() {
+		first, _ := re.MatchFirst(in)
+		hits := re.Match(in)
+		if len(hits) > 0 && hits[0] != first {
+			t.Fatalf("Match 顺序与 MatchFirst 不一致: %v / %s", hits, first)
+		}
+	}
+
+	// 开关打开后 beta 参与
+	re.SetFlagDefault(true)
+	rep, _ = re.ImpactAnalysis("target", impactInputs())
+	if rep.UniqueHits != 0 || rep.Overlap["beta"] != 6 || rep.Overlap["off"] != 0 {
+		t.Fatalf("开关打开后: %+v", rep)
+	}
+}
+
+func TestImpactAnalysisByTag(t *testing.T) {
+	re := impactEngine(t)
+	re.SetFlagDefault(true)
+	rep, err := re.ImpactAnalysis("target", impactInputs(), ImpactOptions{Tag: "risk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 组内只有 target / big / vip
+	if rep.Tag != "risk" || rep.Hits != 6 || rep.UniqueHits != 3 || rep.FirstMatch != 4 {
+		t.Fatalf("%+v", rep)
+	}
+	if want := map[string]int{"big": 2, "vip": 2}; !maps.Equal(rep.Overlap, want) {
+		t.Fatalf("Overlap = %v, 应为 %v", rep.Overlap, want)
+	}
+	if want := map[string]int{"": 3, "vip": 1}; !maps.Equal(rep.FirstChange, want) {
+		t.Fatalf("FirstChange = %v, 应为 %v", rep.FirstChange, want)
+	}
+
+	if _, err := re.ImpactAnalysis("prod", impactInputs(), ImpactOptions{Tag: "risk"}); err == nil || !strings.Contains(err.Error(), "risk") {
+		t.Fatalf("规则不带该标签应报错: %v", err)
+	}
+	if _, err := re.ImpactAnalysis("nope", impactInputs()); err == nil {
+		t.Fatal("规则不存在应报错")
+	}
+}
@@ -0,0 +1,149 @@
+package rule_expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/* ---------- JSON 规则文件 ---------- */
+
+// JSONRule JSON 规则文件中的一项。除 id 与 expr 外均可省略
+type JSONRule struct {
+	ID          string   `json:"id"`
+	Expr        string   `json:"expr"`
+	Description string   `json:"description,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Flag        string   `json:"flag,omitempty"`
+	// Enabled 为 false 时加入后即处于停用状态（见 DisableRule），省略时启用
+	Enabled *bool `json:"enabled,omitempty"`
+	// AllowUndefined 是否容忍缺失因子（见 WithAllowUndefined），省略时随引擎默认值
+	AllowUndefined *bool `json:"allow_undefined,omitempty"`
+}
+
+// LoadRulesFromJSON 读取 JSONRule 数组并逐条加入引擎。
+// 文件结构错误（不是数组、缺少 id/expr、ID 重复）时不加载任何规则；
+// 否则编译成功的规则照常加入，全部编译失败的规则以带行号与 ID 的错误合并返回
+func LoadRulesFromJSON(re *RuleEngine, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	rules, lines, err := decodeJSONRules(data)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, jr := range rules {
+		meta, err := jr.meta()
+		if err == nil {
+			err = re.addRule(jr.ID, jr.Expr, meta, true)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("第 %d 行 规则 %s: %w", lines[i], jr.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (jr JSONRule) meta() (RuleMeta, error) {
+	tags, err := normalizeTags(jr.Tags)
+	return RuleMeta{Flag: jr.Flag, Priority: jr.Priority, Tags: tags, Description: jr.Description,
+		Disabled: jr.Enabled != nil && !*jr.Enabled, AllowUndefined: jr.AllowUndefined}, err
+}
+
+// LoadRulesFromJSONFile 同 LoadRulesFromJSON，从 path 读取
+func LoadRulesFromJSONFile(re *RuleEngine, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := LoadRulesFromJSON(re, f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// decodeJSONRules 解析规则数组并校验结构，lines[i] 为第 i 项起始所在的行号
+func decodeJSONRules(data []byte) (rules []JSONRule, lines []int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	lineAt := func(offset int64) int {
+		return 1 + bytes.Count(data[:offset], []byte("\n"))
+	}
+	if tok, err := dec.Token(); err != nil {
+		return nil, nil, fmt.Errorf("读取规则文件失败: %w", err)
+	} else if tok != json.Delim('[') {
+		return nil, nil, fmt.Errorf("规则文件应为 JSON 数组")
+	}
+	seen := make(map[string]int)
+	for dec.More() {
+		// 解码器此时停在上一项之后的逗号或空白处，跳过它们取本项的起始行
+		start := dec.InputOffset()
+		for start < int64(len(data)) && bytes.IndexByte([]byte(" \t\r\n,"), data[start]) >= 0 {
+			start++
+		}
+		line := lineAt(start)
+		var jr JSONRule
+		if err := dec.Decode(&jr); err != nil {
+			return nil, nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		switch {
+		case jr.ID == "":
+			return nil, nil, fmt.Errorf("第 %d 行: 缺少 id", line)
+		case jr.Expr == "":
+			return nil, nil, fmt.Errorf("第 %d 行 规则 %s: 缺少 expr", line, jr.ID)
+		}
+		if first, dup := seen[jr.ID]; dup {
+			return nil, nil, fmt.Errorf("第 %d 行: 规则 ID %s 与第 %d 行重复", line, jr.ID, first)
+		}
+		seen[jr.ID] = line
+		rules = append(rules, jr)
+		lines = append(lines, line)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, fmt.Errorf("读取规则文件失败: %w", err)
+	}
+	return rules, lines, nil
+}
+
+// SaveRulesToJSON 按 ID 排序写出当前规则集，输出可被 LoadRulesFromJSON 读回。
+// 停用的规则写出 "enabled": false。容忍缺失因子的规则写出 allow_undefined，引擎默认容忍时严格的规则也写出，
+// 读回到默认值不同的引擎时模式不变
+func SaveRulesToJSON(re *RuleEngine, w io.Writer) error {
+	infos := re.ListRules()
+	rules := make([]JSONRule, len(infos))
+	for i, info := range infos {
+		rules[i] = JSONRule{
+			ID:          info.ID,
+			Expr:        info.Expr,
+			Description: info.Description,
+			Priority:    info.Priority,
+			Tags:        info.Tags,
+			Flag:        info.Flag,
+		}
+		if !info.Enabled {
+			rules[i].Enabled = &info.Enabled
+		}
+		if info.AllowUndefined || re.allowUndefined {
+			rules[i].AllowUndefined = &info.AllowUndefined
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rules)
+}
+
+// SaveRulesToJSONFile 同 SaveRulesToJSON，写入 path
+func SaveRulesToJSONFile(re *RuleEngine, path string) error {
+	var buf bytes.Buffer
+	if err := SaveRulesToJSON(re, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
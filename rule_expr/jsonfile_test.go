@@ -0,0 +1,85 @@
+package rule_expr
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestJSONRoundTrip 写出再读回后 ListRules 完全相同，包括停用状态与容忍缺失因子的模式；
+// 源引擎默认容忍缺失因子时，读回到默认严格的引擎，各规则的模式也不变
+func TestJSONRoundTrip(t *testing.T) {
+	for _, opts := range [][]EngineOption{nil, {WithAllowUndefined()}} {
+		testJSONRoundTrip(t, NewRuleEngine(opts...), opts)
+	}
+}
+
+func testJSONRoundTrip(t *testing.T, re *RuleEngine, opts []EngineOption) {
+	t.Helper()
+	f := false
+	for id, meta := range map[string]RuleMeta{
+		"vip":     {Priority: 5, Tags: []string{"risk", "vip"}, Description: "会员"},
+		"prod":    {Flag: "beta", Disabled: true},
+		"lenient": {AllowUndefined: ptrTo(true), Description: "缺失 is_vip 时不报错"},
+		"strict":  {AllowUndefined: &f, Disabled: true},
+	} {
+		expr := map[string]string{"vip": "is_vip", "prod": `env == "prod"`, "lenient": "not is_vip", "strict": "amount > 100"}[id]
+		if err := re.addRule(id, expr, meta, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := SaveRulesToJSON(re, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), `"enabled": false`); n != 2 {
+		t.Fatalf("应为两条停用的规则写出 enabled: false，实际 %d 处:\n%s", n, buf.String())
+	}
+	for _, back := range []*RuleEngine{NewRuleEngine(opts...), NewRuleEngine()} {
+		if err := LoadRulesFromJSON(back, bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := back.ListRules(), re.ListRules(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("源引擎默认容忍 %v，读回后:\n%+v\n应为:\n%+v", re.allowUndefined, got, want)
+		}
+		if hits := back.Match(map[string]interface{}{"env": "prod", "amount": 500.0, "is_vip": true}); !reflect.DeepEqual(hits, []string{"vip"}) {
+			t.Fatalf("停用的规则不应命中: %v", hits)
+		}
+	}
+}
+
+func ptrTo[T any](v T) *T { return &v }
+
+// TestLoadRulesFromJSONErrors 结构错误时不加载任何规则并报告行号；编译失败的规则带行号与 ID，其余照常加入
+func TestLoadRulesFromJSONErrors(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"重复 ID", "[\n  {\"id\": \"a\", \"expr\": \"is_vip\"},\n  {\"id\": \"b\", \"expr\": \"is_vip\"},\n  {\"id\": \"a\", \"expr\": \"amount > 1\"}\n]", "第 4 行: 规则 ID a 与第 2 行重复"},
+		{"缺少 id", "[\n  {\"id\": \"a\", \"expr\": \"is_vip\"},\n\n  {\"expr\": \"is_vip\"}\n]", "第 4 行: 缺少 id"},
+		{"缺少 expr", "[{\"id\": \"a\", \"expr\": \"is_vip\"}, {\"id\": \"b\"}]", "第 1 行 规则 b: 缺少 expr"},
+		{"未知字段", "[\n{\"id\": \"a\", \"expr\": \"is_vip\", \"enable\": false}]", "第 2 行"},
+		{"不是数组", `{"id": "a"}`, "应为 JSON 数组"},
+	}
+	for _, c := range cases {
+		re := NewRuleEngine()
+		err := LoadRulesFromJSON(re, strings.NewReader(c.src))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: %v，应包含 %q", c.name, err, c.want)
+		}
+		if re.RuleCount() != 0 {
+			t.Errorf("%s: 结构错误时不应加载任何规则，实际 %d 条", c.name, re.RuleCount())
+		}
+	}
+
+	re := NewRuleEngine()
+	src := "[\n  {\"id\": \"ok\", \"expr\": \"is_vip\"},\n  {\"id\": \"bad\", \"expr\": \"amount >\"},\n  {\"id\": \"bad_tag\", \"expr\": \"is_vip\", \"tags\": [\"\"]}\n]"
+	err := LoadRulesFromJSON(re, strings.NewReader(src))
+	if err == nil || !strings.Contains(err.Error(), "第 3 行 规则 bad:") || !strings.Contains(err.Error(), "第 4 行 规则 bad_tag:") {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if re.RuleCount() != 1 {
+		t.Fatalf("编译成功的规则应照常加入，实际 %d 条", re.RuleCount())
+	}
+}
@@ -0,0 +1,236 @@
+package rule_expr
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+/* ---------- 声明域静态检查 ---------- */
+
+// tri 三值逻辑：恒真 / 恒假 / 取决于输入
+type tri int
+
+const (
+	triUnknown tri = iota
+	triTrue
+	triFalse
+)
+
+// LintFinding 一条静态检查结果
+type LintFinding struct {
+	RuleID  string
+	Message string
+	// Verdict 整条规则在声明域下的结论："unsatisfiable"（永不命中）、"always"（恒命中）或空
+	Verdict string
+}
+
+// lintExpr 在因子池声明的封闭域上检查表达式：
+// 对 Enumerated 因子，与域外常量的 == 恒假、!= 恒真；同一 and 链上对同一因子的数值比较区间为空时恒假；
+// 再按 and/or/not 传播得出整条规则的结论
+func lintExpr(exprStr string, pool *FactorPool) (warnings []string, verdict string, err error) {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return nil, "", err
+	}
+	l := &linter{pool: pool}
+	switch l.eval(tree.Node) {
+	case triFalse:
+		verdict = "unsatisfiable"
+		l.warnings = append(l.warnings, "在声明的取值域下规则永远不会命中")
+	case triTrue:
+		verdict = "always"
+		l.warnings = append(l.warnings, "在声明的取值域下规则恒命中")
+	}
+	return l.warnings, verdict, nil
+}
+
+type linter struct {
+	pool     *FactorPool
+	warnings []string
+}
+
+func (l *linter) eval(node ast.Node) tri {
+	switch n := node.(type) {
+	case *ast.UnaryNode:
+		if n.Operator == "not" || n.Operator == "!" {
+			switch l.eval(n.Node) {
+			case triTrue:
+				return triFalse
+			case triFalse:
+				return triTrue
+			}
+		}
+		return triUnknown
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "and", "&&":
+			left, right := l.eval(n.Left), l.eval(n.Right)
+			if left == triFalse || right == triFalse {
+				return triFalse
+			}
+			if left == triTrue && right == triTrue {
+				return triTrue
+			}
+			if msg, ok := rangeConflict(n); ok {
+				l.warnings = append(l.warnings, msg)
+				return triFalse
+			}
+			return triUnknown
+		case "or", "||":
+			left, right := l.eval(n.Left), l.eval(n.Right)
+			if left == triTrue || right == triTrue {
+				return triTrue
+			}
+			if left == triFalse && right == triFalse {
+				return triFalse
+			}
+			return triUnknown
+		case "==", "!=":
+			return l.evalEquality(n)
+		}
+	}
+	return triUnknown
+}
+
+// evalEquality 处理 "因子 ==/!= 常量"，仅对 Enumerated 因子下结论
+func (l *linter) evalEquality(n *ast.BinaryNode) tri {
+	id, lit := n.Left, n.Right
+	if _, ok := factorPath(id); !ok {
+		id, lit = n.Right, n.Left
+	}
+	path, ok := factorPath(id)
+	if !ok {
+		return triUnknown
+	}
+	value, ok := literalValue(lit)
+	if !ok {
+		return triUnknown
+	}
+	f, ok := l.pool.Lookup(path)
+	if !ok || !f.Enumerated || slices.Contains(f.SampleValues, value) {
+		return triUnknown
+	}
+	hint := ""
+	if f.Description != "" {
+		hint = "（" + f.Description + "）"
+	}
+	if n.Operator == "==" {
+		l.warnings = append(l.warnings, fmt.Sprintf("%s%s 的取值域 %v 不含 %#v，%s 恒为 false", f.Name, hint, f.SampleValues, value, n))
+		return triFalse
+	}
+	l.warnings = append(l.warnings, fmt.Sprintf("%s%s 的取值域 %v 不含 %#v，%s 恒为 true", f.Name, hint, f.SampleValues, value, n))
+	return triTrue
+}
+
+/* ---------- 数值区间 ---------- */
+
+// interval 因子可取值的实数区间，端点可开可闭
+type interval struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+}
+
+// narrow 与 "x op c" 求交
+func (iv *interval) narrow(op string, c float64) {
+	if op == ">" || op == ">=" || op == "==" {
+		if open := op == ">"; c > iv.lo || c == iv.lo && open {
+			iv.lo, iv.loOpen = c, open
+		}
+	}
+	if op == "<" || op == "<=" || op == "==" {
+		if open := op == "<"; c < iv.hi || c == iv.hi && open {
+			iv.hi, iv.hiOpen = c, open
+		}
+	}
+}
+
+func (iv interval) empty() bool {
+	return iv.lo > iv.hi || iv.lo == iv.hi && (iv.loOpen || iv.hiOpen)
+}
+
+// flippedOps 常量在左侧时换成等价的 "因子 op 常量"
+var flippedOps = map[string]string{">": "<", ">=": "<=", "<": ">", "<=": ">=", "==": "=="}
+
+// numericBound 识别 "因子 op 数值常量" 或 "数值常量 op 因子"，op 为 == 或大小比较
+func numericBound(node ast.Node) (path, op string, c float64, ok bool) {
+	n, isBin := node.(*ast.BinaryNode)
+	if !isBin {
+		return "", "", 0, false
+	}
+	if _, ok := flippedOps[n.Operator]; !ok {
+		return "", "", 0, false
+	}
+	op, fac, lit := n.Operator, n.Left, n.Right
+	if _, ok := factorPath(fac); !ok {
+		op, fac, lit = flippedOps[op], n.Right, n.Left
+	}
+	path, ok = factorPath(fac)
+	if !ok {
+		return "", "", 0, false
+	}
+	switch v := lit.(type) {
+	case *ast.IntegerNode:
+		return path, op, float64(v.Value), true
+	case *ast.FloatNode:
+		return path, op, v.Value, true
+	}
+	return "", "", 0, false
+}
+
+// rangeConflict 沿 and 链按因子收集数值比较并求交，某个因子的区间为空时返回说明。
+// 按实数处理，不考虑因子的整数类型（x > 1 and x < 2 不视为矛盾）
+func rangeConflict(node ast.Node) (string, bool) {
+	var leaves []ast.Node
+	var walk func(ast.Node)
+	walk = func(node ast.Node) {
+		if n, ok := node.(*ast.BinaryNode); ok && (n.Operator == "and" || n.Operator == "&&") {
+			walk(n.Left)
+			walk(n.Right)
+			return
+		}
+		leaves = append(leaves, node)
+	}
+	walk(node)
+
+	ranges := make(map[string]*interval)
+	texts := make(map[string][]string)
+	var paths []string
+	for _, leaf := range leaves {
+		path, op, c, ok := numericBound(leaf)
+		if !ok {
+			continue
+		}
+		iv, seen := ranges[path]
+		if !seen {
+			iv = &interval{lo: math.Inf(-1), hi: math.Inf(1)}
+			ranges[path] = iv
+			paths = append(paths, path)
+		}
+		iv.narrow(op, c)
+		texts[path] = append(texts[path], leaf.String())
+	}
+	for _, path := range paths {
+		if ranges[path].empty() {
+			return fmt.Sprintf("%s 不能同时成立，所在的 and 分支恒为 false", strings.Join(texts[path], " 与 ")), true
+		}
+	}
+	return "", false
+}
+
+// FindUnsatisfiable 返回在声明域下永不命中或恒命中的规则，按 ID 排序
+func (re *RuleEngine) FindUnsatisfiable() []LintFinding {
+	var out []LintFinding
+	for _, r := range re.snapshot() {
+		if r.verdict != "" {
+			out = append(out, LintFinding{RuleID: r.ID, Message: r.Warnings[len(r.Warnings)-1], Verdict: r.verdict})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RuleID < out[j].RuleID })
+	return out
+}
@@ -0,0 +1,60 @@
+package rule_expr
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFindUnsatisfiable 数值区间矛盾与声明域外的常量得出结论；可满足与无法静态判断的规则不报
+func TestFindUnsatisfiable(t *testing.T) {
+	cases := []struct {
+		id, expr, verdict string
+	}{
+		{"gap", "amount > 5 && amount < 3", "unsatisfiable"},
+		{"open_point", "amount > 5 and amount <= 5", "unsatisfiable"},
+		{"two_values", "user_id == 1 and user_id == 2", "unsatisfiable"},
+		{"flipped", "3 > amount and amount > 5", "unsatisfiable"},
+		{"nested", "is_vip and (user.profile.level >= 4 and env == \"prod\") and user.profile.level < 2", "unsatisfiable"},
+		{"negated", "not (amount > 5 and amount < 3)", "always"},
+		{"or_branch", "amount > 5 and amount < 3 or is_vip", ""},
+		{"domain", `user.country == "FR"`, "unsatisfiable"},
+		{"domain_ne", `user.country != "FR"`, "always"},
+		// 可满足
+		{"closed_point", "amount >= 5 and amount <= 5", ""},
+		{"disjoint_or", "amount > 5 or amount < 3", ""},
+		{"two_factors", "amount > 5 and user_id < 3", ""},
+		{"in_domain", `user.country == "CN"`, ""},
+		// 无法静态判断
+		{"factor_vs_factor", "amount > user_id and amount < user_id", ""},
+		{"call", "len(tags) > 5 and len(tags) < 3", ""},
+		{"arith", "amount + 1 > 5 and amount + 1 < 3", ""},
+		{"int_gap", "user_id > 1 and user_id < 2", ""}, // 按实数处理，不考虑整数类型
+	}
+	re := NewRuleEngine()
+	want := make(map[string]string)
+	for _, c := range cases {
+		if err := re.AddRule(c.id, c.expr); err != nil {
+			t.Fatalf("%s: %v", c.id, err)
+		}
+		if c.verdict != "" {
+			want[c.id] = c.verdict
+		}
+	}
+	got := make(map[string]string)
+	for _, f := range re.FindUnsatisfiable() {
+		got[f.RuleID] = f.Verdict
+		if f.Message == "" {
+			t.Errorf("%s: 缺少说明", f.RuleID)
+		}
+	}
+	for _, c := range cases {
+		if got[c.id] != want[c.id] {
+			t.Errorf("%s（%s）: 结论 %q，应为 %q", c.id, c.expr, got[c.id], want[c.id])
+		}
+	}
+
+	r, _ := re.lookup("gap")
+	if warnings := r.Warnings; len(warnings) != 2 || !strings.Contains(warnings[0], "amount > 5 与 amount < 3 不能同时成立") {
+		t.Fatalf("gap 的警告: %v", r.Warnings)
+	}
+}
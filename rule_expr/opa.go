@@ -0,0 +1,89 @@
+package rule_expr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/* ---------- OPA decision log 输出 ---------- */
+
+// OPADecision OPA decision log 中的一条决策记录
+type OPADecision struct {
+	DecisionID string                 `json:"decision_id"`
+	Path       string                 `json:"path"`
+	Input      map[string]interface{} `json:"input"`
+	Result     []string               `json:"result"`
+	Timestamp  string                 `json:"timestamp"` // RFC3339Nano, UTC
+	Metrics    map[string]int64       `json:"metrics,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+}
+
+// OPAConfig 字段映射
+type OPAConfig struct {
+	Path        string            // 决策路径，默认 "goexprtester/match"
+	TimerMetric string            // 耗时写入 metrics 的键名，默认 "timer_rego_query_eval_ns"
+	Labels      map[string]string // 附加到每条记录的标签
+}
+
+func (c OPAConfig) withDefaults() OPAConfig {
+	if c.Path == "" {
+		c.Path = "goexprtester/match"
+	}
+	if c.TimerMetric == "" {
+		c.TimerMetric = "timer_rego_query_eval_ns"
+	}
+	return c
+}
+
+// DecisionFromMatch 把一条匹配记录转换为 OPA 决策；输入沿用记录里已脱敏的副本，result 按 ID 排序，
+// decision_id 由输入指纹与序号组成，同一记录多次转换结果一致
+func DecisionFromMatch(m RecentMatch, cfg OPAConfig) OPADecision {
+	cfg = cfg.withDefaults()
+	result := sortedHits(m.Hits) // 命中顺序取决于规则遍历顺序，排序后同一决策的输出稳定
+	if result == nil {
+		result = []string{}
+	}
+	return OPADecision{
+		DecisionID: hex.EncodeToString(m.Fingerprint[:]) + "-" + strconv.FormatUint(m.Seq, 10),
+		Path:       cfg.Path,
+		Input:      m.Input,
+		Result:     result,
+		Timestamp:  m.At.UTC().Format(time.RFC3339Nano),
+		Metrics:    map[string]int64{cfg.TimerMetric: m.Duration.Nanoseconds()},
+		Labels:     cfg.Labels,
+	}
+}
+
+// OPADecisionLogger 以 NDJSON（每行一条决策）写出 OPA decision log
+type OPADecisionLogger struct {
+	cfg OPAConfig
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewOPADecisionLogger 创建写到 w 的 decision log 输出
+func NewOPADecisionLogger(w io.Writer, cfg OPAConfig) *OPADecisionLogger {
+	return &OPADecisionLogger{cfg: cfg.withDefaults(), enc: json.NewEncoder(w)}
+}
+
+// Log 写出一条匹配记录
+func (l *OPADecisionLogger) Log(m RecentMatch) error {
+	d := DecisionFromMatch(m, l.cfg)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(d)
+}
+
+// LogAll 依次写出多条记录，例如 RecentMatches() 的结果
+func (l *OPADecisionLogger) LogAll(ms []RecentMatch) error {
+	for _, m := range ms {
+		if err := l.Log(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
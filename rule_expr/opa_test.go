@@ -0,0 +1,73 @@
+package rule_expr
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "用当前输出覆盖 testdata 下的 golden 文件")
+
+// checkGolden 比较 got 与 testdata/name；带 -update 运行时改为写入
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v（首次运行请加 -update 生成）", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s 与 golden 文件不同:\n%s\n应为:\n%s", name, got, want)
+	}
+}
+
+// goldenMatches 经脱敏与最近匹配记录得到的几条记录；时间与耗时改为固定值，其余字段保持引擎的输出
+func goldenMatches(t *testing.T) []RecentMatch {
+	re := NewRuleEngine()
+	re.SetRedactor(HashBucketRedactor(10))
+	re.SetRecentMatches(8, RecentOptions{KeepInputs: true})
+	re.AddRule("vip", "is_vip")
+	re.AddRule("big", "amount > 100")
+	re.AddRule("cn", `user.country == "CN"`)
+	for _, in := range []map[string]interface{}{
+		{"is_vip": true, "amount": 250.0, "user": map[string]interface{}{"country": "CN"}, "card": "4111-1111-1111-1111"},
+		{"is_vip": false, "amount": 3.5, "user": map[string]interface{}{"country": "US"}},
+		{"is_vip": true, "amount": 101.0, "tags": []interface{}{"beta"}},
+	} {
+		re.Match(in)
+	}
+	ms := re.RecentMatches()
+	if len(ms) != 3 {
+		t.Fatalf("RecentMatches = %d 条", len(ms))
+	}
+	for i := range ms {
+		ms[i].At = time.Date(2024, 5, 1, 8, 0, i, 123456789, time.FixedZone("CST", 8*3600))
+		ms[i].Duration = time.Duration(1500 * (i + 1))
+	}
+	return ms
+}
+
+// TestOPADecisionLogGolden 默认与自定义配置下的 NDJSON 输出与 golden 文件逐字节一致
+func TestOPADecisionLogGolden(t *testing.T) {
+	ms := goldenMatches(t)
+	configs := map[string]OPAConfig{
+		"opa_default.golden.ndjson": {},
+		"opa_custom.golden.ndjson":  {Path: "risk/decide", TimerMetric: "eval_ns", Labels: map[string]string{"env": "test", "id": "node-1"}},
+	}
+	for name, cfg := range configs {
+		var buf bytes.Buffer
+		if err := NewOPADecisionLogger(&buf, cfg).LogAll(ms); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, name, buf.Bytes())
+	}
+}
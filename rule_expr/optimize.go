@@ -0,0 +1,135 @@
+package rule_expr
+
+import (
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 编译期优化效果观测 ---------- */
+
+// CompileStats 单次编译的规模与求值耗时
+type CompileStats struct {
+	Nodes       int           // 优化后（或未优化）AST 节点数
+	Ops         int           // 字节码指令数
+	Constants   int           // 常量表长度
+	DisasmLen   int           // 反汇编文本长度
+	EvalPerCall time.Duration // 在样本输入上的平均单次求值耗时
+}
+
+// CompileReport 同一表达式开启与关闭优化器的对比
+type CompileReport struct {
+	Expr      string
+	Optimized CompileStats
+	Plain     CompileStats
+}
+
+// Reduced 优化器是否缩减了字节码
+func (c CompileReport) Reduced() bool {
+	return c.Optimized.Ops < c.Plain.Ops
+}
+
+// 样本输入固定种子生成，保证多次报告可比
+const (
+	compileSampleSeed   = 1
+	compileSampleInputs = 64
+	compileSampleRounds = 20
+)
+
+// ExplainCompile 分别以优化器开、关编译 exprStr，报告 AST/字节码规模与求值耗时的差异
+func ExplainCompile(exprStr string) (CompileReport, error) {
+	return explainCompile(exprStr, genRandomInputs(compileSampleSeed, compileSampleInputs, 1), compileSampleRounds)
+}
+
+func explainCompile(exprStr string, inputs []map[string]interface{}, rounds int) (CompileReport, error) {
+	report := CompileReport{Expr: exprStr}
+	optimized, err := expr.Compile(exprStr, expr.AsBool())
+	if err != nil {
+		return report, err
+	}
+	plain, err := expr.Compile(exprStr, expr.AsBool(), expr.Optimize(false))
+	if err != nil {
+		return report, err
+	}
+	report.Optimized = compileStats(optimized, inputs, rounds)
+	report.Plain = compileStats(plain, inputs, rounds)
+	return report, nil
+}
+
+func compileStats(p *vm.Program, inputs []map[string]interface{}, rounds int) CompileStats {
+	var nodes nodeCounter
+	node := p.Node()
+	ast.Walk(&node, &nodes)
+	s := CompileStats{
+		Nodes:     int(nodes),
+		Ops:       len(p.Bytecode),
+		Constants: len(p.Constants),
+		DisasmLen: len(p.Disassemble()),
+	}
+	if len(inputs) == 0 || rounds <= 0 {
+		return s
+	}
+	var machine vm.VM
+	start := time.Now()
+	for i := 0; i < rounds; i++ {
+		for _, in := range inputs {
+			_, _ = machine.Run(p, in)
+		}
+	}
+	s.EvalPerCall = time.Since(start) / time.Duration(rounds*len(inputs))
+	return s
+}
+
+type nodeCounter int
+
+func (c *nodeCounter) Visit(*ast.Node) { *c++ }
+
+// OptimizationSummary 全部规则的优化效果汇总
+type OptimizationSummary struct {
+	Rules            int
+	Reduced          int // 字节码被缩减的规则数
+	OpsOptimized     int
+	OpsPlain         int
+	NodesOptimized   int
+	NodesPlain       int
+	EvalOptimized    time.Duration // 平均单次求值耗时
+	EvalPlain        time.Duration
+	CompileFailures  int
+	LargestReduction string // 字节码缩减最多的规则 ID
+}
+
+// OptimizationSummary 对每条规则执行 ExplainCompile 并汇总；为控制耗时，每条规则只在样本上求值一轮
+func (re *RuleEngine) OptimizationSummary() OptimizationSummary {
+	inputs := genRandomInputs(compileSampleSeed, compileSampleInputs, 1)
+	var sum OptimizationSummary
+	var evalOpt, evalPlain time.Duration
+	best := 0
+	for _, r := range re.snapshot() {
+		rep, err := explainCompile(r.ExprStr, inputs, 1)
+		if err != nil {
+			sum.CompileFailures++
+			continue
+		}
+		sum.Rules++
+		sum.OpsOptimized += rep.Optimized.Ops
+		sum.OpsPlain += rep.Plain.Ops
+		sum.NodesOptimized += rep.Optimized.Nodes
+		sum.NodesPlain += rep.Plain.Nodes
+		evalOpt += rep.Optimized.EvalPerCall
+		evalPlain += rep.Plain.EvalPerCall
+		if rep.Reduced() {
+			sum.Reduced++
+			// 缩减量相同时取 ID 较小者，使结果与遍历顺序无关
+			if d := rep.Plain.Ops - rep.Optimized.Ops; d > best || d == best && r.ID < sum.LargestReduction {
+				best, sum.LargestReduction = d, r.ID
+			}
+		}
+	}
+	if sum.Rules > 0 {
+		sum.EvalOptimized = evalOpt / time.Duration(sum.Rules)
+		sum.EvalPlain = evalPlain / time.Duration(sum.Rules)
+	}
+	return sum
+}
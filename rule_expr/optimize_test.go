@@ -0,0 +1,38 @@
+package rule_expr
+
+import "testing"
+
+// TestExplainCompile 可折叠的常量子表达式使字节码与 AST 变小；只有一个因子的规则两者规模相同
+func TestExplainCompile(t *testing.T) {
+	folded, err := ExplainCompile("amount > 60 * 60 * 24 and user.country in [\"CN\", \"US\"]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, p := folded.Optimized, folded.Plain
+	if !folded.Reduced() || o.Ops >= p.Ops || o.Nodes >= p.Nodes || o.DisasmLen >= p.DisasmLen {
+		t.Fatalf("优化后 %+v，未优化 %+v", o, p)
+	}
+	if o.EvalPerCall <= 0 || p.EvalPerCall <= 0 {
+		t.Fatalf("应在样本输入上计时: %+v / %+v", o, p)
+	}
+
+	trivial, err := ExplainCompile("is_vip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trivial.Reduced() || trivial.Optimized.Ops != trivial.Plain.Ops || trivial.Optimized.Nodes != trivial.Plain.Nodes {
+		t.Fatalf("优化后 %+v，未优化 %+v", trivial.Optimized, trivial.Plain)
+	}
+	if _, err := ExplainCompile("amount >"); err == nil {
+		t.Fatal("无法编译的表达式应报错")
+	}
+
+	re := NewRuleEngine()
+	re.AddRule("folded", folded.Expr)
+	re.AddRule("trivial", "is_vip")
+	sum := re.OptimizationSummary()
+	if sum.Rules != 2 || sum.Reduced != 1 || sum.LargestReduction != "folded" ||
+		sum.OpsPlain-sum.OpsOptimized != p.Ops-o.Ops || sum.NodesPlain-sum.NodesOptimized != p.Nodes-o.Nodes {
+		t.Fatalf("%+v", sum)
+	}
+}
@@ -0,0 +1,20 @@
+package rule_expr
+
+/* ---------- 引擎选项 ---------- */
+
+// EngineOption 构造 RuleEngine 时的可选配置
+type EngineOption func(re *RuleEngine)
+
+// WithPersistence 让规则变更写穿到 store
+func WithPersistence(store RuleStore) EngineOption {
+	return func(re *RuleEngine) {
+		re.store = store
+	}
+}
+
+// WithFactorPool 指定静态检查使用的因子池，默认 DefaultFactorPool()
+func WithFactorPool(pool *FactorPool) EngineOption {
+	return func(re *RuleEngine) {
+		re.pool = pool
+	}
+}
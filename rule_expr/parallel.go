@@ -0,0 +1,129 @@
+package rule_expr
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"goexprtester/bench"
+
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 并行匹配 ---------- */
+
+// minRulesPerWorker 每个并行段至少分到的规则数；规则更少时启动 goroutine 的开销超过并行收益
+const minRulesPerWorker = 256
+
+// MatchParallel 把调用开始时的候选规则（已按等值索引过滤）切成至多 workers 段，由同样数量的 goroutine 并行评估，
+// 合并后按规则 ID 排序返回，便于与 Match 的结果对比。每段至少 minRulesPerWorker 条规则，
+// 因此 workers <= 1 或规则少于 2*minRulesPerWorker 条时在调用方 goroutine 中串行评估。
+// 开关在分段前一次查询完毕，各段只读共享；与 Match 共用结果缓存并计入 EvalStats。
+// 每段使用独立的 VM；不受 ShuffleEvaluationOrder 影响
+func (re *RuleEngine) MatchParallel(input map[string]interface{}, workers int) []string {
+	start := time.Now()
+	generation := re.generation.Load()
+	flags := re.flagSet()
+	cache := re.resultCacheFor(flags.p)
+	var key [16]byte
+	var canonical []byte
+	if cache != nil {
+		canonical = appendCanonicalMap(nil, input)
+		key = cache.hash(canonical)
+		if hits, ok := cache.get(key, canonical, generation, flags.p); ok {
+			re.matchCalls.Add(1)
+			slices.Sort(hits)
+			re.recordMatch(input, hits, start)
+			return hits
+		}
+	}
+	rules := re.orderedView().candidates(input)
+	flags.resolve(rules)
+	n := min(workers, len(rules)/minRulesPerWorker)
+	var hits []string
+	var evaluated int
+	var failed bool
+	if n <= 1 {
+		hits, evaluated, failed = re.evalShard(rules, input, &flags)
+	} else {
+		shards := make([]shardResult, n)
+		chunk := (len(rules) + n - 1) / n
+		var wg sync.WaitGroup
+		for w := range shards {
+			lo, hi := w*chunk, min((w+1)*chunk, len(rules))
+			if lo >= hi {
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sh := &shards[w]
+				sh.hits, sh.evaluated, sh.failed = re.evalShard(rules[lo:hi], input, &flags)
+			}()
+		}
+		wg.Wait()
+		for _, sh := range shards {
+			hits = append(hits, sh.hits...)
+			evaluated += sh.evaluated
+			failed = failed || sh.failed
+		}
+	}
+	re.matchCalls.Add(1)
+	re.evaluated.Add(uint64(evaluated))
+	// 各段按评估顺序相连，缓存中的结果与 Match 写入的顺序一致
+	if cache != nil && !failed {
+		cache.put(key, canonical, generation, flags.p, hits)
+	}
+	slices.Sort(hits)
+	re.recordMatch(input, hits, start)
+	return hits
+}
+
+// shardResult 一个并行段的评估结果
+type shardResult struct {
+	hits      []string
+	evaluated int
+	failed    bool
+}
+
+// evalShard 用独立的 VM 评估一段规则；flags 须已对这些规则 resolve，此处只读
+func (re *RuleEngine) evalShard(rules []*Rule, input map[string]interface{}, flags *flagSet) (hits []string, evaluated int, failed bool) {
+	var machine vm.VM
+	for _, r := range rules {
+		if !flags.allows(r) {
+			continue
+		}
+		evaluated++
+		if ok, err := evalRule(&machine, r, input); err != nil {
+			re.noteEvalError(r, err)
+			failed = true
+		} else if ok {
+			hits = append(hits, r.ID)
+		}
+	}
+	return hits, evaluated, failed
+}
+
+// ParallelPoint 并行匹配在某个 workers 下的耗时
+type ParallelPoint struct {
+	Workers int
+	Timing  bench.Timing
+	Speedup float64 // 串行 Match 单次耗时 / 本点单次耗时
+}
+
+// BenchmarkMatchParallel 依次以 workers 中的每个取值运行 MatchParallel，给出相对串行 Match 的加速比
+func BenchmarkMatchParallel(re *RuleEngine, inputs []map[string]interface{}, workers []int) []ParallelPoint {
+	serial := BenchmarkMatchPrecise(re, inputs).PerCall
+	points := make([]ParallelPoint, 0, len(workers))
+	for _, w := range workers {
+		t := bench.Measure(func(in map[string]interface{}) []string {
+			return re.MatchParallel(in, w)
+		}, inputs, bench.Options{})
+		p := ParallelPoint{Workers: w, Timing: t}
+		if t.PerCall > 0 {
+			p.Speedup = float64(serial) / float64(t.PerCall)
+		}
+		points = append(points, p)
+	}
+	return points
+}
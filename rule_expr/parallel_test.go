@@ -0,0 +1,137 @@
+package rule_expr
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMatchParallelMatchesSerial(t *testing.T) {
+	for _, n := range []int{10, 3 * minRulesPerWorker} {
+		re := NewRuleEngine()
+		if err := InjectRandomRulesSeeded(re, n, 3); err != nil {
+			t.Fatal(err)
+		}
+		for i, in := range GenRandomInputsSeeded(50, 4) {
+			want := slices.Sorted(slices.Values(re.Match(in)))
+			for _, workers := range []int{0, 1, 2, 8} {
+				if got := re.MatchParallel(in, workers); !slices.Equal(got, want) {
+					t.Fatalf("%d 条规则、%d 个 worker、输入 #%d: %v，串行 %v", n, workers, i, got, want)
+				}
+			}
+		}
+	}
+}
+
+// evaluatedOnCaller 规则执行出错时记录出错回调是否运行在调用 MatchParallel 的 goroutine 上
+func evaluatedOnCaller(t *testing.T, rules, workers int) bool {
+	t.Helper()
+	re := NewRuleEngine()
+	for i := 0; i < rules; i++ {
+		if err := re.AddRule(fmt.Sprintf("r%04d", i), "x > 1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var spawned atomic.Bool
+	re.SetEvalErrorHandler(func(string, error) {
+		buf := make([]byte, 4096)
+		// 分段 goroutine 的栈以 MatchParallel 中的闭包开始
+		if strings.Contains(string(buf[:runtime.Stack(buf, false)]), "MatchParallel.func") {
+			spawned.Store(true)
+		}
+	})
+	re.MatchParallel(map[string]interface{}{"x": "a"}, workers)
+	return !spawned.Load()
+}
+
+func TestMatchParallelSerialFallback(t *testing.T) {
+	if !evaluatedOnCaller(t, 2*minRulesPerWorker-1, 8) {
+		t.Error("规则少于 2*minRulesPerWorker 条时应在调用方 goroutine 中串行评估")
+	}
+	if !evaluatedOnCaller(t, 4*minRulesPerWorker, 1) {
+		t.Error("workers <= 1 时应串行评估")
+	}
+	if evaluatedOnCaller(t, 4*minRulesPerWorker, 4) {
+		t.Error("规则足够多时应并行评估")
+	}
+}
+
+func BenchmarkMatchParallelWorkers(b *testing.B) {
+	re := NewRuleEngine()
+	if err := InjectRandomRulesSeeded(re, 10000, 1); err != nil {
+		b.Fatal(err)
+	}
+	inputs := GenRandomInputsSeeded(64, 2)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				re.MatchParallel(inputs[i%len(inputs)], workers)
+			}
+		})
+	}
+}
+
+// TestMatchParallelQueriesFlagsOnce 开关在分段前查询一次，各段共享：每次调用每个开关只查询一次
+func TestMatchParallelQueriesFlagsOnce(t *testing.T) {
+	re := NewRuleEngine()
+	flags := []string{"a", "b", "c"}
+	for i := 0; i < 8*minRulesPerWorker; i++ {
+		if err := re.AddRuleWithFlag(fmt.Sprintf("r%04d", i), fmt.Sprintf("x == %d", i%10), flags[i%len(flags)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var calls atomic.Int64
+	re.SetFlagProvider(func(flag string) bool {
+		calls.Add(1)
+		return flag != "c"
+	})
+	in := map[string]interface{}{"x": 3}
+	want := slices.Sorted(slices.Values(re.Match(in)))
+	for _, workers := range []int{1, 4, 8} {
+		calls.Store(0)
+		if got := re.MatchParallel(in, workers); !slices.Equal(got, want) {
+			t.Fatalf("%d 个 worker: %v，串行 %v", workers, got, want)
+		}
+		if n := calls.Load(); n != int64(len(flags)) {
+			t.Fatalf("%d 个 worker 时查询开关 %d 次，应为 %d", workers, n, len(flags))
+		}
+	}
+}
+
+// TestMatchParallelSharesMatchPaths MatchParallel 与 Match 共用等值索引、结果缓存与 EvalStats 计数
+func TestMatchParallelSharesMatchPaths(t *testing.T) {
+	re := NewRuleEngine(WithEqualityIndex())
+	for i := 0; i < 4*minRulesPerWorker; i++ {
+		if err := re.AddRule(fmt.Sprintf("r%04d", i), fmt.Sprintf(`env == "e%d" and amount > %d`, i%4, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := map[string]interface{}{"env": "e1", "amount": 300.0}
+	ordered := re.Match(in)
+	want := slices.Sorted(slices.Values(ordered))
+	before := re.EvalStats()
+	if got := re.MatchParallel(in, 4); !slices.Equal(got, want) {
+		t.Fatalf("%v，串行 %v", got, want)
+	}
+	after := re.EvalStats()
+	// 等值索引只留下 env == "e1" 的四分之一规则
+	if after.Matches != before.Matches+1 || after.Evaluated-before.Evaluated != minRulesPerWorker {
+		t.Fatalf("EvalStats %+v → %+v，应计 1 次匹配、%d 次评估", before, after, minRulesPerWorker)
+	}
+
+	re.SetResultCache(8)
+	re.MatchParallel(in, 4) // 写入缓存
+	if got := re.Match(in); !slices.Equal(got, ordered) || re.ResultCacheStats().Hits != 1 {
+		t.Fatalf("Match 应命中 MatchParallel 写入的缓存: %v, %+v", got, re.ResultCacheStats())
+	}
+	evaluated := re.EvalStats().Evaluated
+	if got := re.MatchParallel(in, 4); !slices.Equal(got, want) || re.ResultCacheStats().Hits != 2 {
+		t.Fatalf("MatchParallel 应命中缓存: %v, %+v", got, re.ResultCacheStats())
+	}
+	if re.EvalStats().Evaluated != evaluated {
+		t.Fatal("缓存命中时不应执行规则")
+	}
+}
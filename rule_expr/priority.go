@@ -0,0 +1,74 @@
+package rule_expr
+
+import (
+	"cmp"
+	"slices"
+)
+
+/* ---------- 规则优先级 ---------- */
+
+// AddRuleWithPriority 加入带优先级的规则：匹配时优先级高的先评估，同优先级按 ID 升序。
+// AddRule 加入的规则优先级为 0
+func (re *RuleEngine) AddRuleWithPriority(id, exprStr string, priority int) error {
+	return re.addRule(id, exprStr, RuleMeta{Priority: priority}, true)
+}
+
+// SetPriority 修改已有规则的优先级，不重新编译；配置了 store 时写入 store
+func (re *RuleEngine) SetPriority(id string, priority int) error {
+	return re.updateMeta(id, func(meta *RuleMeta) { meta.Priority = priority })
+}
+
+// MatchFirstByPriority 返回优先级最高的命中规则（同优先级取 ID 最小者），不受 ShuffleEvaluationOrder 影响
+func (re *RuleEngine) MatchFirstByPriority(input map[string]interface{}) (string, bool) {
+	return re.matchFirst(re.orderedView().candidates(input), input)
+}
+
+// orderedRules 由某个规则快照排序得到的评估顺序及标签索引
+type orderedRules struct {
+	src   *[]*Rule // 排序所依据的快照，与 re.rules 当前值不同即已过期
+	rules []*Rule
+	byTag map[string][]*Rule // 标签 -> 带该标签的规则，保持评估顺序
+	eq    *eqIndex           // 启用等值索引时建立，见 WithEqualityIndex
+}
+
+// ordered 返回按评估顺序排列的当前规则，调用方不得修改
+func (re *RuleEngine) ordered() []*Rule {
+	return re.orderedView().rules
+}
+
+// orderedView 返回当前快照的评估顺序与标签索引。
+// 排序在规则变更后的第一次匹配时进行（O(n log n)），之后直到下次变更都直接复用；
+// 批量加载规则时因此只排序一次。重建期间其他匹配等待同一次排序完成，不会重复排序
+func (re *RuleEngine) orderedView() *orderedRules {
+	src := re.rules.Load()
+	if o := re.order.Load(); o != nil && o.src == src {
+		return o
+	}
+	re.orderMu.Lock()
+	defer re.orderMu.Unlock()
+	src = re.rules.Load()
+	if o := re.order.Load(); o != nil && o.src == src {
+		return o
+	}
+	var rules []*Rule
+	if src != nil {
+		rules = slices.Clone(*src)
+	}
+	slices.SortFunc(rules, func(a, b *Rule) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	o := &orderedRules{src: src, rules: rules, byTag: make(map[string][]*Rule)}
+	for _, r := range rules {
+		for _, tag := range r.Tags {
+			o.byTag[tag] = append(o.byTag[tag], r)
+		}
+	}
+	if re.useIndex {
+		o.eq = buildEqIndex(rules)
+	}
+	re.order.Store(o)
+	return o
+}
@@ -0,0 +1,161 @@
+package rule_expr
+
+import (
+	"fmt"
+	"goexprtester/rule_engine"
+	"math"
+
+	"github.com/expr-lang/expr"
+)
+
+/* ---------- 规则边界探测 ---------- */
+
+// ProbeResult 单个探测输入的执行结果
+type ProbeResult struct {
+	Label  string // 探测点描述，例如 env="prod"、user_id 缺失
+	Input  map[string]interface{}
+	Result bool
+	Err    string // 非空表示该输入导致执行出错
+}
+
+// ProbeReport ProbeRule 的结果表
+type ProbeReport struct {
+	Expr       string
+	CompileErr string
+	Probes     []ProbeResult
+	Errors     int      // 执行出错的探测数
+	Warnings   []string // 例如引用了因子池之外的变量
+}
+
+// ProbeRule 根据表达式自身的 AST 与因子池生成边界输入（各枚举值、缺失、nil、极端整数），
+// 逐个执行并汇总结果，便于在挑选真实流量前发现规则的意外行为
+func ProbeRule(exprStr string, pool *FactorPool) ProbeReport {
+	report := ProbeReport{Expr: exprStr}
+	program, err := expr.Compile(exprStr, expr.AsBool())
+	if err == nil {
+		err = checkBool(exprStr)
+	}
+	if err != nil {
+		report.CompileErr = err.Error()
+		return report
+	}
+	shape, err := analyzeExpr(exprStr)
+	if err != nil {
+		report.CompileErr = err.Error()
+		return report
+	}
+
+	for _, name := range shape.Identifiers {
+		if _, ok := pool.Lookup(name); !ok {
+			report.Warnings = append(report.Warnings, pool.UnknownFactorMessage(name))
+		}
+	}
+
+	// 基准输入：Bool 取 false，其他取第一个样例值
+	base := make(map[string]interface{}, len(pool.Factors))
+	for _, f := range pool.Factors {
+		switch f.Kind {
+		case Bool:
+			rule_engine.SetPath(base, f.Name, false)
+		default:
+			if len(f.SampleValues) > 0 {
+				rule_engine.SetPath(base, f.Name, f.SampleValues[0])
+			}
+		}
+	}
+
+	run := func(label string, input map[string]interface{}) {
+		res := ProbeResult{Label: label, Input: input}
+		out, err := expr.Run(program, input)
+		if err != nil {
+			res.Err = err.Error()
+			report.Errors++
+		} else if b, ok := out.(bool); ok {
+			res.Result = b
+		} else {
+			res.Err = fmt.Sprintf("结果类型 %T 不是 bool", out)
+			report.Errors++
+		}
+		report.Probes = append(report.Probes, res)
+	}
+	clone := func() map[string]interface{} {
+		return rule_engine.CloneRow(base)
+	}
+	with := func(name string, value interface{}) map[string]interface{} {
+		in := clone()
+		rule_engine.SetPath(in, name, value)
+		return in
+	}
+
+	run("基准", clone())
+	for _, name := range shape.Identifiers {
+		for _, v := range probeValues(name, pool, shape.Comparisons) {
+			run(fmt.Sprintf("%s=%#v", name, v), with(name, v))
+		}
+		missing := clone()
+		rule_engine.DeletePath(missing, name)
+		run(name+" 缺失", missing)
+		run(name+"=nil", with(name, nil))
+	}
+	return report
+}
+
+// probeValues 为单个因子挑选探测值：声明的样例值、规则里出现的常量及其邻值、整数与浮点极值、空列表
+func probeValues(name string, pool *FactorPool, comps []comparison) []interface{} {
+	var values []interface{}
+	seen := make(map[string]bool)
+	add := func(v interface{}) {
+		key := fmt.Sprintf("%T:%v", v, v)
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, v)
+		}
+	}
+
+	f, known := pool.Lookup(name)
+	if known && f.Kind == Bool {
+		add(true)
+		add(false)
+	}
+	if known {
+		for _, v := range f.SampleValues {
+			add(v)
+		}
+	}
+	for _, c := range comps {
+		if c.Factor != name || c.Value == nil {
+			continue
+		}
+		if c.Operator == "in" {
+			// "元素 in 列表"：探测只含该元素的列表，空列表见下方 List 分支
+			if s, ok := c.Value.(string); ok {
+				add([]string{s})
+			}
+			continue
+		}
+		add(c.Value)
+		switch n := c.Value.(type) {
+		case int:
+			add(n - 1)
+			add(n + 1)
+		case float64:
+			// 范围比较的边界两侧：最接近阈值的上下两个 float64
+			add(math.Nextafter(n, math.Inf(-1)))
+			add(math.Nextafter(n, math.Inf(1)))
+		}
+	}
+	if known && f.Kind == Int {
+		add(0)
+		add(math.MinInt)
+		add(math.MaxInt)
+	}
+	if known && f.Kind == Float {
+		add(0.0)
+		add(math.Inf(-1))
+		add(math.Inf(1))
+	}
+	if known && f.Kind == List {
+		add([]string{})
+	}
+	return values
+}
@@ -0,0 +1,91 @@
+package rule_expr
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"goexprtester/rule_engine"
+)
+
+func probeLabels(report ProbeReport) map[string]ProbeResult {
+	out := make(map[string]ProbeResult, len(report.Probes))
+	for _, p := range report.Probes {
+		out[p.Label] = p
+	}
+	return out
+}
+
+// TestProbeRuleEnumEquality 三值枚举的等值规则：三个取值与缺失都被探测，且结果正确
+func TestProbeRuleEnumEquality(t *testing.T) {
+	pool, err := rule_engine.NewFactorPool([]FactorTemplate{
+		{Name: "tier", Kind: String, SampleValues: []interface{}{"gold", "silver", "bronze"}, Enumerated: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := ProbeRule(`tier == "silver"`, pool)
+	if report.CompileErr != "" || len(report.Warnings) != 0 {
+		t.Fatalf("不应有编译错误或警告: %+v", report)
+	}
+	labels := probeLabels(report)
+	want := map[string]bool{`tier="gold"`: false, `tier="silver"`: true, `tier="bronze"`: false, "tier 缺失": false, "tier=nil": false}
+	for label, result := range want {
+		p, ok := labels[label]
+		if !ok {
+			t.Errorf("缺少探测 %s", label)
+			continue
+		}
+		if p.Result != result || p.Err != "" {
+			t.Errorf("%s: result=%v err=%q，应为 %v", label, p.Result, p.Err, result)
+		}
+	}
+	if _, ok := labels["tier 缺失"].Input["tier"]; ok {
+		t.Error("缺失探测的输入不应包含 tier")
+	}
+	if report.Errors != 0 {
+		t.Errorf("等值比较不会出错，Errors = %d", report.Errors)
+	}
+}
+
+// TestProbeRuleFlagsEvalErrors 导致执行出错的探测被标出并计数
+func TestProbeRuleFlagsEvalErrors(t *testing.T) {
+	report := ProbeRule("amount > 100", DefaultFactorPool())
+	labels := probeLabels(report)
+	for _, label := range []string{"amount 缺失", "amount=nil"} {
+		if labels[label].Err == "" {
+			t.Errorf("%s 应执行出错", label)
+		}
+	}
+	errs := 0
+	for _, p := range report.Probes {
+		if p.Err != "" {
+			errs++
+		}
+	}
+	if report.Errors != errs || errs != 2 {
+		t.Fatalf("Errors = %d，出错的探测 %d 个，应为 2", report.Errors, errs)
+	}
+	// 阈值两侧与极值
+	for label, result := range map[string]bool{"amount=100": false, "amount=101": true, "amount=99": false, "amount=+Inf": true, "amount=-Inf": false} {
+		if p, ok := labels[label]; !ok || p.Result != result {
+			t.Errorf("%s: %+v，应为 %v", label, p, result)
+		}
+	}
+	if _, ok := labels["amount=+Inf"].Input["amount"].(float64); !ok || !math.IsInf(labels["amount=+Inf"].Input["amount"].(float64), 1) {
+		t.Error("浮点因子应探测 +Inf")
+	}
+}
+
+func TestProbeRuleCompileErrorAndUnknownFactor(t *testing.T) {
+	if report := ProbeRule("is_vip and (", DefaultFactorPool()); report.CompileErr == "" || len(report.Probes) != 0 {
+		t.Fatalf("编译失败时不应探测: %+v", report)
+	}
+	if report := ProbeRule("amount + 1", DefaultFactorPool()); !strings.Contains(report.CompileErr, "不是 bool") {
+		t.Fatalf("结果一定不是 bool 的表达式应报编译错误: %q", report.CompileErr)
+	}
+	report := ProbeRule("is_vipp", DefaultFactorPool())
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "is_vip") {
+		t.Fatalf("拼错的因子应给出提示: %q", report.Warnings)
+	}
+}
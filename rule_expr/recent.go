@@ -0,0 +1,113 @@
+package rule_expr
+
+import (
+	"goexprtester/rule_engine"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+/* ---------- 最近匹配记录 ---------- */
+
+// DefaultRecentMatches 开启记录时建议保留的条数；引擎默认不记录
+const DefaultRecentMatches = 32
+
+// RecentOptions 最近匹配记录的选项，零值只保留指纹与命中
+type RecentOptions struct {
+	// KeepInputs 同时保留（经脱敏钩子的）输入副本；每次匹配多一次整份复制
+	KeepInputs bool
+}
+
+// RecentMatch 一次匹配调用的记录，写入后不再修改
+type RecentMatch struct {
+	Seq         uint64 // 全局递增序号
+	At          time.Time
+	Fingerprint [16]byte               // FingerprintInput(原始输入)，脱敏前计算
+	Input       map[string]interface{} // 已经过脱敏钩子；未开启 KeepInputs 时为 nil
+	Hits        []string
+	Duration    time.Duration
+	Generation  uint64 // 匹配时的规则集版本
+}
+
+// recentRing 固定大小的环形缓冲：写入只需一次原子自增加一次指针比较交换
+type recentRing struct {
+	next       atomic.Uint64
+	slots      []atomic.Pointer[RecentMatch]
+	keepInputs bool
+}
+
+// SetRecentMatches 设置保留的最近匹配条数，0（默认）关闭记录；会丢弃已有记录。
+// 开启后每次匹配多计算一次输入指纹，KeepInputs 时还要复制整份输入
+func (re *RuleEngine) SetRecentMatches(k int, opts ...RecentOptions) {
+	if k <= 0 {
+		re.recent.Store(nil)
+		return
+	}
+	var opt RecentOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	re.recent.Store(&recentRing{slots: make([]atomic.Pointer[RecentMatch], k), keepInputs: opt.KeepInputs})
+}
+
+// RecentMatches 返回最近的匹配记录，按时间从旧到新
+func (re *RuleEngine) RecentMatches() []RecentMatch {
+	ring := re.recent.Load()
+	if ring == nil {
+		return nil
+	}
+	end := ring.next.Load()
+	k := uint64(len(ring.slots))
+	start := uint64(0)
+	if end > k {
+		start = end - k
+	}
+	out := make([]RecentMatch, 0, end-start)
+	for seq := start; seq < end; seq++ {
+		e := ring.slots[seq%k].Load()
+		// 槽位可能尚未写入，或已被更新的记录覆盖
+		if e != nil && e.Seq == seq {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// recordMatch 在开启记录时追加一条
+func (re *RuleEngine) recordMatch(input map[string]interface{}, hits []string, start time.Time) {
+	ring := re.recent.Load()
+	if ring == nil {
+		return
+	}
+	e := &RecentMatch{
+		At:          start,
+		Fingerprint: FingerprintInput(input),
+		Hits:        slices.Clone(hits),
+		Duration:    time.Since(start),
+		Generation:  re.generation.Load(),
+	}
+	if ring.keepInputs {
+		e.Input = re.retainInput(input)
+	}
+	e.Seq = ring.next.Add(1) - 1
+	slot := &ring.slots[e.Seq%uint64(len(ring.slots))]
+	// 并发写入时较旧的记录可能晚到，不能覆盖槽位中更新的记录
+	for {
+		cur := slot.Load()
+		if cur != nil && cur.Seq > e.Seq {
+			return
+		}
+		if slot.CompareAndSwap(cur, e) {
+			return
+		}
+	}
+}
+
+// retainInput 记录需要长期持有的输入副本，调用方此后修改输入不影响记录：
+// 设置了脱敏钩子时 RedactInput 的结果本身就是副本，否则逐层复制
+func (re *RuleEngine) retainInput(input map[string]interface{}) map[string]interface{} {
+	if re.redactor.Load() != nil {
+		return re.RedactInput(input)
+	}
+	return rule_engine.CloneRow(input)
+}
@@ -0,0 +1,123 @@
+package rule_expr
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRecentMatchesOffByDefault(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("vip", "is_vip")
+	re.Match(map[string]interface{}{"is_vip": true})
+	if got := re.RecentMatches(); got != nil {
+		t.Fatalf("默认不应记录最近匹配: %+v", got)
+	}
+}
+
+func TestRecentMatchesFingerprintOnly(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("vip", "is_vip")
+	re.SetRecentMatches(2)
+	input := map[string]interface{}{"is_vip": true}
+	re.Match(input)
+	got := re.RecentMatches()
+	if len(got) != 1 || got[0].Input != nil || got[0].Fingerprint != FingerprintInput(input) || len(got[0].Hits) != 1 {
+		t.Fatalf("未开启 KeepInputs 时应只保留指纹与命中: %+v", got)
+	}
+
+	re.SetRecentMatches(2, RecentOptions{KeepInputs: true})
+	re.Match(input)
+	input["is_vip"] = false // 记录持有的是副本
+	got = re.RecentMatches()
+	if len(got) != 1 || got[0].Input["is_vip"] != true {
+		t.Fatalf("KeepInputs 时应保留输入副本: %+v", got)
+	}
+}
+
+// TestRecentRingConcurrent 并发写入与读取：读到的记录完整、按序号递增，结束后恰好保留最后 K 条
+func TestRecentRingConcurrent(t *testing.T) {
+	const k, writers, perWriter = 8, 4, 500
+	re := NewRuleEngine()
+	re.AddRule("vip", "is_vip")
+	re.SetRecentMatches(k, RecentOptions{KeepInputs: true})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			got := re.RecentMatches()
+			if len(got) > k {
+				t.Errorf("读到 %d 条，超过容量 %d", len(got), k)
+				return
+			}
+			for i, m := range got {
+				if i > 0 && m.Seq <= got[i-1].Seq {
+					t.Errorf("序号未递增: %d 后是 %d", got[i-1].Seq, m.Seq)
+					return
+				}
+				// 同一条记录的输入、指纹与命中须一致，不能拼接自不同写入
+				vip, _ := m.Input["is_vip"].(bool)
+				if m.Fingerprint != FingerprintInput(m.Input) || vip != (len(m.Hits) == 1) {
+					t.Errorf("读到撕裂的记录: %+v", m)
+					return
+				}
+			}
+		}
+	}()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				re.Match(map[string]interface{}{"is_vip": (w+i)%2 == 0, "n": w*perWriter + i})
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-readerDone
+
+	got := re.RecentMatches()
+	if len(got) != k {
+		t.Fatalf("应恰好保留 %d 条，实际 %d", k, len(got))
+	}
+	const total = writers * perWriter
+	for i, m := range got {
+		if m.Seq != uint64(total-k+i) {
+			t.Fatalf("第 %d 条序号 %d，应为 %d", i, m.Seq, total-k+i)
+		}
+	}
+}
+
+func BenchmarkMatchRecentOff(b *testing.B) {
+	benchmarkMatchRecent(b, 0, RecentOptions{})
+}
+
+func BenchmarkMatchRecentFingerprint(b *testing.B) {
+	benchmarkMatchRecent(b, DefaultRecentMatches, RecentOptions{})
+}
+
+func BenchmarkMatchRecentKeepInputs(b *testing.B) {
+	benchmarkMatchRecent(b, DefaultRecentMatches, RecentOptions{KeepInputs: true})
+}
+
+func benchmarkMatchRecent(b *testing.B, k int, opt RecentOptions) {
+	re := NewRuleEngine()
+	if err := InjectRandomRulesSeeded(re, 200, 1); err != nil {
+		b.Fatal(err)
+	}
+	re.SetRecentMatches(k, opt)
+	input := GenRandomInputsSeeded(1, 2)[0]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		re.Match(input)
+	}
+}
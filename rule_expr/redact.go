@@ -0,0 +1,87 @@
+package rule_expr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+/* ---------- 输入脱敏 ---------- */
+
+// Redactor 在输入值离开引擎（审计、追踪、示例、错误信息等）之前对其做脱敏
+type Redactor func(factor string, value interface{}) interface{}
+
+// PassthroughRedactor 默认脱敏器：原样返回
+func PassthroughRedactor(_ string, value interface{}) interface{} {
+	return value
+}
+
+// SetRedactor 设置脱敏钩子，传 nil 恢复为原样输出
+func (re *RuleEngine) SetRedactor(fn Redactor) {
+	if fn == nil {
+		re.redactor.Store(nil)
+		return
+	}
+	re.redactor.Store(&fn)
+}
+
+// RedactInput 返回经脱敏钩子处理后的输入副本，嵌套对象逐层复制，钩子收到的因子名为点路径（如 user.country）；
+// 未设置钩子时直接返回原输入，不产生拷贝。输入值离开引擎的各处（最近匹配记录、输入校验的错误信息、
+// HTTP 接口的错误响应等）都经过这里或 redactValue
+func (re *RuleEngine) RedactInput(input map[string]interface{}) map[string]interface{} {
+	p := re.redactor.Load()
+	if p == nil {
+		return input
+	}
+	return redactMap(*p, input, "")
+}
+
+func redactMap(fn Redactor, m map[string]interface{}, prefix string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			out[k] = redactMap(fn, nested, prefix+k+".")
+			continue
+		}
+		out[k] = fn(prefix+k, v)
+	}
+	return out
+}
+
+// redactValue 对单个因子取值做脱敏，未设置钩子时原样返回
+func (re *RuleEngine) redactValue(factor string, v interface{}) interface{} {
+	if p := re.redactor.Load(); p != nil {
+		return (*p)(factor, v)
+	}
+	return v
+}
+
+// HashBucketRedactor 提供的脱敏器：字符串取 sha256 前 8 字节，整数按 bucket 宽度分桶，其他类型原样返回
+func HashBucketRedactor(bucket int) Redactor {
+	if bucket <= 0 {
+		bucket = 1
+	}
+	return func(_ string, value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			sum := sha256.Sum256([]byte(v))
+			return "sha256:" + hex.EncodeToString(sum[:8])
+		case int:
+			lo := v - ((v%bucket)+bucket)%bucket
+			return fmt.Sprintf("[%d,%d)", lo, lo+bucket)
+		case int64:
+			b := int64(bucket)
+			lo := v - ((v%b)+b)%b
+			return fmt.Sprintf("[%d,%d)", lo, lo+b)
+		case float64:
+			b := float64(bucket)
+			lo := float64(int64(v/b)) * b
+			if v < 0 && lo != v {
+				lo -= b
+			}
+			return fmt.Sprintf("[%g,%g)", lo, lo+b)
+		default:
+			return value
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package rule_expr
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// redactionSurfaces 输入值离开引擎的各处，每项返回该处的输出。
+// 新增携带输入值的导出类型时须在此登记（或在 redactionExempt 中说明理由），否则 TestRedactionChecklistComplete 失败
+var redactionSurfaces = map[string]func(t *testing.T, re *RuleEngine, input map[string]interface{}) interface{}{
+	"RecentMatch": func(t *testing.T, re *RuleEngine, input map[string]interface{}) interface{} {
+		re.Match(input)
+		return re.RecentMatches()
+	},
+	"OPADecision": func(t *testing.T, re *RuleEngine, input map[string]interface{}) interface{} {
+		re.Match(input)
+		recent := re.RecentMatches()
+		if len(recent) == 0 {
+			t.Fatal("没有最近匹配记录")
+		}
+		return DecisionFromMatch(recent[len(recent)-1], OPAConfig{})
+	},
+	"InputError": func(t *testing.T, re *RuleEngine, input map[string]interface{}) interface{} {
+		err := re.ValidateInput(input)
+		if err == nil {
+			t.Fatal("取值域之外的输入应校验失败")
+		}
+		return err.Error()
+	},
+}
+
+// redactionExempt 携带输入形状的数据、但其中的值不来自调用方的类型
+var redactionExempt = map[string]string{
+	"ProbeResult": "探测输入由因子池样例值与规则常量合成",
+	"TruthRow":    "真值表由声明的取值域枚举生成",
+}
+
+const secret = "4111-1111-1111-1111"
+
+func redactingEngine() *RuleEngine {
+	re := NewRuleEngine()
+	re.SetRecentMatches(4, RecentOptions{KeepInputs: true})
+	re.SetRedactor(func(_ string, v interface{}) interface{} {
+		if _, ok := v.(string); ok {
+			return "<redacted>"
+		}
+		return v
+	})
+	if err := re.AddRule("r1", `env == "prod"`); err != nil {
+		panic(err)
+	}
+	return re
+}
+
+func TestRedactorAppliedOnEverySurface(t *testing.T) {
+	for name, surface := range redactionSurfaces {
+		t.Run(name, func(t *testing.T) {
+			re := redactingEngine()
+			input := map[string]interface{}{"env": secret, "user": map[string]interface{}{"country": secret}}
+			raw, err := json.Marshal(surface(t, re, input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(raw), secret) {
+				t.Errorf("%s 输出了未脱敏的输入: %s", name, raw)
+			}
+			if !strings.Contains(string(raw), "redacted") {
+				t.Errorf("%s 的输出中没有脱敏结果: %s", name, raw)
+			}
+			if input["env"] != secret {
+				t.Errorf("脱敏修改了调用方的输入")
+			}
+		})
+	}
+}
+
+// TestRedactionChecklistComplete 包内每个带 map[string]interface{} 导出字段的导出类型都须登记
+func TestRedactionChecklistComplete(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				spec, ok := n.(*ast.TypeSpec)
+				if !ok || !spec.Name.IsExported() {
+					return true
+				}
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				for _, field := range st.Fields.List {
+					if !isInputMap(field.Type) || len(field.Names) == 0 || !field.Names[0].IsExported() {
+						continue
+					}
+					name := spec.Name.Name
+					if _, ok := redactionSurfaces[name]; ok {
+						continue
+					}
+					if _, ok := redactionExempt[name]; !ok {
+						t.Errorf("%s.%s 携带输入值，须在 redactionSurfaces 中登记并经过脱敏钩子", name, field.Names[0].Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func isInputMap(e ast.Expr) bool {
+	m, ok := e.(*ast.MapType)
+	if !ok {
+		return false
+	}
+	key, ok := m.Key.(*ast.Ident)
+	if !ok || key.Name != "string" {
+		return false
+	}
+	iface, ok := m.Value.(*ast.InterfaceType)
+	return ok && len(iface.Methods.List) == 0
+}
+
+func TestRedactInputWithoutRedactor(t *testing.T) {
+	re := NewRuleEngine()
+	input := map[string]interface{}{"env": "prod"}
+	out := re.RedactInput(input)
+	if reflect.ValueOf(out).UnsafePointer() != reflect.ValueOf(input).UnsafePointer() {
+		t.Error("未设置脱敏钩子时应返回原输入")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { re.RedactInput(input) }); allocs != 0 {
+		t.Errorf("未设置脱敏钩子时 RedactInput 分配了 %v 次", allocs)
+	}
+}
+
+func TestRedactInputNestedPaths(t *testing.T) {
+	re := NewRuleEngine()
+	var seen []string
+	re.SetRedactor(func(factor string, v interface{}) interface{} {
+		seen = append(seen, factor)
+		return v
+	})
+	re.RedactInput(map[string]interface{}{"user": map[string]interface{}{"profile": map[string]interface{}{"level": 2}}})
+	if len(seen) != 1 || seen[0] != "user.profile.level" {
+		t.Errorf("钩子收到的因子名 = %v，应为 [user.profile.level]", seen)
+	}
+}
+
+func TestHashBucketRedactor(t *testing.T) {
+	fn := HashBucketRedactor(10)
+	cases := []struct {
+		in   interface{}
+		want interface{}
+	}{
+		{15, "[10,20)"},
+		{-5, "[-10,0)"},
+		{int64(20), "[20,30)"},
+		{12.5, "[10,20)"},
+		{-0.5, "[-10,0)"},
+		{true, true},
+	}
+	for _, c := range cases {
+		if got := fn("f", c.in); got != c.want {
+			t.Errorf("HashBucketRedactor(10)(%v) = %v，应为 %v", c.in, got, c.want)
+		}
+	}
+	s := fn("f", secret).(string)
+	if !strings.HasPrefix(s, "sha256:") || strings.Contains(s, secret) || s != fn("g", secret) {
+		t.Errorf("字符串应稳定地哈希: %q", s)
+	}
+}
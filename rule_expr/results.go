@@ -0,0 +1,141 @@
+package rule_expr
+
+import (
+	"bytes"
+	"container/list"
+	"slices"
+	"sync"
+)
+
+/* ---------- 匹配结果缓存 ---------- */
+
+// ResultCacheOptions 结果缓存的选项，零值只按指纹比较
+type ResultCacheOptions struct {
+	// Paranoid 条目同时保存输入的规范编码，命中时逐字节核对；
+	// 指纹相同而编码不同即为碰撞，计入 ResultCacheStats.Collisions 并按未命中处理
+	Paranoid bool
+}
+
+// ResultCacheStats 结果缓存的累计统计
+type ResultCacheStats struct {
+	Hits       uint64
+	Misses     uint64 // 含条目属于旧规则集版本与检测到碰撞的情况
+	Collisions uint64 // 仅 Paranoid 模式能检测到
+	Evictions  uint64
+	Entries    int
+	Capacity   int
+}
+
+// resultCache 以 FingerprintInput 为键的 LRU；条目记录写入时的规则集版本，版本不同即失效
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	paranoid bool
+	hash     func(canonical []byte) [16]byte // 测试可替换为弱哈希以制造碰撞
+	ll       *list.List                      // 最近使用的在前，元素值为 *resultEntry
+	items    map[[16]byte]*list.Element
+	stats    ResultCacheStats
+}
+
+type resultEntry struct {
+	key        [16]byte
+	generation uint64
+	flags      *flagProvider // 写入时的开关配置；SetFlagDefault 等会替换 provider，旧条目随之失效
+	canonical  []byte        // 仅 Paranoid 模式保存
+	hits       []string
+}
+
+// SetResultCache 让 Match 缓存最近 size 个不同输入的命中结果，0（默认）关闭；会丢弃已有条目。
+// 规则集任何变更（含启停、改优先级）及开关默认值变更后旧条目自动失效。
+// 设置了特性开关查询函数或随机评估顺序时结果可能随外部状态变化，不使用缓存；
+// 执行出错的匹配不写入缓存。命中时不执行规则，因此不计入 EvalStats 的评估次数。
+// 只有 Match / MatchContext 使用缓存
+func (re *RuleEngine) SetResultCache(size int, opts ...ResultCacheOptions) {
+	if size <= 0 {
+		re.results.Store(nil)
+		return
+	}
+	var opt ResultCacheOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	re.results.Store(&resultCache{
+		capacity: size,
+		paranoid: opt.Paranoid,
+		hash:     fingerprintBytes,
+		ll:       list.New(),
+		items:    make(map[[16]byte]*list.Element),
+	})
+}
+
+// ResultCacheStats 返回结果缓存的统计；未开启时为零值
+func (re *RuleEngine) ResultCacheStats() ResultCacheStats {
+	c := re.results.Load()
+	if c == nil {
+		return ResultCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries, s.Capacity = c.ll.Len(), c.capacity
+	return s
+}
+
+// resultCacheFor 本次匹配可用的缓存：未开启，或结果可能随外部状态变化时返回 nil
+func (re *RuleEngine) resultCacheFor(flags *flagProvider) *resultCache {
+	c := re.results.Load()
+	if c == nil || (flags != nil && flags.fn != nil) || re.shuffle.Load() != nil {
+		return nil
+	}
+	return c
+}
+
+// get 查找 generation 版本与 flags 配置下的结果，返回命中的副本
+func (c *resultCache) get(key [16]byte, canonical []byte, generation uint64, flags *flagProvider) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := el.Value.(*resultEntry)
+	if c.paranoid && !bytes.Equal(e.canonical, canonical) {
+		c.stats.Collisions++
+		c.stats.Misses++
+		return nil, false
+	}
+	if e.generation != generation || e.flags != flags {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return slices.Clone(e.hits), true
+}
+
+// put 写入或覆盖 key 的条目；hits 被复制，调用方可以继续使用
+func (c *resultCache) put(key [16]byte, canonical []byte, generation uint64, flags *flagProvider, hits []string) {
+	e := &resultEntry{key: key, generation: generation, flags: flags, hits: slices.Clone(hits)}
+	if c.paranoid {
+		e.canonical = canonical
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		// 并发匹配可能晚于规则变更写入旧版本的结果，不能覆盖更新的条目
+		if el.Value.(*resultEntry).generation > generation {
+			return
+		}
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*resultEntry).key)
+		c.stats.Evictions++
+	}
+}
@@ -0,0 +1,230 @@
+package rule_expr
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+/* ---------- 因子改名 / 改类型迁移 ---------- */
+
+// FactorRewrite 描述一个因子的迁移方式
+type FactorRewrite struct {
+	RenameTo string // 新因子名，空表示不改名
+	// ToString 把与该因子比较的整数常量改写为同值字符串（如 12345 -> "12345"）
+	ToString bool
+	// Convert 自定义常量转换，优先于 ToString；返回值须为 string/int/float64/bool
+	Convert func(v interface{}) (interface{}, error)
+}
+
+func (f FactorRewrite) retypes() bool {
+	return f.ToString || f.Convert != nil
+}
+
+func (f FactorRewrite) convert(v interface{}) (interface{}, error) {
+	if f.Convert != nil {
+		return f.Convert(v)
+	}
+	if n, ok := v.(int); ok {
+		return strconv.Itoa(n), nil
+	}
+	return nil, fmt.Errorf("常量 %#v 不是整数，无法改写为字符串", v)
+}
+
+// RuleRewrite 一条被改写的规则
+type RuleRewrite struct {
+	ID  string
+	Old string
+	New string
+}
+
+// RewriteFailure 无法自动改写、需要人工处理的规则
+type RewriteFailure struct {
+	ID  string
+	Err string
+}
+
+// RewriteReport 迁移结果，各列表按规则 ID 排序
+type RewriteReport struct {
+	DryRun    bool
+	Changed   []RuleRewrite
+	Failed    []RewriteFailure
+	Unchanged int // 未引用任何待迁移因子的规则数
+}
+
+// Diff 以每条规则一对 -/+ 行的形式列出改写
+func (r RewriteReport) Diff() string {
+	var b strings.Builder
+	for _, c := range r.Changed {
+		fmt.Fprintf(&b, "- %s: %s\n+ %s: %s\n", c.ID, c.Old, c.ID, c.New)
+	}
+	return b.String()
+}
+
+// PlanRewrite 计算 RewriteRules 会做的改写，但不修改引擎
+func (re *RuleEngine) PlanRewrite(mapping map[string]FactorRewrite) RewriteReport {
+	report := RewriteReport{DryRun: true}
+	rules := slices.Clone(re.snapshot())
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	for _, r := range rules {
+		out, changed, err := rewriteExpr(r.ExprStr, mapping)
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, RewriteFailure{r.ID, err.Error()})
+		case changed:
+			report.Changed = append(report.Changed, RuleRewrite{r.ID, r.ExprStr, out})
+		default:
+			report.Unchanged++
+		}
+	}
+	return report
+}
+
+// RewriteRules 对全部规则应用改名与常量改类型，以规范形式重新编译并替换（配置了 store 时同步落盘），
+// 优先级、标签、说明等元数据保持不变。
+// 无法自动改写的规则保持原样并列入 Failed；重新编译失败时停止并返回错误，已替换的规则不回滚
+func (re *RuleEngine) RewriteRules(mapping map[string]FactorRewrite) (RewriteReport, error) {
+	report := re.PlanRewrite(mapping)
+	report.DryRun = false
+	for _, c := range report.Changed {
+		var meta RuleMeta
+		if r, ok := re.lookup(c.ID); ok {
+			meta = r.meta()
+		}
+		if err := re.addRule(c.ID, c.New, meta, true); err != nil {
+			return report, fmt.Errorf("改写后的规则 %s 编译失败: %w", c.ID, err)
+		}
+	}
+	return report, nil
+}
+
+// rewriteExpr 分两遍：先按旧因子名改写比较中的常量，再改名并检查改类型的因子
+// 是否还出现在无法自动改写的位置；最后输出规范形式
+func rewriteExpr(exprStr string, mapping map[string]FactorRewrite) (string, bool, error) {
+	tree, err := parser.Parse(exprStr)
+	if err != nil {
+		return "", false, err
+	}
+	rt := &retyper{mapping: mapping, handled: make(map[*ast.IdentifierNode]bool)}
+	ast.Walk(&tree.Node, rt)
+	if rt.err != nil {
+		return "", false, rt.err
+	}
+	rn := &renamer{mapping: mapping, handled: rt.handled}
+	ast.Walk(&tree.Node, rn)
+	if rn.err != nil {
+		return "", false, rn.err
+	}
+	if !rt.changed && !rn.changed {
+		return exprStr, false, nil
+	}
+	ast.Walk(&tree.Node, canonicalizer{})
+	return tree.Node.String(), true, nil
+}
+
+// retyper 改写 "因子 ==/!= 常量" 与 "因子 in [常量...]" 中的常量
+type retyper struct {
+	mapping map[string]FactorRewrite
+	handled map[*ast.IdentifierNode]bool // 其比较常量已改写的标识符
+	changed bool
+	err     error
+}
+
+func (w *retyper) Visit(node *ast.Node) {
+	n, ok := (*node).(*ast.BinaryNode)
+	if !ok || w.err != nil {
+		return
+	}
+	switch n.Operator {
+	case "==", "!=":
+		if id, ok := w.target(n.Left); ok {
+			w.convertAt(id, &n.Right)
+		} else if id, ok := w.target(n.Right); ok {
+			w.convertAt(id, &n.Left)
+		}
+	case "in", "not in":
+		id, ok := w.target(n.Left)
+		arr, isArr := n.Right.(*ast.ArrayNode)
+		if !ok || !isArr {
+			return
+		}
+		for i := range arr.Nodes {
+			w.convertAt(id, &arr.Nodes[i])
+		}
+	}
+}
+
+// target 返回需要改类型的因子标识符
+func (w *retyper) target(node ast.Node) (*ast.IdentifierNode, bool) {
+	id, ok := node.(*ast.IdentifierNode)
+	if !ok || !w.mapping[id.Value].retypes() {
+		return nil, false
+	}
+	return id, true
+}
+
+func (w *retyper) convertAt(id *ast.IdentifierNode, slot *ast.Node) {
+	v, ok := literalValue(*slot)
+	if !ok {
+		return // 与非常量比较，留给 renamer 判为无法改写
+	}
+	nv, err := w.mapping[id.Value].convert(v)
+	if err != nil {
+		w.err = fmt.Errorf("因子 %s: %w", id.Value, err)
+		return
+	}
+	lit, err := literalNode(nv)
+	if err != nil {
+		w.err = fmt.Errorf("因子 %s: %w", id.Value, err)
+		return
+	}
+	*slot = lit
+	w.handled[id] = true
+	w.changed = true
+}
+
+func literalNode(v interface{}) (ast.Node, error) {
+	switch x := v.(type) {
+	case string:
+		return &ast.StringNode{Value: x}, nil
+	case int:
+		return &ast.IntegerNode{Value: x}, nil
+	case float64:
+		return &ast.FloatNode{Value: x}, nil
+	case bool:
+		return &ast.BoolNode{Value: x}, nil
+	}
+	return nil, fmt.Errorf("转换结果 %#v 的类型 %T 不受支持", v, v)
+}
+
+// renamer 改名；改类型的因子若出现在未改写常量的位置（如 > 比较、算术）则报错
+type renamer struct {
+	mapping map[string]FactorRewrite
+	handled map[*ast.IdentifierNode]bool
+	changed bool
+	err     error
+}
+
+func (w *renamer) Visit(node *ast.Node) {
+	id, ok := (*node).(*ast.IdentifierNode)
+	if !ok || w.err != nil {
+		return
+	}
+	fr, ok := w.mapping[id.Value]
+	if !ok {
+		return
+	}
+	if fr.retypes() && !w.handled[id] {
+		w.err = fmt.Errorf("因子 %s 出现在无法自动改写类型的位置", id.Value)
+		return
+	}
+	if fr.RenameTo != "" && fr.RenameTo != id.Value {
+		id.Value = fr.RenameTo
+		w.changed = true
+	}
+}
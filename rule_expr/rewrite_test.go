@@ -0,0 +1,145 @@
+package rule_expr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func rewriteEngine(t *testing.T, rules map[string]string) *RuleEngine {
+	t.Helper()
+	re := NewRuleEngine()
+	for id, expr := range rules {
+		if err := re.AddRule(id, expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return re
+}
+
+func ruleExpr(t *testing.T, re *RuleEngine, id string) string {
+	t.Helper()
+	r, ok := re.lookup(id)
+	if !ok {
+		t.Fatalf("规则 %s 不存在", id)
+	}
+	return r.ExprStr
+}
+
+// TestRewriteRenameNested 嵌套在 not 与括号中的因子全部改名，改写前后对等价输入的结果相同
+func TestRewriteRenameNested(t *testing.T) {
+	const old = `not (ip_risk and amount > 100) and (env == "prod" or (ip_risk || is_vip))`
+	re := NewRuleEngine()
+	if err := re.AddRuleWithPriority("nested", old, 7); err != nil {
+		t.Fatal(err)
+	}
+	before := make(map[bool][]string)
+	for _, risk := range []bool{false, true} {
+		before[risk] = re.Match(map[string]interface{}{"ip_risk": risk, "amount": 50.0, "env": "test_env", "is_vip": false})
+	}
+
+	report, err := re.RewriteRules(map[string]FactorRewrite{"ip_risk": {RenameTo: "high_risk_ip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DryRun || len(report.Changed) != 1 || len(report.Failed) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	got := ruleExpr(t, re, "nested")
+	if strings.Contains(got, "ip_risk") || strings.Count(got, "high_risk_ip") != 2 {
+		t.Fatalf("两处 ip_risk 都应改名: %s", got)
+	}
+	if c := report.Changed[0]; c.ID != "nested" || c.Old != old || c.New != got {
+		t.Fatalf("Changed = %+v", c)
+	}
+	if r, _ := re.lookup("nested"); r.Priority != 7 {
+		t.Fatalf("改写不应丢失优先级: %d", r.Priority)
+	}
+	for _, risk := range []bool{false, true} {
+		after := re.Match(map[string]interface{}{"high_risk_ip": risk, "amount": 50.0, "env": "test_env", "is_vip": false})
+		if !slices.Equal(after, before[risk]) {
+			t.Fatalf("high_risk_ip=%v: 改写后命中 %v，改写前 %v", risk, after, before[risk])
+		}
+	}
+}
+
+// TestRewriteRetype 内置的整数转字符串与自定义转换函数，覆盖 ==、!= 与 in 列表
+func TestRewriteRetype(t *testing.T) {
+	re := rewriteEngine(t, map[string]string{
+		"uid":  "user_id == 12345 or 67890 != user_id",
+		"list": "user_id in [1, 2] and is_vip",
+		"tier": `level == 3`,
+	})
+	toLabel := func(v interface{}) (interface{}, error) {
+		n, ok := v.(int)
+		if !ok {
+			return nil, fmt.Errorf("level 应为整数: %#v", v)
+		}
+		return []string{"", "bronze", "silver", "gold"}[n], nil
+	}
+	report, err := re.RewriteRules(map[string]FactorRewrite{
+		"user_id": {ToString: true},
+		"level":   {RenameTo: "tier", Convert: toLabel},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changed) != 3 || len(report.Failed) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	want := map[string]string{
+		"uid":  `user_id == "12345" or "67890" != user_id`,
+		"list": `user_id in ["1", "2"] and is_vip`,
+		"tier": `tier == "gold"`,
+	}
+	for id, w := range want {
+		if got := ruleExpr(t, re, id); got != w {
+			t.Errorf("%s: %s，应为 %s", id, got, w)
+		}
+	}
+	hits := re.Match(map[string]interface{}{"user_id": "1", "is_vip": true, "tier": "gold"})
+	slices.Sort(hits)
+	if !slices.Equal(hits, []string{"list", "tier", "uid"}) {
+		t.Fatalf("改写后应按字符串取值命中: %v", hits)
+	}
+}
+
+// TestRewriteUntouchedAndFailed 未引用因子的规则原样保留；无法自动改类型的规则列入 Failed 且不修改
+func TestRewriteUntouchedAndFailed(t *testing.T) {
+	const untouched = `env=="prod"   and amount>100` // 非规范写法也不应被重排
+	re := rewriteEngine(t, map[string]string{
+		"other": untouched,
+		"range": "user_id > 10",
+		"eq":    "user_id == 7",
+	})
+	mapping := map[string]FactorRewrite{"user_id": {ToString: true}}
+
+	plan := re.PlanRewrite(mapping)
+	if !plan.DryRun || plan.Unchanged != 1 || len(plan.Changed) != 1 || len(plan.Failed) != 1 || plan.Failed[0].ID != "range" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if got := ruleExpr(t, re, "eq"); got != "user_id == 7" {
+		t.Fatalf("dry run 不应修改引擎: %s", got)
+	}
+	if diff := plan.Diff(); diff != "- eq: user_id == 7\n+ eq: user_id == \"7\"\n" {
+		t.Fatalf("Diff = %q", diff)
+	}
+
+	report, err := re.RewriteRules(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Unchanged != 1 || len(report.Failed) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if got := ruleExpr(t, re, "other"); got != untouched {
+		t.Fatalf("未涉及的规则被改动: %s", got)
+	}
+	if got := ruleExpr(t, re, "range"); got != "user_id > 10" {
+		t.Fatalf("无法改写的规则应保持原样: %s", got)
+	}
+	if got := ruleExpr(t, re, "eq"); got != `user_id == "7"` {
+		t.Fatalf("eq = %s", got)
+	}
+}
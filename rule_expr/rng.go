@@ -0,0 +1,68 @@
+package rule_expr
+
+import (
+	"math/rand"
+	"sync"
+)
+
+/* ---------- 可拆分随机源 ---------- */
+
+// splitMix64 轻量 rand.Source64，状态只有一个 uint64，创建成本远低于 rand.NewSource
+type splitMix64 struct {
+	state uint64
+}
+
+func (s *splitMix64) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix64) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix64) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// itemRand 返回第 i 个生成项专用的随机源。
+// 种子由 (seed, i) 哈希得到，因此无论由哪个 worker、以什么顺序生成，第 i 项的结果都相同
+func itemRand(seed int64, i int) *rand.Rand {
+	return rand.New(&splitMix64{state: itemSeed(seed, i)})
+}
+
+// itemSeed 第 i 项的初始状态；热循环里可以直接重置同一个 splitMix64 以免逐项分配
+func itemSeed(seed int64, i int) uint64 {
+	mix := splitMix64{state: uint64(seed)}
+	mix.state ^= mix.Uint64() + uint64(i)*0xd1b54a32d192ed03
+	return mix.Uint64()
+}
+
+// parallelFill 用 workers 个 goroutine 对 [0, n) 的每个下标调用 fn；workers<=1 时串行
+func parallelFill(n, workers int, fn func(i int)) {
+	if workers <= 1 || n < workers {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, min((w+1)*chunk, n)
+		if lo >= hi {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
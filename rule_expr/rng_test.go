@@ -0,0 +1,68 @@
+package rule_expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func corpusBytes(t *testing.T, rows []map[string]interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(rows) // map 按键排序输出，字节相同即内容相同
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestParallelGenerationMatchesSerial(t *testing.T) {
+	const seed, n = 42, 1000
+	serial := corpusBytes(t, genRandomInputs(seed, n, 1))
+	for _, workers := range []int{2, 8, 13} {
+		if got := corpusBytes(t, genRandomInputs(seed, n, workers)); !bytes.Equal(got, serial) {
+			t.Errorf("%d 个 worker 生成的数据与串行生成不同", workers)
+		}
+	}
+	if bytes.Equal(corpusBytes(t, genRandomInputs(seed+1, n, 8)), serial) {
+		t.Error("不同种子生成了相同的数据")
+	}
+}
+
+// TestParallelGenerationRace 多个生成同时进行、各自以 8 个 worker 并行，供 -race 检查
+func TestParallelGenerationRace(t *testing.T) {
+	want := corpusBytes(t, genRandomInputs(7, 500, 1))
+	var wg sync.WaitGroup
+	results := make([][]byte, 4)
+	for g := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			raw, _ := json.Marshal(genRandomInputs(7, 500, 8))
+			results[g] = raw
+		}()
+	}
+	wg.Wait()
+	for g, got := range results {
+		if !bytes.Equal(got, want) {
+			t.Errorf("并发生成 #%d 的结果与串行生成不同", g)
+		}
+	}
+}
+
+func TestItemSeedIndependentOfOrder(t *testing.T) {
+	seen := make(map[uint64]int)
+	for i := 0; i < 10000; i++ {
+		s := itemSeed(1, i)
+		if j, ok := seen[s]; ok {
+			t.Fatalf("第 %d 项与第 %d 项的种子相同", i, j)
+		}
+		seen[s] = i
+	}
+	// 同一 (seed, i) 总得到同一随机序列，与此前取过哪些项无关
+	for i := 99; i >= 0; i-- {
+		if itemRand(1, i).Int63() != itemRand(1, i).Int63() {
+			t.Fatalf("第 %d 项的随机源不确定", i)
+		}
+	}
+}
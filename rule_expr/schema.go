@@ -0,0 +1,260 @@
+package rule_expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"goexprtester/rule_engine"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* ---------- 输入 JSON Schema ---------- */
+
+// schemaCache 按规则集版本缓存生成的 schema
+type schemaCache struct {
+	generation uint64
+	doc        []byte
+}
+
+// InputJSONSchema 根据因子池生成匹配输入的 JSON Schema（draft 2020-12）：
+// 类型来自 Kind，Enumerated 因子给出 enum，其余给出 examples；
+// 被任一规则引用的因子列为 required。结果按 Generation 缓存，规则变更后重新生成
+func (re *RuleEngine) InputJSONSchema() ([]byte, error) {
+	gen := re.generation.Load()
+	if c := re.schema.Load(); c != nil && c.generation == gen {
+		return c.doc, nil
+	}
+	doc, err := json.MarshalIndent(re.buildSchema(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("生成输入 schema 失败: %w", err)
+	}
+	re.schema.Store(&schemaCache{generation: gen, doc: doc})
+	return doc, nil
+}
+
+func (re *RuleEngine) buildSchema() map[string]interface{} {
+	props := make(map[string]interface{}, len(re.pool.Factors))
+	for _, f := range re.pool.Factors {
+		p := map[string]interface{}{"type": jsonType(f.Kind)}
+		switch f.Kind {
+		case Time:
+			p["format"] = "date-time"
+		case List:
+			p["items"] = map[string]interface{}{"type": "string"}
+		}
+		if f.Description != "" {
+			p["description"] = f.Description
+		}
+		if f.Enumerated && len(f.SampleValues) > 0 {
+			p["enum"] = f.SampleValues
+		} else if f.Example != nil {
+			p["examples"] = []interface{}{f.Example}
+		}
+		schemaObject(props, f.Name)[lastSegment(f.Name)] = p
+	}
+	doc := map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "match input",
+		"type":       "object",
+		"properties": props,
+		"required":   []string{},
+	}
+	// 嵌套因子的必填项记在各层对象上：user.country 必填即 user 必填且 user 的 country 必填
+	for _, name := range re.requiredFactors() {
+		obj := doc
+		for _, seg := range strings.Split(name, ".") {
+			if req := obj["required"].([]string); !slices.Contains(req, seg) {
+				obj["required"] = append(req, seg)
+			}
+			if next, ok := obj["properties"].(map[string]interface{})[seg].(map[string]interface{}); ok {
+				obj = next
+			}
+		}
+	}
+	return doc
+}
+
+// schemaObject 返回嵌套因子 name 所在对象的 properties，沿途缺失的对象层按 {"type": "object"} 新建；
+// 顶层因子直接返回 props
+func schemaObject(props map[string]interface{}, name string) map[string]interface{} {
+	segs := strings.Split(name, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		obj, ok := props[seg].(map[string]interface{})
+		if !ok {
+			obj = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}, "required": []string{}}
+			props[seg] = obj
+		}
+		props = obj["properties"].(map[string]interface{})
+	}
+	return props
+}
+
+func lastSegment(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+// requiredFactors 返回被至少一条规则引用、且在因子池中声明过的因子，已排序
+func (re *RuleEngine) requiredFactors() []string {
+	seen := make(map[string]bool)
+	for _, r := range re.snapshot() {
+		for _, id := range r.shape.Identifiers {
+			if _, ok := re.pool.Lookup(id); ok {
+				seen[id] = true
+			}
+		}
+	}
+	required := make([]string, 0, len(seen))
+	for id := range seen {
+		required = append(required, id)
+	}
+	sort.Strings(required)
+	return required
+}
+
+func jsonType(k Kind) string {
+	switch k {
+	case Bool:
+		return "boolean"
+	case Int:
+		return "integer"
+	case Float:
+		return "number"
+	case List:
+		return "array"
+	default:
+		return "string"
+	}
+}
+
+// SchemaViolation 输入不符合 schema 的一处位置
+type SchemaViolation struct {
+	Pointer string // JSON Pointer（RFC 6901），如 "/env"
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	return v.Pointer + ": " + v.Message
+}
+
+// ValidateInput 按 InputJSONSchema 描述的约束检查 input：被规则引用的因子缺失、取值类型与因子池不符、
+// 不在枚举取值域中都算违规，全部违规一次性汇总为 *InputError 返回，没有违规时返回 nil。
+// 未在因子池中声明的字段不做检查；启用 WithLenientInputs 时不检查缺失，见 MatchStrict
+func (re *RuleEngine) ValidateInput(input map[string]interface{}) error {
+	if out := re.inputViolations(input); len(out) > 0 {
+		return &InputError{Violations: out}
+	}
+	return nil
+}
+
+// inputViolations 返回按指针排序的违规项
+func (re *RuleEngine) inputViolations(input map[string]interface{}) []SchemaViolation {
+	var out []SchemaViolation
+	if !re.lenient {
+		for _, name := range re.requiredFactors() {
+			if _, ok := rule_engine.LookupPath(input, name); !ok {
+				out = append(out, SchemaViolation{jsonPointer(name), "缺少必填字段"})
+			}
+		}
+	}
+	for _, f := range re.pool.Factors {
+		name := f.Name
+		v, ok := rule_engine.LookupPath(input, name)
+		if !ok {
+			continue
+		}
+		if !matchesKind(f.Kind, v) {
+			out = append(out, SchemaViolation{jsonPointer(name), fmt.Sprintf("应为 %s，实际为 %T", jsonType(f.Kind), v)})
+			continue
+		}
+		if f.Enumerated && len(f.SampleValues) > 0 && !slices.ContainsFunc(f.SampleValues, func(s interface{}) bool {
+			return sameValue(s, v)
+		}) {
+			out = append(out, SchemaViolation{jsonPointer(name), fmt.Sprintf("%#v 不在取值域 %v 中", re.redactValue(name, v), f.SampleValues)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pointer < out[j].Pointer })
+	return out
+}
+
+// InputError 输入不符合因子池的全部违规项
+type InputError struct {
+	Violations []SchemaViolation // 按指针排序
+}
+
+func (e *InputError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return fmt.Sprintf("输入有 %d 处不符合因子池: %s", len(parts), strings.Join(parts, "; "))
+}
+
+// matchesKind 同时接受 Go 原生类型与 encoding/json 解码出的 float64
+func matchesKind(k Kind, v interface{}) bool {
+	switch k {
+	case Bool:
+		_, ok := v.(bool)
+		return ok
+	case String:
+		_, ok := v.(string)
+		return ok
+	case Int:
+		switch n := v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case float64:
+			return n == math.Trunc(n) && !math.IsInf(n, 0)
+		case json.Number:
+			_, err := n.Int64()
+			return err == nil
+		}
+	case Float:
+		switch n := v.(type) {
+		case float64:
+			return !math.IsInf(n, 0) && !math.IsNaN(n)
+		case float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case json.Number:
+			_, err := n.Float64()
+			return err == nil
+		}
+	case Time:
+		switch t := v.(type) {
+		case time.Time:
+			return true
+		case string:
+			_, err := time.Parse(time.RFC3339, t)
+			return err == nil
+		}
+	case List:
+		switch l := v.(type) {
+		case []string:
+			return true
+		case []interface{}:
+			for _, item := range l {
+				if _, ok := item.(string); !ok {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func sameValue(a, b interface{}) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// jsonPointer 因子对应的 JSON Pointer，嵌套因子的每一段各为一个引用记号
+func jsonPointer(name string) string {
+	esc := strings.NewReplacer("~", "~0", "/", "~1")
+	var b strings.Builder
+	for _, seg := range strings.Split(name, ".") {
+		b.WriteString("/" + esc.Replace(seg))
+	}
+	return b.String()
+}
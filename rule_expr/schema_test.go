@@ -0,0 +1,162 @@
+package rule_expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+func schemaEngine(t *testing.T) *RuleEngine {
+	t.Helper()
+	re := NewRuleEngine()
+	for id, exprStr := range map[string]string{
+		"cn_prod": `env == "prod" and user.country == "CN"`,
+		"big":     "amount > 10",
+	} {
+		if err := re.AddRule(id, exprStr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return re
+}
+
+// compileSchema 用独立的 JSON Schema 实现编译 doc；编译时按 draft 2020-12 元 schema 校验文档本身
+func compileSchema(t *testing.T, doc []byte) *jsonschema.Schema {
+	t.Helper()
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	if err := c.AddResource("input.json", v); err != nil {
+		t.Fatal(err)
+	}
+	sch, err := c.Compile("input.json")
+	if err != nil {
+		t.Fatalf("生成的 schema 不合法: %v", err)
+	}
+	return sch
+}
+
+// leafPointers 校验器报告的违规位置；required 的位置是缺失的字段本身
+func leafPointers(err *jsonschema.ValidationError) []string {
+	if len(err.Causes) == 0 {
+		loc := slices.Clone(err.InstanceLocation)
+		if req, ok := err.ErrorKind.(*kind.Required); ok {
+			loc = append(loc, req.Missing[0])
+		}
+		return []string{"/" + strings.Join(loc, "/")}
+	}
+	var out []string
+	for _, c := range err.Causes {
+		out = append(out, leafPointers(c)...)
+	}
+	return out
+}
+
+// related 两个指针指向同一位置，或一个是另一个的祖先
+func related(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// TestInputJSONSchemaAgreesWithValidator 符合与违反 schema 的样例：独立校验器与 ValidateInput 结论一致，违规位置对应
+func TestInputJSONSchemaAgreesWithValidator(t *testing.T) {
+	re := schemaEngine(t)
+	doc, err := re.InputJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := compileSchema(t, doc)
+
+	base := `"env": "prod", "amount": 12.5, "user": {"country": "CN"}`
+	cases := []struct {
+		name, input string
+		valid       bool
+	}{
+		{"最小合法输入", `{` + base + `}`, true},
+		{"可选字段与未声明字段", `{` + base + `, "user_id": 3, "tags": ["a"], "is_vip": true, "account_created_at": "2024-01-01T00:00:00Z", "extra": 1}`, true},
+		{"整数形式的浮点数", `{"env": "prod", "amount": 12, "user": {"country": "CN"}}`, true},
+		{"缺少顶层字段", `{"amount": 12.5, "user": {"country": "CN"}}`, false},
+		{"缺少嵌套对象", `{"env": "prod", "amount": 12.5}`, false},
+		{"缺少嵌套字段", `{"env": "prod", "amount": 12.5, "user": {}}`, false},
+		{"枚举之外的值", `{"env": "dev", "amount": 12.5, "user": {"country": "CN"}}`, false},
+		{"字符串冒充数字", `{"env": "prod", "amount": "12.5", "user": {"country": "CN"}}`, false},
+		{"整数字段是小数", `{` + base + `, "user_id": 1.5}`, false},
+		{"列表元素类型", `{` + base + `, "tags": [1]}`, false},
+		{"时间格式", `{` + base + `, "account_created_at": "yesterday"}`, false},
+		{"多处违规", `{"env": 5, "amount": true, "user": {"country": 1}, "is_vip": "yes"}`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			inst, err := jsonschema.UnmarshalJSON(strings.NewReader(c.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var input map[string]interface{}
+			if err := json.Unmarshal([]byte(c.input), &input); err != nil {
+				t.Fatal(err)
+			}
+			schemaErr := sch.Validate(inst)
+			ownErr := re.ValidateInput(input)
+			if (schemaErr == nil) != c.valid || (ownErr == nil) != c.valid {
+				t.Fatalf("校验器: %v\nValidateInput: %v\n应为 valid=%v", schemaErr, ownErr, c.valid)
+			}
+			if c.valid {
+				return
+			}
+			var ve *jsonschema.ValidationError
+			var ie *InputError
+			if !errors.As(schemaErr, &ve) || !errors.As(ownErr, &ie) {
+				t.Fatalf("错误类型不对: %T / %T", schemaErr, ownErr)
+			}
+			theirs := leafPointers(ve)
+			for _, v := range ie.Violations {
+				if !slices.ContainsFunc(theirs, func(p string) bool { return related(p, v.Pointer) }) {
+					t.Errorf("ValidateInput 报告 %s，校验器只报告 %v", v.Pointer, theirs)
+				}
+			}
+			for _, p := range theirs {
+				if !slices.ContainsFunc(ie.Violations, func(v SchemaViolation) bool { return related(p, v.Pointer) }) {
+					t.Errorf("校验器报告 %s，ValidateInput 只报告 %v", p, ie.Violations)
+				}
+			}
+		})
+	}
+}
+
+// TestInputJSONSchemaFollowsGeneration 规则集不变时返回同一份缓存，规则变更后 required 随之更新
+func TestInputJSONSchemaFollowsGeneration(t *testing.T) {
+	re := schemaEngine(t)
+	first, _ := re.InputJSONSchema()
+	again, _ := re.InputJSONSchema()
+	if &first[0] != &again[0] {
+		t.Fatal("规则集未变时应返回缓存的文档")
+	}
+	required := func(doc []byte) []string {
+		var parsed struct {
+			Required []string `json:"required"`
+		}
+		if err := json.Unmarshal(doc, &parsed); err != nil {
+			t.Fatal(err)
+		}
+		return parsed.Required
+	}
+	if got := required(first); !slices.Equal(got, []string{"amount", "env", "user"}) {
+		t.Fatalf("required = %v", got)
+	}
+
+	re.RemoveRule("big")
+	re.AddRule("vip", "is_vip")
+	next, _ := re.InputJSONSchema()
+	if got := required(next); !slices.Equal(got, []string{"env", "is_vip", "user"}) {
+		t.Fatalf("规则变更后 required = %v", got)
+	}
+	compileSchema(t, next)
+}
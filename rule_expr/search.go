@@ -0,0 +1,64 @@
+package rule_expr
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/* ---------- 规则检索 ---------- */
+
+// RuleQuery 规则检索条件，所有非空条件按 AND 组合。
+// 文本条件作用于规范形式的表达式（见 canonicalExpr），&& 与 and 等不同写法的规则同样能被检索到
+type RuleQuery struct {
+	Contains string         // 表达式文本包含的子串
+	Regex    *regexp.Regexp // 表达式文本需匹配的正则
+	Vars     []string       // 必须全部引用的因子
+	Ops      []string       // 必须全部用到的运算符（and/or/not 也可写作 &&/||/!）
+	Tags     []string       // 必须全部带有的标签
+	// MinPriority / MaxPriority 非 nil 时限定优先级范围（闭区间）
+	MinPriority *int
+	MaxPriority *int
+}
+
+// SearchRules 返回满足 q 的规则，按 ID 排序。遍历当前规则快照，不阻塞写入
+func (re *RuleEngine) SearchRules(q RuleQuery) []*Rule {
+	ops := make([]string, len(q.Ops))
+	for i, op := range q.Ops {
+		if alias, ok := opAliases[op]; ok {
+			op = alias
+		}
+		ops[i] = op
+	}
+
+	var out []*Rule
+	for _, r := range re.snapshot() {
+		text := r.shape.Canonical
+		if q.Contains != "" && !strings.Contains(text, q.Contains) {
+			continue
+		}
+		if q.Regex != nil && !q.Regex.MatchString(text) {
+			continue
+		}
+		if !containsAll(r.shape.Identifiers, q.Vars) || !containsAll(r.shape.Operators, ops) || !containsAll(r.Tags, q.Tags) {
+			continue
+		}
+		if q.MinPriority != nil && r.Priority < *q.MinPriority || q.MaxPriority != nil && r.Priority > *q.MaxPriority {
+			continue
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// containsAll have 是否包含 want 中的全部元素
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package rule_expr
+
+import (
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func searchEngine(t *testing.T) *RuleEngine {
+	t.Helper()
+	re := NewRuleEngine()
+	for _, r := range []struct {
+		id, expr string
+		meta     RuleMeta
+	}{
+		{"paypal", `payment_method == "PAYPAL" && amount > 100`, RuleMeta{Priority: 5, Tags: []string{"risk", "payment"}}},
+		{"pattern", `payment_method matches "^P"`, RuleMeta{Priority: 1, Tags: []string{"payment"}}},
+		{"not_vip", `!is_vip and env == "prod"`, RuleMeta{Tags: []string{"risk"}}},
+		{"country", `user.country in ["CN", "US"]`, RuleMeta{Priority: 9}},
+	} {
+		if err := re.addRule(r.id, r.expr, r.meta, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return re
+}
+
+func ids(rules []*Rule) []string {
+	out := []string{}
+	for _, r := range rules {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func intp(n int) *int { return &n }
+
+func TestSearchRules(t *testing.T) {
+	re := searchEngine(t)
+	cases := []struct {
+		name string
+		q    RuleQuery
+		want []string
+	}{
+		{"全部", RuleQuery{}, []string{"country", "not_vip", "pattern", "paypal"}},
+		{"变量", RuleQuery{Vars: []string{"payment_method"}}, []string{"pattern", "paypal"}},
+		{"变量 + 运算符", RuleQuery{Vars: []string{"payment_method"}, Ops: []string{"matches"}}, []string{"pattern"}},
+		{"符号写法的运算符", RuleQuery{Ops: []string{"&&"}}, []string{"not_vip", "paypal"}},
+		{"not 与 ! 等价", RuleQuery{Ops: []string{"not"}}, []string{"not_vip"}},
+		{"嵌套字段", RuleQuery{Vars: []string{"user.country"}, Ops: []string{"in"}}, []string{"country"}},
+		{"子串匹配规范形式", RuleQuery{Contains: `payment_method == "PAYPAL" and`}, []string{"paypal"}},
+		{"正则", RuleQuery{Regex: regexp.MustCompile(`^not `)}, []string{"not_vip"}},
+		{"标签", RuleQuery{Tags: []string{"risk", "payment"}}, []string{"paypal"}},
+		{"优先级范围", RuleQuery{MinPriority: intp(1), MaxPriority: intp(5)}, []string{"pattern", "paypal"}},
+		{"组合", RuleQuery{Vars: []string{"amount"}, Tags: []string{"risk"}, MinPriority: intp(5), Contains: "PAYPAL"}, []string{"paypal"}},
+		{"无结果", RuleQuery{Vars: []string{"env"}, Ops: []string{"matches"}}, []string{}},
+		{"未知变量", RuleQuery{Vars: []string{"no_such_factor"}}, []string{}},
+	}
+	for _, c := range cases {
+		if got := ids(re.SearchRules(c.q)); !slices.Equal(got, c.want) {
+			t.Errorf("%s: %v，应为 %v", c.name, got, c.want)
+		}
+	}
+}
+
+// TestSearchRulesDuringWrites 检索遍历快照，与规则变更并发安全，且只看到完整的规则
+func TestSearchRulesDuringWrites(t *testing.T) {
+	re := searchEngine(t)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			re.AddRule("tmp", `payment_method == "STRIPE"`)
+			re.RemoveRule("tmp")
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		for _, r := range re.SearchRules(RuleQuery{Vars: []string{"payment_method"}}) {
+			if r.ID != "paypal" && r.ID != "pattern" && r.ID != "tmp" {
+				t.Fatalf("意外的规则 %s", r.ID)
+			}
+		}
+	}
+	wg.Wait()
+}
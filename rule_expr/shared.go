@@ -0,0 +1,273 @@
+package rule_expr
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/compiler"
+	"github.com/expr-lang/expr/conf"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 共享原子条件 ---------- */
+
+// 大量规则共用相同的叶子条件（env == "prod"、is_vip 等）。启用 WithSharedPredicates 后，
+// 规则在编译时按 and / or / not 拆成原子条件上的布尔树，原子条件按规范文本去重后放进引擎级的共享表；
+// MatchShared 对每个输入只执行一次用到的原子条件，再按各规则的布尔树组合缓存的结果
+
+// WithSharedPredicates 编译规则时额外拆出原子条件，供 MatchShared 使用；Match 不受影响。
+// 共享表只增不减：删除或替换规则后不再用到的原子条件仍保留，直到引擎释放
+func WithSharedPredicates() EngineOption {
+	return func(re *RuleEngine) {
+		re.preds = &predicateTable{ids: make(map[string]int32)}
+		re.preds.progs.Store(&[]*vm.Program{})
+	}
+}
+
+// predicateTable 引擎内全部规则共享的原子条件
+type predicateTable struct {
+	mu    sync.Mutex       // 串行化新增
+	ids   map[string]int32 // 规范文本 -> 下标，由 mu 保护
+	progs atomic.Pointer[[]*vm.Program]
+	// progs 下标 -> 编译结果；新增时写时复制，匹配路径无锁读取。
+	// 规则发布前其原子条件已经写入，因此先取规则快照、后取 progs 的匹配总能找到规则用到的条件
+}
+
+// 布尔树节点
+const (
+	boolPred = iota // 原子条件
+	boolAnd
+	boolOr
+	boolNot
+)
+
+// boolNode 规则在原子条件上的布尔树；叶子引用共享表中的条件，多条规则共用同一叶子即构成 DAG
+type boolNode struct {
+	op   uint8
+	pred int32 // op 为 boolPred 时有效
+	l, r *boolNode
+}
+
+// decompose 把表达式拆成原子条件上的布尔树。拆分的是 expr 检查并优化之后的语法树（与 Compile 相同的流程），
+// 常量折叠（x or true 等）与优化改写都已生效，短路与出错行为和 Match 执行的程序一致。
+// 某个原子条件无法单独编译或取得规范文本时，整条规则作为一个原子条件。
+// lenient 的规则拆分改写后的语法树，改写产生的 ?? 等节点留在原子条件内，去重键随之不同
+func (t *predicateTable) decompose(exprStr string, lenient bool) (*boolNode, error) {
+	tree, config, err := checkedTree(exprStr, lenient)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := t.build(tree, tree.Node, config); err == nil {
+		return n, nil
+	}
+	key := exprStr
+	if lenient {
+		key, err = nodeText(tree.Node)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.leaf(tree, tree.Node, config, key)
+}
+
+func (t *predicateTable) build(tree *parser.Tree, node ast.Node, config *conf.Config) (*boolNode, error) {
+	switch n := node.(type) {
+	case *ast.BinaryNode:
+		var op uint8
+		switch n.Operator {
+		case "and", "&&":
+			op = boolAnd
+		case "or", "||":
+			op = boolOr
+		default:
+			return t.leaf(tree, node, config, "")
+		}
+		l, err := t.build(tree, n.Left, config)
+		if err != nil {
+			return nil, err
+		}
+		r, err := t.build(tree, n.Right, config)
+		if err != nil {
+			return nil, err
+		}
+		return &boolNode{op: op, l: l, r: r}, nil
+	case *ast.UnaryNode:
+		if n.Operator == "not" || n.Operator == "!" {
+			l, err := t.build(tree, n.Node, config)
+			if err != nil {
+				return nil, err
+			}
+			return &boolNode{op: boolNot, l: l}, nil
+		}
+	}
+	return t.leaf(tree, node, config, "")
+}
+
+// leaf 登记一个原子条件：key 为空时取节点的规范文本作为去重键，节点直接编译，不经过文本还原
+func (t *predicateTable) leaf(tree *parser.Tree, node ast.Node, config *conf.Config, key string) (*boolNode, error) {
+	if key == "" {
+		var err error
+		if key, err = nodeText(node); err != nil {
+			return nil, err
+		}
+	}
+	id, err := t.intern(key, func() (*vm.Program, error) {
+		return compiler.Compile(&parser.Tree{Node: node, Source: tree.Source}, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &boolNode{op: boolPred, pred: id}, nil
+}
+
+// nodeText 节点的规范文本。优化产生的常量节点按 JSON 输出，个别类型无法输出时 expr 会 panic，这里转为错误
+func nodeText(node ast.Node) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("原子条件无法输出为文本: %v", r)
+		}
+	}()
+	return node.String(), nil
+}
+
+// intern 返回原子条件的下标，首次出现时编译并加入共享表
+func (t *predicateTable) intern(key string, compile func() (*vm.Program, error)) (int32, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[key]; ok {
+		return id, nil
+	}
+	p, err := compile()
+	if err != nil {
+		return 0, fmt.Errorf("编译原子条件 %s 失败: %w", key, err)
+	}
+	old := *t.progs.Load()
+	next := append(old[:len(old):len(old)], p) // 不写入旧切片的空余容量，读者持有的旧快照不变
+	id := int32(len(old))
+	t.ids[key] = id
+	t.progs.Store(&next)
+	return id, nil
+}
+
+// SharedPredicates 返回共享表中的原子条件数；未启用 WithSharedPredicates 时为 0
+func (re *RuleEngine) SharedPredicates() int {
+	if re.preds == nil {
+		return 0
+	}
+	return len(*re.preds.progs.Load())
+}
+
+// predEval 一次匹配内的原子条件缓存：每个条件最多执行一次
+type predEval struct {
+	progs   []*vm.Program
+	input   map[string]interface{}
+	machine vm.VM
+	state   []uint8         // 0 未执行，1 false，2 true，3 出错或结果不是 bool
+	errs    map[int32]error // 出错条件的原因
+}
+
+const (
+	predFalse = 1
+	predTrue  = 2
+	predErr   = 3
+)
+
+// pred 执行（或取缓存的）原子条件
+func (e *predEval) pred(id int32) (bool, error) {
+	if e.state[id] == 0 {
+		out, err := e.machine.Run(e.progs[id], e.input)
+		switch b, ok := out.(bool); {
+		case err != nil:
+		case !ok:
+			err = fmt.Errorf("条件结果不是 bool: %T(%v)", out, out)
+		case b:
+			e.state[id] = predTrue
+		default:
+			e.state[id] = predFalse
+		}
+		if err != nil {
+			if e.errs == nil {
+				e.errs = make(map[int32]error)
+			}
+			e.state[id], e.errs[id] = predErr, err
+		}
+	}
+	if e.state[id] == predErr {
+		return false, e.errs[id]
+	}
+	return e.state[id] == predTrue, nil
+}
+
+// eval 按 expr 的短路语义组合：and 左侧为 false、or 左侧为 true 时不执行右侧；
+// 参与组合的值不是 bool 时 expr 报错，这里同样作为错误，规则按未命中处理
+func (e *predEval) eval(n *boolNode) (bool, error) {
+	switch n.op {
+	case boolAnd:
+		if ok, err := e.eval(n.l); err != nil || !ok {
+			return false, err
+		}
+		return e.eval(n.r)
+	case boolOr:
+		if ok, err := e.eval(n.l); err != nil || ok {
+			return ok, err
+		}
+		return e.eval(n.r)
+	case boolNot:
+		ok, err := e.eval(n.l)
+		return !ok && err == nil, err
+	}
+	return e.pred(n.pred)
+}
+
+// MatchShared 与 Match 命中相同（含优先级顺序、开关、等值索引与评估顺序随机化），
+// 但每个原子条件对本输入只执行一次，结果在规则间共享；规则重叠越多越省。
+// 未启用 WithSharedPredicates 时等同于 Match
+func (re *RuleEngine) MatchShared(input map[string]interface{}) []string {
+	if re.preds == nil {
+		return re.Match(input)
+	}
+	start := time.Now()
+	rules := re.orderedView().candidates(input)
+	if sh := re.shuffle.Load(); sh != nil {
+		rules = sh.shuffled(rules)
+	}
+	progs := *re.preds.progs.Load() // 须在取规则快照之后
+	e := &predEval{progs: progs, input: input, state: make([]uint8, len(progs))}
+	flags := re.flagSet()
+	var hits []string
+	for _, r := range rules {
+		if !flags.allows(r) {
+			continue
+		}
+		if ok, err := e.eval(r.dag); err != nil {
+			re.noteEvalError(r, err)
+		} else if ok {
+			hits = append(hits, r.ID)
+		}
+	}
+	re.recordMatch(input, hits, start)
+	return hits
+}
+
+// Shared 把 MatchShared 作为 Match 的 RuleEngine 视图，便于两种匹配方式用同一套基准对比
+type Shared struct {
+	*RuleEngine
+}
+
+func (s Shared) Match(input map[string]interface{}) []string {
+	return s.MatchShared(input)
+}
+
+var _ rule_engine.Engine = Shared{}
+
+// BenchmarkMatchShared 同 BenchmarkMatch，但计时 MatchShared；在同一个引擎上分别调用两者即可对比
+func BenchmarkMatchShared(re *RuleEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	return rule_engine.BenchmarkMatch(Shared{re}, inputs, opts...)
+}
@@ -0,0 +1,70 @@
+package rule_expr
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+/* ---------- 评估顺序随机化 ---------- */
+
+// shuffleState 每次 Match 用 (seed, 调用序号) 派生独立的洗牌顺序
+type shuffleState struct {
+	seed  int64
+	calls atomic.Int64
+}
+
+// ShuffleEvaluationOrder 调试选项：此后每次 Match/MatchFirst 都以随机顺序评估规则，
+// 用于在测试环境暴露依赖评估顺序的问题（有状态的自定义函数、记忆化错误等）。
+// 仅影响顺序，命中集合应保持不变；MatchFirst 等短路接口的结果会随顺序合法地变化
+func (re *RuleEngine) ShuffleEvaluationOrder(seed int64) {
+	re.shuffle.Store(&shuffleState{seed: seed})
+}
+
+// StopShuffle 恢复默认评估顺序
+func (re *RuleEngine) StopShuffle() {
+	re.shuffle.Store(nil)
+}
+
+// shuffled 返回本次调用洗牌后的规则副本，rules 本身不变
+func (s *shuffleState) shuffled(rules []*Rule) []*Rule {
+	out := slices.Clone(rules)
+	r := itemRand(s.seed, int(s.calls.Add(1)))
+	r.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// OrderViolation 同一输入在不同评估顺序下命中集合不一致
+type OrderViolation struct {
+	InputIndex int
+	Seed       int64
+	Baseline   []string // 默认顺序下的命中（已排序）
+	Shuffled   []string // 洗牌顺序下的命中（已排序）
+}
+
+// CheckOrderIndependence 对每个种子以洗牌顺序重跑 inputs，返回与默认顺序命中集合不同的情况。
+// 检查结束后恢复默认顺序
+func CheckOrderIndependence(re *RuleEngine, inputs []map[string]interface{}, seeds []int64) []OrderViolation {
+	re.StopShuffle()
+	baseline := make([][]string, len(inputs))
+	for i, in := range inputs {
+		baseline[i] = sortedHits(re.Match(in))
+	}
+	var violations []OrderViolation
+	for _, seed := range seeds {
+		re.ShuffleEvaluationOrder(seed)
+		for i, in := range inputs {
+			got := sortedHits(re.Match(in))
+			if !slices.Equal(got, baseline[i]) {
+				violations = append(violations, OrderViolation{i, seed, baseline[i], got})
+			}
+		}
+	}
+	re.StopShuffle()
+	return violations
+}
+
+func sortedHits(hits []string) []string {
+	out := slices.Clone(hits)
+	slices.Sort(out)
+	return out
+}
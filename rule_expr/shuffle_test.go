@@ -0,0 +1,90 @@
+package rule_expr
+
+import (
+	"slices"
+	"testing"
+)
+
+// alternating 有状态的自定义函数：奇数次调用返回 true。同一次 Match 中先被评估的规则拿到 true，
+// 模拟依赖评估顺序的记忆化错误
+func alternating() func() bool {
+	calls := 0
+	return func() bool {
+		calls++
+		return calls%2 == 1
+	}
+}
+
+func TestCheckOrderIndependenceCatchesStatefulFunction(t *testing.T) {
+	re := NewRuleEngine()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := re.AddRule(id, "claim()"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputs := []map[string]interface{}{{"claim": alternating()}}
+	if got := re.Match(inputs[0]); !slices.Equal(got, []string{"a", "c"}) {
+		t.Fatalf("默认顺序命中 %v，应为 [a c]", got)
+	}
+
+	violations := CheckOrderIndependence(re, inputs, []int64{1, 2, 3, 4, 5, 6, 7, 8})
+	if len(violations) == 0 {
+		t.Fatal("有状态函数导致的顺序依赖未被发现")
+	}
+	for _, v := range violations {
+		if v.InputIndex != 0 || !slices.Equal(v.Baseline, []string{"a", "c"}) || slices.Equal(v.Shuffled, v.Baseline) {
+			t.Fatalf("违规记录不对: %+v", v)
+		}
+	}
+	// 检查结束后恢复默认顺序
+	if got := re.Match(inputs[0]); !slices.Equal(got, []string{"a", "c"}) {
+		t.Fatalf("检查后命中 %v，应恢复默认顺序", got)
+	}
+}
+
+func TestCheckOrderIndependencePureRules(t *testing.T) {
+	re := NewRuleEngine(WithEqualityIndex())
+	if err := InjectRandomRulesSeeded(re, 200, 3); err != nil {
+		t.Fatal(err)
+	}
+	if v := CheckOrderIndependence(re, GenRandomInputsSeeded(50, 3), []int64{1, 2, 3}); len(v) != 0 {
+		t.Fatalf("无状态的规则不应依赖评估顺序: %+v", v[0])
+	}
+}
+
+// TestShuffleEvaluationOrder 同一种子得到相同的顺序序列，每次调用的顺序各不相同
+func TestShuffleEvaluationOrder(t *testing.T) {
+	re := NewRuleEngine()
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, id := range ids {
+		re.AddRule(id, "true")
+	}
+	orders := func(seed int64) [][]string {
+		re.ShuffleEvaluationOrder(seed)
+		defer re.StopShuffle()
+		var out [][]string
+		for i := 0; i < 5; i++ {
+			out = append(out, re.Match(nil))
+		}
+		return out
+	}
+	first, again := orders(42), orders(42)
+	if !slices.EqualFunc(first, again, slices.Equal[[]string]) {
+		t.Fatalf("同一种子的顺序不同:\n%v\n%v", first, again)
+	}
+	distinct := 0
+	for _, o := range first {
+		if !slices.Equal(o, ids) {
+			distinct++
+		}
+		if !slices.Equal(sortedHits(o), ids) {
+			t.Fatalf("洗牌改变了命中集合: %v", o)
+		}
+	}
+	if distinct == 0 {
+		t.Fatal("洗牌后顺序从未改变")
+	}
+	if got := re.Match(nil); !slices.Equal(got, ids) {
+		t.Fatalf("StopShuffle 后应恢复默认顺序: %v", got)
+	}
+}
@@ -0,0 +1,40 @@
+package rule_expr
+
+import "fmt"
+
+/* ---------- 规则持久化 ---------- */
+
+// RuleStore 规则的持久化后端
+//
+// 写入顺序：AddRule 先落盘再更新内存。若两者之间进程崩溃，重启后 LoadFromStore
+// 以存储为准恢复，即崩溃前已落盘但尚未进入内存的规则会被恢复出来。
+// 只改元数据的操作（SetPriority、SetTags、DisableRule 等）同样以 SaveRule 写入整条规则。
+type RuleStore interface {
+	SaveRule(id, exprStr string, meta RuleMeta) error
+	DeleteRule(id string) error
+	LoadRules() (map[string]StoredRule, error)
+	Close() error
+}
+
+// StoredRule 持久化的一条规则
+type StoredRule struct {
+	Expr string
+	Meta RuleMeta
+}
+
+// LoadFromStore 从持久化后端重新编译并恢复全部规则及其元数据（不会回写 store）
+func (re *RuleEngine) LoadFromStore() error {
+	if re.store == nil {
+		return fmt.Errorf("未配置持久化后端")
+	}
+	rules, err := re.store.LoadRules()
+	if err != nil {
+		return fmt.Errorf("读取持久化规则失败: %w", err)
+	}
+	for id, sr := range rules {
+		if err := re.addRule(id, sr.Expr, sr.Meta, false); err != nil {
+			return fmt.Errorf("编译规则 %s 失败: %w", id, err)
+		}
+	}
+	return nil
+}
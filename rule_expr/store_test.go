@@ -0,0 +1,157 @@
+package rule_expr
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// memStore 内存中的 RuleStore，可注入写入失败与缓慢关闭
+type memStore struct {
+	mu        sync.Mutex
+	rules     map[string]StoredRule
+	failSave  error         // 非 nil 时 SaveRule 返回该错误
+	closeGate chan struct{} // 非 nil 时 Close 等到它关闭才返回
+	closed    bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{rules: make(map[string]StoredRule)}
+}
+
+func (s *memStore) SaveRule(id, exprStr string, meta RuleMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failSave != nil {
+		return s.failSave
+	}
+	s.rules[id] = StoredRule{Expr: exprStr, Meta: meta}
+	return nil
+}
+
+func (s *memStore) DeleteRule(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *memStore) LoadRules() (map[string]StoredRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errors.New("store 已关闭")
+	}
+	return maps.Clone(s.rules), nil
+}
+
+func (s *memStore) Close() error {
+	if s.closeGate != nil {
+		<-s.closeGate
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return nil
+}
+
+// TestMetadataWriteThrough 只改元数据的操作写入 store，重启后 LoadFromStore 恢复全部元数据
+func TestMetadataWriteThrough(t *testing.T) {
+	store := newMemStore()
+	re := NewRuleEngine(WithPersistence(store))
+	allow := true
+	if err := re.addRule("r1", "user.country == 'CN'", RuleMeta{Flag: "beta", Description: "国内用户", AllowUndefined: &allow}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.AddRule("r2", "is_vip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.SetPriority("r1", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.SetTags("r1", "risk", "geo"); err != nil {
+		t.Fatal(err)
+	}
+	if !re.DisableRule("r2") {
+		t.Fatal("DisableRule 失败")
+	}
+
+	restarted := NewRuleEngine(WithPersistence(store))
+	if err := restarted.LoadFromStore(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restarted.ListRules(), re.ListRules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("重启后规则不同:\n%+v\n%+v", got, want)
+	}
+	stored := store.rules["r1"].Meta
+	if stored.AllowUndefined == nil || !*stored.AllowUndefined || stored.Priority != 5 || !slices.Equal(stored.Tags, []string{"geo", "risk"}) {
+		t.Fatalf("store 中的元数据不对: %+v", stored)
+	}
+	if !store.rules["r2"].Meta.Disabled {
+		t.Fatal("DisableRule 未写入 store")
+	}
+	if !re.EnableRule("r2") || store.rules["r2"].Meta.Disabled {
+		t.Fatal("EnableRule 未写入 store")
+	}
+}
+
+// TestMetadataUpdateFailureKeepsMemory store 写入失败时内存中的规则保持不变
+func TestMetadataUpdateFailureKeepsMemory(t *testing.T) {
+	store := newMemStore()
+	re := NewRuleEngine(WithPersistence(store))
+	if err := re.AddRuleWithPriority("r1", "is_vip", 1); err != nil {
+		t.Fatal(err)
+	}
+	gen := re.Generation()
+	store.failSave = errors.New("磁盘已满")
+	if err := re.SetPriority("r1", 9); err == nil {
+		t.Fatal("store 写入失败时 SetPriority 应报错")
+	}
+	if err := re.SetTags("r1", "x"); err == nil {
+		t.Fatal("store 写入失败时 SetTags 应报错")
+	}
+	if re.DisableRule("r1") {
+		t.Fatal("store 写入失败时 DisableRule 应返回 false")
+	}
+	info := re.ListRules()[0]
+	if info.Priority != 1 || len(info.Tags) != 0 || !info.Enabled || re.Generation() != gen {
+		t.Fatalf("写入失败后内存被修改: %+v", info)
+	}
+	if err := re.SetPriority("missing", 1); err == nil {
+		t.Fatal("不存在的规则应报错")
+	}
+}
+
+// TestSetPriorityReordersWithoutRecompile 修改优先级后评估顺序随之改变，编译产物沿用
+func TestSetPriorityReordersWithoutRecompile(t *testing.T) {
+	re := NewRuleEngine()
+	re.AddRule("a", "is_vip")
+	re.AddRule("b", "is_vip")
+	in := map[string]interface{}{"is_vip": true}
+	if id, _ := re.MatchFirstByPriority(in); id != "a" {
+		t.Fatalf("同优先级应取 a，实际 %s", id)
+	}
+	before, _ := re.lookup("b")
+	if err := re.SetPriority("b", 1); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := re.MatchFirstByPriority(in); id != "b" {
+		t.Fatalf("提高优先级后应取 b，实际 %s", id)
+	}
+	after, _ := re.lookup("b")
+	if after.Program != before.Program || before.Priority != 0 {
+		t.Fatal("SetPriority 不应重新编译，也不应修改旧快照中的规则")
+	}
+	if err := re.SetTags("a", "ops"); err != nil {
+		t.Fatal(err)
+	}
+	if got := re.MatchByTag("ops", in); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("MatchByTag = %v", got)
+	}
+	if err := re.SetTags("a", "flag=x"); err == nil {
+		t.Fatal("非法标签应报错")
+	}
+}
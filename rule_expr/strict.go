@@ -0,0 +1,63 @@
+package rule_expr
+
+import (
+	"strings"
+
+	"goexprtester/rule_engine"
+)
+
+/* ---------- 严格匹配 ---------- */
+
+// WithLenientInputs 放宽 ValidateInput / MatchStrict 对缺失因子的要求：MatchStrict 把缺失的 Bool 因子
+// 按 false 补齐，其余缺失的因子保持缺失（引用它们的比较不命中），都不再算违规；取值类型不符仍然拒绝
+func WithLenientInputs() EngineOption {
+	return func(re *RuleEngine) {
+		re.lenient = true
+	}
+}
+
+// MatchStrict 先用 ValidateInput 检查 input，不通过时不匹配，返回 *InputError；通过时与 Match 相同。
+// 启用 WithLenientInputs 时先在副本上补齐缺失的 Bool 因子，调用方的 input 不会被修改
+func (re *RuleEngine) MatchStrict(input map[string]interface{}) ([]string, error) {
+	if re.lenient {
+		input = re.fillMissingBools(input)
+	}
+	if err := re.ValidateInput(input); err != nil {
+		return nil, err
+	}
+	return re.Match(input), nil
+}
+
+// fillMissingBools 返回补齐了缺失 Bool 因子（取 false）的输入；无需补齐时原样返回，否则返回副本
+func (re *RuleEngine) fillMissingBools(input map[string]interface{}) map[string]interface{} {
+	out, copied := input, false
+	for _, f := range re.pool.Factors {
+		if f.Kind != Bool || !absentPath(input, f.Name) {
+			continue
+		}
+		if !copied {
+			out, copied = rule_engine.CloneRow(input), true
+		}
+		rule_engine.SetPath(out, f.Name, false)
+	}
+	return out
+}
+
+// absentPath 点路径上的某一层不存在（或为 nil）时返回 true；中间层存在但不是 map（如结构体）时
+// 返回 false，以免补齐时覆盖调用方的数据
+func absentPath(row map[string]interface{}, name string) bool {
+	for {
+		head, rest, ok := strings.Cut(name, ".")
+		if !ok {
+			return row[name] == nil
+		}
+		next := row[head]
+		if next == nil {
+			return true
+		}
+		if row, ok = next.(map[string]interface{}); !ok {
+			return false
+		}
+		name = rest
+	}
+}
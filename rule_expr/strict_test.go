@@ -0,0 +1,118 @@
+package rule_expr
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"goexprtester/rule_engine"
+)
+
+func strictEngine(t *testing.T, opts ...EngineOption) *RuleEngine {
+	t.Helper()
+	re := NewRuleEngine(opts...)
+	for id, expr := range map[string]string{
+		"prod":    `env == "prod"`,
+		"user":    "user_id > 100",
+		"vip":     "is_vip",
+		"country": `user.country == "CN"`,
+	} {
+		if err := re.AddRule(id, expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return re
+}
+
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+	var ie *InputError
+	if !errors.As(err, &ie) {
+		t.Fatalf("err = %v，应为 *InputError", err)
+	}
+	var out []string
+	for _, v := range ie.Violations {
+		out = append(out, v.String())
+	}
+	return out
+}
+
+// TestMatchStrictTypeMismatch 期望字符串时给了整数、期望整数时给了字符串，两处违规一次报告
+func TestMatchStrictTypeMismatch(t *testing.T) {
+	re := strictEngine(t)
+	input := map[string]interface{}{"env": 5, "user_id": "12345", "is_vip": true, "user": map[string]interface{}{"country": "CN"}}
+	hits, err := re.MatchStrict(input)
+	if hits != nil {
+		t.Fatalf("输入不合法时不应匹配，实际 %v", hits)
+	}
+	want := []string{"/env: 应为 string，实际为 int", "/user_id: 应为 integer，实际为 string"}
+	if got := violations(t, err); !slices.Equal(got, want) {
+		t.Fatalf("违规项 = %q，应为 %q", got, want)
+	}
+}
+
+func TestMatchStrictReportsEveryMissingFactor(t *testing.T) {
+	re := strictEngine(t)
+	_, err := re.MatchStrict(map[string]interface{}{"env": "prod"})
+	want := []string{"/is_vip: 缺少必填字段", "/user/country: 缺少必填字段", "/user_id: 缺少必填字段"}
+	if got := violations(t, err); !slices.Equal(got, want) {
+		t.Fatalf("违规项 = %q，应为 %q", got, want)
+	}
+
+	valid := map[string]interface{}{"env": "prod", "user_id": 12345, "is_vip": false, "user": map[string]interface{}{"country": "US"}}
+	hits, err := re.MatchStrict(valid)
+	if err != nil || !slices.Equal(hits, re.Match(valid)) {
+		t.Fatalf("合法输入 MatchStrict = %v, %v", hits, err)
+	}
+}
+
+// TestLenientFillsMissingBools 宽松模式把缺失（含值为 nil）的 Bool 因子补为 false，其余因子保持缺失
+func TestLenientFillsMissingBools(t *testing.T) {
+	re := strictEngine(t, WithLenientInputs())
+	for name, input := range map[string]map[string]interface{}{
+		"缺少键":    {"env": "prod"},
+		"值为 nil": {"env": "prod", "is_vip": nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			before := rule_engine.CloneRow(input)
+			hits, err := re.MatchStrict(input)
+			if err != nil {
+				t.Fatalf("宽松模式下缺失因子不应违规: %v", err)
+			}
+			if !slices.Equal(hits, []string{"prod"}) {
+				t.Fatalf("命中 = %v", hits)
+			}
+			if !reflect.DeepEqual(input, before) {
+				t.Fatal("补齐不应修改调用方的输入")
+			}
+		})
+	}
+	filled := re.fillMissingBools(map[string]interface{}{"is_vip": nil})
+	if filled["is_vip"] != false {
+		t.Fatalf("值为 nil 的 Bool 因子应补为 false: %v", filled)
+	}
+	if _, err := re.MatchStrict(map[string]interface{}{"env": 5}); err == nil {
+		t.Fatal("宽松模式仍应拒绝类型不符的输入")
+	}
+}
+
+func TestAbsentPath(t *testing.T) {
+	row := map[string]interface{}{
+		"a":    nil,
+		"b":    false,
+		"user": map[string]interface{}{"flag": nil, "ok": true},
+		"obj":  struct{ X bool }{},
+	}
+	cases := map[string]bool{
+		"a": true, "b": false, "missing": true,
+		"user.flag": true, "user.ok": false, "user.none": true,
+		"nothing.x": true, "a.x": true,
+		"obj.X": false, // 中间层不是 map 时不补齐
+	}
+	for name, want := range cases {
+		if got := absentPath(row, name); got != want {
+			t.Errorf("absentPath(%q) = %v，应为 %v", name, got, want)
+		}
+	}
+}
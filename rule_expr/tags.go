@@ -0,0 +1,66 @@
+package rule_expr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+/* ---------- 规则标签 ---------- */
+
+// AddRuleWithTags 加入带标签的规则，供 MatchByTag 按业务域只评估部分规则。
+// 标签不能为空、不能含逗号或换行，也不能以 "flag="、"undefined=" 或 "desc=" 开头（文本格式中的标记，见 TextRule）
+func (re *RuleEngine) AddRuleWithTags(id, exprStr string, tags ...string) error {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return fmt.Errorf("规则 %s: %w", id, err)
+	}
+	return re.addRule(id, exprStr, RuleMeta{Tags: normalized}, true)
+}
+
+// SetTags 替换已有规则的全部标签，不重新编译；配置了 store 时写入 store
+func (re *RuleEngine) SetTags(id string, tags ...string) error {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return fmt.Errorf("规则 %s: %w", id, err)
+	}
+	return re.updateMeta(id, func(meta *RuleMeta) { meta.Tags = normalized })
+}
+
+// normalizeTags 校验标签并排序去重
+func normalizeTags(tags []string) ([]string, error) {
+	for _, tag := range tags {
+		switch {
+		case tag == "":
+			return nil, fmt.Errorf("标签不能为空")
+		case strings.ContainsAny(tag, ",\n"):
+			return nil, fmt.Errorf("标签 %q 不能含逗号或换行", tag)
+		}
+		for _, prefix := range reservedTagPrefixes {
+			if strings.HasPrefix(tag, prefix) {
+				return nil, fmt.Errorf("标签 %q 与文本格式的标记前缀 %q 冲突", tag, prefix)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	out := slices.Clone(tags)
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// MatchByTag 只评估带 tag 的规则，按评估顺序返回命中 ID。
+// 规则集变更后的第一次匹配会重建标签索引，此后按标签直接取到规则列表，不扫描其他规则
+func (re *RuleEngine) MatchByTag(tag string, input map[string]interface{}) []string {
+	start := time.Now()
+	rules := re.orderedView().byTag[tag]
+	if sh := re.shuffle.Load(); sh != nil {
+		rules = sh.shuffled(rules)
+	}
+	flags := re.flagSet()
+	hits, _, _ := re.evalRules(rules, &flags, input, nil, nil)
+	re.recordMatch(input, hits, start)
+	return hits
+}
@@ -0,0 +1,293 @@
+package rule_expr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/* ---------- 紧凑文本格式 ---------- */
+
+// 每行一条规则：
+//
+//	id | priority | tags | canonical-expression
+//
+// 字段内的 '\'、'|' 与换行分别转义为 "\\"、"\|"、"\n"（表达式字段中的 '|' 读取时可不转义）；
+// tags 以逗号分隔，其中的逗号转义为 "\,"；空行与 '#' 开头的行忽略。
+// 导出按 ID 排序、表达式规范化，因此改一条规则只产生一行 diff。
+// tags 即规则标签（见 AddRuleWithTags），表达式以外的其他属性以带前缀的标记写在其中：
+//
+//	flag=名称            特性开关，见 AddRuleWithFlag
+//	undefined=allow      容忍缺失因子；undefined=strict 为严格模式，见 AddRuleWithUndefined
+//	desc=说明            规则说明
+
+// TextRule 文本格式中的一行
+type TextRule struct {
+	ID       string
+	Priority int
+	Tags     []string // 含 flag= 等标记
+	Expr     string
+}
+
+// ExportText 按 ID 排序写出全部规则。启用状态只在内存中，不写出。
+// 与 SaveRulesToJSON 相同，容忍缺失因子的规则写出 undefined=allow，引擎默认容忍时严格的规则写出 undefined=strict
+func (re *RuleEngine) ExportText(w io.Writer) error {
+	var rules []TextRule
+	for _, r := range re.snapshot() {
+		tr := TextRule{ID: r.ID, Priority: r.Priority, Tags: slices.Clone(r.Tags), Expr: r.ExprStr}
+		if r.Flag != "" {
+			tr.Tags = append(tr.Tags, flagTagPrefix+r.Flag)
+		}
+		if r.AllowUndefined {
+			tr.Tags = append(tr.Tags, undefinedTagPrefix+"allow")
+		} else if re.allowUndefined {
+			tr.Tags = append(tr.Tags, undefinedTagPrefix+"strict")
+		}
+		if r.Description != "" {
+			tr.Tags = append(tr.Tags, descTagPrefix+r.Description)
+		}
+		rules = append(rules, tr)
+	}
+	return WriteText(w, rules)
+}
+
+// ImportText 读取文本格式并逐条 AddRule；遇到第一个错误即返回并带上行号
+func (re *RuleEngine) ImportText(r io.Reader) error {
+	rules, err := ReadText(r)
+	if err != nil {
+		return err
+	}
+	for _, tr := range rules {
+		meta, err := tr.meta()
+		if err != nil {
+			return fmt.Errorf("规则 %s: %w", tr.ID, err)
+		}
+		if err := re.addRule(tr.ID, tr.Expr, meta, true); err != nil {
+			return fmt.Errorf("编译规则 %s 失败: %w", tr.ID, err)
+		}
+	}
+	return nil
+}
+
+// meta 从优先级与 tags 中拆出各个标记与规则标签
+func (tr TextRule) meta() (RuleMeta, error) {
+	meta := RuleMeta{Priority: tr.Priority}
+	var tags []string
+	for _, tag := range tr.Tags {
+		if flag, ok := strings.CutPrefix(tag, flagTagPrefix); ok {
+			meta.Flag = flag
+		} else if mode, ok := strings.CutPrefix(tag, undefinedTagPrefix); ok {
+			var allow bool
+			switch mode {
+			case "allow":
+				allow = true
+			case "strict":
+			default:
+				return meta, fmt.Errorf("%s 只能取 allow 或 strict，实际 %q", undefinedTagPrefix, mode)
+			}
+			meta.AllowUndefined = &allow
+		} else if desc, ok := strings.CutPrefix(tag, descTagPrefix); ok {
+			meta.Description = desc
+		} else {
+			tags = append(tags, tag)
+		}
+	}
+	var err error
+	meta.Tags, err = normalizeTags(tags)
+	return meta, err
+}
+
+// tags 中表示其他属性的标记前缀，普通标签不能以它们开头
+const (
+	flagTagPrefix      = "flag="
+	undefinedTagPrefix = "undefined="
+	descTagPrefix      = "desc="
+)
+
+var reservedTagPrefixes = []string{flagTagPrefix, undefinedTagPrefix, descTagPrefix}
+
+// WriteText 把规则规范化（表达式、标签排序）并按 ID 排序写出
+func WriteText(w io.Writer, rules []TextRule) error {
+	sorted := make([]TextRule, len(rules))
+	copy(sorted, rules)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	bw := bufio.NewWriter(w)
+	for _, r := range sorted {
+		canonical, err := canonicalExpr(r.Expr)
+		if err != nil {
+			return fmt.Errorf("规则 %s: %w", r.ID, err)
+		}
+		tags := make([]string, len(r.Tags))
+		for i, t := range r.Tags {
+			tags[i] = escapeTag(t)
+		}
+		sort.Strings(tags)
+		fmt.Fprintf(bw, "%s | %d | %s | %s\n", escapeField(r.ID), r.Priority, strings.Join(tags, ","), escapeField(canonical))
+	}
+	return bw.Flush()
+}
+
+// ReadText 解析文本格式；ID 重复或字段数不对时报错并带行号
+func ReadText(r io.Reader) ([]TextRule, error) {
+	var rules []TextRule
+	seen := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields, err := splitFields(text)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("第 %d 行: 需要 4 个字段，实际 %d 个", line, len(fields))
+		}
+		var tr TextRule
+		tr.ID = fields[0]
+		if _, err := fmt.Sscanf(fields[1], "%d", &tr.Priority); err != nil {
+			return nil, fmt.Errorf("第 %d 行: 优先级 %q 不是整数", line, fields[1])
+		}
+		tags, err := splitTags(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		for _, tag := range tags {
+			// 手写文件中逗号两侧的空白与多余的逗号不影响结果，格式化才能幂等
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tr.Tags = append(tr.Tags, tag)
+			}
+		}
+		tr.Expr = fields[3]
+		if tr.ID == "" {
+			return nil, fmt.Errorf("第 %d 行: 缺少 ID", line)
+		}
+		if prev, ok := seen[tr.ID]; ok {
+			return nil, fmt.Errorf("第 %d 行: ID %s 与第 %d 行重复", line, tr.ID, prev)
+		}
+		seen[tr.ID] = line
+		rules = append(rules, tr)
+	}
+	return rules, sc.Err()
+}
+
+// FormatText 把 r 中的规则改写为规范形式写到 w，对已规范的输入是幂等的
+func FormatText(r io.Reader, w io.Writer) error {
+	rules, err := ReadText(r)
+	if err != nil {
+		return err
+	}
+	return WriteText(w, rules)
+}
+
+func escapeField(s string) string {
+	return escapeChars(s, "\\|\n")
+}
+
+// escapeTag 在 escapeField 之外还转义逗号，标签（如 desc= 的说明）中可以含逗号
+func escapeTag(s string) string {
+	return escapeChars(s, "\\|\n,")
+}
+
+func escapeChars(s, chars string) string {
+	if !strings.ContainsAny(s, chars) {
+		return s
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if !strings.ContainsRune(chars, c) {
+			b.WriteRune(c)
+		} else if c == '\n' {
+			b.WriteString(`\n`)
+		} else {
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// splitTags 按未转义的逗号切分 tags 字段并反转义
+func splitTags(field string) ([]string, error) {
+	var tags []string
+	var cur strings.Builder
+	escaped := false
+	for _, c := range field {
+		if escaped {
+			if err := unescapeRune(&cur, c, ','); err != nil {
+				return nil, err
+			}
+			escaped = false
+			continue
+		}
+		switch c {
+		case '\\':
+			escaped = true
+		case ',':
+			tags = append(tags, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if escaped {
+		return nil, errors.New("tags 末尾存在未完成的转义")
+	}
+	return append(tags, cur.String()), nil
+}
+
+// unescapeRune 写出转义序列 '\' c 表示的字符；extra 为该字段额外允许转义的字符
+func unescapeRune(b *strings.Builder, c, extra rune) error {
+	switch c {
+	case '\\', '|', extra:
+		b.WriteRune(c)
+	case 'n':
+		b.WriteRune('\n')
+	default:
+		return fmt.Errorf("未知转义 \\%c", c)
+	}
+	return nil
+}
+
+// splitFields 按未转义的 '|' 切分出前三个字段，其余整体作为表达式；反转义并去掉字段两端空白。
+// 因此手写文件中表达式里的 "||" 无需转义。tags 字段保留转义，由 splitTags 按逗号切分时反转义
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	escaped := false
+	for _, c := range line {
+		if escaped {
+			if len(fields) == 2 {
+				cur.WriteRune('\\')
+				cur.WriteRune(c)
+			} else if err := unescapeRune(&cur, c, '|'); err != nil {
+				return nil, err
+			}
+			escaped = false
+			continue
+		}
+		switch c {
+		case '\\':
+			escaped = true
+		case '|':
+			if len(fields) == 3 {
+				cur.WriteRune(c)
+				continue
+			}
+			fields = append(fields, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if escaped {
+		return nil, errors.New("行尾存在未完成的转义")
+	}
+	return append(fields, strings.TrimSpace(cur.String())), nil
+}
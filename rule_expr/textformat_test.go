@@ -0,0 +1,114 @@
+package rule_expr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const canonicalText = `a | 0 |  | is_vip
+b\|c | 5 | flag=beta,risk | env == "prod" or amount > 100
+multi\nline | -1 | x | note == "line1\\nline2" and path == "a\\\\b\|c"
+`
+
+// TestTextRoundTrip 文本 → 引擎 → 文本逐字节相同
+func TestTextRoundTrip(t *testing.T) {
+	re := NewRuleEngine()
+	if err := re.ImportText(strings.NewReader(canonicalText)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := re.ExportText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != canonicalText {
+		t.Fatalf("往返结果不同:\n--- 原文\n%s--- 导出\n%s", canonicalText, out.String())
+	}
+}
+
+func TestTextEscaping(t *testing.T) {
+	// ID 中的 '|' 与换行、表达式字符串中的 '|' 与转义序列，以及跨行书写的表达式
+	rules := []TextRule{{ID: "p|q\nr", Priority: 1, Tags: []string{"t"}, Expr: "note == \"a|b\"\n  && memo == \"x\\ny\""}}
+	var buf bytes.Buffer
+	if err := WriteText(&buf, rules); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Fatalf("表达式中的换行须转义，一条规则应只占一行，实际 %d 行:\n%s", n, buf.String())
+	}
+	got, err := ReadText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "p|q\nr" || got[0].Expr != `note == "a|b" and memo == "x\ny"` {
+		t.Fatalf("转义往返失败: %+v", got)
+	}
+
+	// 手写文件中表达式里的 "||" 无需转义
+	got, err = ReadText(strings.NewReader("r | 0 | | a || b\n"))
+	if err != nil || len(got) != 1 || got[0].Expr != "a || b" {
+		t.Fatalf("未转义的表达式 '|' 解析失败: %+v, %v", got, err)
+	}
+	if _, err := ReadText(strings.NewReader(`r | 0 | | a \q`)); err == nil {
+		t.Fatal("未知转义应报错")
+	}
+}
+
+// TestFormatTextIdempotent 格式化手写文件得到规范形式，再格式化一次不变
+func TestFormatTextIdempotent(t *testing.T) {
+	messy := "# 手写\n\nz|1|risk , |  env=='prod'  and amount>100\n  a | 0 |  | is_vip  \n"
+	var once, twice bytes.Buffer
+	if err := FormatText(strings.NewReader(messy), &once); err != nil {
+		t.Fatal(err)
+	}
+	if err := FormatText(bytes.NewReader(once.Bytes()), &twice); err != nil {
+		t.Fatal(err)
+	}
+	if once.String() != twice.String() {
+		t.Fatalf("格式化不幂等:\n%s---\n%s", once.String(), twice.String())
+	}
+	if !strings.HasPrefix(once.String(), "a | 0 |  | is_vip\n") {
+		t.Fatalf("应按 ID 排序:\n%s", once.String())
+	}
+}
+
+// TestTextOneRuleOneLineDiff 改一条规则只改动对应的一行
+func TestTextOneRuleOneLineDiff(t *testing.T) {
+	re := NewRuleEngine()
+	if err := re.ImportText(strings.NewReader(canonicalText)); err != nil {
+		t.Fatal(err)
+	}
+	var before, after bytes.Buffer
+	re.ExportText(&before)
+	if err := re.AddRuleWithPriority("a", "is_vip", 7); err != nil {
+		t.Fatal(err)
+	}
+	re.ExportText(&after)
+	b, a := strings.Split(before.String(), "\n"), strings.Split(after.String(), "\n")
+	if len(a) != len(b) {
+		t.Fatalf("行数变化: %d → %d", len(b), len(a))
+	}
+	changed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			changed++
+		}
+	}
+	if changed != 1 {
+		t.Fatalf("改动了 %d 行，应为 1 行", changed)
+	}
+}
+
+func TestReadTextErrors(t *testing.T) {
+	cases := map[string]string{
+		"字段数":   "a | 0 | x\n",
+		"优先级":   "a | high | | x\n",
+		"ID 重复": "a | 0 | | x\na | 1 | | y\n",
+		"缺少 ID": " | 0 | | x\n",
+	}
+	for name, text := range cases {
+		if _, err := ReadText(strings.NewReader(text)); err == nil || !strings.Contains(err.Error(), "行") {
+			t.Errorf("%s: err = %v，应报错并带行号", name, err)
+		}
+	}
+}
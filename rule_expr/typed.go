@@ -0,0 +1,290 @@
+package rule_expr
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 类型化环境 ---------- */
+
+// TypedEnv 由因子列表生成的结构体类型：每个因子一个字段（Bool/String/Int/Float/Time/List 分别对应
+// bool/string/int/float64/time.Time/[]string），字段以 expr 标签对应因子名，嵌套因子按点路径生成嵌套结构体。
+// 以它编译的规则在编译期就确定每个因子的类型，执行时按字段下标取值，不经过 map 查找与 interface 拆箱
+type TypedEnv struct {
+	typ    reflect.Type
+	fields []typedField
+}
+
+// typedField 一个因子在结构体中的位置
+type typedField struct {
+	name  string // 因子名（点路径）
+	kind  Kind
+	index []int // 字段下标路径，供 FieldByIndex 使用
+}
+
+// typedNode 构造结构体时的路径树；叶子对应因子，非叶子对应嵌套结构体
+type typedNode struct {
+	names    []string // 子节点按出现顺序
+	children map[string]*typedNode
+	factor   *rule_engine.Factor
+}
+
+// NewTypedEnv 按 factors 生成结构体类型，factors 为 nil 时使用 rule_engine.Factors
+func NewTypedEnv(factors []rule_engine.Factor) (*TypedEnv, error) {
+	if factors == nil {
+		factors = rule_engine.Factors
+	}
+	root := &typedNode{children: make(map[string]*typedNode)}
+	for i := range factors {
+		f := &factors[i]
+		n := root
+		for _, seg := range strings.Split(f.Name, ".") {
+			if seg == "" {
+				return nil, fmt.Errorf("因子 %s 的路径含空段", f.Name)
+			}
+			if n.factor != nil {
+				return nil, fmt.Errorf("因子 %s 是嵌套因子 %s 的上层对象，不能同时作为取值", n.factor.Name, f.Name)
+			}
+			next := n.children[seg]
+			if next == nil {
+				next = &typedNode{children: make(map[string]*typedNode)}
+				n.children[seg] = next
+				n.names = append(n.names, seg)
+			}
+			n = next
+		}
+		if n.factor != nil {
+			return nil, fmt.Errorf("因子 %s 重复", f.Name)
+		}
+		if len(n.children) > 0 {
+			return nil, fmt.Errorf("因子 %s 是其他嵌套因子的上层对象，不能同时作为取值", f.Name)
+		}
+		n.factor = f
+	}
+	env := &TypedEnv{}
+	typ, err := env.structOf(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	env.typ = typ
+	return env, nil
+}
+
+// structOf 生成 n 对应的结构体类型，并按 prefix 记录各因子的字段下标路径
+func (env *TypedEnv) structOf(n *typedNode, prefix []int) (reflect.Type, error) {
+	fields := make([]reflect.StructField, len(n.names))
+	for i, seg := range n.names {
+		child := n.children[seg]
+		index := append(prefix[:len(prefix):len(prefix)], i)
+		var t reflect.Type
+		if f := child.factor; f != nil {
+			if t = typedKinds[f.Kind]; t == nil {
+				return nil, fmt.Errorf("因子 %s 的类型 %s 不受支持", f.Name, f.Kind)
+			}
+			env.fields = append(env.fields, typedField{name: f.Name, kind: f.Kind, index: index})
+		} else {
+			var err error
+			if t, err = env.structOf(child, index); err != nil {
+				return nil, err
+			}
+		}
+		// 因子名不一定是合法的导出标识符，字段名按下标生成，expr 通过标签按因子名访问
+		fields[i] = reflect.StructField{Name: "F" + strconv.Itoa(i), Type: t, Tag: reflect.StructTag(`expr:"` + seg + `"`)}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+// typedKinds 因子类型对应的字段类型
+var typedKinds = map[Kind]reflect.Type{
+	Bool:   reflect.TypeOf(false),
+	String: reflect.TypeOf(""),
+	Int:    reflect.TypeOf(0),
+	Float:  reflect.TypeOf(0.0),
+	Time:   reflect.TypeOf(time.Time{}),
+	List:   reflect.TypeOf([]string(nil)),
+}
+
+// Type 返回生成的结构体类型
+func (env *TypedEnv) Type() reflect.Type {
+	return env.typ
+}
+
+// zero 返回零值结构体，用作 expr.Env 的类型样本
+func (env *TypedEnv) zero() interface{} {
+	return reflect.New(env.typ).Elem().Interface()
+}
+
+// Convert 把 map 输入（如 GenRandomInputs 生成的数据）转换为结构体值，供 TypedEngine.MatchEnv 使用
+// （expr 对结构体值按字段下标直接取值，传指针则退回按名称反射查找，反而比 map 慢）。
+// 缺失或为 nil 的因子取字段零值（map 路径下为 nil，二者对 == 等比较的结果可能不同）；
+// Int / Float 因子接受任意数值类型并按字段类型转换，List 因子接受元素全为字符串的 []interface{}；
+// 其余类型不符时返回错误
+func (env *TypedEnv) Convert(input map[string]interface{}) (interface{}, error) {
+	ptr := reflect.New(env.typ)
+	s := ptr.Elem()
+	for _, f := range env.fields {
+		v, ok := rule_engine.LookupPath(input, f.name)
+		if !ok || v == nil {
+			continue
+		}
+		if err := setTyped(s.FieldByIndex(f.index), v); err != nil {
+			return nil, fmt.Errorf("因子 %s (%s) 的取值 %v (%T) 无法转换: %w", f.name, f.kind, v, v, err)
+		}
+	}
+	return s.Interface(), nil
+}
+
+// setTyped 把 v 写入字段 dst，必要时做数值与列表转换
+func setTyped(dst reflect.Value, v interface{}) error {
+	src := reflect.ValueOf(v)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Int, reflect.Float64:
+		if isNumeric(src.Kind()) {
+			dst.Set(src.Convert(dst.Type()))
+			return nil
+		}
+	case reflect.Slice:
+		if items, ok := v.([]interface{}); ok {
+			out := make([]string, len(items))
+			for i, item := range items {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("第 %d 个元素不是字符串", i+1)
+				}
+				out[i] = s
+			}
+			dst.Set(reflect.ValueOf(out))
+			return nil
+		}
+	}
+	return fmt.Errorf("需要 %s", dst.Type())
+}
+
+func isNumeric(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+/* ---------- TypedEngine ---------- */
+
+// TypedEngine 以 TypedEnv 编译规则的 expr 引擎：规则与因子类型不符（如字符串因子与整数比较）
+// 或引用未声明的因子时 AddRule 即报错。MatchEnv 直接接受 TypedEnv.Convert 的结果，
+// Match 则先转换 map 输入，便于和 map 路径的 RuleEngine 在同一套基准中对比
+type TypedEngine struct {
+	env   *TypedEnv
+	rules sync.Map   // id -> *typedRule
+	mu    sync.Mutex // 串行化规则变更
+	count int        // 规则数，由 mu 保护
+}
+
+type typedRule struct {
+	id      string
+	program *vm.Program
+}
+
+var _ rule_engine.Engine = (*TypedEngine)(nil)
+
+// NewTypedEngine 以 factors 生成结构体环境，factors 为 nil 时使用 rule_engine.Factors
+func NewTypedEngine(factors []rule_engine.Factor) (*TypedEngine, error) {
+	env, err := NewTypedEnv(factors)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedEngine{env: env}, nil
+}
+
+// Env 返回引擎使用的结构体环境
+func (te *TypedEngine) Env() *TypedEnv {
+	return te.env
+}
+
+// AddRule 按因子类型编译并加入/替换一条规则
+func (te *TypedEngine) AddRule(id, exprStr string) error {
+	p, err := expr.Compile(exprStr, expr.Env(te.env.zero()), expr.AsBool())
+	if err != nil {
+		return fmt.Errorf("规则 %s 未通过因子类型检查: %w", id, err)
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if _, loaded := te.rules.Swap(id, &typedRule{id: id, program: p}); !loaded {
+		te.count++
+	}
+	return nil
+}
+
+// RemoveRule 删除规则，返回该 ID 是否存在；可与匹配并发
+func (te *TypedEngine) RemoveRule(id string) bool {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	_, ok := te.rules.LoadAndDelete(id)
+	if ok {
+		te.count--
+	}
+	return ok
+}
+
+// RuleCount 返回当前规则数
+func (te *TypedEngine) RuleCount() int {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	return te.count
+}
+
+// Match 转换 input 后匹配，转换耗时计入调用；取值类型不符、无法转换时整条输入不命中
+func (te *TypedEngine) Match(input map[string]interface{}) []string {
+	env, err := te.env.Convert(input)
+	if err != nil {
+		return nil
+	}
+	return te.MatchEnv(env)
+}
+
+// MatchEnv 对 TypedEnv.Convert 的结果执行全部规则并返回命中 ID；执行出错的规则按未命中处理
+func (te *TypedEngine) MatchEnv(env interface{}) []string {
+	var machine vm.VM
+	var hits []string
+	te.rules.Range(func(_, value any) bool {
+		r := value.(*typedRule)
+		if out, err := machine.Run(r.program, env); err == nil && out == true {
+			hits = append(hits, r.id)
+		}
+		return true
+	})
+	return hits
+}
+
+// BenchmarkMatchTyped 先把 inputs 全部转换为结构体，再计时 MatchEnv，与 BenchmarkMatch 计时 Match 的方式相同，
+// 得到不含转换开销的类型化路径耗时。计时框架按 map 传递输入，这里为每条输入传一个只含下标的小 map，
+// 每次调用多出的一次查找相对整批规则的执行可以忽略
+func BenchmarkMatchTyped(te *TypedEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) (time.Duration, error) {
+	envs := make([]interface{}, len(inputs))
+	slots := make([]map[string]interface{}, len(inputs))
+	for i, in := range inputs {
+		env, err := te.env.Convert(in)
+		if err != nil {
+			return 0, fmt.Errorf("转换第 %d 条输入失败: %w", i+1, err)
+		}
+		envs[i], slots[i] = env, map[string]interface{}{"": i}
+	}
+	var o bench.RepeatOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	match := func(slot map[string]interface{}) []string {
+		return te.MatchEnv(envs[slot[""].(int)])
+	}
+	return bench.MeasureRepeated(match, slots, o).Mean, nil
+}
@@ -0,0 +1,155 @@
+package rule_expr
+
+import (
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/checker"
+	"github.com/expr-lang/expr/compiler"
+	"github.com/expr-lang/expr/conf"
+	"github.com/expr-lang/expr/optimizer"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 容忍缺失因子 ---------- */
+
+// 默认情况下输入缺少规则引用的因子时，规则在执行期出错（nil 参与 and / not、大小比较、len，
+// 或从 nil 上取嵌套字段），按未命中处理并计入执行错误。容忍模式在编译时改写语法树，让缺失的因子取 nil / false：
+//   - 嵌套字段一律按可选链访问（user.country 等同 user?.country），上层缺失时取 nil
+//   - 作为布尔值使用的变量（规则本身、and / or / not 的操作数、三元式的条件）缺失时取 false
+//   - 变量直接参与的 < > <= >= contains startsWith endsWith matches 在变量缺失时为 false
+//   - len(变量) 在变量缺失时为 0
+// == / != / in 本来就按 nil 比较，不做改写；变量先参与算术运算再比较时仍会出错
+
+// WithAllowUndefined 让规则默认以容忍模式编译（并启用 expr.AllowUndefinedVariables），
+// 单条规则可用 AddRuleWithUndefined 覆盖。ListRules 的 AllowUndefined 标出每条规则的实际模式
+func WithAllowUndefined() EngineOption {
+	return func(re *RuleEngine) {
+		re.allowUndefined = true
+	}
+}
+
+// AddRuleWithUndefined 同 AddRule，但显式指定该规则是否容忍缺失因子，不随引擎默认值
+func (re *RuleEngine) AddRuleWithUndefined(id, exprStr string, allow bool) error {
+	return re.addRule(id, exprStr, RuleMeta{AllowUndefined: &allow}, true)
+}
+
+// ruleAllowsUndefined 规则的实际模式：meta 未指定时取引擎默认值
+func (re *RuleEngine) ruleAllowsUndefined(meta RuleMeta) bool {
+	if meta.AllowUndefined != nil {
+		return *meta.AllowUndefined
+	}
+	return re.allowUndefined
+}
+
+// compileProgram 编译规则表达式；lenient 时按容忍模式改写后编译，否则与 expr.Compile 相同
+func compileProgram(exprStr string, lenient bool) (*vm.Program, error) {
+	if !lenient {
+		return expr.Compile(exprStr, expr.AsBool())
+	}
+	tree, config, err := checkedTree(exprStr, true, expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile(tree, config)
+}
+
+// checkedTree 按 expr.Compile 的流程解析、检查并优化表达式，返回语法树与所用配置；
+// lenient 时在类型检查前按容忍模式改写语法树
+func checkedTree(exprStr string, lenient bool, opts ...expr.Option) (*parser.Tree, *conf.Config, error) {
+	config := conf.CreateNew()
+	for _, opt := range opts {
+		opt(config)
+	}
+	if lenient {
+		expr.AllowUndefinedVariables()(config)
+	}
+	var tree *parser.Tree
+	var err error
+	if lenient {
+		if tree, err = parser.ParseWithConfig(exprStr, config); err != nil {
+			return nil, nil, err
+		}
+		ast.Walk(&tree.Node, undefinedPatcher{})
+		tree.Node = orFalse(tree.Node)
+		if _, err = checker.Check(tree, config); err != nil {
+			return nil, nil, err
+		}
+	} else if tree, err = checker.ParseCheck(exprStr, config); err != nil {
+		return nil, nil, err
+	}
+	if err := optimizer.Optimize(&tree.Node, config); err != nil {
+		return nil, nil, err
+	}
+	return tree, config, nil
+}
+
+// undefinedPatcher 容忍模式的语法树改写，见本节开头；规则本身的布尔化由 checkedTree 处理
+type undefinedPatcher struct{}
+
+func (undefinedPatcher) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.MemberNode:
+		if n.Method {
+			break
+		}
+		// 可选链须包在 ChainNode 中；内层已包好的链拆开并入外层，与 a?.b?.c 的解析结果相同
+		n.Optional = true
+		if inner, ok := n.Node.(*ast.ChainNode); ok {
+			n.Node = inner.Node
+		}
+		*node = &ast.ChainNode{Node: n}
+	case *ast.UnaryNode:
+		if n.Operator == "not" || n.Operator == "!" {
+			n.Node = orFalse(n.Node)
+		}
+	case *ast.ConditionalNode:
+		n.Cond = orFalse(n.Cond)
+	case *ast.BuiltinNode:
+		if n.Name == "len" && len(n.Arguments) == 1 && isVariable(n.Arguments[0]) {
+			n.Arguments[0] = &ast.BinaryNode{Operator: "??", Left: n.Arguments[0], Right: &ast.ArrayNode{}}
+		}
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "and", "&&", "or", "||":
+			n.Left, n.Right = orFalse(n.Left), orFalse(n.Right)
+		case "<", ">", "<=", ">=", "contains", "startsWith", "endsWith", "matches":
+			var missing ast.Node
+			for _, side := range []ast.Node{n.Left, n.Right} {
+				if !isVariable(side) {
+					continue
+				}
+				isNil := &ast.BinaryNode{Operator: "==", Left: side, Right: &ast.NilNode{}}
+				if missing == nil {
+					missing = isNil
+				} else {
+					missing = &ast.BinaryNode{Operator: "or", Left: missing, Right: isNil}
+				}
+			}
+			if missing != nil {
+				*node = &ast.ConditionalNode{Cond: missing, Exp1: &ast.BoolNode{Value: false}, Exp2: n}
+			}
+		}
+	}
+}
+
+// isVariable 节点是否直接读取输入变量（顶层变量或嵌套字段）
+func isVariable(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.IdentifierNode:
+		return true
+	case *ast.MemberNode:
+		return isVariable(n.Node)
+	case *ast.ChainNode:
+		return isVariable(n.Node)
+	}
+	return false
+}
+
+// orFalse 变量节点改写为 变量 ?? false，其余原样返回
+func orFalse(n ast.Node) ast.Node {
+	if !isVariable(n) {
+		return n
+	}
+	return &ast.BinaryNode{Operator: "??", Left: n, Right: &ast.BoolNode{Value: false}}
+}
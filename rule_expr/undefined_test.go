@@ -0,0 +1,146 @@
+package rule_expr
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 同一组规则在部分输入上的结果：严格模式下引用缺失因子的规则执行出错，容忍模式按 nil / false 处理
+var undefinedCases = []struct {
+	id, expr string
+	lenient  bool // 容忍模式下是否命中
+}{
+	{"missing_bool", "not is_vip", true},
+	{"missing_and", "is_vip and env == 'prod'", false},
+	{"missing_or", "is_vip or env == 'prod'", true},
+	{"nested", "user.country == 'CN'", false},
+	{"nested_ne", "user.country != 'CN'", true},
+	{"compare", "amount > 100", false},
+	{"length", "len(tags) == 0", true},
+	{"present", "env == 'prod'", true},
+}
+
+var partialInput = map[string]interface{}{"env": "prod"}
+
+func TestStrictVersusLenient(t *testing.T) {
+	strict, lenient := NewRuleEngine(), NewRuleEngine(WithAllowUndefined())
+	for _, c := range undefinedCases {
+		if err := strict.AddRule(c.id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+		if err := lenient.AddRule(c.id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hits, errs := strict.MatchWithErrors(partialInput)
+	if !slices.Equal(hits, []string{"present"}) {
+		t.Errorf("严格模式命中 %v，应只有 present", hits)
+	}
+	for _, c := range undefinedCases {
+		if c.id == "present" || c.id == "missing_or" || c.id == "nested_ne" {
+			continue
+		}
+		if errs[c.id] == nil {
+			t.Errorf("严格模式下 %s 应执行出错", c.id)
+		}
+	}
+
+	hits, errs = lenient.MatchWithErrors(partialInput)
+	if len(errs) != 0 {
+		t.Errorf("容忍模式不应出错: %v", errs)
+	}
+	for _, c := range undefinedCases {
+		if slices.Contains(hits, c.id) != c.lenient {
+			t.Errorf("容忍模式下 %s 命中 = %v，应为 %v", c.id, !c.lenient, c.lenient)
+		}
+	}
+}
+
+func TestAllowUndefinedOverrideVisibleInListRules(t *testing.T) {
+	re := NewRuleEngine(WithAllowUndefined())
+	re.AddRule("default", "is_vip")
+	re.AddRuleWithUndefined("strict", "is_vip", false)
+	got := map[string]bool{}
+	for _, info := range re.ListRules() {
+		got[info.ID] = info.AllowUndefined
+	}
+	if !reflect.DeepEqual(got, map[string]bool{"default": true, "strict": false}) {
+		t.Fatalf("ListRules 中的模式 = %v", got)
+	}
+	if hits := re.Match(map[string]interface{}{}); !slices.Equal(hits, []string(nil)) {
+		t.Fatalf("命中 %v", hits)
+	}
+	if re.EvalErrors() != 1 {
+		t.Fatalf("严格规则应在缺失因子时出错一次，实际 %d", re.EvalErrors())
+	}
+}
+
+// TestTextKeepsModeAndDescription 文本格式往返后容忍模式与说明不变，包括读入默认值不同的引擎
+func TestTextKeepsModeAndDescription(t *testing.T) {
+	src := NewRuleEngine(WithAllowUndefined())
+	if err := src.AddRule("lenient", `user.country == "CN"`); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.AddRuleWithUndefined("strict", "amount > 100", false); err != nil {
+		t.Fatal(err)
+	}
+	desc := "大额, \"可疑\" | 交易\n第二行 \\"
+	if err := src.addRule("described", "is_vip", RuleMeta{Description: desc, Tags: []string{"risk"}, Flag: "beta"}, true); err != nil {
+		t.Fatal(err)
+	}
+	var text bytes.Buffer
+	if err := src.ExportText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(text.String(), "\n"); n != 3 {
+		t.Fatalf("每条规则应只占一行:\n%s", text.String())
+	}
+
+	for _, dst := range []*RuleEngine{NewRuleEngine(), NewRuleEngine(WithAllowUndefined())} {
+		if err := dst.ImportText(bytes.NewReader(text.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dst.ListRules(), src.ListRules(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("往返后规则不同:\n%+v\n%+v", got, want)
+		}
+		var again bytes.Buffer
+		dst.allowUndefined = src.allowUndefined
+		dst.ExportText(&again)
+		if again.String() != text.String() {
+			t.Fatalf("再次导出不同:\n%s---\n%s", text.String(), again.String())
+		}
+	}
+}
+
+func TestTextMarkerErrors(t *testing.T) {
+	if err := NewRuleEngine().ImportText(strings.NewReader("a | 0 | undefined=maybe | is_vip\n")); err == nil {
+		t.Fatal("undefined= 的非法取值应报错")
+	}
+	for _, tag := range []string{"flag=x", "undefined=allow", "desc=x"} {
+		if err := NewRuleEngine().AddRuleWithTags("a", "is_vip", tag); err == nil {
+			t.Errorf("标签 %q 与标记前缀冲突，应报错", tag)
+		}
+	}
+}
+
+// TestRewriteKeepsMetadata -rewrite 经 RewriteRules 与 ExportText 写回文件，元数据须保持不变
+func TestRewriteKeepsMetadata(t *testing.T) {
+	const src = "a | 3 | desc=旧因子,risk,undefined=allow | env == \"prod\"\n"
+	re := NewRuleEngine()
+	if err := re.ImportText(strings.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := re.RewriteRules(map[string]FactorRewrite{"env": {RenameTo: "environment"}})
+	if err != nil || len(rep.Changed) != 1 {
+		t.Fatalf("RewriteRules = %+v, %v", rep, err)
+	}
+	var out bytes.Buffer
+	re.ExportText(&out)
+	if want := "a | 3 | desc=旧因子,risk,undefined=allow | environment == \"prod\"\n"; out.String() != want {
+		t.Fatalf("改写后:\n%s应为:\n%s", out.String(), want)
+	}
+}
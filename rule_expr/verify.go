@@ -0,0 +1,195 @@
+package rule_expr
+
+import (
+	"fmt"
+	"goexprtester/rule_engine"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+/* ---------- 全域穷举验证 ---------- */
+
+// GroupAssertion 对每个输入的命中集合求值的断言，表达式可引用
+// hits（命中的规则 ID 列表）与 input（当前输入），须返回 bool
+type GroupAssertion struct {
+	Name string
+	Expr string
+}
+
+// VerifyLimits 穷举的上限，零值取默认
+type VerifyLimits struct {
+	MaxCombinations int           // 默认 1<<20
+	Timeout         time.Duration // 默认 10s
+}
+
+func (l VerifyLimits) withDefaults() VerifyLimits {
+	if l.MaxCombinations <= 0 {
+		l.MaxCombinations = 1 << 20
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = 10 * time.Second
+	}
+	return l
+}
+
+// TruthRow 真值表中的一行
+type TruthRow struct {
+	Input  map[string]interface{}
+	Hits   []string
+	Errors []string // 执行出错的规则及原因
+}
+
+// AssertionViolation 断言在某个输入上不成立
+type AssertionViolation struct {
+	Assertion string
+	Row       int // Table 中的下标
+	Err       string
+}
+
+// VerifyReport 穷举结果；Complete 为 false 表示因上限或超时只覆盖了部分输入
+type VerifyReport struct {
+	Rules        []string
+	Factors      []string
+	Combinations int // 取值域笛卡尔积的总大小
+	Complete     bool
+	Table        []TruthRow
+	Violations   []AssertionViolation
+}
+
+// VerifyExhaustive 枚举 ruleIDs（nil 表示全部规则）所引用因子的完整取值域笛卡尔积，
+// 逐一求值并检查断言。只支持 Bool 因子与 Enumerated 因子；引用其他因子时返回错误
+func (re *RuleEngine) VerifyExhaustive(ruleIDs []string, assertions []GroupAssertion, limits VerifyLimits) (VerifyReport, error) {
+	return re.verifyExhaustive(ruleIDs, assertions, limits, time.Now)
+}
+
+// verifyExhaustive 时钟由 now 提供，测试可以注入假时钟验证超时路径
+func (re *RuleEngine) verifyExhaustive(ruleIDs []string, assertions []GroupAssertion, limits VerifyLimits, now func() time.Time) (VerifyReport, error) {
+	limits = limits.withDefaults()
+	rules, err := re.selectRules(ruleIDs)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	factors, domains, err := re.enumerableDomains(rules)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	checks := make([]*vm.Program, len(assertions))
+	env := map[string]interface{}{"hits": []string{}, "input": map[string]interface{}{}}
+	for i, a := range assertions {
+		if checks[i], err = expr.Compile(a.Expr, expr.Env(env), expr.AsBool()); err != nil {
+			return VerifyReport{}, fmt.Errorf("断言 %s 编译失败: %w", a.Name, err)
+		}
+	}
+
+	report := VerifyReport{Factors: factors, Combinations: 1, Complete: true}
+	for _, r := range rules {
+		report.Rules = append(report.Rules, r.ID)
+	}
+	for _, d := range domains {
+		if report.Combinations > limits.MaxCombinations {
+			break // 已超出上限，无需继续相乘
+		}
+		report.Combinations *= len(d)
+	}
+
+	deadline := now().Add(limits.Timeout)
+	var machine vm.VM
+	idx := make([]int, len(factors)) // 当前组合在各因子取值域中的下标
+	for n := 0; ; n++ {
+		if n >= limits.MaxCombinations || now().After(deadline) {
+			report.Complete = false
+			break
+		}
+		input := make(map[string]interface{}, len(factors))
+		for i, name := range factors {
+			rule_engine.SetPath(input, name, domains[i][idx[i]])
+		}
+		row := TruthRow{Input: input, Hits: []string{}}
+		for _, r := range rules {
+			ok, err := evalRule(&machine, r, input)
+			if err != nil {
+				row.Errors = append(row.Errors, r.ID+": "+err.Error())
+			} else if ok {
+				row.Hits = append(row.Hits, r.ID)
+			}
+		}
+		report.Table = append(report.Table, row)
+		scope := map[string]interface{}{"hits": row.Hits, "input": input}
+		for i, p := range checks {
+			out, err := machine.Run(p, scope)
+			if err != nil {
+				report.Violations = append(report.Violations, AssertionViolation{assertions[i].Name, n, err.Error()})
+			} else if b, _ := out.(bool); !b {
+				report.Violations = append(report.Violations, AssertionViolation{Assertion: assertions[i].Name, Row: n})
+			}
+		}
+		if !nextCombination(idx, domains) {
+			break
+		}
+	}
+	return report, nil
+}
+
+// selectRules 按 ID 取规则并排序；nil 表示全部
+func (re *RuleEngine) selectRules(ids []string) ([]*Rule, error) {
+	var rules []*Rule
+	if ids == nil {
+		rules = slices.Clone(re.snapshot())
+	} else {
+		for _, id := range ids {
+			r, ok := re.lookup(id)
+			if !ok {
+				return nil, fmt.Errorf("规则 %s 不存在", id)
+			}
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// enumerableDomains 收集规则引用的因子及其完整取值域，按因子名排序
+func (re *RuleEngine) enumerableDomains(rules []*Rule) ([]string, [][]interface{}, error) {
+	seen := make(map[string]bool)
+	var factors []string
+	for _, r := range rules {
+		for _, id := range r.shape.Identifiers {
+			if !seen[id] {
+				seen[id] = true
+				factors = append(factors, id)
+			}
+		}
+	}
+	sort.Strings(factors)
+	domains := make([][]interface{}, len(factors))
+	for i, name := range factors {
+		f, ok := re.pool.Lookup(name)
+		switch {
+		case !ok:
+			return nil, nil, fmt.Errorf("因子 %s 未在因子池中声明", name)
+		case f.Kind == Bool:
+			domains[i] = []interface{}{false, true}
+		case f.Enumerated && len(f.SampleValues) > 0:
+			domains[i] = f.SampleValues
+		default:
+			return nil, nil, fmt.Errorf("因子 %s 的取值域不可枚举", name)
+		}
+	}
+	return factors, domains, nil
+}
+
+// nextCombination 以末位最快的顺序推进 idx，全部枚举完返回 false
+func nextCombination(idx []int, domains [][]interface{}) bool {
+	for i := len(idx) - 1; i >= 0; i-- {
+		idx[i]++
+		if idx[i] < len(domains[i]) {
+			return true
+		}
+		idx[i] = 0
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package rule_expr
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"goexprtester/rule_engine"
+)
+
+var mutualExclusion = []GroupAssertion{{Name: "allow/deny 互斥", Expr: `not ("r_allow" in hits and "r_deny" in hits)`}}
+
+// verifyEngine 两个可枚举因子（is_blocked × tier，共 6 种输入）上的一组规则
+func verifyEngine(t *testing.T, rules map[string]string) *RuleEngine {
+	t.Helper()
+	pool, err := rule_engine.NewFactorPool([]FactorTemplate{
+		{Name: "is_blocked", Kind: Bool},
+		{Name: "tier", Kind: String, SampleValues: []interface{}{"gold", "silver", "bronze"}, Enumerated: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := NewRuleEngine(WithFactorPool(pool))
+	for id, expr := range rules {
+		if err := re.AddRule(id, expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return re
+}
+
+func TestVerifyExhaustivePasses(t *testing.T) {
+	re := verifyEngine(t, map[string]string{
+		"r_allow": `tier == "gold" and not is_blocked`,
+		"r_deny":  "is_blocked",
+	})
+	report, err := re.VerifyExhaustive(nil, mutualExclusion, VerifyLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Complete || report.Combinations != 6 || len(report.Table) != 6 {
+		t.Fatalf("应完整覆盖 6 种输入: complete=%v combinations=%d table=%d", report.Complete, report.Combinations, len(report.Table))
+	}
+	if !slices.Equal(report.Factors, []string{"is_blocked", "tier"}) || !slices.Equal(report.Rules, []string{"r_allow", "r_deny"}) {
+		t.Fatalf("factors = %v, rules = %v", report.Factors, report.Rules)
+	}
+	if len(report.Violations) != 0 {
+		t.Fatalf("断言应成立: %+v", report.Violations)
+	}
+	// 真值表逐行检查：blocked 时只命中 r_deny，gold 且未 blocked 时只命中 r_allow
+	for _, row := range report.Table {
+		blocked, tier := row.Input["is_blocked"].(bool), row.Input["tier"].(string)
+		var want []string
+		switch {
+		case blocked:
+			want = []string{"r_deny"}
+		case tier == "gold":
+			want = []string{"r_allow"}
+		default:
+			want = []string{}
+		}
+		if !slices.Equal(row.Hits, want) || len(row.Errors) != 0 {
+			t.Errorf("%v: hits = %v errors = %v，应为 %v", row.Input, row.Hits, row.Errors, want)
+		}
+	}
+}
+
+// TestVerifyExhaustiveViolation 互斥断言被破坏时，报告精确指出唯一的违例输入
+func TestVerifyExhaustiveViolation(t *testing.T) {
+	re := verifyEngine(t, map[string]string{
+		"r_allow": `tier == "gold" and not is_blocked`,
+		"r_deny":  `is_blocked or tier == "gold"`,
+		"r_other": `tier == "silver"`,
+	})
+	report, err := re.VerifyExhaustive([]string{"r_deny", "r_allow"}, mutualExclusion, VerifyLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(report.Rules, "r_other") {
+		t.Fatalf("只应验证指定的规则: %v", report.Rules)
+	}
+	if len(report.Violations) != 1 {
+		t.Fatalf("应恰好 1 处违例: %+v", report.Violations)
+	}
+	v := report.Violations[0]
+	row := report.Table[v.Row]
+	if v.Assertion != "allow/deny 互斥" || v.Err != "" || row.Input["is_blocked"] != false || row.Input["tier"] != "gold" {
+		t.Fatalf("违例应指向 is_blocked=false, tier=gold: %+v, input %v", v, row.Input)
+	}
+	if !slices.Equal(row.Hits, []string{"r_allow", "r_deny"}) {
+		t.Fatalf("违例输入的命中 = %v", row.Hits)
+	}
+}
+
+func TestVerifyExhaustiveLimits(t *testing.T) {
+	re := verifyEngine(t, map[string]string{"r_deny": `is_blocked or tier == "gold"`})
+
+	report, err := re.VerifyExhaustive(nil, nil, VerifyLimits{MaxCombinations: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete || len(report.Table) != 4 || report.Combinations != 6 {
+		t.Fatalf("超出组合上限应返回不完整的部分报告: complete=%v table=%d combinations=%d",
+			report.Complete, len(report.Table), report.Combinations)
+	}
+
+	// 假时钟每次读取前进 1s，超时 3s：截止前恰好求值 3 个输入
+	clock := time.Unix(0, 0)
+	now := func() time.Time {
+		at := clock
+		clock = clock.Add(time.Second)
+		return at
+	}
+	report, err = re.verifyExhaustive(nil, nil, VerifyLimits{Timeout: 3 * time.Second}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete || len(report.Table) != 3 {
+		t.Fatalf("超时应返回不完整的部分报告: complete=%v table=%d", report.Complete, len(report.Table))
+	}
+}
+
+func TestVerifyExhaustiveErrors(t *testing.T) {
+	re := verifyEngine(t, map[string]string{"r_deny": "is_blocked"})
+	if _, err := re.VerifyExhaustive([]string{"missing"}, nil, VerifyLimits{}); err == nil {
+		t.Error("不存在的规则应报错")
+	}
+	if _, err := re.VerifyExhaustive(nil, []GroupAssertion{{Name: "bad", Expr: "len(hits)"}}, VerifyLimits{}); err == nil {
+		t.Error("结果不是 bool 的断言应报错")
+	}
+	re.AddRule("r_amount", "amount > 100")
+	if _, err := re.VerifyExhaustive(nil, nil, VerifyLimits{}); err == nil {
+		t.Error("引用不可枚举因子时应报错")
+	}
+}
@@ -0,0 +1,232 @@
+package rule_expr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ---------- 规则文件热加载 ---------- */
+
+// ReloadResult 一次重载的结果
+type ReloadResult struct {
+	At      time.Time
+	Applied bool     // 是否已整体替换规则集
+	Added   []string // 以下三项相对替换前的规则集，均已排序
+	Removed []string
+	Updated []string
+	Failed  map[string]error // 编译失败的规则；非空时不替换
+	Err     error            // 读取或解析文件失败、ID 重复、引擎已关闭等
+}
+
+// ErrReloadWithStore 配置了持久化后端的引擎不能从文件整体替换规则集：
+// 替换不经过 store，内存与 store 会出现分歧，重启后 LoadFromStore 恢复的也不是文件中的规则
+var ErrReloadWithStore = errors.New("引擎配置了持久化后端，不能从规则文件重载")
+
+// RuleWatcher 周期性检查规则文件的后台任务，见 WatchRulesFile
+type RuleWatcher struct {
+	re       *RuleEngine
+	path     string
+	onReload func(ReloadResult)
+	last     uint64 // 上次尝试加载的文件内容哈希
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// WatchRulesFile 先同步加载 path，成功后每隔 interval 检查一次文件内容，变化时在后台重新编译，
+// 全部规则编译成功才整体替换规则集（见 ReloadRulesFile）。每次内容变化后的重载结果传给 onReload
+// （可为 nil），在后台 goroutine 中调用。首次加载失败、interval <= 0 或引擎配置了持久化后端
+// （见 ErrReloadWithStore）时返回错误，不启动后台任务。引擎关闭后后台任务自行结束
+func (re *RuleEngine) WatchRulesFile(path string, interval time.Duration, onReload func(ReloadResult)) (*RuleWatcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("检查间隔必须为正数，实际 %s", interval)
+	}
+	if re.store != nil {
+		return nil, ErrReloadWithStore
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := re.reloadRules(path, data)
+	if !res.Applied {
+		return nil, res.error()
+	}
+	w := &RuleWatcher{
+		re:       re,
+		path:     path,
+		onReload: onReload,
+		last:     contentHash(data),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.loop(interval)
+	return w, nil
+}
+
+// Stop 停止后台检查并等待进行中的重载结束；可重复调用
+func (w *RuleWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *RuleWatcher) loop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		data, err := os.ReadFile(w.path)
+		var res ReloadResult
+		if err != nil {
+			// 文件暂时缺失（编辑器先删后写）不算内容变化，下次再看
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			res = ReloadResult{At: time.Now(), Err: err}
+		} else {
+			h := contentHash(data)
+			if h == w.last {
+				continue
+			}
+			w.last = h
+			res = w.re.reloadRules(w.path, data)
+		}
+		if w.onReload != nil {
+			w.onReload(res)
+		}
+		if errors.Is(res.Err, ErrEngineClosed) {
+			return
+		}
+	}
+}
+
+// ReloadRulesFile 读取 path 并在全部规则编译成功时整体替换规则集。
+// 扩展名为 .json 时按 LoadRulesFromJSON 的格式解析，否则按文本格式（见 ReadText）。
+// 替换是一次快照发布：进行中的匹配继续使用旧规则集，之后的匹配只看到新规则集；
+// 仍存在的规则保留停用状态。文件是规则的唯一来源，配置了持久化后端时不替换并返回 ErrReloadWithStore
+func (re *RuleEngine) ReloadRulesFile(path string) ReloadResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ReloadResult{At: time.Now(), Err: err}
+	}
+	return re.reloadRules(path, data)
+}
+
+// pendingRule 解析出但尚未编译的规则
+type pendingRule struct {
+	id, expr string
+	meta     RuleMeta
+}
+
+func (re *RuleEngine) reloadRules(path string, data []byte) ReloadResult {
+	res := ReloadResult{At: time.Now()}
+	if re.store != nil {
+		res.Err = ErrReloadWithStore
+		return res
+	}
+	pending, err := parseRulesFile(path, data)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	compiled := make([]*Rule, len(pending))
+	errs := make([]error, len(pending))
+	parallelFill(len(pending), runtime.GOMAXPROCS(0), func(i int) {
+		p := pending[i]
+		compiled[i], errs[i] = re.compileRule(p.id, p.expr, p.meta)
+	})
+	for i, err := range errs {
+		if err != nil {
+			if res.Failed == nil {
+				res.Failed = make(map[string]error)
+			}
+			res.Failed[pending[i].id] = err
+		}
+	}
+	if res.Failed != nil {
+		return res
+	}
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.closed {
+		res.Err = ErrEngineClosed
+		return res
+	}
+	res.Added, res.Removed, res.Updated = re.replaceAllLocked(compiled)
+	res.Applied = true
+	re.generation.Add(1)
+	return res
+}
+
+// parseRulesFile 按扩展名解析规则文件并检查 ID 唯一
+func parseRulesFile(path string, data []byte) ([]pendingRule, error) {
+	var pending []pendingRule
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		rules, _, err := decodeJSONRules(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, jr := range rules {
+			meta, err := jr.meta()
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s: %w", jr.ID, err)
+			}
+			pending = append(pending, pendingRule{jr.ID, jr.Expr, meta})
+		}
+		return pending, nil
+	}
+	rules, err := ReadText(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(rules))
+	for _, tr := range rules {
+		if seen[tr.ID] {
+			return nil, fmt.Errorf("规则 ID %s 重复", tr.ID)
+		}
+		seen[tr.ID] = true
+		meta, err := tr.meta()
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s: %w", tr.ID, err)
+		}
+		pending = append(pending, pendingRule{tr.ID, tr.Expr, meta})
+	}
+	return pending, nil
+}
+
+// error 把未能替换的原因合并为一个错误，已替换时返回 nil
+func (r ReloadResult) error() error {
+	if r.Applied {
+		return nil
+	}
+	if r.Err != nil {
+		return r.Err
+	}
+	errs := make([]error, 0, len(r.Failed))
+	for _, id := range slices.Sorted(maps.Keys(r.Failed)) {
+		errs = append(errs, fmt.Errorf("编译规则 %s 失败: %w", id, r.Failed[id]))
+	}
+	return errors.Join(errs...)
+}
+
+func contentHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
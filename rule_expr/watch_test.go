@@ -0,0 +1,112 @@
+package rule_expr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeRulesFile 先写临时文件再改名，后台检查不会读到写了一半的文件
+func writeRulesFile(t *testing.T, path, text string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchRulesFileRejectsBadArguments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	writeRulesFile(t, path, "a | 0 | | is_vip\n")
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if w, err := NewRuleEngine().WatchRulesFile(path, interval, nil); err == nil {
+			w.Stop()
+			t.Fatalf("interval = %s 时应报错", interval)
+		}
+	}
+	re := NewRuleEngine(WithPersistence(newMemStore()))
+	if _, err := re.WatchRulesFile(path, time.Millisecond, nil); !errors.Is(err, ErrReloadWithStore) {
+		t.Fatalf("配置了 store 时 err = %v，应为 ErrReloadWithStore", err)
+	}
+	if res := re.ReloadRulesFile(path); res.Applied || !errors.Is(res.Err, ErrReloadWithStore) {
+		t.Fatalf("配置了 store 时 ReloadRulesFile = %+v", res)
+	}
+	if re.RuleCount() != 0 {
+		t.Fatal("拒绝重载时不应修改规则集")
+	}
+}
+
+func TestWatchRulesFileReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	writeRulesFile(t, path, "a | 0 | | is_vip\nb | 0 | | blacklisted\n")
+	re := NewRuleEngine()
+	results := make(chan ReloadResult, 8)
+	w, err := re.WatchRulesFile(path, 5*time.Millisecond, func(res ReloadResult) { results <- res })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	re.DisableRule("a")
+
+	next := func() ReloadResult {
+		t.Helper()
+		select {
+		case res := <-results:
+			return res
+		case <-time.After(5 * time.Second):
+			t.Fatal("等待重载超时")
+			return ReloadResult{}
+		}
+	}
+
+	writeRulesFile(t, path, "a | 0 | | is_vip\nb | 0 | | amount > 100\nc | 0 | | is_vip\n")
+	res := next()
+	if !res.Applied || !slices.Equal(res.Added, []string{"c"}) || !slices.Equal(res.Updated, []string{"b"}) || len(res.Removed) != 0 {
+		t.Fatalf("重载结果 = %+v", res)
+	}
+	if hits := re.Match(map[string]interface{}{"is_vip": true, "amount": 200}); !slices.Equal(hits, []string{"b", "c"}) {
+		t.Fatalf("命中 %v，a 应保持停用", hits)
+	}
+
+	// 有规则编译失败时整体不替换
+	writeRulesFile(t, path, "a | 0 | | is_vip\nbad | 0 | | is_vip and (\n")
+	res = next()
+	if res.Applied || res.Failed["bad"] == nil || re.RuleCount() != 3 {
+		t.Fatalf("编译失败时不应替换: %+v，规则数 %d", res, re.RuleCount())
+	}
+
+	writeRulesFile(t, path, "a | 0 | | is_vip\n")
+	res = next()
+	if !res.Applied || !slices.Equal(res.Removed, []string{"b", "c"}) {
+		t.Fatalf("重载结果 = %+v", res)
+	}
+}
+
+func TestWatchStopsWhenEngineCloses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	writeRulesFile(t, path, "a | 0 | | is_vip\n")
+	re := NewRuleEngine()
+	results := make(chan ReloadResult, 1)
+	w, err := re.WatchRulesFile(path, time.Millisecond, func(res ReloadResult) { results <- res })
+	if err != nil {
+		t.Fatal(err)
+	}
+	re.Close()
+	writeRulesFile(t, path, "a | 0 | | blacklisted\n")
+	if res := <-results; !errors.Is(res.Err, ErrEngineClosed) {
+		t.Fatalf("引擎关闭后 err = %v", res.Err)
+	}
+	done := make(chan struct{})
+	go func() { w.Stop(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("引擎关闭后后台任务应自行结束")
+	}
+}
@@ -0,0 +1,49 @@
+package rule_govaluate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"goexprtester/rule_engine"
+)
+
+func TestAddRulesMixedBatch(t *testing.T) {
+	re := &RuleEngine{CompileCache: rule_engine.NewCompileCache(0)}
+	re.AddRule("ok_vip", "blacklisted") // 被批次覆盖
+	added, failures := re.AddRules(map[string]string{
+		"ok_prod":   "env == 'prod'",
+		"ok_vip":    "is_vip",
+		"ok_amount": "amount > 100",
+		"ok_dup":    "amount > 100", // 与 ok_amount 同文本，共享解析结果
+		"bad_parse": "amount >",
+		"bad_paren": "(env == 'prod'",
+		"bad_op":    "amount >>> 1",
+	})
+	var failed []string
+	for id, err := range failures {
+		failed = append(failed, id)
+		if !strings.Contains(err.Error(), id) {
+			t.Errorf("%s 的错误应带上规则 ID: %v", id, err)
+		}
+	}
+	slices.Sort(failed)
+	if added != 4 || !slices.Equal(failed, []string{"bad_op", "bad_paren", "bad_parse"}) {
+		t.Fatalf("added = %d, failures = %v", added, failures)
+	}
+	if re.RuleCount() != 4 {
+		t.Fatalf("RuleCount = %d，覆盖已有 ID 不应重复计数", re.RuleCount())
+	}
+	if s := re.CompileCacheStats(); s.Entries != 4 {
+		t.Fatalf("同文本应共用一个缓存条目，失败的不缓存: %+v", s)
+	}
+	hits := re.Match(map[string]interface{}{"env": "prod", "is_vip": true, "amount": 500.0, "blacklisted": false})
+	slices.Sort(hits)
+	if !slices.Equal(hits, []string{"ok_amount", "ok_dup", "ok_prod", "ok_vip"}) {
+		t.Fatalf("hits = %v", hits)
+	}
+
+	if added, failures := re.AddRules(nil); added != 0 || failures != nil {
+		t.Fatalf("空批次: added = %d, failures = %v", added, failures)
+	}
+}
@@ -0,0 +1,62 @@
+package rule_govaluate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Knetic/govaluate"
+)
+
+/* ---------- 结果类型检查 ---------- */
+
+// ErrNotBool 表达式的结果一定不是 bool
+var ErrNotBool = errors.New("表达式结果不是 bool")
+
+// CheckBool 根据最外层运算判断表达式的结果能否是 bool：最外层是比较、逻辑运算或 ! 时是 bool，
+// 是算术运算、取负或数字/字符串常量时不是。变量、函数与三元表达式的类型在解析时未知，视为可能是 bool
+func CheckBool(e *govaluate.EvaluableExpression) error {
+	if !boolTokens(e.Tokens()) {
+		return fmt.Errorf("%w: %s", ErrNotBool, e.String())
+	}
+	return nil
+}
+
+// boolTokens 判断一段括号平衡的 token 序列的结果能否是 bool
+func boolTokens(tokens []govaluate.ExpressionToken) bool {
+	depth := 0
+	var arith bool
+	for _, t := range tokens {
+		switch t.Kind {
+		case govaluate.CLAUSE:
+			depth++
+		case govaluate.CLAUSE_CLOSE:
+			depth--
+		case govaluate.TERNARY:
+			if depth == 0 {
+				return true // 结果取决于分支，解析时无法判断
+			}
+		case govaluate.COMPARATOR, govaluate.LOGICALOP:
+			if depth == 0 {
+				return true // 优先级低于算术运算，决定了整个表达式的类型
+			}
+		case govaluate.MODIFIER:
+			if depth == 0 {
+				arith = true
+			}
+		}
+	}
+	if arith || len(tokens) == 0 {
+		return false
+	}
+	switch first := tokens[0]; first.Kind {
+	case govaluate.PREFIX:
+		return first.Value == "!"
+	case govaluate.CLAUSE:
+		// 没有顶层运算符时整个表达式就是一对括号
+		return boolTokens(tokens[1 : len(tokens)-1])
+	case govaluate.NUMERIC, govaluate.STRING, govaluate.TIME, govaluate.PATTERN:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,262 @@
+package rule_govaluate
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+)
+
+// treeUses 规则树中是否出现满足 pred 的节点
+func treeUses(t *rule_engine.Tree, pred func(*rule_engine.Tree) bool) bool {
+	if t == nil {
+		return false
+	}
+	return pred(t) || treeUses(t.Cond, pred) || treeUses(t.Left, pred) || treeUses(t.Right, pred)
+}
+
+// TestGenConfigConformance 每种 govaluate 额外语法单独打开（以及全部打开）时，同一批规则树分别按 govaluate 与 expr
+// 的写法加入两个引擎，在 Int 因子约三成为 nil 的输入上，两个引擎的命中都与 Tree.Eval 的参考结果完全相同
+func TestGenConfigConformance(t *testing.T) {
+	constructs := []struct {
+		name string
+		cfg  GenConfig
+		uses func(*rule_engine.Tree) bool
+	}{
+		{"三元式", GenConfig{Ternary: true}, func(n *rule_engine.Tree) bool { return n.Op == "cond" }},
+		{"空值合并", GenConfig{Coalesce: true}, func(n *rule_engine.Tree) bool { return n.Coalesce }},
+		{"算术修饰", GenConfig{Modifiers: true}, func(n *rule_engine.Tree) bool { return n.Arith != "" }},
+		{"全部", GenConfig{Ternary: true, Coalesce: true, Modifiers: true, Rate: 0.5}, func(n *rule_engine.Tree) bool {
+			return n.Op == "cond" || n.Coalesce || n.Arith != ""
+		}},
+	}
+	const seed = 11
+	for _, c := range constructs {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.MissingRate = 0.3
+			trees := rule_engine.RandomTreesFrom(c.cfg.pool(), 300, seed, c.cfg.GenOptions())
+			used := 0
+			for _, tree := range trees {
+				if treeUses(tree, c.uses) {
+					used++
+				}
+			}
+			if used < len(trees)/10 {
+				t.Fatalf("只有 %d 条规则用到该语法", used)
+			}
+
+			ge, ee := &RuleEngine{}, rule_expr.NewRuleEngine()
+			if err := InjectRandomRulesConfig(ge, len(trees), seed, c.cfg); err != nil {
+				t.Fatal(err)
+			}
+			if err := rule_engine.InjectRandomRulesFrom(ee, rule_expr.Syntax, c.cfg.pool(), len(trees), seed, c.cfg.GenOptions()); err != nil {
+				t.Fatal(err)
+			}
+			raw := rule_engine.GenRandomInputsMissing(c.cfg.pool(), 200, seed, c.cfg.MissingRate)
+			adapted := GenRandomInputsMissing(200, seed, c.cfg)
+			failed := 0
+			for i, in := range raw {
+				var want []string
+				for k, tree := range trees {
+					ok, err := tree.Eval(in)
+					if err != nil {
+						failed++
+					}
+					if ok && err == nil {
+						want = append(want, fmt.Sprintf("auto-%d", k+1))
+					}
+				}
+				slices.Sort(want)
+				gotG := slices.Sorted(slices.Values(ge.Match(adapted[i])))
+				gotE := slices.Sorted(slices.Values(ee.Match(in)))
+				if !slices.Equal(gotG, want) || !slices.Equal(gotE, want) {
+					t.Fatalf("输入 #%d %v:\ngovaluate %v\nexpr      %v\n参考实现  %v", i, in, gotG, gotE, want)
+				}
+			}
+			if failed == 0 {
+				t.Fatal("nil 输入应让部分规则出错，否则没有覆盖空值分支")
+			}
+		})
+	}
+}
+
+// TestKnownDivergences 两个引擎语义确实不同、因而不在随机输入中出现的情形，逐类记录各自的结果：
+// 随机输入只让 Int 因子取 nil 且保留键，避开了这些差异
+func TestKnownDivergences(t *testing.T) {
+	cases := []struct {
+		class          string
+		expr, gval     string
+		input          map[string]interface{}
+		exprHit, gvHit bool
+	}{
+		// govaluate 对不存在的键报错（?? 也救不回来），expr 把不存在的键当作 nil
+		{"键缺失", "(user_id ?? 0) == 0", "(user_id ?? 0) == 0", map[string]interface{}{}, true, false},
+		{"键缺失", "user_id != 5", "user_id != 5", map[string]interface{}{}, true, false},
+		// govaluate 的 Bool 叶子写作 == true，nil 按 false 处理；expr 对 nil 取反报错
+		{"nil 布尔值", "not (is_vip)", "! (is_vip == true)", map[string]interface{}{"is_vip": nil}, false, true},
+	}
+	for _, c := range cases {
+		ee, ge := rule_expr.NewRuleEngine(), &RuleEngine{}
+		if err := ee.AddRule("r", c.expr); err != nil {
+			t.Fatal(err)
+		}
+		if err := ge.AddRule("r", c.gval); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(ee.Match(c.input)) == 1; got != c.exprHit {
+			t.Errorf("%s: expr %s 命中 = %v，记录为 %v", c.class, c.expr, got, c.exprHit)
+		}
+		if got := len(ge.Match(c.input)) == 1; got != c.gvHit {
+			t.Errorf("%s: govaluate %s 命中 = %v，记录为 %v", c.class, c.gval, got, c.gvHit)
+		}
+	}
+}
+
+// TestInjectRandomRulesConfigSeeded 同一种子生成的规则逐字节相同；零值 cfg 与 InjectRandomRulesSeeded 相同
+func TestInjectRandomRulesConfigSeeded(t *testing.T) {
+	cfg := GenConfig{Ternary: true, Coalesce: true, Modifiers: true}
+	a, b, plain, seeded := &RuleEngine{}, &RuleEngine{}, &RuleEngine{}, &RuleEngine{}
+	for _, err := range []error{
+		InjectRandomRulesConfig(a, 200, 5, cfg),
+		InjectRandomRulesConfig(b, 200, 5, cfg),
+		InjectRandomRulesConfig(plain, 200, 5, GenConfig{}),
+		rule_engine.InjectRandomRulesSeeded(seeded, Syntax, 200, 5),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(a.ListRules(), b.ListRules()) {
+		t.Fatal("同一种子生成的规则不同")
+	}
+	if !slices.Equal(plain.ListRules(), seeded.ListRules()) {
+		t.Fatal("零值 cfg 应与 InjectRandomRulesSeeded 生成相同的规则")
+	}
+	if slices.Equal(a.ListRules(), plain.ListRules()) {
+		t.Fatal("打开额外语法后规则应有变化")
+	}
+
+	// 因子池来自 cfg.Pool
+	pool, err := rule_engine.NewFactorPool([]rule_engine.Factor{{Name: "n", Kind: Int, SampleValues: []interface{}{1, 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range GenRandomInputsMissing(50, 1, GenConfig{Pool: pool, MissingRate: 0.5}) {
+		if _, ok := in["n"]; !ok || len(in) != 1 {
+			t.Fatalf("输入应只含 cfg.Pool 中的因子: %v", in)
+		}
+	}
+}
+
+// TestFloatBoundaries Float 因子在边界值（NaN、±Inf、±0、超出 2^53 的大整数转成的浮点数）上，
+// 两个引擎对每种比较的结果都与 Tree.Eval 一致：NaN 与任何常量比较除 != 外均为 false，-0 与 0 相等
+func TestFloatBoundaries(t *testing.T) {
+	amount := rule_engine.Factor{Name: "amount", Kind: rule_engine.Float}
+	consts := []float64{0, math.Copysign(0, -1), 99.5, -1e-9, 1 << 53, float64(math.MaxInt64), 1e308}
+	values := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0, 99.5, 99.49999999999999,
+		1 << 53, 1<<53 + 1, float64(1<<53 + 1), float64(math.MaxInt64), float64(math.MinInt64), math.MaxFloat64, math.SmallestNonzeroFloat64}
+	var trees []*rule_engine.Tree
+	ge, ee := &RuleEngine{}, rule_expr.NewRuleEngine()
+	for _, c := range consts {
+		for _, op := range []string{"==", "!=", ">", ">=", "<", "<="} {
+			tree := &rule_engine.Tree{Factor: amount, Cmp: op, Value: c}
+			id := fmt.Sprintf("r%d", len(trees))
+			if err := ge.AddRule(id, tree.Render(Syntax)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ee.AddRule(id, tree.Render(rule_expr.Syntax)); err != nil {
+				t.Fatal(err)
+			}
+			trees = append(trees, tree)
+		}
+	}
+	for _, v := range values {
+		in := map[string]interface{}{"amount": v}
+		var want []string
+		for i, tree := range trees {
+			if ok, err := tree.Eval(in); err != nil {
+				t.Fatalf("%s: %v", tree.Render(Syntax), err)
+			} else if ok {
+				want = append(want, fmt.Sprintf("r%d", i))
+			}
+		}
+		slices.Sort(want)
+		gotG := slices.Sorted(slices.Values(ge.Match(in)))
+		gotE := slices.Sorted(slices.Values(ee.Match(in)))
+		if !slices.Equal(gotG, want) || !slices.Equal(gotE, want) {
+			t.Errorf("amount = %v:\ngovaluate %v\nexpr      %v\n参考实现  %v", v, gotG, gotE, want)
+		}
+		if math.IsNaN(v) && len(want) != len(consts) {
+			t.Errorf("NaN 应只命中 != 规则，参考实现命中 %v", want)
+		}
+	}
+}
+
+// TestTimeRepresentation Time 因子在两个后端的表示：expr 的输入保持 time.Time、常量写作 date("RFC 3339")；
+// govaluate 的输入经 AdaptInputs 转为 int64 Unix 秒（嵌套因子展平后同样转换）、常量写作 Unix 秒。
+// 精确到秒的时刻（含非 UTC 时区与 1970 年之前）上两个引擎与 Tree.Eval 一致；
+// 带亚秒部分的输入在 govaluate 中被截断到秒，是已知的表示差异，生成器因此只产生整秒时刻
+func TestTimeRepresentation(t *testing.T) {
+	created := rule_engine.Factor{Name: "user.created_at", Kind: rule_engine.Time}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got := (&rule_engine.Tree{Factor: created, Cmp: ">", Value: at}).Render(rule_expr.Syntax); got != `user.created_at > date("2024-03-01T12:00:00Z")` {
+		t.Fatalf("expr 写法: %s", got)
+	}
+	if got := (&rule_engine.Tree{Factor: created, Cmp: ">", Value: at}).Render(Syntax); got != "[user.created_at] > 1709294400" {
+		t.Fatalf("govaluate 写法: %s", got)
+	}
+	in := []map[string]interface{}{{"user": map[string]interface{}{"created_at": at}}}
+	if got := rule_engine.AdaptInputs(rule_expr.Syntax, in); got[0]["user"].(map[string]interface{})["created_at"] != at {
+		t.Fatalf("expr 的输入应保持 time.Time: %v", got[0])
+	}
+	if got := rule_engine.AdaptInputs(Syntax, in); got[0]["user.created_at"] != at.Unix() {
+		t.Fatalf("govaluate 的输入应为 int64 Unix 秒: %#v", got[0])
+	}
+
+	consts := []time.Time{at, time.Unix(0, 0).UTC(), time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)}
+	shanghai := time.FixedZone("CST", 8*3600)
+	values := []time.Time{at, at.Add(time.Second), at.Add(-time.Second), at.In(shanghai), at.Add(time.Second).In(shanghai),
+		time.Unix(0, 0), time.Unix(-1, 0), time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2100, 1, 1, 0, 0, 0, 0, shanghai)}
+	ge, ee := &RuleEngine{}, rule_expr.NewRuleEngine()
+	var trees []*rule_engine.Tree
+	for _, c := range consts {
+		for _, op := range []string{"<", ">"} {
+			tree := &rule_engine.Tree{Factor: created, Cmp: op, Value: c}
+			id := fmt.Sprintf("r%d", len(trees))
+			if err := ge.AddRule(id, tree.Render(Syntax)); err != nil {
+				t.Fatal(err)
+			}
+			if err := ee.AddRule(id, tree.Render(rule_expr.Syntax)); err != nil {
+				t.Fatal(err)
+			}
+			trees = append(trees, tree)
+		}
+	}
+	match := func(v time.Time) (gotG, gotE, want []string) {
+		raw := map[string]interface{}{"user": map[string]interface{}{"created_at": v}}
+		for i, tree := range trees {
+			if ok, err := tree.Eval(raw); err != nil {
+				t.Fatal(err)
+			} else if ok {
+				want = append(want, fmt.Sprintf("r%d", i))
+			}
+		}
+		adapted := rule_engine.AdaptInputs(Syntax, []map[string]interface{}{raw})[0]
+		return slices.Sorted(slices.Values(ge.Match(adapted))), slices.Sorted(slices.Values(ee.Match(raw))), slices.Sorted(slices.Values(want))
+	}
+	for _, v := range values {
+		if gotG, gotE, want := match(v); !slices.Equal(gotG, want) || !slices.Equal(gotE, want) {
+			t.Errorf("%v:\ngovaluate %v\nexpr      %v\n参考实现  %v", v, gotG, gotE, want)
+		}
+	}
+
+	// 比常量晚半秒：expr 与参考实现按纳秒比较为晚于，govaluate 截断到秒后与常量相等
+	gotG, gotE, want := match(at.Add(500 * time.Millisecond))
+	if !slices.Equal(gotE, want) || !slices.Contains(want, "r1") || slices.Contains(gotG, "r1") {
+		t.Fatalf("亚秒输入:\ngovaluate %v\nexpr      %v\n参考实现  %v", gotG, gotE, want)
+	}
+}
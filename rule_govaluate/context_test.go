@@ -0,0 +1,49 @@
+package rule_govaluate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// TestMatchContextCancelStopsEarly 第 100 次求值时取消：最多再评估 ctxCheckEvery 条即停止并返回 ctx.Err()
+func TestMatchContextCancelStopsEarly(t *testing.T) {
+	const rules, cancelAt = 10000, 100
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 规则调用 len，借此统计求值次数；解析时取用函数，因此替换须在加入规则之前
+	var calls atomic.Int64
+	orig := functions["len"]
+	functions["len"] = func(args ...interface{}) (interface{}, error) {
+		if calls.Add(1) == cancelAt {
+			cancel()
+		}
+		return orig(args...)
+	}
+	defer func() { functions["len"] = orig }()
+
+	re := &RuleEngine{}
+	for i := 0; i < rules; i++ {
+		if err := re.AddRule(fmt.Sprintf("r%05d", i), "len(tags) >= 0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := map[string]interface{}{"tags": []interface{}{"a"}}
+	hits, err := re.MatchContext(ctx, input)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v，应为 context.Canceled", err)
+	}
+	if n := calls.Load(); n < cancelAt || n > cancelAt+ctxCheckEvery || int(n) >= re.RuleCount() {
+		t.Fatalf("取消后评估了 %d 条规则，应在 [%d, %d] 之间", n, cancelAt, cancelAt+ctxCheckEvery)
+	}
+	if int64(len(hits)) != calls.Load() {
+		t.Fatalf("应返回已评估规则的命中：%d 条命中，评估 %d 条", len(hits), calls.Load())
+	}
+
+	calls.Store(0)
+	if hits, err := re.MatchContext(context.Background(), input); err != nil || len(hits) != rules {
+		t.Fatalf("未取消时应评估全部规则: hits %d, err %v", len(hits), err)
+	}
+}
@@ -1,8 +1,13 @@
 package rule_govaluate
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+	"runtime"
+	"slices"
+	"sort"
 	"time"
 
 	"sync"
@@ -12,65 +17,273 @@ import (
 
 /* ---------- 因子模板 ---------- */
 
-type Kind int
+// 因子定义与内置因子池由 rule_engine 提供，与 rule_expr 共用同一份
+type (
+	Kind           = rule_engine.Kind
+	FactorTemplate = rule_engine.Factor
+)
 
 const (
-	Bool Kind = iota
-	String
-	Int
+	Bool   = rule_engine.Bool
+	String = rule_engine.String
+	Int    = rule_engine.Int
+	Float  = rule_engine.Float
+	Time   = rule_engine.Time
+	List   = rule_engine.List
 )
 
-type FactorTemplate struct {
-	Name         string
-	Kind         Kind
-	SampleValues []interface{}
-}
-
-var factorPool = []FactorTemplate{
-	// Bool
-	{"is_vip", Bool, nil},
-	{"blacklisted", Bool, nil},
-	{"email_verified", Bool, nil},
-	{"high_risk_ip", Bool, nil},
-	// String
-	{"env", String, []interface{}{"prod", "staging", "test_env"}},
-	{"payment_method", String, []interface{}{"ABCD", "XYZ", "PAYPAL", "STRIPE"}},
-	// Int
-	{"user_id", Int, []interface{}{12345, 67890, 13579, 24680}},
-}
-
 /* ---------- RuleEngine 与 Rule (Govaluate) ---------- */
 
 type Rule struct {
 	ID         string
 	ExprString string
 	Expr       *govaluate.EvaluableExpression
+	// Factors 表达式读取的变量（EvaluableExpression.Vars），去重并排序；[user.country] 记为 user.country
+	Factors []string
+	// AllowUndefined 输入缺少的变量取 false 而不是让 Evaluate 报错，见 RuleEngine.AllowUndefined
+	AllowUndefined bool
+}
+
+// newRule 用解析结果构造规则并提取读取的变量
+func newRule(id, exprStr string, e *govaluate.EvaluableExpression, allowUndefined bool) *Rule {
+	factors := e.Vars()
+	sort.Strings(factors)
+	return &Rule{ID: id, ExprString: exprStr, Expr: e, Factors: slices.Compact(factors), AllowUndefined: allowUndefined}
+}
+
+// evaluate 执行规则；容忍缺失变量的规则经 lenientParams 取值
+func (r *Rule) evaluate(input map[string]interface{}) (interface{}, error) {
+	if r.AllowUndefined {
+		return r.Expr.Eval(lenientParams(input))
+	}
+	return r.Expr.Evaluate(input)
+}
+
+// lenientParams 缺失的变量取 false：!x 与 and / or 照常计算，== / != 按不相等处理；
+// 大小比较等要求数值的运算仍然报错，规则按未命中处理
+type lenientParams map[string]interface{}
+
+func (p lenientParams) Get(name string) (interface{}, error) {
+	if v, ok := p[name]; ok {
+		return v, nil
+	}
+	return false, nil
 }
 
 type RuleEngine struct {
-	rules sync.Map // id -> *Rule
+	// AllowUndefined 为 true 时此后加入的规则默认容忍缺失变量（govaluate 默认对未知参数报错），
+	// 单条规则可用 AddRuleWithUndefined 覆盖
+	AllowUndefined bool
+	// CompileCache 非 nil 时表达式文本相同的规则共享同一份解析结果（*govaluate.EvaluableExpression），
+	// AddRule 与 AddRules 都经过缓存；适合同一表达式以大量 ID 重复加入的规则集。多个 govaluate 引擎可共用一个缓存
+	CompileCache *rule_engine.CompileCache
+
+	rules sync.Map   // id -> *Rule
+	mu    sync.Mutex // 串行化规则变更与 ListRules 快照
+	count int        // 规则数，由 mu 保护
+}
+
+// functions 规则可调用的函数；govaluate 本身没有取列表长度的写法。
+// govaluate 调用函数时会把 []interface{} 参数展开为参数列表，因此 len(tags) 收到的是 tags 的各个元素，
+// 参数个数即列表长度（空列表为 0 个参数）
+var functions = map[string]govaluate.ExpressionFunction{
+	"len": func(args ...interface{}) (interface{}, error) {
+		return float64(len(args)), nil
+	},
+}
+
+// Parse 解析一条 govaluate 表达式，可使用 functions 中的函数
+func Parse(exprStr string) (*govaluate.EvaluableExpression, error) {
+	return govaluate.NewEvaluableExpressionWithFunctions(exprStr, functions)
+}
+
+// parse 解析表达式；设置了 CompileCache 时同文本只解析一次。
+// 解析结果构造后只读，多条规则共用同一份、并发 Evaluate 都是安全的
+func (re *RuleEngine) parse(exprStr string) (*govaluate.EvaluableExpression, error) {
+	cache := re.CompileCache
+	if cache == nil {
+		return Parse(exprStr)
+	}
+	if e, ok := cache.Get(exprStr); ok {
+		return e.(*govaluate.EvaluableExpression), nil
+	}
+	e, err := Parse(exprStr)
+	if err != nil {
+		return nil, err
+	}
+	return cache.Add(exprStr, e).(*govaluate.EvaluableExpression), nil
+}
+
+// CompileCacheStats 返回 CompileCache 的命中 / 未命中 / 淘汰统计；未设置缓存时为零值
+func (re *RuleEngine) CompileCacheStats() rule_engine.CacheStats {
+	if re.CompileCache == nil {
+		return rule_engine.CacheStats{}
+	}
+	return re.CompileCache.Stats()
 }
 
 // AddRule 解析并加入/替换一条规则
 func (re *RuleEngine) AddRule(id, exprStr string) error {
-	parsedExpr, err := govaluate.NewEvaluableExpression(exprStr)
+	return re.AddRuleWithUndefined(id, exprStr, re.AllowUndefined)
+}
+
+// AddRuleWithUndefined 同 AddRule，但显式指定该规则是否容忍缺失变量
+func (re *RuleEngine) AddRuleWithUndefined(id, exprStr string, allow bool) error {
+	parsedExpr, err := re.parse(exprStr)
 	if err != nil {
 		return err
 	}
-	re.rules.Store(id, &Rule{
-		ID:         id,
-		ExprString: exprStr,
-		Expr:       parsedExpr,
-	})
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if _, loaded := re.rules.Swap(id, newRule(id, exprStr, parsedExpr, allow)); !loaded {
+		re.count++
+	}
 	return nil
 }
 
+// AddRules 并行解析 rules（id -> 表达式），把解析成功的规则加入引擎，
+// 返回加入的条数与失败的规则（ID -> 原因，没有失败时为 nil）；一条规则失败不影响其他规则
+func (re *RuleEngine) AddRules(rules map[string]string) (added int, failures map[string]error) {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parsed := make([]*Rule, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	chunk := (len(ids) + workers - 1) / workers
+	for lo := 0; lo < len(ids); lo += chunk {
+		hi := min(lo+chunk, len(ids))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				e, err := re.parse(rules[ids[i]])
+				if err != nil {
+					errs[i] = fmt.Errorf("解析规则 %s 失败: %w", ids[i], err)
+					continue
+				}
+				parsed[i] = newRule(ids[i], rules[ids[i]], e, re.AllowUndefined)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			if failures == nil {
+				failures = make(map[string]error)
+			}
+			failures[ids[i]] = err
+		}
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	for _, r := range parsed {
+		if r == nil {
+			continue
+		}
+		if _, loaded := re.rules.Swap(r.ID, r); !loaded {
+			re.count++
+		}
+		added++
+	}
+	return added, failures
+}
+
+// RemoveRule 删除规则，返回该 ID 是否存在；可与 Match 并发
+func (re *RuleEngine) RemoveRule(id string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	_, ok := re.rules.LoadAndDelete(id)
+	if ok {
+		re.count--
+	}
+	return ok
+}
+
+// RuleInfo 规则的只读快照，不含解析结果
+type RuleInfo struct {
+	ID             string
+	Expr           string
+	AllowUndefined bool // 见 Rule.AllowUndefined
+}
+
+// ListRules 返回按 ID 排序的规则快照；与规则变更互斥，因此是某一时刻的完整规则集
+func (re *RuleEngine) ListRules() []RuleInfo {
+	re.mu.Lock()
+	out := make([]RuleInfo, 0, re.count)
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		out = append(out, RuleInfo{ID: r.ID, Expr: r.ExprString, AllowUndefined: r.AllowUndefined})
+		return true
+	})
+	re.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RuleCount 返回当前规则数
+func (re *RuleEngine) RuleCount() int {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.count
+}
+
+// GetRuleFactors 返回规则读取的变量（见 Rule.Factors）；规则不存在时返回 false
+func (re *RuleEngine) GetRuleFactors(id string) ([]string, bool) {
+	v, ok := re.rules.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return slices.Clone(v.(*Rule).Factors), true
+}
+
+// FactorUsage 返回每个变量被多少条规则读取；同一规则多次读取只计一次
+func (re *RuleEngine) FactorUsage() map[string]int {
+	usage := make(map[string]int)
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.rules.Range(func(_, value any) bool {
+		for _, f := range value.(*Rule).Factors {
+			usage[f]++
+		}
+		return true
+	})
+	return usage
+}
+
 // Match 遍历执行全部规则并返回命中 ID
 func (re *RuleEngine) Match(input map[string]interface{}) []string {
+	hits, _ := re.MatchContext(context.Background(), input)
+	return hits
+}
+
+// ctxCheckEvery MatchContext 每评估多少条规则检查一次 ctx
+const ctxCheckEvery = 64
+
+// MatchContext 同 Match，但每评估 ctxCheckEvery 条规则检查一次 ctx：
+// ctx 结束时停止遍历，返回已收集的部分命中与 ctx.Err()
+func (re *RuleEngine) MatchContext(ctx context.Context, input map[string]interface{}) ([]string, error) {
+	done := ctx.Done() // Background 等不可取消的 ctx 为 nil，跳过检查
 	var hits []string
+	var ctxErr error
+	i := 0
 	re.rules.Range(func(_, value any) bool {
+		if done != nil && i%ctxCheckEvery == 0 {
+			select {
+			case <-done:
+				ctxErr = ctx.Err()
+				return false
+			default:
+			}
+		}
+		i++
 		r := value.(*Rule)
-		out, err := r.Expr.Evaluate(input)
+		out, err := r.evaluate(input)
 		if err == nil {
 			if ok, _ := out.(bool); ok {
 				hits = append(hits, r.ID)
@@ -78,101 +291,118 @@ func (re *RuleEngine) Match(input map[string]interface{}) []string {
 		}
 		return true
 	})
-	return hits
+	return hits, ctxErr
+}
+
+// MatchWithErrors 同 Match，另返回执行出错或结果不是 bool 的规则：规则 ID -> 错误；没有错误时 map 为 nil
+func (re *RuleEngine) MatchWithErrors(input map[string]interface{}) ([]string, map[string]error) {
+	var hits []string
+	var errs map[string]error
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		out, err := r.evaluate(input)
+		if err == nil {
+			if b, ok := out.(bool); ok {
+				if b {
+					hits = append(hits, r.ID)
+				}
+				return true
+			}
+			err = fmt.Errorf("规则结果不是 bool: %T(%v)", out, out)
+		}
+		if errs == nil {
+			errs = make(map[string]error)
+		}
+		errs[r.ID] = err
+		return true
+	})
+	return hits, errs
 }
 
 /* ---------- 随机规则注入 ---------- */
 
-func InjectRandomRules(re *RuleEngine, count int) error {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < count; i++ {
-		ruleID := fmt.Sprintf("auto-%d", i+1)
-		exprStr := randomExpr(r, 5) // ≤ 5 因子
-		if err := re.AddRule(ruleID, exprStr); err != nil {
-			return fmt.Errorf("编译规则 %s 失败: %w", ruleID, err)
-		} else {
-			fmt.Printf("编译规则 %s 成功: %s\n", ruleID, exprStr)
-		}
-	}
-	return nil
+// GenConfig 控制生成器额外使用的 govaluate 语法，零值与 InjectRandomRules 的生成结果一致。
+// 这些语法都是规则树（rule_engine.Tree）的一部分，同一棵树也能按 expr 等其他写法输出，并可用 Tree.Eval 求参考结果
+type GenConfig struct {
+	Ternary   bool    // 生成布尔三元式 (cond ? a : b)
+	Coalesce  bool    // Int 因子写成 (x ?? 0) 形式，配合 MissingRate 覆盖空值
+	Modifiers bool    // Int 因子使用 % / + 等算术修饰符再比较
+	Rate      float64 // 上述语法在每个片段上出现的概率，默认 0.3
+	// MissingRate GenRandomInputsMissing 中 Int 因子取 nil 的概率
+	MissingRate float64
+	// Pool 规则与输入引用的因子，须已通过 Validate；nil 时用内置因子池
+	Pool *rule_engine.FactorPool
+	Ops  rule_engine.GenOptions // 规则结构与 Int / String 因子的运算符分布，零值取默认；其 Logger / Verbose 同样控制输出
 }
 
-// ---- 表达式生成（与前版一致，只是保留了 "not/and/or" 语义） ----
+func (c GenConfig) rate() float64 {
+	if c.Rate <= 0 {
+		return 0.3
+	}
+	return c.Rate
+}
 
-func randomExpr(r *rand.Rand, maxFactors int) string {
-	n := r.Intn(maxFactors) + 1
-	perm := r.Perm(len(factorPool))[:n]
-	var factors []FactorTemplate
-	for _, idx := range perm {
-		factors = append(factors, factorPool[idx])
+func (c GenConfig) pool() *rule_engine.FactorPool {
+	if c.Pool == nil {
+		return rule_engine.DefaultFactorPool()
 	}
-	return buildSubExpr(r, factors)
+	return c.Pool
 }
 
-func buildSubExpr(r *rand.Rand, factors []FactorTemplate) string {
-	if len(factors) == 1 {
-		frag := snippet(r, factors[0])
-		if r.Float64() < 0.3 { // 30% 概率加 not
-			return "! (" + frag + ")"
-		}
-		return frag
+// GenOptions 把 c 转为 rule_engine 的生成参数：在 c.Ops 上按开关设置三元式、?? 与算术修饰的概率
+func (c GenConfig) GenOptions() rule_engine.GenOptions {
+	o := c.Ops
+	if c.Ternary {
+		o.TernaryRate = c.rate()
+	}
+	if c.Coalesce {
+		o.CoalesceRate = c.rate()
 	}
-	split := r.Intn(len(factors)-1) + 1
-	left := buildSubExpr(r, factors[:split])
-	right := buildSubExpr(r, factors[split:])
-	op := "&&"
-	if r.Float64() < 0.5 {
-		op = "||"
+	if c.Modifiers {
+		o.ArithRate = c.rate()
 	}
-	return fmt.Sprintf("(%s %s %s)", left, op, right)
+	return o
 }
 
-func snippet(r *rand.Rand, f FactorTemplate) string {
-	switch f.Kind {
-	case Bool:
-		// Govaluate 不支持裸变量，必须写成 == true 或 == false
-		return fmt.Sprintf("%s == true", f.Name)
-	case String:
-		v := f.SampleValues[r.Intn(len(f.SampleValues))].(string)
-		return fmt.Sprintf("%s == \"%s\"", f.Name, v)
-	case Int:
-		v := f.SampleValues[r.Intn(len(f.SampleValues))].(int)
-		return fmt.Sprintf("%s == %d", f.Name, v)
-	default:
-		return f.Name
-	}
+// Syntax 随机规则使用的 govaluate 写法；Govaluate 不支持裸变量，Bool 因子写成 == true。
+// govaluate 的数值一律按 float64 比较且无法比较 time.Time，Time 因子以 Unix 秒表示；
+// IN 只接受 []interface{}，List 因子的输入须转换；govaluate 不能访问 map 的成员，
+// 嵌套因子以方括号转义的完整点路径引用（如 [user.country]），输入展平为同名的键
+var Syntax = rule_engine.Syntax{Not: "!", And: "&&", Or: "||", Bool: "%s == true", In: "%[1]s IN %[2]s", Set: "(%s)", Len: "len(%s)", AnyLists: true,
+	Path: "[%s]", FlatPaths: true, Ternary: "((%s) ? %s : %s)", Coalesce: "(%s ?? 0)"}
+
+var _ rule_engine.Engine = (*RuleEngine)(nil)
+
+// InjectRandomRules 生成 count 条随机规则，见 rule_engine.InjectRandomRules
+func InjectRandomRules(re *RuleEngine, count int, opts ...rule_engine.GenOptions) error {
+	return rule_engine.InjectRandomRules(re, Syntax, count, opts...)
+}
+
+// InjectRandomRulesConfig 用 seed 按 cfg 生成并注入 count 条随机规则（ID 为 auto-1 起）；种子相同时规则文本逐字节相同。
+// cfg 为零值时与以同一种子调用 rule_engine.InjectRandomRulesSeeded 的结果相同
+func InjectRandomRulesConfig(re *RuleEngine, count int, seed int64, cfg GenConfig) error {
+	return rule_engine.InjectRandomRulesFrom(re, Syntax, cfg.pool(), count, seed, cfg.GenOptions())
 }
 
 /* ---------- 随机数据生成 & Benchmark ---------- */
 
+// GenRandomInputs 生成 n 条随机测试数据，见 rule_engine.GenRandomInputs；Time 因子为 int64 Unix 秒，
+// List 因子为 []interface{}，嵌套因子展平为点路径键
 func GenRandomInputs(n int) []map[string]interface{} {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	rows := make([]map[string]interface{}, n)
-	for i := 0; i < n; i++ {
-		row := make(map[string]interface{}, len(factorPool))
-		for _, f := range factorPool {
-			switch f.Kind {
-			case Bool:
-				row[f.Name] = r.Intn(2) == 0
-			case String:
-				row[f.Name] = f.SampleValues[r.Intn(len(f.SampleValues))]
-			case Int:
-				if r.Float64() < 0.8 {
-					row[f.Name] = f.SampleValues[r.Intn(len(f.SampleValues))]
-				} else {
-					row[f.Name] = r.Intn(90000) + 10000
-				}
-			}
-		}
-		rows[i] = row
-	}
-	return rows
+	return rule_engine.AdaptInputs(Syntax, rule_engine.GenRandomInputs(n))
 }
 
-func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}) time.Duration {
-	start := time.Now()
-	for _, in := range inputs {
-		_ = re.Match(in)
-	}
-	return time.Since(start) / time.Duration(len(inputs))
+// GenRandomInputsMissing 用 seed 按 cfg.Pool 生成 n 条随机测试数据，Int 因子以 cfg.MissingRate 的概率取 nil，
+// 用于覆盖 ?? 的空值分支，见 rule_engine.GenRandomInputsMissing；已按 Syntax 转换
+func GenRandomInputsMissing(n int, seed int64, cfg GenConfig) []map[string]interface{} {
+	return rule_engine.AdaptInputs(Syntax, rule_engine.GenRandomInputsMissing(cfg.pool(), n, seed, cfg.MissingRate))
+}
+
+func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	return rule_engine.BenchmarkMatch(re, inputs, opts...)
+}
+
+// BenchmarkMatchPrecise 只对引擎调用本身计时，并给出计时与循环开销
+func BenchmarkMatchPrecise(re *RuleEngine, inputs []map[string]interface{}) bench.Timing {
+	return rule_engine.BenchmarkMatchPrecise(re, inputs)
 }
@@ -0,0 +1,106 @@
+package rule_govaluate
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// TestStrictVersusLenient 同一条规则在部分输入上：严格模式下 Evaluate 对未知参数报错，容忍模式缺失变量取 false
+func TestStrictVersusLenient(t *testing.T) {
+	cases := []struct {
+		id, expr string
+		lenient  bool // 容忍模式下是否命中
+	}{
+		{"missing_bool", "!is_vip", true},
+		{"missing_and", "is_vip && env == 'prod'", false},
+		{"missing_or", "is_vip || env == 'prod'", true},
+		{"missing_ne", "payment_method != 'PAYPAL'", true},
+		{"present", "env == 'prod'", true},
+	}
+	strict, lenient := &RuleEngine{}, &RuleEngine{AllowUndefined: true}
+	for _, c := range cases {
+		if err := strict.AddRule(c.id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+		if err := lenient.AddRule(c.id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := map[string]interface{}{"env": "prod"}
+
+	hits, errs := strict.MatchWithErrors(input)
+	if !slices.Equal(hits, []string{"present"}) {
+		t.Errorf("严格模式命中 %v，应只有 present", hits)
+	}
+	for _, c := range cases {
+		if c.id != "present" && errs[c.id] == nil {
+			t.Errorf("严格模式下 %s 应报错", c.id)
+		}
+	}
+
+	hits, errs = lenient.MatchWithErrors(input)
+	if len(errs) != 0 {
+		t.Errorf("容忍模式不应出错: %v", errs)
+	}
+	for _, c := range cases {
+		if slices.Contains(hits, c.id) != c.lenient {
+			t.Errorf("容忍模式下 %s 命中 = %v，应为 %v", c.id, !c.lenient, c.lenient)
+		}
+	}
+
+	// 大小比较要求数值，缺失变量取 false 后仍然报错
+	if err := lenient.AddRule("compare", "amount > 100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := lenient.MatchWithErrors(input); errs["compare"] == nil {
+		t.Error("容忍模式下缺失变量参与大小比较仍应报错")
+	}
+}
+
+func TestAllowUndefinedVisibleInListRules(t *testing.T) {
+	re := &RuleEngine{AllowUndefined: true}
+	re.AddRule("default", "is_vip")
+	re.AddRuleWithUndefined("strict", "is_vip", false)
+	infos := re.ListRules()
+	if len(infos) != 2 || !infos[0].AllowUndefined || infos[1].AllowUndefined {
+		t.Fatalf("ListRules = %+v", infos)
+	}
+}
+
+// TestRuleFactors 取反、嵌套、[a.b] 形式的成员访问与函数调用中抽取的变量；函数名不计入，重复读取只计一次
+func TestRuleFactors(t *testing.T) {
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"!is_vip", []string{"is_vip"}},
+		{"!([user.country] in ('CN', 'US')) && !(amount > 100)", []string{"amount", "user.country"}},
+		{"is_vip || (amount > 100 && ([user.profile.level] >= 2 || [user.country] == 'CN'))",
+			[]string{"amount", "is_vip", "user.country", "user.profile.level"}},
+		{"len(tags) > 0 && 'vip' in tags", []string{"tags"}},
+		{"amount > 100 && amount < 500 || amount == 7", []string{"amount"}},
+		{"((user_id ?? 0) % 2 == 1) ? is_vip : env == 'prod'", []string{"env", "is_vip", "user_id"}},
+	}
+	re := &RuleEngine{}
+	want := make(map[string]int)
+	for i, c := range cases {
+		id := fmt.Sprintf("r%d", i)
+		if err := re.AddRule(id, c.expr); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := re.GetRuleFactors(id); !ok || !slices.Equal(got, c.want) {
+			t.Errorf("%s: %v，应为 %v", c.expr, got, c.want)
+		}
+		for _, f := range c.want {
+			want[f]++
+		}
+	}
+	if got := re.FactorUsage(); !maps.Equal(got, want) {
+		t.Fatalf("FactorUsage = %v，应为 %v", got, want)
+	}
+	if _, ok := re.GetRuleFactors("missing"); ok {
+		t.Fatal("不存在的规则应返回 false")
+	}
+}
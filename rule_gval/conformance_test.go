@@ -0,0 +1,75 @@
+package rule_gval
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+)
+
+// TestMatchesExprEngine 同一批随机规则分别按 gval 与 expr 的写法加入两个引擎，
+// 在同一组种子输入上（按各自写法转换时间与列表）命中必须完全相同
+func TestMatchesExprEngine(t *testing.T) {
+	for _, seed := range []int64{1, 7, 42} {
+		targets := []rule_engine.Target{
+			{Name: "expr", Engine: rule_expr.NewRuleEngine(), Syntax: rule_expr.Syntax},
+			{Name: "gval", Engine: &RuleEngine{}, Syntax: Syntax},
+		}
+		rep, err := rule_engine.Verify(targets, seed, 300, rule_engine.GenRandomInputsSeeded(300, seed+1))
+		if err != nil {
+			t.Fatalf("种子 %d: %v", seed, err)
+		}
+		for _, m := range rep.Mismatches[:min(len(rep.Mismatches), 5)] {
+			raw, _ := json.Marshal(m.Input)
+			for _, d := range m.Rules {
+				t.Errorf("种子 %d 输入 #%d %s: 规则 %s 仅被 %v 命中\n  expr %s\n  gval %s", seed, m.InputIndex, raw, d.ID, d.HitBy, d.Exprs[0], d.Exprs[1])
+			}
+		}
+		if n := len(rep.Mismatches); n > 0 {
+			t.Fatalf("种子 %d: %d 条输入命中不一致", seed, n)
+		}
+	}
+}
+
+// TestMatchesExprEngineHandWritten 随机规则不易覆盖的写法：否定、嵌套、列表与字符串比较
+func TestMatchesExprEngineHandWritten(t *testing.T) {
+	rules := []struct{ id, expr, gval string }{
+		{"not_vip", "not is_vip", "!is_vip"},
+		{"nested", `(amount > 100 and env == "prod") or (is_vip and not blacklisted)`, `(amount > 100 && env == "prod") || (is_vip && !blacklisted)`},
+		{"in_set", `env in ["prod", "staging"]`, `env in ["prod", "staging"]`},
+		{"str_lt", `env < "q"`, `env < "q"`},
+		{"eq_int", "user_id == 12345", "user_id == 12345"},
+	}
+	ee, ge := rule_expr.NewRuleEngine(), &RuleEngine{}
+	for _, r := range rules {
+		if err := ee.AddRule(r.id, r.expr); err != nil {
+			t.Fatal(err)
+		}
+		if err := ge.AddRule(r.id, r.gval); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputs := append(rule_engine.GenRandomInputsSeeded(200, 3),
+		map[string]interface{}{"env": "prod", "amount": 500.0, "is_vip": false, "blacklisted": false, "user_id": 12345},
+		map[string]interface{}{"env": "staging", "amount": 0.0, "is_vip": true, "blacklisted": false, "user_id": 1},
+	)
+	gvalInputs := rule_engine.AdaptInputs(Syntax, inputs)
+	seen := make(map[string]bool)
+	for i, in := range inputs {
+		want := slices.Sorted(slices.Values(ee.Match(in)))
+		got := slices.Sorted(slices.Values(ge.Match(gvalInputs[i])))
+		if !slices.Equal(got, want) {
+			t.Fatalf("输入 #%d %v:\ngval %v\nexpr %v", i, in, got, want)
+		}
+		for _, id := range got {
+			seen[id] = true
+		}
+	}
+	for _, r := range rules {
+		if !seen[r.id] {
+			t.Errorf("规则 %s 在全部输入上都未命中，比较没有意义", r.id)
+		}
+	}
+}
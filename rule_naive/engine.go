@@ -0,0 +1,121 @@
+package rule_naive
+
+import (
+	"goexprtester/bench"
+	"goexprtester/rule_engine"
+	"sort"
+	"sync"
+	"time"
+)
+
+/* ---------- RuleEngine 与 Rule (手写求值器) ---------- */
+
+// 本包只支持随机规则与规则包用到的最小语法（见 parse.go），直接在 AST 上求值，
+// 作为通用表达式库单条规则求值开销的下限参照
+
+type Rule struct {
+	ID         string
+	ExprString string
+	Root       node
+}
+
+type RuleEngine struct {
+	rules sync.Map   // id -> *Rule
+	mu    sync.Mutex // 串行化规则变更与 ListRules 快照
+	count int        // 规则数，由 mu 保护
+}
+
+var _ rule_engine.Engine = (*RuleEngine)(nil)
+
+// AddRule 解析并加入/替换一条规则；不支持的语法或结果不可能是 bool 时返回错误
+func (re *RuleEngine) AddRule(id, exprStr string) error {
+	root, err := parse(exprStr)
+	if err != nil {
+		return err
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if _, loaded := re.rules.Swap(id, &Rule{
+		ID:         id,
+		ExprString: exprStr,
+		Root:       root,
+	}); !loaded {
+		re.count++
+	}
+	return nil
+}
+
+// RemoveRule 删除规则，返回该 ID 是否存在；可与 Match 并发
+func (re *RuleEngine) RemoveRule(id string) bool {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	_, ok := re.rules.LoadAndDelete(id)
+	if ok {
+		re.count--
+	}
+	return ok
+}
+
+// RuleInfo 规则的只读快照，不含解析结果
+type RuleInfo struct {
+	ID   string
+	Expr string
+}
+
+// ListRules 返回按 ID 排序的规则快照；与规则变更互斥，因此是某一时刻的完整规则集
+func (re *RuleEngine) ListRules() []RuleInfo {
+	re.mu.Lock()
+	out := make([]RuleInfo, 0, re.count)
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		out = append(out, RuleInfo{ID: r.ID, Expr: r.ExprString})
+		return true
+	})
+	re.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RuleCount 返回当前规则数
+func (re *RuleEngine) RuleCount() int {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return re.count
+}
+
+// Match 遍历执行全部规则并返回命中 ID；执行出错（如单独出现的因子不是 bool）的规则按未命中处理
+func (re *RuleEngine) Match(input map[string]interface{}) []string {
+	var hits []string
+	re.rules.Range(func(_, value any) bool {
+		r := value.(*Rule)
+		if ok, err := r.Root.eval(input); err == nil && ok {
+			hits = append(hits, r.ID)
+		}
+		return true
+	})
+	return hits
+}
+
+/* ---------- 随机规则注入 & Benchmark ---------- */
+
+// Syntax 随机规则使用的写法，逻辑运算符与 rule_expr.Syntax 相同；不支持 time.Time，Time 因子以 Unix 秒比较
+var Syntax = rule_engine.Syntax{Not: "not", And: "and", Or: "or", Bool: "%s", In: "%[1]s in %[2]s", Set: "[%s]", Len: "len(%s)"}
+
+// InjectRandomRules 生成 count 条随机规则，见 rule_engine.InjectRandomRules
+func InjectRandomRules(re *RuleEngine, count int, opts ...rule_engine.GenOptions) error {
+	return rule_engine.InjectRandomRules(re, Syntax, count, opts...)
+}
+
+// GenRandomInputs 生成 n 条随机测试数据，见 rule_engine.GenRandomInputs；Time 因子为 int64 Unix 秒
+func GenRandomInputs(n int) []map[string]interface{} {
+	return rule_engine.AdaptInputs(Syntax, rule_engine.GenRandomInputs(n))
+}
+
+func BenchmarkMatch(re *RuleEngine, inputs []map[string]interface{}, opts ...bench.RepeatOptions) time.Duration {
+	return rule_engine.BenchmarkMatch(re, inputs, opts...)
+}
+
+// BenchmarkMatchPrecise 只对引擎调用本身计时，并给出计时与循环开销
+func BenchmarkMatchPrecise(re *RuleEngine, inputs []map[string]interface{}) bench.Timing {
+	return rule_engine.BenchmarkMatchPrecise(re, inputs)
+}
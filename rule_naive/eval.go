@@ -0,0 +1,217 @@
+package rule_naive
+
+import (
+	"fmt"
+	"goexprtester/rule_engine"
+	"slices"
+)
+
+/* ---------- AST 与求值 ---------- */
+
+// node 布尔表达式节点；求值出错（引用的因子不是 bool、比较的类型不对）时返回错误
+type node interface {
+	eval(input map[string]interface{}) (bool, error)
+}
+
+type notNode struct{ x node }
+
+type andNode struct{ l, r node }
+
+type orNode struct{ l, r node }
+
+// varNode 单独出现的因子，输入中必须是 bool
+type varNode struct{ name string }
+
+type litNode bool
+
+type cmpOp int
+
+const (
+	opEq cmpOp = iota
+	opNe
+	opLt
+	opLe
+	opGt
+	opGe
+	opIn // 左侧为元素，右侧为列表
+)
+
+var cmpOps = map[string]cmpOp{"==": opEq, "!=": opNe, "<": opLt, "<=": opLe, ">": opGt, ">=": opGe, "in": opIn}
+
+type cmpNode struct {
+	op   cmpOp
+	l, r operand
+}
+
+type operandKind int
+
+const (
+	opVar operandKind = iota
+	opInt
+	opFloat
+	opStr
+	opBool
+	opLen  // len(因子)，因子须为列表
+	opList // 常量列表，如 ["a", "b"]
+)
+
+// operand 比较的一侧：因子、因子的列表长度或常量；常量在解析时已转换为对应类型
+type operand struct {
+	kind operandKind
+	name string
+	n    int64
+	f    float64
+	s    string
+	b    bool
+	list []interface{}
+}
+
+func (n notNode) eval(input map[string]interface{}) (bool, error) {
+	v, err := n.x.eval(input)
+	return !v, err
+}
+
+func (n andNode) eval(input map[string]interface{}) (bool, error) {
+	l, err := n.l.eval(input)
+	if err != nil || !l {
+		return false, err
+	}
+	return n.r.eval(input)
+}
+
+func (n orNode) eval(input map[string]interface{}) (bool, error) {
+	l, err := n.l.eval(input)
+	if err != nil || l {
+		return l, err
+	}
+	return n.r.eval(input)
+}
+
+func (n varNode) eval(input map[string]interface{}) (bool, error) {
+	v := lookup(input, n.name)
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("因子 %s 不是 bool: %v", n.name, v)
+	}
+	return b, nil
+}
+
+func (n litNode) eval(map[string]interface{}) (bool, error) {
+	return bool(n), nil
+}
+
+func (n cmpNode) eval(input map[string]interface{}) (bool, error) {
+	l, r := n.l.value(input), n.r.value(input)
+	switch n.op {
+	case opEq:
+		return equal(l, r), nil
+	case opNe:
+		return !equal(l, r), nil
+	case opIn:
+		return contains(r, l)
+	}
+	a, okA := number(l)
+	b, okB := number(r)
+	if !okA || !okB {
+		return false, fmt.Errorf("无法比较大小: %v 与 %v", l, r)
+	}
+	switch n.op {
+	case opLt:
+		return a < b, nil
+	case opLe:
+		return a <= b, nil
+	case opGt:
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+// value 取操作数的值；输入中缺失的因子为 nil，len 的因子不是列表时也为 nil
+func (o operand) value(input map[string]interface{}) interface{} {
+	switch o.kind {
+	case opVar:
+		return lookup(input, o.name)
+	case opLen:
+		switch l := lookup(input, o.name).(type) {
+		case []string:
+			return int64(len(l))
+		case []interface{}:
+			return int64(len(l))
+		}
+		return nil
+	case opInt:
+		return o.n
+	case opFloat:
+		return o.f
+	case opStr:
+		return o.s
+	case opList:
+		return o.list
+	default:
+		return o.b
+	}
+}
+
+// lookup 取因子的值：先按名称取，点路径在顶层没有同名键时按嵌套 map 逐层取；缺失时为 nil
+func lookup(input map[string]interface{}, name string) interface{} {
+	if v, ok := input[name]; ok || !rule_engine.IsNested(name) {
+		return v
+	}
+	v, _ := rule_engine.LookupPath(input, name)
+	return v
+}
+
+// equal 与 expr 的 == 一致：数值按值比较（不区分 int 与 float64），其余类型须相同且相等，nil 只等于 nil
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case nil:
+		return b == nil
+	}
+	return false
+}
+
+// number 把输入中常见的数值类型转换为 float64
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// contains 判断列表 items（[]string 或 []interface{}）是否含有 v，元素按 equal 比较；
+// []string 直接遍历，不转换也不分配
+func contains(items, v interface{}) (bool, error) {
+	switch l := items.(type) {
+	case []string:
+		s, ok := v.(string)
+		return ok && slices.Contains(l, s), nil
+	case []interface{}:
+		for _, item := range l {
+			if equal(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("in 的右侧不是列表: %v", items)
+}
@@ -0,0 +1,339 @@
+package rule_naive
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+/* ---------- 词法 ---------- */
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokLParen
+	tokRParen
+	tokLBrack
+	tokRBrack
+	tokComma
+	tokNot // not / !
+	tokAnd // and / &&
+	tokOr  // or / ||
+	tokCmp // == != < <= > >= in
+)
+
+type token struct {
+	kind tokKind
+	text string // 标识符、运算符原文；字符串为去掉引号后的值
+	pos  int    // 在表达式中的字节偏移，用于错误提示
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			toks = append(toks, token{tokLBrack, "[", i})
+			i++
+		case c == ']':
+			toks = append(toks, token{tokRBrack, "]", i})
+			i++
+		case c == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(src) || src[i+1] != c {
+				return nil, fmt.Errorf("位置 %d: 不支持的运算符 %q", i, string(c))
+			}
+			kind := tokAnd
+			if c == '|' {
+				kind = tokOr
+			}
+			toks = append(toks, token{kind, src[i : i+2], i})
+			i += 2
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			if i+1 < len(src) && src[i+1] == '=' {
+				toks = append(toks, token{tokCmp, src[i : i+2], i})
+				i += 2
+			} else if c == '=' {
+				return nil, fmt.Errorf("位置 %d: 不支持赋值，比较请用 ==", i)
+			} else if c == '!' {
+				toks = append(toks, token{tokNot, "!", i})
+				i++
+			} else {
+				toks = append(toks, token{tokCmp, string(c), i})
+				i++
+			}
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("位置 %d: 字符串缺少结束引号", i)
+			}
+			s, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("位置 %d: 字符串不合法: %w", i, err)
+			}
+			toks = append(toks, token{tokString, s, i})
+			i = end + 1
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+				end++
+			}
+			kind := tokInt
+			if end+1 < len(src) && src[end] == '.' && src[end+1] >= '0' && src[end+1] <= '9' {
+				kind = tokFloat
+				end++
+				for end < len(src) && src[end] >= '0' && src[end] <= '9' {
+					end++
+				}
+			}
+			toks = append(toks, token{kind, src[i:end], i})
+			i = end
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i
+			for end < len(src) && (src[end] == '_' || unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) ||
+				src[end] == '.' && end+1 < len(src) && (src[end+1] == '_' || unicode.IsLetter(rune(src[end+1])))) {
+				end++
+			}
+			word := src[i:end]
+			kind := tokIdent
+			switch word {
+			case "not":
+				kind = tokNot
+			case "and":
+				kind = tokAnd
+			case "or":
+				kind = tokOr
+			case "in":
+				kind = tokCmp
+			}
+			toks = append(toks, token{kind, word, i})
+			i = end
+		default:
+			return nil, fmt.Errorf("位置 %d: 不支持的字符 %q", i, string(c))
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+/* ---------- 语法 ---------- */
+
+// 语法（优先级从低到高）：
+//
+//	or      = and { ("or" | "||") and }
+//	and     = unary { ("and" | "&&") unary }
+//	unary   = ("not" | "!") unary | primary
+//	primary = "(" or ")" | operand [ cmp operand ]
+//	cmp     = "==" | "!=" | "<" | "<=" | ">" | ">=" | "in"
+//	operand = 标识符 | "len" "(" 标识符 ")" | 常量 | "[" [ 常量 { "," 常量 } ] "]"
+//	常量    = 整数 | 小数 | 双引号字符串 | true | false
+//
+// 标识符可以是点路径（如 user.country），按嵌套 map 逐层取值
+//
+// 与 expr 不同，not 作用于其后的整个比较：not a == 1 即 not (a == 1)
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// parse 把表达式解析为 AST；不支持的语法返回错误
+func parse(src string) (node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("位置 %d: 多余的 %q", t.pos, t.text)
+	}
+	return n, nil
+}
+
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (node, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.peek().kind == tokLParen {
+		open := p.next()
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("位置 %d: 括号未闭合", open.pos)
+		}
+		p.next()
+		return n, nil
+	}
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokCmp {
+		// 单独出现的操作数只能是 bool
+		switch l.kind {
+		case opVar:
+			return varNode{l.name}, nil
+		case opBool:
+			return litNode(l.b), nil
+		}
+		return nil, errors.New("表达式结果不是 bool")
+	}
+	op := p.next()
+	r, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return cmpNode{op: cmpOps[op.text], l: l, r: r}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return operand{kind: opBool, b: true}, nil
+		case "false":
+			return operand{kind: opBool}, nil
+		case "len":
+			if p.peek().kind == tokLParen {
+				return p.lenCall(t)
+			}
+		}
+		return operand{kind: opVar, name: t.text}, nil
+	case tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("位置 %d: 整数 %s 超出范围", t.pos, t.text)
+		}
+		return operand{kind: opInt, n: n}, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("位置 %d: 小数 %s 不合法", t.pos, t.text)
+		}
+		return operand{kind: opFloat, f: f}, nil
+	case tokString:
+		return operand{kind: opStr, s: t.text}, nil
+	case tokLBrack:
+		return p.listLit()
+	case tokEOF:
+		return operand{}, errors.New("表达式意外结束")
+	}
+	return operand{}, fmt.Errorf("位置 %d: 此处需要因子或常量，得到 %q", t.pos, t.text)
+}
+
+// lenCall 解析 len 之后的 "(" 标识符 ")"
+func (p *parser) lenCall(fn token) (operand, error) {
+	p.next()
+	arg := p.next()
+	if arg.kind != tokIdent {
+		return operand{}, fmt.Errorf("位置 %d: len 的参数须为因子", arg.pos)
+	}
+	if p.next().kind != tokRParen {
+		return operand{}, fmt.Errorf("位置 %d: len 的括号未闭合", fn.pos)
+	}
+	return operand{kind: opLen, name: arg.text}, nil
+}
+
+// listLit 解析 "[" 之后的常量列表，元素只能是常量
+func (p *parser) listLit() (operand, error) {
+	list := []interface{}{}
+	for p.peek().kind != tokRBrack {
+		if len(list) > 0 {
+			if t := p.next(); t.kind != tokComma {
+				return operand{}, fmt.Errorf("位置 %d: 列表元素之间需要逗号，得到 %q", t.pos, t.text)
+			}
+		}
+		at := p.peek()
+		elem, err := p.operand()
+		if err != nil {
+			return operand{}, err
+		}
+		if elem.kind == opVar || elem.kind == opLen || elem.kind == opList {
+			return operand{}, fmt.Errorf("位置 %d: 列表元素须为常量", at.pos)
+		}
+		list = append(list, elem.value(nil))
+	}
+	p.next()
+	return operand{kind: opList, list: list}, nil
+}
@@ -0,0 +1,212 @@
+package rule_pack
+
+import (
+	"math/rand"
+	"slices"
+)
+
+/* ---------- 带欺诈注入的事件流 ---------- */
+
+// Event 一条交易事件；Pattern 为注入的欺诈模式，正常事件为空
+type Event struct {
+	Input   map[string]interface{}
+	Pattern string
+}
+
+// GenEvents 用固定种子生成 n 条事件，其中约 fraudRate 比例按 Patterns 均匀注入欺诈模式。
+// 注入事件的取值保证至少命中该模式的最宽松一档规则；正常事件的阈值类因子都落在各模式规则之外，
+// 因此模式规则在正常事件上的命中只来自国家、商户类别等组合，量很小
+func GenEvents(seed int64, n int, fraudRate float64) []Event {
+	r := rand.New(rand.NewSource(seed))
+	events := make([]Event, n)
+	for i := range events {
+		in := benignEvent(r)
+		pattern := ""
+		if r.Float64() < fraudRate {
+			pattern = Patterns[r.Intn(len(Patterns))]
+			inject(r, pattern, in)
+		}
+		events[i] = Event{Input: in, Pattern: pattern}
+	}
+	return events
+}
+
+// Inputs 取出事件的输入，供基准与报告使用
+func Inputs(events []Event) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(events))
+	for i, e := range events {
+		out[i] = e.Input
+	}
+	return out
+}
+
+// benignEvent 正常交易：低频、小额为主、老账户、国家基本一致
+func benignEvent(r *rand.Rand) map[string]interface{} {
+	amount := 5 + int(r.ExpFloat64()*80)
+	amount = min(amount, 3000)
+	count24h := r.Intn(13)
+	home := pick(r, lowRiskCountries) // 正常账户主要来自低风险国家
+	if r.Float64() < 0.05 {
+		home = pick(r, countries)
+	}
+	ip, billing := home, home
+	switch x := r.Float64(); {
+	case x < 0.005: // 少量出行到任意国家或使用代理
+		ip = pick(r, countries)
+	case x < 0.08:
+		ip = pick(r, lowRiskCountries)
+	}
+	if r.Float64() < 0.03 {
+		billing = pick(r, countries)
+	}
+	return map[string]interface{}{
+		"amount":             amount,
+		"txn_count_1h":       r.Intn(4),
+		"txn_count_24h":      count24h,
+		"amount_sum_24h":     amount + count24h*r.Intn(200),
+		"distinct_cards_24h": 1 + r.Intn(2),
+		"failed_logins_24h":  r.Intn(2),
+		"device_accounts":    1 + r.Intn(2),
+		"account_age_days":   31 + r.Intn(3000),
+		"hour":               r.Intn(24),
+		"country":            home,
+		"billing_country":    billing,
+		"ip_country":         ip,
+		"merchant_category":  pick(r, merchantCategories),
+		"channel":            pick(r, channels),
+		"is_new_device":      r.Float64() < 0.05,
+		"email_verified":     r.Float64() < 0.9,
+		"high_risk_ip":       r.Float64() < 0.02,
+		"blacklisted":        false,
+		"is_vip":             r.Float64() < 0.05,
+	}
+}
+
+// inject 在正常事件上叠加 pattern 的特征，各分支的取值下限即该模式最宽松一档规则的阈值
+func inject(r *rand.Rand, pattern string, in map[string]interface{}) {
+	switch pattern {
+	case CardTesting:
+		in["txn_count_1h"] = 8 + r.Intn(33)
+		in["amount"] = 1 + r.Intn(5)
+		in["distinct_cards_24h"] = 3 + r.Intn(8)
+		in["channel"] = "web"
+	case AccountTakeover:
+		in["failed_logins_24h"] = 3 + r.Intn(13)
+		in["is_new_device"] = true
+		in["ip_country"] = pickOther(r, countries, in["country"])
+		in["amount"] = 100 + r.Intn(2900)
+	case VelocitySpike:
+		count := 25 + r.Intn(96)
+		in["txn_count_1h"] = 6 + r.Intn(25)
+		in["txn_count_24h"] = count
+		in["amount"] = 51 + r.Intn(500)
+		in["amount_sum_24h"] = count * (200 + r.Intn(400))
+	case NewAccountBurst:
+		in["account_age_days"] = r.Intn(31)
+		in["amount"] = 500 + r.Intn(7500)
+	case DeviceFarm:
+		in["device_accounts"] = 4 + r.Intn(27)
+		in["account_age_days"] = r.Intn(31)
+	case GeoMismatch:
+		ip := highRiskCountries[r.Intn(len(highRiskCountries))]
+		home := pickOther(r, lowRiskCountries, ip)
+		in["ip_country"] = ip
+		in["country"] = home
+		in["billing_country"] = home
+		in["high_risk_ip"] = r.Float64() < 0.5
+		in["amount"] = 200 + r.Intn(4800)
+	case Cashout:
+		in["merchant_category"] = cashoutCategories[r.Intn(len(cashoutCategories))]
+		in["account_age_days"] = r.Intn(91)
+		in["amount"] = 200 + r.Intn(4800)
+	}
+}
+
+func pick(r *rand.Rand, values []interface{}) interface{} {
+	return values[r.Intn(len(values))]
+}
+
+// pickOther 从 values 中随机取一个不等于 not 的值
+func pickOther(r *rand.Rand, values []interface{}, not interface{}) interface{} {
+	for {
+		if v := pick(r, values); v != not {
+			return v
+		}
+	}
+}
+
+/* ---------- 检出报告 ---------- */
+
+// PatternDetection 某个欺诈模式的检出情况
+type PatternDetection struct {
+	Pattern  string
+	Injected int // 注入该模式的事件数
+	Caught   int // 其中至少命中一条该模式规则的事件数
+	// FalseAlarms 正常事件中命中该模式规则的事件数
+	FalseAlarms int
+}
+
+// Recall 检出率 Caught / Injected
+func (d PatternDetection) Recall() float64 {
+	if d.Injected == 0 {
+		return 0
+	}
+	return float64(d.Caught) / float64(d.Injected)
+}
+
+// DetectionReport 规则包在事件流上的检出情况
+type DetectionReport struct {
+	Events    int
+	Benign    int
+	Patterns  []PatternDetection // 按 Patterns 顺序
+	RuleHits  map[string]int     // 规则 ID -> 命中事件数，含通用规则
+	NeverHit  []string           // 一次也没命中的规则，按 ID 排序
+	PolicyHit int                // 至少命中一条通用规则的事件数
+}
+
+// Detect 对每条事件调用 match（任一引擎的 Match），按规则所属模式统计检出与误报
+func Detect(events []Event, match func(map[string]interface{}) []string) DetectionReport {
+	specs := Rules()
+	patternOf := make(map[string]string, len(specs))
+	for _, s := range specs {
+		patternOf[s.ID] = s.Pattern
+	}
+	rep := DetectionReport{Events: len(events), RuleHits: make(map[string]int)}
+	index := make(map[string]int, len(Patterns))
+	for i, p := range Patterns {
+		index[p] = i
+		rep.Patterns = append(rep.Patterns, PatternDetection{Pattern: p})
+	}
+	for _, e := range events {
+		if e.Pattern == "" {
+			rep.Benign++
+		} else {
+			rep.Patterns[index[e.Pattern]].Injected++
+		}
+		fired := make(map[string]bool)
+		for _, id := range match(e.Input) {
+			rep.RuleHits[id]++
+			fired[patternOf[id]] = true
+		}
+		if fired[""] {
+			rep.PolicyHit++
+		}
+		for p := range fired {
+			i, ok := index[p]
+			switch {
+			case !ok:
+			case p == e.Pattern:
+				rep.Patterns[i].Caught++
+			case e.Pattern == "":
+				rep.Patterns[i].FalseAlarms++
+			}
+		}
+	}
+	for _, s := range specs {
+		if rep.RuleHits[s.ID] == 0 {
+			rep.NeverHit = append(rep.NeverHit, s.ID)
+		}
+	}
+	slices.Sort(rep.NeverHit)
+	return rep
+}
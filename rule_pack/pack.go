@@ -0,0 +1,288 @@
+package rule_pack
+
+import (
+	"fmt"
+
+	"goexprtester/rule_expr"
+)
+
+/* ---------- 欺诈/风控规则包 ---------- */
+
+// 注入的欺诈模式，RuleSpec.Pattern 与 Event.Pattern 取这些值
+const (
+	CardTesting     = "card_testing"     // 盗卡试卡：短时间大量小额交易、多张卡
+	AccountTakeover = "account_takeover" // 盗号：多次登录失败后在新设备、异地发起交易
+	VelocitySpike   = "velocity_spike"   // 交易频次或金额激增
+	NewAccountBurst = "new_account"      // 新注册账户短期内大额交易
+	DeviceFarm      = "device_farm"      // 同一设备关联大量账户
+	GeoMismatch     = "geo_mismatch"     // 账单国家与 IP 国家不一致且 IP 位于高风险地区
+	Cashout         = "cashout"          // 新账户通过礼品卡/加密货币套现
+)
+
+// Patterns 全部欺诈模式，按报告顺序排列
+var Patterns = []string{CardTesting, AccountTakeover, VelocitySpike, NewAccountBurst, DeviceFarm, GeoMismatch, Cashout}
+
+// RuleSpec 与后端无关的规则：表达式只使用 expr 与 govaluate 的公共子集
+// （比较、&&、||、!、括号、双引号字符串），两个引擎都能直接 AddRule
+type RuleSpec struct {
+	ID      string
+	Expr    string
+	Intent  string // 规则意图
+	Pattern string // 规则针对的欺诈模式；空表示通用的策略/监控规则
+}
+
+var (
+	countries          = []interface{}{"US", "GB", "DE", "FR", "CN", "IN", "BR", "NG", "RU"}
+	lowRiskCountries   = countries[:6]
+	highRiskCountries  = []string{"BR", "NG", "RU"} // 与 countries 末尾三项一致
+	merchantCategories = []interface{}{"grocery", "electronics", "gift_cards", "travel", "gaming", "crypto"}
+	cashoutCategories  = []string{"gift_cards", "crypto"}
+	channels           = []interface{}{"web", "app", "pos"}
+)
+
+// FactorPool 规则包使用的扩展因子池，供静态检查（rule_expr.WithFactorPool）与工具展示
+func FactorPool() *rule_expr.FactorPool {
+	return &rule_expr.FactorPool{Factors: []rule_expr.FactorTemplate{
+		{Name: "amount", Kind: rule_expr.Int, Description: "本笔交易金额（元）", Example: 120},
+		{Name: "txn_count_1h", Kind: rule_expr.Int, Description: "账户近 1 小时交易笔数", Example: 1},
+		{Name: "txn_count_24h", Kind: rule_expr.Int, Description: "账户近 24 小时交易笔数", Example: 4},
+		{Name: "amount_sum_24h", Kind: rule_expr.Int, Description: "账户近 24 小时交易总额（元）", Example: 600},
+		{Name: "distinct_cards_24h", Kind: rule_expr.Int, Description: "账户近 24 小时使用的不同卡数", Example: 1},
+		{Name: "failed_logins_24h", Kind: rule_expr.Int, Description: "账户近 24 小时登录失败次数", Example: 0},
+		{Name: "device_accounts", Kind: rule_expr.Int, Description: "同一 device_id 关联的账户数", Example: 1},
+		{Name: "account_age_days", Kind: rule_expr.Int, Description: "账户注册天数", Example: 400},
+		{Name: "hour", Kind: rule_expr.Int, Description: "交易发生的小时（0-23，账户所在时区）", Example: 14},
+		{Name: "country", Kind: rule_expr.String, SampleValues: countries,
+			Description: "账户注册国家", Example: "US", Enumerated: true},
+		{Name: "billing_country", Kind: rule_expr.String, SampleValues: countries,
+			Description: "账单地址国家", Example: "US", Enumerated: true},
+		{Name: "ip_country", Kind: rule_expr.String, SampleValues: countries,
+			Description: "请求 IP 所在国家", Example: "US", Enumerated: true},
+		{Name: "merchant_category", Kind: rule_expr.String, SampleValues: merchantCategories,
+			Description: "商户类别", Example: "grocery", Enumerated: true},
+		{Name: "channel", Kind: rule_expr.String, SampleValues: channels,
+			Description: "交易渠道", Example: "app", Enumerated: true},
+		{Name: "is_new_device", Kind: rule_expr.Bool, Description: "是否首次在该设备上交易", Example: false},
+		{Name: "email_verified", Kind: rule_expr.Bool, Description: "邮箱是否已验证", Example: true},
+		{Name: "high_risk_ip", Kind: rule_expr.Bool, Description: "请求 IP 是否被标记为高风险", Example: false},
+		{Name: "blacklisted", Kind: rule_expr.Bool, Description: "用户是否在黑名单中", Example: false},
+		{Name: "is_vip", Kind: rule_expr.Bool, Description: "用户是否为 VIP", Example: false},
+	}}
+}
+
+// Rules 返回规则包中的全部规则（约 200 条），每次调用返回新切片，顺序固定。
+// 同一族规则只是阈值不同，对应真实系统中按风险等级分档的做法
+func Rules() []RuleSpec {
+	var b builder
+
+	// 盗卡试卡
+	for _, n := range []int{8, 10, 15, 20, 30} {
+		for _, a := range []int{5, 10, 20, 50} {
+			b.add(CardTesting, fmt.Sprintf("txn_count_1h >= %d && amount <= %d", n, a),
+				"1 小时内 %d 笔以上且单笔不超过 %d 元，疑似试卡", n, a)
+		}
+	}
+	for _, c := range []int{3, 4, 5, 8} {
+		for _, n := range []int{5, 10} {
+			b.add(CardTesting, fmt.Sprintf("distinct_cards_24h >= %d && txn_count_1h >= %d", c, n),
+				"24 小时内换用 %d 张以上卡且 1 小时内 %d 笔以上", c, n)
+		}
+	}
+	for _, c := range []int{3, 5} {
+		for _, a := range []int{10, 50} {
+			b.add(CardTesting, fmt.Sprintf("distinct_cards_24h >= %d && amount <= %d", c, a),
+				"多卡（%d 张以上）小额（不超过 %d 元）交易", c, a)
+		}
+	}
+
+	// 盗号
+	for _, f := range []int{3, 5, 8, 12} {
+		b.add(AccountTakeover, fmt.Sprintf("failed_logins_24h >= %d && is_new_device", f),
+			"登录失败 %d 次以上后在新设备交易", f)
+	}
+	for _, f := range []int{3, 5, 8} {
+		b.add(AccountTakeover, fmt.Sprintf("failed_logins_24h >= %d && ip_country != country", f),
+			"登录失败 %d 次以上且 IP 国家与注册国家不同", f)
+	}
+	for _, f := range []int{2, 4} {
+		b.add(AccountTakeover, fmt.Sprintf("is_new_device && !email_verified && failed_logins_24h >= %d", f),
+			"未验证邮箱的账户登录失败 %d 次以上后在新设备交易", f)
+	}
+	for _, f := range []int{3, 5} {
+		for _, a := range []int{100, 500, 1000} {
+			b.add(AccountTakeover, fmt.Sprintf("is_new_device && failed_logins_24h >= %d && amount >= %d", f, a),
+				"登录失败 %d 次以上后在新设备发起 %d 元以上交易", f, a)
+		}
+	}
+	for _, f := range []int{5, 10} {
+		b.add(AccountTakeover, fmt.Sprintf("failed_logins_24h >= %d && channel == \"web\"", f),
+			"网页渠道登录失败 %d 次以上", f)
+	}
+
+	// 频次/金额激增
+	for _, n := range []int{6, 10, 15, 25} {
+		b.add(VelocitySpike, fmt.Sprintf("txn_count_1h >= %d && amount > 50", n),
+			"1 小时内 %d 笔以上非小额交易", n)
+	}
+	for _, n := range []int{25, 40, 60, 100} {
+		b.add(VelocitySpike, fmt.Sprintf("txn_count_24h >= %d", n), "24 小时内 %d 笔以上交易", n)
+	}
+	for _, s := range []int{8000, 10000, 20000, 50000} {
+		b.add(VelocitySpike, fmt.Sprintf("amount_sum_24h >= %d", s), "24 小时交易总额达到 %d 元", s)
+	}
+	for _, n := range []int{25, 40} {
+		for _, s := range []int{5000, 10000, 20000} {
+			b.add(VelocitySpike, fmt.Sprintf("txn_count_24h >= %d && amount_sum_24h >= %d", n, s),
+				"24 小时内 %d 笔以上且总额达到 %d 元", n, s)
+		}
+	}
+
+	// 新账户大额
+	for _, d := range []int{1, 3, 7, 14, 30} {
+		for _, a := range []int{500, 1000, 2000, 5000} {
+			b.add(NewAccountBurst, fmt.Sprintf("account_age_days <= %d && amount >= %d", d, a),
+				"注册 %d 天内发起 %d 元以上交易", d, a)
+		}
+	}
+	for _, d := range []int{1, 7} {
+		for _, n := range []int{5, 10, 20} {
+			b.add(NewAccountBurst, fmt.Sprintf("account_age_days <= %d && txn_count_24h >= %d", d, n),
+				"注册 %d 天内 24 小时交易 %d 笔以上", d, n)
+		}
+	}
+	for _, d := range []int{3, 14} {
+		for _, a := range []int{200, 1000} {
+			b.add(NewAccountBurst, fmt.Sprintf("account_age_days <= %d && !email_verified && amount >= %d", d, a),
+				"注册 %d 天内、邮箱未验证且交易 %d 元以上", d, a)
+		}
+	}
+
+	// 设备农场
+	for _, k := range []int{4, 6, 10, 20} {
+		b.add(DeviceFarm, fmt.Sprintf("device_accounts >= %d", k), "同一设备关联 %d 个以上账户", k)
+	}
+	for _, k := range []int{3, 5} {
+		for _, d := range []int{7, 30} {
+			b.add(DeviceFarm, fmt.Sprintf("device_accounts >= %d && account_age_days <= %d", k, d),
+				"设备关联 %d 个以上账户且账户注册不足 %d 天", k, d)
+		}
+	}
+	for _, k := range []int{4, 8} {
+		for _, a := range []int{100, 1000} {
+			b.add(DeviceFarm, fmt.Sprintf("device_accounts >= %d && amount >= %d", k, a),
+				"设备关联 %d 个以上账户且交易 %d 元以上", k, a)
+		}
+	}
+
+	// 地理位置不一致
+	for _, c := range highRiskCountries {
+		b.add(GeoMismatch, fmt.Sprintf("ip_country == %q && billing_country != %q", c, c),
+			"IP 位于高风险国家 %s 而账单地址不在当地", c)
+	}
+	for _, c := range highRiskCountries {
+		for _, a := range []int{500, 2000} {
+			b.add(GeoMismatch, fmt.Sprintf("ip_country == %q && billing_country != ip_country && amount >= %d", c, a),
+				"IP 位于 %s、与账单国家不同且交易 %d 元以上", c, a)
+		}
+	}
+	for _, a := range []int{200, 500, 1000, 3000} {
+		b.add(GeoMismatch, fmt.Sprintf("billing_country != ip_country && high_risk_ip && amount >= %d", a),
+			"账单国家与 IP 国家不同、IP 高风险且交易 %d 元以上", a)
+	}
+	b.add(GeoMismatch, "billing_country != ip_country && country != ip_country && high_risk_ip",
+		"账单、注册国家均与 IP 国家不同且 IP 高风险")
+
+	// 套现
+	for _, c := range cashoutCategories {
+		for _, d := range []int{7, 30, 90} {
+			for _, a := range []int{200, 1000} {
+				b.add(Cashout, fmt.Sprintf("merchant_category == %q && account_age_days <= %d && amount >= %d", c, d, a),
+					"注册 %d 天内在 %s 类商户消费 %d 元以上", d, c, a)
+			}
+		}
+	}
+	for _, c := range cashoutCategories {
+		for _, a := range []int{500, 2000} {
+			b.add(Cashout, fmt.Sprintf("merchant_category == %q && is_new_device && amount >= %d", c, a),
+				"新设备在 %s 类商户消费 %d 元以上", c, a)
+		}
+	}
+
+	// 通用策略/监控规则：不针对特定模式，正常流量也会命中
+	for _, a := range []int{1000, 2000, 5000, 10000} {
+		b.add("", fmt.Sprintf("amount >= %d", a), "单笔 %d 元以上进入人工复核", a)
+	}
+	for _, a := range []int{300, 1000} {
+		b.add("", fmt.Sprintf("amount >= %d && !email_verified", a), "邮箱未验证的账户单笔 %d 元以上", a)
+	}
+	for _, a := range []int{300, 1000, 3000} {
+		b.add("", fmt.Sprintf("hour <= 4 && amount >= %d", a), "凌晨 0-4 点单笔 %d 元以上", a)
+	}
+	for _, ch := range channels {
+		for _, a := range []int{2000, 5000} {
+			b.add("", fmt.Sprintf("channel == %q && amount >= %d", ch, a), "%s 渠道单笔 %d 元以上", ch, a)
+		}
+	}
+	for _, mc := range merchantCategories {
+		for _, a := range []int{1000, 3000} {
+			b.add("", fmt.Sprintf("merchant_category == %q && amount >= %d", mc, a), "%s 类商户单笔 %d 元以上", mc, a)
+		}
+	}
+	for _, c := range countries {
+		for _, a := range []int{2000, 8000} {
+			b.add("", fmt.Sprintf("country == %q && amount >= %d", c, a), "注册国家为 %s 的账户单笔 %d 元以上", c, a)
+		}
+	}
+	for _, a := range []int{1000, 3000} {
+		for _, d := range []int{60, 180} {
+			b.add("", fmt.Sprintf("!is_vip && amount >= %d && account_age_days <= %d", a, d),
+				"非 VIP、注册 %d 天内单笔 %d 元以上", d, a)
+		}
+	}
+	b.add("", "blacklisted", "黑名单用户的任何交易")
+	for _, a := range []int{100, 500, 2000} {
+		b.add("", fmt.Sprintf("high_risk_ip && amount >= %d", a), "高风险 IP 单笔 %d 元以上", a)
+	}
+	b.add("", "high_risk_ip && is_new_device", "高风险 IP 且首次使用该设备")
+	for _, a := range []int{500, 1000, 3000, 8000} {
+		b.add("", fmt.Sprintf("is_new_device && amount >= %d", a), "新设备单笔 %d 元以上", a)
+	}
+	for _, n := range []int{5, 10, 20} {
+		b.add("", fmt.Sprintf("!email_verified && txn_count_24h >= %d", n), "邮箱未验证的账户 24 小时交易 %d 笔以上", n)
+	}
+	b.add("", "channel == \"pos\" && ip_country != country", "线下 POS 交易但 IP 国家与注册国家不同")
+	return b.specs
+}
+
+// Load 用 add（两个引擎的 AddRule 签名相同）加载全部规则，遇到第一条失败即返回
+func Load(add func(id, exprStr string) error) error {
+	for _, s := range Rules() {
+		if err := add(s.ID, s.Expr); err != nil {
+			return fmt.Errorf("加载规则 %s 失败: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+// builder 按模式编号：同一模式的规则 ID 依次为 <pattern>-001、<pattern>-002……，通用规则前缀为 policy
+type builder struct {
+	specs []RuleSpec
+	seq   map[string]int
+}
+
+func (b *builder) add(pattern, exprStr, intent string, args ...any) {
+	if b.seq == nil {
+		b.seq = make(map[string]int)
+	}
+	prefix := pattern
+	if prefix == "" {
+		prefix = "policy"
+	}
+	b.seq[prefix]++
+	b.specs = append(b.specs, RuleSpec{
+		ID:      fmt.Sprintf("%s-%03d", prefix, b.seq[prefix]),
+		Expr:    exprStr,
+		Intent:  fmt.Sprintf(intent, args...),
+		Pattern: pattern,
+	})
+}
@@ -0,0 +1,80 @@
+package rule_pack
+
+import (
+	"math"
+	"testing"
+
+	"goexprtester/rule_expr"
+	"goexprtester/rule_govaluate"
+)
+
+// TestRulesCompileInBothEngines 规则包只用两个引擎的公共子集：每条规则都能在 expr 与 govaluate 中编译，
+// 结果为 bool，且按规则包的因子池静态检查没有提示
+func TestRulesCompileInBothEngines(t *testing.T) {
+	ee := rule_expr.NewRuleEngine(rule_expr.WithFactorPool(FactorPool()))
+	ge := &rule_govaluate.RuleEngine{}
+	specs := Rules()
+	seen := make(map[string]bool, len(specs))
+	for _, s := range specs {
+		if seen[s.ID] {
+			t.Fatalf("规则 ID %s 重复", s.ID)
+		}
+		seen[s.ID] = true
+		if err := ee.AddRule(s.ID, s.Expr); err != nil {
+			t.Errorf("expr: %s: %v", s.ID, err)
+		}
+		parsed, err := rule_govaluate.Parse(s.Expr)
+		if err == nil {
+			err = rule_govaluate.CheckBool(parsed)
+		}
+		if err == nil {
+			err = ge.AddRule(s.ID, s.Expr)
+		}
+		if err != nil {
+			t.Errorf("govaluate: %s: %v", s.ID, err)
+		}
+	}
+	compiled := ee.SearchRules(rule_expr.RuleQuery{})
+	for _, r := range compiled {
+		if len(r.Warnings) > 0 {
+			t.Errorf("%s: %v", r.ID, r.Warnings)
+		}
+	}
+	if len(compiled) != len(specs) || ge.RuleCount() != len(specs) {
+		t.Fatalf("expr %d 条、govaluate %d 条，应为 %d 条", len(compiled), ge.RuleCount(), len(specs))
+	}
+}
+
+// TestFraudRate 注入比例接近配置的 fraudRate，各模式均匀分布；每条注入事件都被本模式的规则检出，
+// 正常事件上的模式规则误报很少，因此命中模式规则的事件比例也接近 fraudRate
+func TestFraudRate(t *testing.T) {
+	const n = 20000
+	ee := rule_expr.NewRuleEngine()
+	if err := Load(ee.AddRule); err != nil {
+		t.Fatal(err)
+	}
+	for _, rate := range []float64{0.02, 0.05, 0.2} {
+		events := GenEvents(1, n, rate)
+		rep := Detect(events, ee.Match)
+		// 二项分布 4 个标准差以内
+		tol := 4 * math.Sqrt(n*rate*(1-rate))
+		if injected := float64(n - rep.Benign); math.Abs(injected-n*rate) > tol {
+			t.Fatalf("fraudRate %.2f: 注入 %.0f 条，期望 %.0f±%.0f", rate, injected, n*rate, tol)
+		}
+		perPattern := n * rate / float64(len(Patterns))
+		flagged := 0
+		for _, d := range rep.Patterns {
+			if math.Abs(float64(d.Injected)-perPattern) > 4*math.Sqrt(perPattern) {
+				t.Errorf("fraudRate %.2f: %s 注入 %d 条，期望约 %.0f", rate, d.Pattern, d.Injected, perPattern)
+			}
+			if d.Caught != d.Injected {
+				t.Errorf("fraudRate %.2f: %s 检出 %d/%d", rate, d.Pattern, d.Caught, d.Injected)
+			}
+			flagged += d.Caught + d.FalseAlarms
+		}
+		// 一条正常事件可能误报多个模式，flagged 是上界
+		if falseRate := float64(flagged-(n-rep.Benign)) / n; falseRate > 0.01 {
+			t.Errorf("fraudRate %.2f: 模式规则在正常事件上的误报比例 %.4f 过高", rate, falseRate)
+		}
+	}
+}
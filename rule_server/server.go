@@ -0,0 +1,510 @@
+// Package rule_server 以 HTTP 接口提供规则的增删查与匹配，同时持有 expr 与 govaluate 两个引擎
+package rule_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+	"goexprtester/rule_govaluate"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---------- HTTP 服务 ---------- */
+
+// maxBodyBytes 请求体上限；POST /match/batch 的重放文件另按 maxBatchBytes 限制
+const (
+	maxBodyBytes  = 1 << 20
+	maxBatchBytes = 64 << 20
+)
+
+// ruleJSON 接口中规则的表示
+type ruleJSON struct {
+	ID             string `json:"id"`
+	Expr           string `json:"expr"`
+	AllowUndefined bool   `json:"allow_undefined,omitempty"` // 规则是否容忍缺失因子
+}
+
+// backend 服务持有的一个引擎；各操作直接转发给引擎
+type backend struct {
+	// add 加入规则，allowUndefined 对应请求的 allow_undefined；不支持该模式的引擎应返回错误
+	add    func(id, exprStr string, allowUndefined bool) error
+	remove func(id string) bool
+	list   func() []ruleJSON
+	match  func(map[string]interface{}) []string
+	// matchBatch 逐条匹配并按规则倒排：规则 ID -> 命中的输入下标
+	matchBatch func([]map[string]interface{}) map[string][]int
+	// expr 为 expr 引擎本身，检索、schema、输入校验与最近匹配只有它支持；其他引擎为 nil
+	expr *rule_expr.RuleEngine
+}
+
+// Options 服务的可选行为，零值即全部关闭
+type Options struct {
+	Validate bool // expr 引擎的 POST /match 先按因子池校验输入，不符时返回 422
+	Redact   bool // 错误响应与 /debug/recent 中的输入值经 HashBucketRedactor 脱敏
+	Recent   int  // expr 引擎保留的最近匹配条数（含输入），0 时 /debug/recent 不可用
+}
+
+// redactBucket 开启 Redact 时数值脱敏的分桶宽度
+const redactBucket = 10
+
+// Server 同时持有 expr 与 govaluate 两个引擎，请求通过 ?engine= 选择，缺省为 def。
+// 两个引擎的规则集相互独立
+type Server struct {
+	backends map[string]backend
+	def      string
+	opts     Options
+	pool     *rule_engine.FactorPool
+}
+
+// New 创建服务，def 为未带 ?engine= 时使用的引擎（expr / govaluate）
+func New(def string, opts ...Options) (*Server, error) {
+	var opt Options
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	ee := rule_expr.NewRuleEngine()
+	if opt.Redact {
+		ee.SetRedactor(rule_expr.HashBucketRedactor(redactBucket))
+	}
+	ee.SetRecentMatches(opt.Recent, rule_expr.RecentOptions{KeepInputs: true})
+	ge := &rule_govaluate.RuleEngine{}
+	s := &Server{def: def, opts: opt, pool: rule_expr.DefaultFactorPool(), backends: map[string]backend{
+		"expr": {
+			expr:   ee,
+			add:    ee.AddRuleWithUndefined,
+			remove: ee.RemoveRule,
+			list: func() []ruleJSON {
+				var out []ruleJSON
+				for _, r := range ee.ListRules() {
+					out = append(out, ruleJSON{r.ID, r.Expr, r.AllowUndefined})
+				}
+				return out
+			},
+			match:      ee.Match,
+			matchBatch: ee.MatchBatchByRule,
+		},
+		"govaluate": {
+			// govaluate 解析时不检查结果类型，先用 CheckBool 拒绝明显不是 bool 的表达式
+			add: func(id, exprStr string, allowUndefined bool) error {
+				e, err := rule_govaluate.Parse(exprStr)
+				if err != nil {
+					return err
+				}
+				if err := rule_govaluate.CheckBool(e); err != nil {
+					return err
+				}
+				return ge.AddRuleWithUndefined(id, exprStr, allowUndefined)
+			},
+			remove: ge.RemoveRule,
+			list: func() []ruleJSON {
+				var out []ruleJSON
+				for _, r := range ge.ListRules() {
+					out = append(out, ruleJSON{r.ID, r.Expr, r.AllowUndefined})
+				}
+				return out
+			},
+			// 请求体中的嵌套对象展平为点路径键，供 [user.country] 这样的引用取值
+			match: func(input map[string]interface{}) []string {
+				return ge.Match(rule_engine.AdaptInputs(rule_govaluate.Syntax, []map[string]interface{}{input})[0])
+			},
+			matchBatch: func(inputs []map[string]interface{}) map[string][]int {
+				perInput := make([][]string, len(inputs))
+				for i, in := range rule_engine.AdaptInputs(rule_govaluate.Syntax, inputs) {
+					perInput[i] = ge.Match(in)
+				}
+				return rule_expr.InvertHits(perInput)
+			},
+		},
+	}}
+	if _, ok := s.backends[def]; !ok {
+		return nil, fmt.Errorf("未知引擎 %q，可选 expr / govaluate", def)
+	}
+	return s, nil
+}
+
+// Handler 返回服务的路由：
+//
+//	POST   /rules          加入或替换规则，请求体 {"id": ..., "expr": ..., "allow_undefined": false}
+//	DELETE /rules/{id}     删除规则
+//	GET    /rules          按 ID 排序列出规则；带 var / op / contains / regex 参数时只列出满足全部条件的规则（仅 expr）
+//	POST   /match          请求体为输入对象，返回 {"hits": [...]}；开启校验时不符合因子池的输入返回 422（仅 expr）
+//	POST   /match/batch    请求体为重放文件（每行一个 JSON 对象），返回 {"inputs": n, "by_rule": {规则 ID: [命中的行下标]}}
+//	POST   /probe          请求体 {"expr": ...}，返回边界输入探测结果表，见 rule_expr.ProbeRule
+//	GET    /factors        因子池及各因子的说明与示例，格式与 -factors 文件相同
+//	GET    /schema         POST /match 请求体的 JSON Schema，ETag 随规则集版本变化（仅 expr）
+//	GET    /debug/recent   最近的匹配记录，从旧到新（仅 expr，需开启 Recent）
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /rules", s.handleAdd)
+	mux.HandleFunc("DELETE /rules/{id}", s.handleRemove)
+	mux.HandleFunc("GET /rules", s.handleList)
+	mux.HandleFunc("POST /match", s.handleMatch)
+	mux.HandleFunc("POST /match/batch", s.handleMatchBatch)
+	mux.HandleFunc("POST /probe", s.handleProbe)
+	mux.HandleFunc("GET /factors", s.handleFactors)
+	mux.HandleFunc("GET /schema", s.handleSchema)
+	mux.HandleFunc("GET /debug/recent", s.handleRecent)
+	return mux
+}
+
+// backend 按 ?engine= 取引擎；未知时写出 400 并返回 false
+func (s *Server) backend(w http.ResponseWriter, r *http.Request) (backend, bool) {
+	name := r.URL.Query().Get("engine")
+	if name == "" {
+		name = s.def
+	}
+	b, ok := s.backends[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("未知引擎 %q，可选 expr / govaluate", name))
+	}
+	return b, ok
+}
+
+// exprBackend 同 backend，但所选引擎须为 expr；否则写出 400 并返回 nil
+func (s *Server) exprBackend(w http.ResponseWriter, r *http.Request, what string) *rule_expr.RuleEngine {
+	b, ok := s.backend(w, r)
+	if !ok {
+		return nil
+	}
+	if b.expr == nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%s 只支持 expr 引擎", what))
+	}
+	return b.expr
+}
+
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.backend(w, r)
+	if !ok {
+		return
+	}
+	var rule ruleJSON
+	if err := decodeBody(w, r, &rule); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(rule.ID) == "" || strings.TrimSpace(rule.Expr) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("id 与 expr 不能为空"))
+		return
+	}
+	if err := b.add(rule.ID, rule.Expr, rule.AllowUndefined); err != nil {
+		// 解析、编译失败、结果不是 bool 与引擎不支持 allow_undefined 都是请求本身的问题
+		writeError(w, http.StatusBadRequest, fmt.Errorf("编译规则 %s 失败: %w", rule.ID, err))
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (s *Server) handleRemove(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.backend(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	if !b.remove(id) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("规则 %s 不存在", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if slices.ContainsFunc(searchParams, q.Has) {
+		s.handleSearch(w, r)
+		return
+	}
+	b, ok := s.backend(w, r)
+	if !ok {
+		return
+	}
+	rules := b.list()
+	if rules == nil {
+		rules = []ruleJSON{}
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *Server) handleMatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.backend(w, r)
+	if !ok {
+		return
+	}
+	var input map[string]interface{}
+	if err := decodeBody(w, r, &input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if input == nil {
+		writeError(w, http.StatusBadRequest, errors.New("输入必须是 JSON 对象"))
+		return
+	}
+	if s.opts.Validate && b.expr != nil {
+		if err := b.expr.ValidateInput(input); err != nil {
+			writeInputError(w, err)
+			return
+		}
+	}
+	hits := b.match(input)
+	if hits == nil {
+		hits = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"hits": hits})
+}
+
+// batchJSON /match/batch 的响应；下标从 0 起，按非空行计数，与 -replay 读入的顺序一致
+type batchJSON struct {
+	Inputs int              `json:"inputs"`
+	ByRule map[string][]int `json:"by_rule"`
+}
+
+func (s *Server) handleMatchBatch(w http.ResponseWriter, r *http.Request) {
+	b, ok := s.backend(w, r)
+	if !ok {
+		return
+	}
+	inputs, err := decodeLines(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if s.opts.Validate && b.expr != nil {
+		for i, in := range inputs {
+			if err := b.expr.ValidateInput(in); err != nil {
+				writeInputError(w, fmt.Errorf("输入 %d: %w", i, err))
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, batchJSON{Inputs: len(inputs), ByRule: b.matchBatch(inputs)})
+}
+
+// searchParams GET /rules 的检索参数，带任一参数时按 handleSearch 处理
+var searchParams = []string{"var", "op", "tag", "contains", "regex", "min_priority", "max_priority"}
+
+// handleSearch GET /rules 带检索参数时的处理；var、op 与 tag 可重复，全部条件按 AND 组合
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	ee := s.exprBackend(w, r, "规则检索")
+	if ee == nil {
+		return
+	}
+	params := r.URL.Query()
+	q := rule_expr.RuleQuery{Contains: params.Get("contains"), Vars: params["var"], Ops: params["op"], Tags: params["tag"]}
+	for name, dst := range map[string]**int{"min_priority": &q.MinPriority, "max_priority": &q.MaxPriority} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("%s 参数不合法: %w", name, err))
+				return
+			}
+			*dst = &n
+		}
+	}
+	if pattern := params.Get("regex"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("regex 参数不合法: %w", err))
+			return
+		}
+		q.Regex = re
+	}
+	rules := []ruleJSON{}
+	for _, rule := range ee.SearchRules(q) {
+		rules = append(rules, ruleJSON{rule.ID, rule.ExprStr, rule.AllowUndefined})
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// probeJSON /probe 的响应
+type probeJSON struct {
+	Expr     string            `json:"expr"`
+	Probes   []probeResultJSON `json:"probes"`
+	Errors   int               `json:"errors"` // 执行出错的探测数
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+type probeResultJSON struct {
+	Label  string                 `json:"label"`
+	Input  map[string]interface{} `json:"input"`
+	Result bool                   `json:"result"`
+	Err    string                 `json:"error,omitempty"`
+}
+
+// handleProbe 探测输入由规则常量与因子池样例值合成，不含调用方数据，与所选引擎无关
+func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Expr string `json:"expr"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Expr) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("expr 不能为空"))
+		return
+	}
+	report := rule_expr.ProbeRule(req.Expr, s.pool)
+	if report.CompileErr != "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("编译失败: %s", report.CompileErr))
+		return
+	}
+	out := probeJSON{Expr: report.Expr, Errors: report.Errors, Warnings: report.Warnings, Probes: []probeResultJSON{}}
+	for _, p := range report.Probes {
+		out.Probes = append(out.Probes, probeResultJSON{p.Label, jsonSafeRow(p.Input), p.Result, p.Err})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// jsonSafeRow 把 JSON 无法表示的 ±Inf 与 NaN（浮点极值探测）替换为 "+Inf" 等字符串，嵌套 map 逐层处理
+func jsonSafeRow(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		switch x := v.(type) {
+		case float64:
+			if math.IsInf(x, 0) || math.IsNaN(x) {
+				v = strconv.FormatFloat(x, 'g', -1, 64)
+			}
+		case map[string]interface{}:
+			v = jsonSafeRow(x)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func (s *Server) handleFactors(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.pool)
+}
+
+// handleSchema 以规则集版本为 ETag，客户端可用 If-None-Match 避免重复下载
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	ee := s.exprBackend(w, r, "/schema")
+	if ee == nil {
+		return
+	}
+	gen := ee.Generation()
+	doc, err := ee.InputJSONSchema()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	etag := `"` + strconv.FormatUint(gen, 10) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(doc)
+}
+
+// recentJSON /debug/recent 中的一条记录；输入已经过引擎的脱敏钩子
+type recentJSON struct {
+	Seq         uint64                 `json:"seq"`
+	At          time.Time              `json:"at"`
+	Fingerprint string                 `json:"fingerprint"`
+	Input       map[string]interface{} `json:"input,omitempty"`
+	Hits        []string               `json:"hits"`
+	DurationNS  int64                  `json:"duration_ns"`
+	Generation  uint64                 `json:"generation"`
+}
+
+func (s *Server) handleRecent(w http.ResponseWriter, r *http.Request) {
+	ee := s.exprBackend(w, r, "/debug/recent")
+	if ee == nil {
+		return
+	}
+	if s.opts.Recent <= 0 {
+		writeError(w, http.StatusNotFound, errors.New("未开启最近匹配记录（Options.Recent）"))
+		return
+	}
+	out := []recentJSON{}
+	for _, m := range ee.RecentMatches() {
+		hits := m.Hits
+		if hits == nil {
+			hits = []string{}
+		}
+		out = append(out, recentJSON{m.Seq, m.At, hex.EncodeToString(m.Fingerprint[:]), m.Input, hits,
+			m.Duration.Nanoseconds(), m.Generation})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// violationJSON 422 响应中的一处违规
+type violationJSON struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// writeInputError 输入不符合因子池时写出 422 与逐项违规；消息中的输入值已经过引擎的脱敏钩子
+func writeInputError(w http.ResponseWriter, err error) {
+	var ie *rule_expr.InputError
+	if !errors.As(err, &ie) {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	violations := make([]violationJSON, len(ie.Violations))
+	for i, v := range ie.Violations {
+		violations[i] = violationJSON{v.Pointer, v.Message}
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"error": err.Error(), "violations": violations})
+}
+
+// decodeBody 把请求体解析为一个 JSON 值，拒绝过大的请求体与多余内容
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("解析请求体失败: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("解析请求体失败: 请求体只能包含一个 JSON 值")
+	}
+	return nil
+}
+
+// decodeLines 把请求体按重放文件格式解析：每行一个 JSON 对象，跳过空行，拒绝过大的请求体
+func decodeLines(w http.ResponseWriter, r *http.Request) ([]map[string]interface{}, error) {
+	var inputs []map[string]interface{}
+	sc := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	sc.Buffer(make([]byte, 64*1024), maxBodyBytes)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var in map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("解析请求体失败: 第 %d 行: %w", line, err)
+		}
+		if in == nil {
+			return nil, fmt.Errorf("解析请求体失败: 第 %d 行: 输入必须是 JSON 对象", line)
+		}
+		inputs = append(inputs, in)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("解析请求体失败: %w", err)
+	}
+	return inputs, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // 表达式里的 && 原样输出
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,400 @@
+package rule_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"goexprtester/rule_engine"
+)
+
+// call 向 h 发出请求，返回状态码与响应体
+func call(t *testing.T, h http.Handler, method, target, body string, header ...string) (int, string, http.Header) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String(), rec.Header()
+}
+
+func newTestServer(t *testing.T, opts ...Options) http.Handler {
+	t.Helper()
+	s, err := New("expr", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Handler()
+}
+
+func decode(t *testing.T, body string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		t.Fatalf("响应不是合法 JSON: %v\n%s", err, body)
+	}
+}
+
+func TestServeRuleLifecycle(t *testing.T) {
+	h := newTestServer(t)
+	for _, engine := range []string{"expr", "govaluate"} {
+		t.Run(engine, func(t *testing.T) {
+			q := "?engine=" + engine
+			if code, body, _ := call(t, h, "POST", "/rules"+q, `{"id":"vip","expr":"is_vip"}`); code != http.StatusCreated {
+				t.Fatalf("POST /rules = %d %s", code, body)
+			}
+			call(t, h, "POST", "/rules"+q, `{"id":"prod","expr":"env == 'prod'"}`)
+
+			code, body, _ := call(t, h, "GET", "/rules"+q, "")
+			var rules []ruleJSON
+			decode(t, body, &rules)
+			if code != http.StatusOK || len(rules) != 2 || rules[0].ID != "prod" || rules[1].ID != "vip" {
+				t.Fatalf("GET /rules = %d %s", code, body)
+			}
+
+			code, body, _ = call(t, h, "POST", "/match"+q, `{"is_vip": true, "env": "prod"}`)
+			var res map[string][]string
+			decode(t, body, &res)
+			slices.Sort(res["hits"])
+			if code != http.StatusOK || !slices.Equal(res["hits"], []string{"prod", "vip"}) {
+				t.Fatalf("POST /match = %d %s", code, body)
+			}
+
+			if code, _, _ := call(t, h, "DELETE", "/rules/vip"+q, ""); code != http.StatusNoContent {
+				t.Fatalf("DELETE = %d", code)
+			}
+			if code, _, _ := call(t, h, "DELETE", "/rules/vip"+q, ""); code != http.StatusNotFound {
+				t.Fatalf("重复 DELETE = %d，应为 404", code)
+			}
+			_, body, _ = call(t, h, "POST", "/match"+q, `{"is_vip": true, "env": "staging"}`)
+			if !strings.Contains(body, `"hits":[]`) {
+				t.Fatalf("无命中时应返回空数组: %s", body)
+			}
+		})
+	}
+}
+
+func TestServeRejectsBadRequests(t *testing.T) {
+	h := newTestServer(t)
+	cases := []struct {
+		name, method, target, body, want string
+	}{
+		{"expr 非 bool", "POST", "/rules", `{"id":"a","expr":"amount + 1"}`, "编译规则 a 失败"},
+		{"govaluate 非 bool", "POST", "/rules?engine=govaluate", `{"id":"a","expr":"amount + 1"}`, "编译规则 a 失败"},
+		{"语法错误", "POST", "/rules", `{"id":"a","expr":"is_vip and ("}`, "编译规则 a 失败"},
+		{"缺少字段", "POST", "/rules", `{"id":"a"}`, "不能为空"},
+		{"多余内容", "POST", "/rules", `{"id":"a","expr":"is_vip"} {}`, "只能包含一个 JSON 值"},
+		{"输入不是对象", "POST", "/match", `[1]`, "解析请求体失败"},
+		{"输入为 null", "POST", "/match", `null`, "必须是 JSON 对象"},
+		{"未知引擎", "GET", "/rules?engine=cel", ``, "未知引擎"},
+		{"检索不支持 govaluate", "GET", "/rules?engine=govaluate&var=env", ``, "只支持 expr"},
+		{"正则不合法", "GET", "/rules?regex=(", ``, "regex 参数不合法"},
+	}
+	for _, c := range cases {
+		code, body, _ := call(t, h, c.method, c.target, c.body)
+		if code != http.StatusBadRequest || !strings.Contains(body, c.want) {
+			t.Errorf("%s: %d %s，应为 400 且包含 %q", c.name, code, body, c.want)
+		}
+	}
+}
+
+func TestServeSearch(t *testing.T) {
+	h := newTestServer(t)
+	for id, expr := range map[string]string{
+		"paypal":  `payment_method == "PAYPAL" and amount > 100`,
+		"pattern": `payment_method matches "^P"`,
+		"env":     `env == "prod"`,
+	} {
+		call(t, h, "POST", "/rules", `{"id":"`+id+`","expr":`+strconvQuote(expr)+`}`)
+	}
+	ids := func(target string) []string {
+		code, body, _ := call(t, h, "GET", target, "")
+		if code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, code, body)
+		}
+		var rules []ruleJSON
+		decode(t, body, &rules)
+		out := []string{}
+		for _, r := range rules {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	if got := ids("/rules?var=payment_method"); !slices.Equal(got, []string{"pattern", "paypal"}) {
+		t.Errorf("var=payment_method: %v", got)
+	}
+	if got := ids("/rules?var=payment_method&op=matches"); !slices.Equal(got, []string{"pattern"}) {
+		t.Errorf("var + op 组合: %v", got)
+	}
+	if got := ids("/rules?var=payment_method&var=amount&op=and"); !slices.Equal(got, []string{"paypal"}) {
+		t.Errorf("多个 var: %v", got)
+	}
+	if _, body, _ := call(t, h, "GET", "/rules?var=env&op=matches", ""); strings.TrimSpace(body) != "[]" {
+		t.Errorf("无结果时应返回空数组: %s", body)
+	}
+	if got := ids("/rules?contains=PAYPAL"); !slices.Equal(got, []string{"paypal"}) {
+		t.Errorf("contains: %v", got)
+	}
+	if got := ids("/rules?var=payment_method&max_priority=0&min_priority=0"); !slices.Equal(got, []string{"pattern", "paypal"}) {
+		t.Errorf("优先级范围: %v", got)
+	}
+	if got := ids("/rules?var=payment_method&tag=risk"); len(got) != 0 {
+		t.Errorf("没有规则带 risk 标签: %v", got)
+	}
+	if code, _, _ := call(t, h, "GET", "/rules?min_priority=high", ""); code != http.StatusBadRequest {
+		t.Errorf("优先级参数不合法时应返回 400，实际 %d", code)
+	}
+}
+
+func strconvQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// TestServeProbe 三值枚举的等值规则：探测覆盖全部三个取值与缺失，执行出错的探测被标出
+func TestServeProbe(t *testing.T) {
+	h := newTestServer(t)
+	code, body, _ := call(t, h, "POST", "/probe", `{"expr":"amount > 100 or env == \"prod\""}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST /probe = %d %s", code, body)
+	}
+	var rep probeJSON
+	decode(t, body, &rep)
+	labels := map[string]probeResultJSON{}
+	for _, p := range rep.Probes {
+		labels[p.Label] = p
+	}
+	for _, want := range []string{`env="prod"`, `env="staging"`, `env="test_env"`, "env 缺失"} {
+		if _, ok := labels[want]; !ok {
+			t.Errorf("缺少探测 %s", want)
+		}
+	}
+	// 基准输入的 amount 不超过 100，结果只取决于 env
+	if !labels[`env="prod"`].Result || labels[`env="staging"`].Result || labels[`env="test_env"`].Result {
+		t.Errorf("枚举探测结果不对: %+v", rep.Probes)
+	}
+	if p := labels["amount 缺失"]; p.Err == "" {
+		t.Errorf("amount 缺失时应执行出错并标出: %+v", p)
+	}
+	if rep.Errors == 0 {
+		t.Error("errors 应统计执行出错的探测")
+	}
+	if !strings.Contains(body, `"+Inf"`) {
+		t.Error("浮点极值探测应以字符串表示")
+	}
+
+	if code, body, _ := call(t, h, "POST", "/probe", `{"expr":"is_vip and ("}`); code != http.StatusBadRequest || !strings.Contains(body, "编译失败") {
+		t.Errorf("编译失败时 = %d %s", code, body)
+	}
+}
+
+// TestServeFactorsRoundTrip /factors 的响应能按因子池文件解析回来，说明与示例不丢失
+func TestServeFactorsRoundTrip(t *testing.T) {
+	h := newTestServer(t)
+	code, body, _ := call(t, h, "GET", "/factors", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET /factors = %d", code)
+	}
+	pool, err := rule_engine.ParseFactorPool([]byte(body), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := rule_engine.DefaultFactorPool()
+	if len(pool.Factors) != len(want.Factors) {
+		t.Fatalf("因子数 %d，应为 %d", len(pool.Factors), len(want.Factors))
+	}
+	for i, f := range pool.Factors {
+		if f.Name != want.Factors[i].Name || f.Description != want.Factors[i].Description || f.Description == "" {
+			t.Errorf("因子 %s 的说明 %q，应为 %q", f.Name, f.Description, want.Factors[i].Description)
+		}
+		if f.Example == nil {
+			t.Errorf("因子 %s 缺少示例", f.Name)
+		}
+	}
+}
+
+func TestServeSchemaETag(t *testing.T) {
+	h := newTestServer(t)
+	code, body, header := call(t, h, "GET", "/schema", "")
+	etag := header.Get("ETag")
+	if code != http.StatusOK || etag == "" || !strings.Contains(body, "2020-12") {
+		t.Fatalf("GET /schema = %d ETag=%q", code, etag)
+	}
+	if code, _, _ := call(t, h, "GET", "/schema", "", "If-None-Match", etag); code != http.StatusNotModified {
+		t.Fatalf("ETag 未变时应返回 304，实际 %d", code)
+	}
+	call(t, h, "POST", "/rules", `{"id":"vip","expr":"is_vip"}`)
+	code, body, header = call(t, h, "GET", "/schema", "", "If-None-Match", etag)
+	if code != http.StatusOK || header.Get("ETag") == etag {
+		t.Fatalf("规则变更后 ETag 应变化: %d %q", code, header.Get("ETag"))
+	}
+	var doc map[string]interface{}
+	decode(t, body, &doc)
+	if !reflect.DeepEqual(doc["required"], []interface{}{"is_vip"}) {
+		t.Errorf("required = %v，应为 [is_vip]", doc["required"])
+	}
+	if code, _, _ := call(t, h, "GET", "/schema?engine=govaluate", ""); code != http.StatusBadRequest {
+		t.Errorf("govaluate 的 /schema 应返回 400，实际 %d", code)
+	}
+}
+
+func TestServeValidateReturns422(t *testing.T) {
+	h := newTestServer(t, Options{Validate: true})
+	call(t, h, "POST", "/rules", `{"id":"prod","expr":"env == 'prod' and user_id > 0"}`)
+	code, body, _ := call(t, h, "POST", "/match", `{"env": 5}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("不合法输入 = %d %s，应为 422", code, body)
+	}
+	var res struct {
+		Violations []violationJSON `json:"violations"`
+	}
+	decode(t, body, &res)
+	want := []violationJSON{{"/env", "应为 string，实际为 float64"}, {"/user_id", "缺少必填字段"}}
+	if !reflect.DeepEqual(res.Violations, want) {
+		t.Fatalf("违规项 = %+v", res.Violations)
+	}
+	if code, body, _ := call(t, h, "POST", "/match", `{"env": "prod", "user_id": 1}`); code != http.StatusOK || !strings.Contains(body, "prod") {
+		t.Fatalf("合法输入 = %d %s", code, body)
+	}
+	// 未开启校验时照常匹配
+	h = newTestServer(t)
+	if code, _, _ := call(t, h, "POST", "/match", `{"env": 5}`); code != http.StatusOK {
+		t.Fatalf("未开启校验时 = %d", code)
+	}
+}
+
+const secretValue = "4111-1111-1111-1111"
+
+// TestServeRedactsErrorBodies 开启脱敏时 422 响应不含原始输入值
+func TestServeRedactsErrorBodies(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		h := newTestServer(t, Options{Validate: true, Redact: redact})
+		code, body, _ := call(t, h, "POST", "/match", `{"env": "`+secretValue+`"}`)
+		if code != http.StatusUnprocessableEntity {
+			t.Fatalf("取值域之外的输入 = %d %s", code, body)
+		}
+		if leaked := strings.Contains(body, secretValue); leaked == redact {
+			t.Errorf("redact=%v 时响应包含原始值 = %v: %s", redact, leaked, body)
+		}
+	}
+}
+
+func TestServeRecentMatches(t *testing.T) {
+	h := newTestServer(t)
+	if code, _, _ := call(t, h, "GET", "/debug/recent", ""); code != http.StatusNotFound {
+		t.Fatalf("未开启时应返回 404，实际 %d", code)
+	}
+
+	h = newTestServer(t, Options{Recent: 2, Redact: true})
+	call(t, h, "POST", "/rules", `{"id":"vip","expr":"is_vip"}`)
+	for _, in := range []string{`{"is_vip": false}`, `{"is_vip": true, "card": "` + secretValue + `"}`, `{"is_vip": true}`} {
+		call(t, h, "POST", "/match", in)
+	}
+	code, body, _ := call(t, h, "GET", "/debug/recent", "")
+	var recent []recentJSON
+	decode(t, body, &recent)
+	if code != http.StatusOK || len(recent) != 2 || recent[0].Seq != 1 || recent[1].Seq != 2 {
+		t.Fatalf("GET /debug/recent = %d %s", code, body)
+	}
+	if !slices.Equal(recent[1].Hits, []string{"vip"}) || len(recent[0].Fingerprint) != 32 || recent[0].Input == nil {
+		t.Errorf("记录内容不对: %+v", recent)
+	}
+	if strings.Contains(body, secretValue) {
+		t.Errorf("最近匹配记录应经过脱敏: %s", body)
+	}
+}
+
+// TestServeAllowUndefined allow_undefined 随规则保存；缺少因子的输入只命中容忍缺失的规则
+func TestServeAllowUndefined(t *testing.T) {
+	h := newTestServer(t)
+	for _, engine := range []string{"expr", "govaluate"} {
+		t.Run(engine, func(t *testing.T) {
+			q := "?engine=" + engine
+			if code, body, _ := call(t, h, "POST", "/rules"+q, `{"id":"lenient","expr":"is_vip || amount > 100","allow_undefined":true}`); code != http.StatusCreated || !strings.Contains(body, `"allow_undefined":true`) {
+				t.Fatalf("POST /rules = %d %s", code, body)
+			}
+			call(t, h, "POST", "/rules"+q, `{"id":"strict","expr":"is_vip || amount > 100"}`)
+
+			_, body, _ := call(t, h, "GET", "/rules"+q, "")
+			var rules []ruleJSON
+			decode(t, body, &rules)
+			if want := []ruleJSON{{"lenient", "is_vip || amount > 100", true}, {"strict", "is_vip || amount > 100", false}}; !reflect.DeepEqual(rules, want) {
+				t.Fatalf("GET /rules = %s", body)
+			}
+
+			code, body, _ := call(t, h, "POST", "/match"+q, `{"amount": 500}`)
+			var res map[string][]string
+			decode(t, body, &res)
+			if code != http.StatusOK || !slices.Equal(res["hits"], []string{"lenient"}) {
+				t.Fatalf("缺少 is_vip 的输入 = %d %s，应只命中 lenient", code, body)
+			}
+		})
+	}
+
+	// 不支持 allow_undefined 的引擎返回 400
+	s, err := New("expr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.backends["strict"] = backend{add: func(id, exprStr string, allowUndefined bool) error {
+		if allowUndefined {
+			return errors.New("不支持 allow_undefined")
+		}
+		return nil
+	}}
+	code, body, _ := call(t, s.Handler(), "POST", "/rules?engine=strict", `{"id":"a","expr":"is_vip","allow_undefined":true}`)
+	if code != http.StatusBadRequest || !strings.Contains(body, "不支持 allow_undefined") {
+		t.Fatalf("= %d %s，应为 400", code, body)
+	}
+}
+
+// TestServeMatchBatch 重放文件按规则倒排，与逐条 POST /match 的结果一致；空行不计下标，坏行返回 400
+func TestServeMatchBatch(t *testing.T) {
+	h := newTestServer(t, Options{Validate: true})
+	lines := []string{`{"is_vip": true, "env": "prod"}`, `{"is_vip": false, "env": "staging"}`, `{"is_vip": true, "env": "staging"}`, `{"is_vip": false, "env": "prod"}`}
+	replay := strings.Join(lines[:2], "\n") + "\n\n" + strings.Join(lines[2:], "\n") + "\n"
+	for _, engine := range []string{"expr", "govaluate"} {
+		q := "?engine=" + engine
+		call(t, h, "POST", "/rules"+q, `{"id":"vip","expr":"is_vip"}`)
+		call(t, h, "POST", "/rules"+q, `{"id":"prod","expr":"env == 'prod'"}`)
+		call(t, h, "POST", "/rules"+q, `{"id":"never","expr":"env == 'nowhere'"}`)
+
+		want := make(map[string][]int)
+		for i, line := range lines {
+			var res map[string][]string
+			_, body, _ := call(t, h, "POST", "/match"+q, line)
+			decode(t, body, &res)
+			for _, id := range res["hits"] {
+				want[id] = append(want[id], i)
+			}
+		}
+		code, body, _ := call(t, h, "POST", "/match/batch"+q, replay)
+		var got batchJSON
+		decode(t, body, &got)
+		if code != http.StatusOK || got.Inputs != 4 || !reflect.DeepEqual(got.ByRule, want) {
+			t.Fatalf("%s: %d %s，应为 %v", engine, code, body, want)
+		}
+		if !reflect.DeepEqual(want, map[string][]int{"vip": {0, 2}, "prod": {0, 3}}) {
+			t.Fatalf("%s: 逐条匹配 %v", engine, want)
+		}
+	}
+
+	if code, body, _ := call(t, h, "POST", "/match/batch", "{\"env\": \"prod\"}\n[1]\n"); code != http.StatusBadRequest || !strings.Contains(body, "第 2 行") {
+		t.Fatalf("坏行: %d %s", code, body)
+	}
+	if code, body, _ := call(t, h, "POST", "/match/batch", "null\n"); code != http.StatusBadRequest || !strings.Contains(body, "必须是 JSON 对象") {
+		t.Fatalf("null: %d %s", code, body)
+	}
+	if code, body, _ := call(t, h, "POST", "/match/batch", lines[0]+"\n"+`{"is_vip": true, "env": 5}`); code != http.StatusUnprocessableEntity || !strings.Contains(body, "输入 1: ") {
+		t.Fatalf("校验失败: %d %s", code, body)
+	}
+	if code, body, _ := call(t, h, "POST", "/match/batch", ""); code != http.StatusOK || !strings.Contains(body, `"by_rule":{}`) {
+		t.Fatalf("空文件: %d %s", code, body)
+	}
+}
@@ -0,0 +1,139 @@
+package rule_store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"goexprtester/rule_expr"
+
+	_ "modernc.org/sqlite"
+)
+
+/* ---------- SQLite 规则存储 ---------- */
+
+// migrations 按版本顺序执行，migrations[i] 把库从版本 i 升到 i+1
+var migrations = []string{
+	`CREATE TABLE rules (
+		id   TEXT PRIMARY KEY,
+		expr TEXT NOT NULL
+	)`,
+	// v2 规则元数据；tags 以逗号连接（标签本身不含逗号），allow_undefined 为 NULL 时随引擎默认值
+	`ALTER TABLE rules ADD COLUMN flag TEXT NOT NULL DEFAULT '';
+	ALTER TABLE rules ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE rules ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+	ALTER TABLE rules ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE rules ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE rules ADD COLUMN allow_undefined INTEGER`,
+}
+
+// SQLiteStore 基于 SQLite 的 RuleStore，单连接写入，WAL 模式
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore 打开（或创建）path 处的规则库并执行未完成的迁移
+func OpenSQLiteStore(path string) (rule_expr.RuleStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // 单写者
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("开启 WAL 失败: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// migrate 读取 schema_version 并依次执行剩余迁移
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("创建版本表失败: %w", err)
+	}
+	var version int
+	err := db.QueryRow(`SELECT version FROM schema_version`).Scan(&version)
+	if err == sql.ErrNoRows {
+		if _, err := db.Exec(`INSERT INTO schema_version (version) VALUES (0)`); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("读取版本失败: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的 %d", version, len(migrations))
+	}
+	for v := version; v < len(migrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("迁移到版本 %d 失败: %w", v+1, err)
+		}
+		if _, err := tx.Exec(`UPDATE schema_version SET version = ?`, v+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SaveRule(id, exprStr string, meta rule_expr.RuleMeta) error {
+	var allowUndefined sql.NullBool
+	if meta.AllowUndefined != nil {
+		allowUndefined = sql.NullBool{Bool: *meta.AllowUndefined, Valid: true}
+	}
+	_, err := s.db.Exec(`INSERT INTO rules (id, expr, flag, priority, tags, description, disabled, allow_undefined)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET expr = excluded.expr, flag = excluded.flag, priority = excluded.priority,
+			tags = excluded.tags, description = excluded.description, disabled = excluded.disabled,
+			allow_undefined = excluded.allow_undefined`,
+		id, exprStr, meta.Flag, meta.Priority, strings.Join(meta.Tags, ","), meta.Description, meta.Disabled, allowUndefined)
+	return err
+}
+
+func (s *SQLiteStore) DeleteRule(id string) error {
+	_, err := s.db.Exec(`DELETE FROM rules WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) LoadRules() (map[string]rule_expr.StoredRule, error) {
+	rows, err := s.db.Query(`SELECT id, expr, flag, priority, tags, description, disabled, allow_undefined FROM rules`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]rule_expr.StoredRule)
+	for rows.Next() {
+		var (
+			id, tags       string
+			sr             rule_expr.StoredRule
+			allowUndefined sql.NullBool
+		)
+		if err := rows.Scan(&id, &sr.Expr, &sr.Meta.Flag, &sr.Meta.Priority, &tags, &sr.Meta.Description,
+			&sr.Meta.Disabled, &allowUndefined); err != nil {
+			return nil, err
+		}
+		if tags != "" {
+			sr.Meta.Tags = strings.Split(tags, ",")
+		}
+		if allowUndefined.Valid {
+			sr.Meta.AllowUndefined = &allowUndefined.Bool
+		}
+		out[id] = sr
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,224 @@
+package rule_store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goexprtester/rule_expr"
+)
+
+func openTestStore(t *testing.T, path string) rule_expr.RuleStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// TestMetadataSurvivesRestart 优先级、标签、开关、说明、停用状态与容忍模式在重启后保持不变
+func TestMetadataSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	store := openTestStore(t, path)
+	re := rule_expr.NewRuleEngine(rule_expr.WithPersistence(store))
+	if err := re.AddRuleWithTags("geo", "user.country == 'CN'", "risk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.AddRuleWithUndefined("lenient", "user.level > 2", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.AddRuleWithUndefined("strict", "user.level > 2", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.AddRuleWithFlag("beta", "is_vip", "new_ui"); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.SetPriority("geo", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := re.SetTags("beta", "ops", "ui"); err != nil {
+		t.Fatal(err)
+	}
+	re.DisableRule("strict")
+	want := re.ListRules()
+	if err := re.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 引擎默认容忍缺失因子：显式指定的规则保持原模式
+	restarted := rule_expr.NewRuleEngine(rule_expr.WithPersistence(openTestStore(t, path)), rule_expr.WithAllowUndefined())
+	defer restarted.Close()
+	if err := restarted.LoadFromStore(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if id := want[i].ID; id == "geo" || id == "beta" {
+			want[i].AllowUndefined = true // 未显式指定的规则随新的引擎默认值
+		}
+	}
+	if got := restarted.ListRules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("重启后规则不同:\n%+v\n%+v", got, want)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "rules.db"))
+	defer store.Close()
+	allow := false
+	meta := rule_expr.RuleMeta{Flag: "f", Priority: -3, Tags: []string{"a", "b"}, Description: "说明 | 含分隔符",
+		Disabled: true, AllowUndefined: &allow}
+	if err := store.SaveRule("r", "is_vip", meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveRule("plain", "x > 1", rule_expr.RuleMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := store.LoadRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]rule_expr.StoredRule{"r": {Expr: "is_vip", Meta: meta}, "plain": {Expr: "x > 1"}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("LoadRules = %+v", rules)
+	}
+	if err := store.DeleteRule("r"); err != nil {
+		t.Fatal(err)
+	}
+	if rules, _ := store.LoadRules(); len(rules) != 1 {
+		t.Fatalf("删除后还剩 %d 条", len(rules))
+	}
+}
+
+// TestMigrateFromV1 只有 (id, expr) 的旧库升级后规则保留，元数据取默认值
+func TestMigrateFromV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE schema_version (version INTEGER NOT NULL)`,
+		`INSERT INTO schema_version (version) VALUES (1)`,
+		migrations[0],
+		`INSERT INTO rules (id, expr) VALUES ('old', 'is_vip')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	store := openTestStore(t, path)
+	defer store.Close()
+	rules, err := store.LoadRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules["old"]; len(rules) != 1 || got.Expr != "is_vip" || !reflect.DeepEqual(got.Meta, rule_expr.RuleMeta{}) {
+		t.Fatalf("升级后 LoadRules = %+v", rules)
+	}
+	var version int
+	if err := store.(*SQLiteStore).db.QueryRow(`SELECT version FROM schema_version`).Scan(&version); err != nil || version != len(migrations) {
+		t.Fatalf("版本 = %d, %v，应为 %d", version, err, len(migrations))
+	}
+}
+
+func TestRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`CREATE TABLE schema_version (version INTEGER NOT NULL)`)
+	db.Exec(`INSERT INTO schema_version (version) VALUES (99)`)
+	db.Close()
+	if store, err := OpenSQLiteStore(path); err == nil {
+		store.Close()
+		t.Fatal("库版本高于程序支持的版本时应拒绝打开")
+	}
+}
+
+// crashingStore 包装真实的 store：crashAfterSave 时落盘成功后 panic，模拟写入 store 与更新内存之间进程崩溃；
+// failDelete 时 DeleteRule 失败
+type crashingStore struct {
+	rule_expr.RuleStore
+	crashAfterSave bool
+	failDelete     bool
+}
+
+type crash struct{}
+
+func (s *crashingStore) SaveRule(id, exprStr string, meta rule_expr.RuleMeta) error {
+	if err := s.RuleStore.SaveRule(id, exprStr, meta); err != nil {
+		return err
+	}
+	if s.crashAfterSave {
+		panic(crash{})
+	}
+	return nil
+}
+
+func (s *crashingStore) DeleteRule(id string) error {
+	if s.failDelete {
+		return errors.New("注入的删除失败")
+	}
+	return s.RuleStore.DeleteRule(id)
+}
+
+// TestCrashBetweenStoreAndMemoryFavorsStore 落盘后、进入内存前崩溃：重启后以 store 为准，规则被恢复
+func TestCrashBetweenStoreAndMemoryFavorsStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	store := &crashingStore{RuleStore: openTestStore(t, path)}
+	re := rule_expr.NewRuleEngine(rule_expr.WithPersistence(store))
+	if err := re.AddRule("before", "is_vip"); err != nil {
+		t.Fatal(err)
+	}
+	store.crashAfterSave = true
+	func() {
+		defer func() {
+			if _, ok := recover().(crash); !ok {
+				t.Fatal("应在落盘后崩溃")
+			}
+		}()
+		re.AddRuleWithPriority("inflight", "blacklisted", 3)
+	}()
+	store.RuleStore.Close()
+
+	restarted := rule_expr.NewRuleEngine(rule_expr.WithPersistence(openTestStore(t, path)))
+	defer restarted.Close()
+	if err := restarted.LoadFromStore(); err != nil {
+		t.Fatal(err)
+	}
+	infos := restarted.ListRules()
+	if len(infos) != 2 || infos[1].ID != "inflight" || infos[1].Priority != 3 {
+		t.Fatalf("重启后应恢复崩溃前已落盘的规则: %+v", infos)
+	}
+}
+
+// TestStoreFailureKeepsMemoryConsistent store 写入或删除失败时内存不变，两者不出现分歧
+func TestStoreFailureKeepsMemoryConsistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.db")
+	store := &crashingStore{RuleStore: openTestStore(t, path)}
+	re := rule_expr.NewRuleEngine(rule_expr.WithPersistence(store))
+	if err := re.AddRule("kept", "is_vip"); err != nil {
+		t.Fatal(err)
+	}
+	store.failDelete = true
+	if re.RemoveRule("kept") {
+		t.Fatal("store 删除失败时 RemoveRule 应返回 false")
+	}
+	if re.RuleCount() != 1 {
+		t.Fatal("store 删除失败后内存中的规则不应删除")
+	}
+
+	store.RuleStore.Close() // 之后的写入全部失败
+	if err := re.AddRule("lost", "blacklisted"); err == nil {
+		t.Fatal("store 写入失败时 AddRule 应报错")
+	}
+	if _, ok := re.GetRuleFactors("lost"); ok {
+		t.Fatal("store 写入失败的规则不应进入内存")
+	}
+}
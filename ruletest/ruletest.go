@@ -0,0 +1,114 @@
+package ruletest
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+)
+
+/* ---------- 规则单测辅助 ---------- */
+
+// T testing.T 中本包用到的部分，便于替换成假的 T 验证失败信息
+type T interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// RuleSpec 一条待加载的规则
+type RuleSpec struct {
+	ID   string
+	Expr string
+}
+
+// Case 表驱动用例：输入及期望命中的规则 ID
+type Case struct {
+	Name  string
+	Input map[string]interface{}
+	Want  []string
+}
+
+// New 创建 expr 引擎并加载 specs，任一规则编译失败即 Fatalf
+func New(t T, specs ...RuleSpec) *rule_expr.RuleEngine {
+	t.Helper()
+	engine := rule_expr.NewRuleEngine()
+	Load(t, engine, specs...)
+	return engine
+}
+
+// Load 把 specs 加载到任意后端的引擎中，任一规则编译失败即 Fatalf
+func Load(t T, engine rule_engine.Engine, specs ...RuleSpec) {
+	t.Helper()
+	for _, s := range specs {
+		if err := engine.AddRule(s.ID, s.Expr); err != nil {
+			t.Fatalf("编译规则 %s 失败: %v", s.ID, err)
+		}
+	}
+}
+
+// AssertHits 断言 input 命中的规则集合恰为 wantIDs（与顺序无关），
+// 不一致时分别列出缺失与多余的命中
+func AssertHits(t T, engine rule_engine.Engine, input map[string]interface{}, wantIDs ...string) {
+	t.Helper()
+	if msg := diffHits(engine.Match(input), wantIDs); msg != "" {
+		t.Errorf("命中不符 input=%v\n%s", input, msg)
+	}
+}
+
+// AssertNoHit 断言 input 不会命中 ruleID
+func AssertNoHit(t T, engine rule_engine.Engine, input map[string]interface{}, ruleID string) {
+	t.Helper()
+	if slices.Contains(engine.Match(input), ruleID) {
+		t.Errorf("规则 %s 不应命中 input=%v", ruleID, input)
+	}
+}
+
+// RunCases 依次执行表驱动用例，失败信息带上用例名
+func RunCases(t T, engine rule_engine.Engine, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		if msg := diffHits(engine.Match(c.Input), c.Want); msg != "" {
+			t.Errorf("用例 %s 命中不符 input=%v\n%s", c.Name, c.Input, msg)
+		}
+	}
+}
+
+// diffHits 比较实际与期望命中，一致时返回空串
+func diffHits(got, want []string) string {
+	gotSet := make(map[string]bool, len(got))
+	for _, id := range got {
+		gotSet[id] = true
+	}
+	wantSet := make(map[string]bool, len(want))
+	for _, id := range want {
+		wantSet[id] = true
+	}
+	var missing, unexpected []string
+	for id := range wantSet {
+		if !gotSet[id] {
+			missing = append(missing, id)
+		}
+	}
+	for id := range gotSet {
+		if !wantSet[id] {
+			unexpected = append(unexpected, id)
+		}
+	}
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return ""
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	var b strings.Builder
+	if len(missing) > 0 {
+		fmt.Fprintf(&b, "  缺失命中: %s\n", strings.Join(missing, ", "))
+	}
+	if len(unexpected) > 0 {
+		fmt.Fprintf(&b, "  多余命中: %s\n", strings.Join(unexpected, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package ruletest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"goexprtester/rule_govaluate"
+)
+
+// fakeT 记录失败信息；Fatalf 以 panic 终止当前调用，由 run 恢复
+type fakeT struct {
+	errors []string
+	fatal  string
+}
+
+type fatalStop struct{}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...any) {
+	f.fatal = fmt.Sprintf(format, args...)
+	panic(fatalStop{})
+}
+
+// run 执行 fn，吞掉 fakeT.Fatalf 引起的终止
+func (f *fakeT) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(fatalStop); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn()
+}
+
+var specs = []RuleSpec{
+	{ID: "vip", Expr: "is_vip"},
+	{ID: "prod", Expr: `env == "prod"`},
+	{ID: "vip_prod", Expr: `is_vip and env == "prod"`},
+}
+
+// TestTypicalUsage 下游团队的典型写法
+func TestTypicalUsage(t *testing.T) {
+	engine := New(t, specs...)
+	AssertHits(t, engine, map[string]interface{}{"is_vip": true, "env": "prod"}, "vip", "prod", "vip_prod")
+	AssertNoHit(t, engine, map[string]interface{}{"is_vip": true, "env": "test_env"}, "prod")
+	RunCases(t, engine, []Case{
+		{Name: "非 VIP 生产", Input: map[string]interface{}{"is_vip": false, "env": "prod"}, Want: []string{"prod"}},
+		{Name: "都不满足", Input: map[string]interface{}{"is_vip": false, "env": "staging"}},
+	})
+}
+
+func TestAssertHitsReportsMissingAndUnexpectedSeparately(t *testing.T) {
+	engine := New(t, specs...)
+	ft := &fakeT{}
+	AssertHits(ft, engine, map[string]interface{}{"is_vip": true, "env": "staging"}, "prod")
+	if len(ft.errors) != 1 {
+		t.Fatalf("应报告一次失败，实际 %d 次: %q", len(ft.errors), ft.errors)
+	}
+	if msg := ft.errors[0]; !strings.Contains(msg, "  缺失命中: prod\n  多余命中: vip\n") {
+		t.Errorf("缺失与多余命中应分行列出:\n%s", msg)
+	}
+
+	ft = &fakeT{}
+	AssertHits(ft, engine, map[string]interface{}{"is_vip": true, "env": "prod"}, "vip")
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "多余命中: prod, vip_prod") || strings.Contains(ft.errors[0], "缺失命中") {
+		t.Errorf("只有多余命中时的失败信息不对: %q", ft.errors)
+	}
+}
+
+func TestAssertHitsPassesSilently(t *testing.T) {
+	ft := &fakeT{}
+	engine := New(ft, specs...)
+	AssertHits(ft, engine, map[string]interface{}{"is_vip": true, "env": "prod"}, "vip_prod", "vip", "prod")
+	if len(ft.errors) != 0 || ft.fatal != "" {
+		t.Fatalf("顺序不同的相同集合不应失败: %q %q", ft.errors, ft.fatal)
+	}
+}
+
+func TestAssertNoHitMessage(t *testing.T) {
+	engine := New(t, specs...)
+	ft := &fakeT{}
+	AssertNoHit(ft, engine, map[string]interface{}{"is_vip": true, "env": "prod"}, "vip")
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "规则 vip 不应命中") {
+		t.Fatalf("失败信息不对: %q", ft.errors)
+	}
+}
+
+func TestNewFailsOnBadRule(t *testing.T) {
+	ft := &fakeT{}
+	ft.run(func() {
+		New(ft, RuleSpec{ID: "ok", Expr: "is_vip"}, RuleSpec{ID: "bad", Expr: "is_vip and ("}, RuleSpec{ID: "later", Expr: "is_vip"})
+		t.Error("编译失败后不应继续")
+	})
+	if !strings.Contains(ft.fatal, "编译规则 bad 失败") {
+		t.Fatalf("Fatalf 信息不对: %q", ft.fatal)
+	}
+}
+
+func TestRunCasesNamesFailingCase(t *testing.T) {
+	engine := New(t, specs...)
+	ft := &fakeT{}
+	RunCases(ft, engine, []Case{
+		{Name: "通过", Input: map[string]interface{}{"is_vip": true, "env": "staging"}, Want: []string{"vip"}},
+		{Name: "期望错误", Input: map[string]interface{}{"is_vip": false, "env": "prod"}, Want: []string{"vip"}},
+	})
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "用例 期望错误") {
+		t.Fatalf("应只报告失败的用例: %q", ft.errors)
+	}
+}
+
+func TestHelpersWorkWithGovaluate(t *testing.T) {
+	engine := &rule_govaluate.RuleEngine{}
+	Load(t, engine, RuleSpec{ID: "vip", Expr: "is_vip"}, RuleSpec{ID: "prod", Expr: `env == "prod"`})
+	AssertHits(t, engine, map[string]interface{}{"is_vip": true, "env": "prod"}, "vip", "prod")
+
+	ft := &fakeT{}
+	AssertHits(ft, engine, map[string]interface{}{"is_vip": false, "env": "prod"}, "vip")
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "缺失命中: vip") {
+		t.Fatalf("govaluate 后端的失败信息不对: %q", ft.errors)
+	}
+}
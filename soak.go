@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goexprtester/rule_expr"
+)
+
+/* ---------- Soak 模式 ---------- */
+
+// SoakConfig 长时间混合负载的参数
+type SoakConfig struct {
+	Duration      time.Duration
+	Interval      time.Duration // 采样间隔
+	Rules         int
+	Matchers      int
+	Mutators      int
+	ReplaceEvery  time.Duration // 整体替换规则集的间隔，<= 0 时取 Interval
+	WarmupRatio   float64       // 前多少比例的样本不参与斜率计算
+	MaxHeapSlope  float64       // 允许的 HeapAlloc 增长上限 (bytes/s)
+	MaxGorouSlope float64       // 允许的 goroutine 增长上限 (个/s)
+}
+
+// SoakSample 单次采样
+type SoakSample struct {
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	HeapAlloc  uint64        `json:"heap_alloc"`
+	Goroutines int           `json:"goroutines"`
+	Matches    uint64        `json:"matches"`
+	Mutations  uint64        `json:"mutations"`
+
+	// 引擎自身的统计
+	Generation   uint64 `json:"generation"`
+	CacheEntries int    `json:"cache_entries"`
+	Recent       int    `json:"recent_matches"`
+}
+
+// SoakReport soak 结果，写入 JSON 报告
+type SoakReport struct {
+	Samples    []SoakSample `json:"samples"`
+	HeapSlope  float64      `json:"heap_slope_bytes_per_sec"`
+	GorouSlope float64      `json:"goroutine_slope_per_sec"`
+	Leaking    bool         `json:"leaking"`
+	Reason     string       `json:"reason,omitempty"`
+	ReplaceErr string       `json:"replace_error,omitempty"` // 第一次整体替换失败的原因
+}
+
+// RunSoak 对开启编译缓存、等值索引与最近匹配记录的 expr 引擎运行混合负载：
+// matcher 持续匹配，mutator 持续覆盖规则，并每隔 cfg.ReplaceEvery 从文件整体替换一次规则集
+func RunSoak(cfg SoakConfig) (*SoakReport, error) {
+	target, err := newEngineSoakTarget(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	defer target.close()
+	return runSoakOn(cfg, target), nil
+}
+
+// soakTarget soak 负载作用的对象；测试中用故意泄漏的实现验证检测逻辑
+type soakTarget interface {
+	match(i int)
+	mutate(r *rand.Rand)
+	replaceAll(r *rand.Rand) error
+	fill(s *SoakSample) // 补充目标自身的统计
+}
+
+// engineSoakTarget 真实引擎上的负载
+type engineSoakTarget struct {
+	engine *rule_expr.RuleEngine
+	inputs []map[string]interface{}
+	exprs  []string
+	path   string // 整体替换时读取的规则文件
+}
+
+func newEngineSoakTarget(rules int) (*engineSoakTarget, error) {
+	engine := rule_expr.NewRuleEngine(rule_expr.WithCompileCache(), rule_expr.WithEqualityIndex())
+	engine.SetRecentMatches(rule_expr.DefaultRecentMatches)
+	if err := rule_expr.InjectRandomRules(engine, rules); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "soak-rules-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("创建规则文件失败: %w", err)
+	}
+	f.Close()
+	t := &engineSoakTarget{engine: engine, inputs: rule_expr.GenRandomInputs(1000), path: f.Name()}
+	t.exprs = make([]string, rules)
+	for i := range t.exprs {
+		t.exprs[i] = fmt.Sprintf("user_id == %d", 10000+i)
+	}
+	return t, nil
+}
+
+func (t *engineSoakTarget) match(i int) {
+	_ = t.engine.Match(t.inputs[i%len(t.inputs)])
+}
+
+func (t *engineSoakTarget) mutate(r *rand.Rand) {
+	// 覆盖已有 ID，规则总数保持不变
+	id := fmt.Sprintf("auto-%d", r.Intn(len(t.exprs))+1)
+	_ = t.engine.AddRule(id, t.exprs[r.Intn(len(t.exprs))])
+}
+
+// replaceAll 写出同样多条规则的新文件并整体替换
+func (t *engineSoakTarget) replaceAll(r *rand.Rand) error {
+	rules := make([]rule_expr.TextRule, len(t.exprs))
+	for i := range rules {
+		rules[i] = rule_expr.TextRule{ID: fmt.Sprintf("auto-%d", i+1), Expr: t.exprs[r.Intn(len(t.exprs))]}
+	}
+	f, err := os.Create(t.path)
+	if err != nil {
+		return err
+	}
+	if err := rule_expr.WriteText(f, rules); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if res := t.engine.ReloadRulesFile(t.path); res.Err != nil || !res.Applied {
+		return fmt.Errorf("整体替换规则集失败: %v %v", res.Err, res.Failed)
+	}
+	return nil
+}
+
+func (t *engineSoakTarget) fill(s *SoakSample) {
+	s.Generation = t.engine.Generation()
+	s.CacheEntries = t.engine.CompileCacheStats().Entries
+	s.Recent = len(t.engine.RecentMatches())
+}
+
+func (t *engineSoakTarget) close() {
+	t.engine.Close()
+	os.Remove(t.path)
+}
+
+// runSoakOn 启动 matcher 与 mutator 持续运行 cfg.Duration，按间隔采样并判断是否存在增长
+func runSoakOn(cfg SoakConfig, target soakTarget) *SoakReport {
+	var matches, mutations atomic.Uint64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Matchers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				target.match(i)
+				matches.Add(1)
+			}
+		}(w)
+	}
+	for w := 0; w < cfg.Mutators; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				target.mutate(r)
+				mutations.Add(1)
+				time.Sleep(time.Millisecond)
+			}
+		}(w)
+	}
+
+	report := &SoakReport{}
+	var replaceErr atomic.Value
+	replaceEvery := cfg.ReplaceEvery
+	if replaceEvery <= 0 {
+		replaceEvery = cfg.Interval
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(-1))
+		ticker := time.NewTicker(replaceEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := target.replaceAll(r); err != nil {
+					replaceErr.CompareAndSwap(nil, err)
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(cfg.Interval)
+	for now := range ticker.C {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		sample := SoakSample{
+			Elapsed:    now.Sub(start),
+			HeapAlloc:  ms.HeapAlloc,
+			Goroutines: runtime.NumGoroutine(),
+			Matches:    matches.Load(),
+			Mutations:  mutations.Load(),
+		}
+		target.fill(&sample)
+		report.Samples = append(report.Samples, sample)
+		if now.Sub(start) >= cfg.Duration {
+			break
+		}
+	}
+	ticker.Stop()
+	close(stop)
+	wg.Wait()
+
+	report.evaluate(cfg)
+	if err, ok := replaceErr.Load().(error); ok {
+		report.ReplaceErr = err.Error()
+	}
+	return report
+}
+
+// evaluate 对预热后的样本做最小二乘拟合，斜率超过阈值即视为泄漏
+func (r *SoakReport) evaluate(cfg SoakConfig) {
+	skip := int(float64(len(r.Samples)) * cfg.WarmupRatio)
+	samples := r.Samples[skip:]
+	if len(samples) < 2 {
+		r.Reason = "样本不足，无法判断"
+		return
+	}
+	xs := make([]float64, len(samples))
+	heap := make([]float64, len(samples))
+	gor := make([]float64, len(samples))
+	for i, s := range samples {
+		xs[i] = s.Elapsed.Seconds()
+		heap[i] = float64(s.HeapAlloc)
+		gor[i] = float64(s.Goroutines)
+	}
+	r.HeapSlope = slope(xs, heap)
+	r.GorouSlope = slope(xs, gor)
+	switch {
+	case r.HeapSlope > cfg.MaxHeapSlope:
+		r.Leaking = true
+		r.Reason = fmt.Sprintf("HeapAlloc 持续增长 %.0f bytes/s，超过阈值 %.0f", r.HeapSlope, cfg.MaxHeapSlope)
+	case r.GorouSlope > cfg.MaxGorouSlope:
+		r.Leaking = true
+		r.Reason = fmt.Sprintf("goroutine 持续增长 %.3f 个/s，超过阈值 %.3f", r.GorouSlope, cfg.MaxGorouSlope)
+	}
+}
+
+// slope 线性回归斜率
+func slope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sx, sy, sxy, sxx float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxy += xs[i] * ys[i]
+		sxx += xs[i] * xs[i]
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
+
+// WriteJSON 把时间序列和结论写到 path
+func (r *SoakReport) WriteJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goexprtester/rule_expr"
+)
+
+// leakyTarget 故意泄漏的负载：每次变更保留一块内存，或留下一个阻塞的 goroutine
+type leakyTarget struct {
+	heap       bool
+	goroutines bool
+
+	mu       sync.Mutex
+	retained [][]byte
+	release  chan struct{}
+}
+
+func (l *leakyTarget) match(int) {}
+
+func (l *leakyTarget) mutate(*rand.Rand) {
+	if l.heap {
+		l.mu.Lock()
+		l.retained = append(l.retained, make([]byte, 64<<10))
+		l.mu.Unlock()
+	}
+	if l.goroutines {
+		go func() { <-l.release }()
+	}
+}
+
+func (l *leakyTarget) replaceAll(*rand.Rand) error { return nil }
+func (l *leakyTarget) fill(*SoakSample)            {}
+
+func shortSoakConfig() SoakConfig {
+	return SoakConfig{
+		Duration:      600 * time.Millisecond,
+		Interval:      50 * time.Millisecond,
+		Rules:         50,
+		Matchers:      1,
+		Mutators:      1,
+		WarmupRatio:   0.2,
+		MaxHeapSlope:  1 << 20,
+		MaxGorouSlope: 1,
+	}
+}
+
+func TestSoakDetectsLeakyStub(t *testing.T) {
+	cases := []struct {
+		name   string
+		target *leakyTarget
+		want   string
+	}{
+		{"heap", &leakyTarget{heap: true}, "HeapAlloc"},
+		{"goroutines", &leakyTarget{goroutines: true}, "goroutine"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.target.release = make(chan struct{})
+			defer close(c.target.release)
+			report := runSoakOn(shortSoakConfig(), c.target)
+			if !report.Leaking || !strings.Contains(report.Reason, c.want) {
+				t.Fatalf("应检测到 %s 增长: leaking=%v reason=%q heap=%.0f gor=%.3f",
+					c.want, report.Leaking, report.Reason, report.HeapSlope, report.GorouSlope)
+			}
+		})
+	}
+
+	report := runSoakOn(shortSoakConfig(), &leakyTarget{})
+	if report.Leaking {
+		t.Fatalf("不泄漏的负载被判为泄漏: %s", report.Reason)
+	}
+}
+
+// TestRunSoakEngine 真实引擎上的短时运行：整体替换生效，引擎统计写入样本，保留的数据有界
+func TestRunSoakEngine(t *testing.T) {
+	cfg := shortSoakConfig()
+	cfg.ReplaceEvery = 100 * time.Millisecond
+	report, err := RunSoak(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ReplaceErr != "" {
+		t.Fatal(report.ReplaceErr)
+	}
+	first, last := report.Samples[0], report.Samples[len(report.Samples)-1]
+	if len(report.Samples) < 3 || last.Matches == 0 || last.Mutations == 0 || last.Generation <= first.Generation {
+		t.Fatalf("负载没有运行起来: %+v", last)
+	}
+	for _, s := range report.Samples {
+		if s.Recent > rule_expr.DefaultRecentMatches || s.CacheEntries > cfg.Rules*2 {
+			t.Fatalf("保留的数据超出上限: %+v", s)
+		}
+	}
+}
+
+func TestSlope(t *testing.T) {
+	xs := []float64{0, 1, 2, 3, 4}
+	ys := []float64{3, 5, 7, 9, 11}
+	if got := slope(xs, ys); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("slope = %v，应为 2", got)
+	}
+	if got := slope([]float64{1, 1}, []float64{1, 5}); got != 0 {
+		t.Fatalf("x 全相同时 slope = %v，应为 0", got)
+	}
+
+	r := &SoakReport{Samples: []SoakSample{{Elapsed: time.Second, HeapAlloc: 100}}}
+	r.evaluate(SoakConfig{})
+	if r.Leaking || !strings.Contains(r.Reason, "样本不足") {
+		t.Fatalf("样本不足时不应下结论: %+v", r)
+	}
+}
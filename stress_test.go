@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goexprtester/rule_engine"
+	"goexprtester/rule_expr"
+	"goexprtester/rule_govaluate"
+)
+
+/* ---------- 并发压力测试 ---------- */
+
+// go test -run TestStress -race [-short] [-stress.seed=N] [-stress.duration=5s]
+//
+// 多个 worker 按随机调度对同一个引擎执行表中的操作，checker 在操作之间取得静止点检查全局不变量。
+// 每个 worker 的操作序列由种子决定，失败时打印种子以便复现（goroutine 间的交错仍取决于调度器）。
+// 新增公开操作时在对应后端的 ops 表里加一项，新增可观察的状态时在 invariants 表里加一项
+var (
+	stressSeed     = flag.Int64("stress.seed", 0, "TestStress 的调度种子，0 表示取当前时间")
+	stressDuration = flag.Duration("stress.duration", 2*time.Second, "TestStress 每个后端的运行时长，-short 时为 200ms")
+	stressWorkers  = flag.Int("stress.workers", 4, "TestStress 并发执行操作的 goroutine 数")
+)
+
+// stressOp 调度中的一种操作；run 在 worker goroutine 中执行，可与其他操作并发
+type stressOp struct {
+	name string
+	run  func(s *stressRun, r *rand.Rand)
+}
+
+// stressInvariant 在静止点（没有进行中的操作）检查的全局不变量
+type stressInvariant struct {
+	name  string
+	check func(s *stressRun, r *rand.Rand) error
+}
+
+// stressRule ListRules 中与不变量有关的部分
+type stressRule struct {
+	ID      string
+	Enabled bool
+}
+
+// stressRun 一个后端的一次压力测试
+type stressRun struct {
+	t      *testing.T
+	seed   int64
+	ids    []string // 规则 ID 取自这个小池子，使添加、替换与删除频繁撞上同一条规则
+	exprs  []string
+	inputs []map[string]interface{}
+	dir    string
+
+	// 后端提供的观察接口
+	list     func() []stressRule // 按 ID 排序
+	count    func() int          // RuleCount
+	match    func(map[string]interface{}) []string
+	counters func() map[string]uint64 // 只增不减的计数器
+	known    func(id string) bool     // 任何时刻都可能出现在命中中的 ID
+
+	ops        []stressOp
+	invariants []stressInvariant
+
+	gate sync.RWMutex // 操作持读锁，checker 持写锁取得静止点
+	last map[string]uint64
+}
+
+func (s *stressRun) id(r *rand.Rand) string   { return s.ids[r.Intn(len(s.ids))] }
+func (s *stressRun) expr(r *rand.Rand) string { return s.exprs[r.Intn(len(s.exprs))] }
+func (s *stressRun) input(r *rand.Rand) map[string]interface{} {
+	return s.inputs[r.Intn(len(s.inputs))]
+}
+
+// hits 检查任意时刻返回的命中：无重复，且每个 ID 都可能存在过
+func (s *stressRun) hits(op string, hits []string) {
+	seen := make(map[string]bool, len(hits))
+	for _, id := range hits {
+		if seen[id] || !s.known(id) {
+			s.t.Errorf("种子 %d：%s 返回了重复或未知的命中 %q: %v", s.seed, op, id, hits)
+			return
+		}
+		seen[id] = true
+	}
+}
+
+// commonInvariants 两个后端共有的不变量
+var commonInvariants = []stressInvariant{
+	{"RuleCount 与 ListRules 一致", func(s *stressRun, _ *rand.Rand) error {
+		if n, list := s.count(), s.list(); n != len(list) {
+			return fmt.Errorf("RuleCount = %d，ListRules 有 %d 条", n, len(list))
+		}
+		return nil
+	}},
+	{"ListRules 按 ID 排序且无重复", func(s *stressRun, _ *rand.Rand) error {
+		list := s.list()
+		for i := 1; i < len(list); i++ {
+			if list[i-1].ID >= list[i].ID {
+				return fmt.Errorf("%s 排在 %s 之前", list[i-1].ID, list[i].ID)
+			}
+		}
+		return nil
+	}},
+	{"命中的规则存在且已启用", func(s *stressRun, r *rand.Rand) error {
+		enabled := make(map[string]bool)
+		for _, rule := range s.list() {
+			enabled[rule.ID] = rule.Enabled
+		}
+		for i := 0; i < 3; i++ {
+			for _, id := range s.match(s.input(r)) {
+				if !enabled[id] {
+					return fmt.Errorf("命中了不存在或已停用的规则 %s", id)
+				}
+			}
+		}
+		return nil
+	}},
+	{"计数器单调", func(s *stressRun, _ *rand.Rand) error {
+		now := s.counters()
+		for name, v := range now {
+			if v < s.last[name] {
+				return fmt.Errorf("%s 从 %d 减少到 %d", name, s.last[name], v)
+			}
+		}
+		s.last = now
+		return nil
+	}},
+}
+
+func TestStress(t *testing.T) {
+	seed := *stressSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	d := *stressDuration
+	if testing.Short() {
+		d = 200 * time.Millisecond
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("复现: go test -run TestStress -race -stress.seed=%d", seed)
+		}
+	})
+	for _, backend := range []struct {
+		name  string
+		setup func(s *stressRun)
+	}{
+		{"expr", setupExprStress},
+		{"govaluate", setupGovaluateStress},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			s := &stressRun{t: t, seed: seed, dir: t.TempDir()}
+			for i := 0; i < 12; i++ {
+				s.ids = append(s.ids, fmt.Sprintf("r%02d", i))
+			}
+			inputs := rule_engine.GenRandomInputsSeeded(16, seed)
+			// 一部分输入缺少因子，覆盖执行出错的路径
+			for i, in := range inputs[:4] {
+				for k := range in {
+					delete(inputs[i], k)
+					break
+				}
+			}
+			s.inputs = inputs
+			backend.setup(s)
+			s.invariants = append(slices.Clone(commonInvariants), s.invariants...)
+			s.last = s.counters()
+			runStress(t, s, d)
+		})
+	}
+}
+
+// runStress 运行 d 时长后停止；每个 worker 的随机源由种子派生
+func runStress(t *testing.T, s *stressRun, d time.Duration) {
+	deadline := time.Now().Add(d)
+	var wg sync.WaitGroup
+	counts := make([]map[string]int, *stressWorkers)
+	for w := range counts {
+		counts[w] = make(map[string]int)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(s.seed + int64(w)))
+			for time.Now().Before(deadline) && !t.Failed() {
+				op := s.ops[r.Intn(len(s.ops))]
+				s.gate.RLock()
+				op.run(s, r)
+				s.gate.RUnlock()
+				counts[w][op.name]++
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	// 写锁等待进行中的操作结束，且阻止新操作开始；释放后每个 worker 各执行一个操作，
+	// 因此 checker 大致在每个操作之后都检查一次
+	r := rand.New(rand.NewSource(s.seed - 1))
+	checks := 0
+	for stop := false; !stop; {
+		select {
+		case <-done:
+			stop = true // 最后再检查一次
+		default:
+		}
+		s.gate.Lock()
+		for _, inv := range s.invariants {
+			if err := inv.check(s, r); err != nil {
+				t.Errorf("种子 %d：第 %d 次检查时不变量「%s」不成立: %v", s.seed, checks, inv.name, err)
+				stop = true
+			}
+		}
+		s.gate.Unlock()
+		checks++
+	}
+	<-done
+
+	total := make(map[string]int)
+	for _, c := range counts {
+		for name, n := range c {
+			total[name] += n
+		}
+	}
+	for _, op := range s.ops {
+		if total[op.name] == 0 && !t.Failed() && !testing.Short() {
+			t.Errorf("操作 %s 一次也没有执行，调大 -stress.duration", op.name)
+		}
+	}
+	t.Logf("种子 %d：%d 次检查，%d 种操作共执行 %d 次", s.seed, checks, len(s.ops), sum(total))
+}
+
+func sum(m map[string]int) int {
+	n := 0
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
+
+// stressExprs 用种子生成的随机规则表达式，外加几条结构特殊的规则
+func stressExprs(syntax rule_engine.Syntax, seed int64, extra ...string) []string {
+	r := rand.New(rand.NewSource(seed))
+	exprs := slices.Clone(extra)
+	for i := 0; i < 24; i++ {
+		exprs = append(exprs, rule_engine.RandomExpr(r, syntax, 3))
+	}
+	return exprs
+}
+
+/* ---------- expr 后端 ---------- */
+
+var stressTags = []string{"risk", "vip", "ops"}
+
+func setupExprStress(s *stressRun) {
+	re := rule_expr.NewRuleEngine(rule_expr.WithCompileCache(), rule_expr.WithEqualityIndex(), rule_expr.WithSharedPredicates())
+	re.SetIDGenerator(rule_expr.ContentHashIDs{Prefix: "h-"})
+	re.SetRecentMatches(8)
+	s.t.Cleanup(func() { re.Close() })
+	s.exprs = stressExprs(rule_expr.Syntax, s.seed,
+		`env == "prod"`, `env == "prod" and is_vip`, `payment_method in ["PAYPAL", "STRIPE"] and amount > 100`)
+
+	s.list = func() []stressRule {
+		var out []stressRule
+		for _, r := range re.ListRules() {
+			out = append(out, stressRule{r.ID, r.Enabled})
+		}
+		return out
+	}
+	s.count = re.RuleCount
+	s.match = re.Match
+	s.counters = func() map[string]uint64 {
+		st, cache := re.EvalStats(), re.CompileCacheStats()
+		return map[string]uint64{
+			"Generation":          re.Generation(),
+			"EvalStats.Matches":   st.Matches,
+			"EvalStats.Evaluated": st.Evaluated,
+			"EvalErrors":          re.EvalErrors(),
+			"CompileCache.Hits":   cache.Hits,
+			"CompileCache.Misses": cache.Misses,
+		}
+	}
+	s.known = func(id string) bool {
+		return slices.Contains(s.ids, id) || strings.HasPrefix(id, "h-")
+	}
+
+	meta := func(r *rand.Rand) rule_expr.RuleMeta {
+		return rule_expr.RuleMeta{Priority: r.Intn(3), Tags: []string{stressTags[r.Intn(len(stressTags))]}}
+	}
+	s.ops = []stressOp{
+		// 规则变更
+		{"AddRule", func(s *stressRun, r *rand.Rand) { re.AddRule(s.id(r), s.expr(r)) }},
+		{"AddRuleWithPriority", func(s *stressRun, r *rand.Rand) { re.AddRuleWithPriority(s.id(r), s.expr(r), r.Intn(3)) }},
+		{"AddRuleWithTags", func(s *stressRun, r *rand.Rand) {
+			re.AddRuleWithTags(s.id(r), s.expr(r), stressTags[r.Intn(len(stressTags))])
+		}},
+		{"AddRuleWithFlag", func(s *stressRun, r *rand.Rand) { re.AddRuleWithFlag(s.id(r), s.expr(r), "beta") }},
+		{"AddRuleWithUndefined", func(s *stressRun, r *rand.Rand) { re.AddRuleWithUndefined(s.id(r), s.expr(r), r.Intn(2) == 0) }},
+		{"AddRules", func(s *stressRun, r *rand.Rand) {
+			re.AddRules(map[string]string{s.id(r): s.expr(r), s.id(r): s.expr(r), s.id(r): "is_vip and ("})
+		}},
+		{"AddRuleAuto", func(s *stressRun, r *rand.Rand) { re.AddRuleAuto(s.expr(r), meta(r)) }},
+		{"RemoveRule", func(s *stressRun, r *rand.Rand) { re.RemoveRule(s.id(r)) }},
+		{"DisableRule", func(s *stressRun, r *rand.Rand) { re.DisableRule(s.id(r)) }},
+		{"EnableRule", func(s *stressRun, r *rand.Rand) { re.EnableRule(s.id(r)) }},
+		{"SetPriority", func(s *stressRun, r *rand.Rand) { re.SetPriority(s.id(r), r.Intn(3)) }},
+		{"SetTags", func(s *stressRun, r *rand.Rand) { re.SetTags(s.id(r), stressTags[r.Intn(len(stressTags))]) }},
+		{"ImportText", func(s *stressRun, r *rand.Rand) {
+			var buf bytes.Buffer
+			rule_expr.WriteText(&buf, []rule_expr.TextRule{{ID: s.id(r), Priority: r.Intn(3), Expr: s.expr(r)}})
+			re.ImportText(&buf)
+		}},
+		{"ReloadRulesFile", func(s *stressRun, r *rand.Rand) {
+			rules := make(map[string]rule_expr.TextRule)
+			for i := 0; i < 4; i++ {
+				id := s.id(r)
+				rules[id] = rule_expr.TextRule{ID: id, Expr: s.expr(r)}
+			}
+			f, err := os.CreateTemp(s.dir, "rules-*.txt")
+			if err != nil {
+				s.t.Error(err)
+				return
+			}
+			rule_expr.WriteText(f, slices.Collect(maps.Values(rules)))
+			f.Close()
+			if res := re.ReloadRulesFile(f.Name()); res.Err != nil {
+				s.t.Errorf("ReloadRulesFile: %v", res.Err)
+			}
+		}},
+		{"RewriteRules", func(s *stressRun, r *rand.Rand) {
+			re.RewriteRules(map[string]rule_expr.FactorRewrite{"user_id": {ToString: r.Intn(2) == 0}})
+		}},
+		// 配置变更
+		{"SetFlagDefault", func(s *stressRun, r *rand.Rand) { re.SetFlagDefault(r.Intn(2) == 0) }},
+		{"ShuffleEvaluationOrder", func(s *stressRun, r *rand.Rand) { re.ShuffleEvaluationOrder(r.Int63()) }},
+		{"StopShuffle", func(s *stressRun, r *rand.Rand) { re.StopShuffle() }},
+		{"SetRecentMatches", func(s *stressRun, r *rand.Rand) {
+			re.SetRecentMatches(r.Intn(8), rule_expr.RecentOptions{KeepInputs: r.Intn(2) == 0})
+		}},
+		{"SetResultCache", func(s *stressRun, r *rand.Rand) {
+			re.SetResultCache(r.Intn(16), rule_expr.ResultCacheOptions{Paranoid: r.Intn(2) == 0})
+		}},
+		// 匹配
+		{"Match", func(s *stressRun, r *rand.Rand) { s.hits("Match", re.Match(s.input(r))) }},
+		{"MatchContext", func(s *stressRun, r *rand.Rand) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if r.Intn(2) == 0 {
+				cancel()
+			}
+			hits, _ := re.MatchContext(ctx, s.input(r))
+			cancel()
+			s.hits("MatchContext", hits)
+		}},
+		{"MatchFirst", func(s *stressRun, r *rand.Rand) {
+			if id, ok := re.MatchFirst(s.input(r)); ok {
+				s.hits("MatchFirst", []string{id})
+			}
+		}},
+		{"MatchFirstByPriority", func(s *stressRun, r *rand.Rand) {
+			if id, ok := re.MatchFirstByPriority(s.input(r)); ok {
+				s.hits("MatchFirstByPriority", []string{id})
+			}
+		}},
+		{"MatchWithErrors", func(s *stressRun, r *rand.Rand) {
+			hits, _ := re.MatchWithErrors(s.input(r))
+			s.hits("MatchWithErrors", hits)
+		}},
+		{"MatchParallel", func(s *stressRun, r *rand.Rand) { s.hits("MatchParallel", re.MatchParallel(s.input(r), 1+r.Intn(4))) }},
+		{"MatchShared", func(s *stressRun, r *rand.Rand) { s.hits("MatchShared", re.MatchShared(s.input(r))) }},
+		{"MatchByTag", func(s *stressRun, r *rand.Rand) {
+			s.hits("MatchByTag", re.MatchByTag(stressTags[r.Intn(len(stressTags))], s.input(r)))
+		}},
+		{"MatchStrict", func(s *stressRun, r *rand.Rand) {
+			hits, _ := re.MatchStrict(s.input(r))
+			s.hits("MatchStrict", hits)
+		}},
+		{"MatchBatchByRule", func(s *stressRun, r *rand.Rand) {
+			for id := range re.MatchBatchByRule(s.inputs[:4]) {
+				s.hits("MatchBatchByRule", []string{id})
+			}
+		}},
+		// 只读查询
+		{"ListRules", func(s *stressRun, r *rand.Rand) { re.ListRules() }},
+		{"RuleCount", func(s *stressRun, r *rand.Rand) { re.RuleCount() }},
+		{"GetRuleFactors", func(s *stressRun, r *rand.Rand) { re.GetRuleFactors(s.id(r)) }},
+		{"FactorUsage", func(s *stressRun, r *rand.Rand) { re.FactorUsage() }},
+		{"SearchRules", func(s *stressRun, r *rand.Rand) { re.SearchRules(rule_expr.RuleQuery{Vars: []string{"env"}}) }},
+		{"ExportText", func(s *stressRun, r *rand.Rand) {
+			var buf bytes.Buffer
+			if err := re.ExportText(&buf); err != nil {
+				s.t.Errorf("ExportText: %v", err)
+			}
+		}},
+		{"InputJSONSchema", func(s *stressRun, r *rand.Rand) {
+			if _, err := re.InputJSONSchema(); err != nil {
+				s.t.Errorf("InputJSONSchema: %v", err)
+			}
+		}},
+		{"ValidateInput", func(s *stressRun, r *rand.Rand) { re.ValidateInput(s.input(r)) }},
+		{"RecentMatches", func(s *stressRun, r *rand.Rand) {
+			for _, m := range re.RecentMatches() {
+				s.hits("RecentMatches", m.Hits)
+			}
+		}},
+		{"EvalStats", func(s *stressRun, r *rand.Rand) { re.EvalStats() }},
+		{"FindUnsatisfiable", func(s *stressRun, r *rand.Rand) { re.FindUnsatisfiable() }},
+		{"OptimizationSummary", func(s *stressRun, r *rand.Rand) { re.OptimizationSummary() }},
+		{"SharedPredicates", func(s *stressRun, r *rand.Rand) { re.SharedPredicates() }},
+		{"PlanRewrite", func(s *stressRun, r *rand.Rand) {
+			re.PlanRewrite(map[string]rule_expr.FactorRewrite{"env": {RenameTo: "environment"}})
+		}},
+		{"ImpactAnalysis", func(s *stressRun, r *rand.Rand) { re.ImpactAnalysis(s.id(r), s.inputs[:4]) }},
+	}
+
+	sameSet := func(name string, got, want []string) error {
+		got, want = slices.Clone(got), slices.Clone(want)
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			return fmt.Errorf("%s 命中 %v，Match 命中 %v", name, got, want)
+		}
+		return nil
+	}
+	s.invariants = []stressInvariant{
+		{"各匹配接口的命中集合相同", func(s *stressRun, r *rand.Rand) error {
+			in := s.input(r)
+			want := re.Match(in)
+			if err := sameSet("MatchShared", re.MatchShared(in), want); err != nil {
+				return err
+			}
+			if err := sameSet("MatchParallel", re.MatchParallel(in, 4), want); err != nil {
+				return err
+			}
+			hits, _ := re.MatchWithErrors(in)
+			return sameSet("MatchWithErrors", hits, want)
+		}},
+		{"最近匹配记录有序且不超前于规则集版本", func(s *stressRun, _ *rand.Rand) error {
+			gen := re.Generation()
+			recent := re.RecentMatches()
+			for i, m := range recent {
+				if i > 0 && m.Seq <= recent[i-1].Seq {
+					return fmt.Errorf("序号 %d 排在 %d 之后", m.Seq, recent[i-1].Seq)
+				}
+				if m.Generation > gen {
+					return fmt.Errorf("记录的版本 %d 大于当前版本 %d", m.Generation, gen)
+				}
+			}
+			return nil
+		}},
+		{"停用的规则不出现在 MatchByTag 中", func(s *stressRun, r *rand.Rand) error {
+			disabled := make(map[string]bool)
+			for _, rule := range re.ListRules() {
+				disabled[rule.ID] = !rule.Enabled
+			}
+			for _, tag := range stressTags {
+				for _, id := range re.MatchByTag(tag, s.input(r)) {
+					if disabled[id] {
+						return fmt.Errorf("停用的规则 %s 在标签 %s 下命中", id, tag)
+					}
+				}
+			}
+			return nil
+		}},
+	}
+}
+
+/* ---------- govaluate 后端 ---------- */
+
+func setupGovaluateStress(s *stressRun) {
+	ge := &rule_govaluate.RuleEngine{CompileCache: rule_engine.NewCompileCache(16)}
+	s.exprs = stressExprs(rule_govaluate.Syntax, s.seed, `env == "prod"`, `[user.country] == "CN"`)
+	s.inputs = rule_engine.AdaptInputs(rule_govaluate.Syntax, s.inputs)
+
+	s.list = func() []stressRule {
+		var out []stressRule
+		for _, r := range ge.ListRules() {
+			out = append(out, stressRule{r.ID, true}) // govaluate 后端没有停用
+		}
+		return out
+	}
+	s.count = ge.RuleCount
+	s.match = ge.Match
+	s.counters = func() map[string]uint64 {
+		cache := ge.CompileCacheStats()
+		return map[string]uint64{
+			"CompileCache.Hits":      cache.Hits,
+			"CompileCache.Misses":    cache.Misses,
+			"CompileCache.Evictions": cache.Evictions,
+		}
+	}
+	s.known = func(id string) bool { return slices.Contains(s.ids, id) }
+
+	s.ops = []stressOp{
+		{"AddRule", func(s *stressRun, r *rand.Rand) { ge.AddRule(s.id(r), s.expr(r)) }},
+		{"AddRuleWithUndefined", func(s *stressRun, r *rand.Rand) { ge.AddRuleWithUndefined(s.id(r), s.expr(r), r.Intn(2) == 0) }},
+		{"AddRules", func(s *stressRun, r *rand.Rand) {
+			ge.AddRules(map[string]string{s.id(r): s.expr(r), s.id(r): s.expr(r), s.id(r): "is_vip && ("})
+		}},
+		{"RemoveRule", func(s *stressRun, r *rand.Rand) { ge.RemoveRule(s.id(r)) }},
+		{"Match", func(s *stressRun, r *rand.Rand) { s.hits("Match", ge.Match(s.input(r))) }},
+		{"MatchContext", func(s *stressRun, r *rand.Rand) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if r.Intn(2) == 0 {
+				cancel()
+			}
+			hits, _ := ge.MatchContext(ctx, s.input(r))
+			cancel()
+			s.hits("MatchContext", hits)
+		}},
+		{"MatchWithErrors", func(s *stressRun, r *rand.Rand) {
+			hits, _ := ge.MatchWithErrors(s.input(r))
+			s.hits("MatchWithErrors", hits)
+		}},
+		{"ListRules", func(s *stressRun, r *rand.Rand) { ge.ListRules() }},
+		{"RuleCount", func(s *stressRun, r *rand.Rand) { ge.RuleCount() }},
+		{"GetRuleFactors", func(s *stressRun, r *rand.Rand) { ge.GetRuleFactors(s.id(r)) }},
+		{"FactorUsage", func(s *stressRun, r *rand.Rand) { ge.FactorUsage() }},
+		{"CompileCacheStats", func(s *stressRun, r *rand.Rand) { ge.CompileCacheStats() }},
+	}
+	s.invariants = []stressInvariant{
+		{"MatchWithErrors 与 Match 命中相同", func(s *stressRun, r *rand.Rand) error {
+			in := s.input(r)
+			hits, _ := ge.MatchWithErrors(in)
+			if want := ge.Match(in); !slices.Equal(hits, want) {
+				return fmt.Errorf("MatchWithErrors 命中 %v，Match 命中 %v", hits, want)
+			}
+			return nil
+		}},
+	}
+}